@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/eventstore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/spf13/cobra"
+)
+
+// tailPollInterval is the interval between two queries of events tail.
+const tailPollInterval = time.Second
+
+var (
+	eventsDB        string
+	eventsSince     string
+	eventsUntil     string
+	eventsSourceIP  string
+	eventsProtocol  string
+	eventsSessionID string
+	eventsStatus    string
+	eventsOutput    string
+	eventsLimit     int
+	eventsTailLines int
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Query the events of the sqlite sink",
+	Long:  "Query the events stored in the local database of the sqlite sink, filtered by time, source IP, protocol, session or status.",
+}
+
+var eventsQueryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Print the latest matching events",
+	Args:  cobra.NoArgs,
+	RunE:  queryEvents,
+}
+
+var eventsTailCmd = &cobra.Command{
+	Use:   "tail",
+	Short: "Print the latest matching events, then the new ones as they are stored",
+	Args:  cobra.NoArgs,
+	RunE:  tailEvents,
+}
+
+func init() {
+	flags := eventsCmd.PersistentFlags()
+	flags.StringVar(&eventsDB, "db", "", "Events database (default: the path of the sqlite sink of the core configuration)")
+	flags.StringVar(&eventsSince, "since", "", "Only events after this time: a duration ago, e.g. 1h, or an RFC 3339 date")
+	flags.StringVar(&eventsUntil, "until", "", "Only events before this time: a duration ago, e.g. 30m, or an RFC 3339 date")
+	flags.StringVar(&eventsSourceIP, "source-ip", "", "Only events of this source IP")
+	flags.StringVar(&eventsProtocol, "protocol", "", "Only events of this protocol, e.g. ssh")
+	flags.StringVar(&eventsSessionID, "session", "", "Only events of this session ID")
+	flags.StringVar(&eventsStatus, "status", "", "Only events of this status: Start, End, Stateless, Interaction, PortForward or Download")
+	flags.StringVarP(&eventsOutput, "output", "o", "table", "Output format: table or json (one event per line)")
+	eventsQueryCmd.Flags().IntVarP(&eventsLimit, "limit", "n", eventstore.DefaultLimit, "Maximum number of events")
+	eventsTailCmd.Flags().IntVarP(&eventsTailLines, "lines", "n", 10, "Number of past events printed before following")
+
+	eventsCmd.AddCommand(eventsQueryCmd)
+	eventsCmd.AddCommand(eventsTailCmd)
+}
+
+func queryEvents(cmd *cobra.Command, _ []string) error {
+	store, query, err := openEvents(eventsLimit)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.Query(commandContext(cmd), query)
+	if err != nil {
+		return err
+	}
+	return printEvents(cmd.OutOrStdout(), records, true)
+}
+
+func tailEvents(cmd *cobra.Command, _ []string) error {
+	store, query, err := openEvents(eventsTailLines)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(commandContext(cmd), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var records []eventstore.Record
+	if eventsTailLines > 0 {
+		if records, err = store.Query(ctx, query); err != nil {
+			return err
+		}
+	}
+	if err := printEvents(cmd.OutOrStdout(), records, true); err != nil {
+		return err
+	}
+
+	// The following events are the ones inserted after the last printed, or after the latest stored when none matched.
+	if len(records) == 0 {
+		if records, err = store.Query(ctx, eventstore.Query{Limit: 1}); err != nil {
+			return err
+		}
+	}
+	query.AfterID = lastID(records, 0)
+	query.Limit = eventstore.DefaultLimit
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		records, err := store.Query(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := printEvents(cmd.OutOrStdout(), records, false); err != nil {
+			return err
+		}
+		query.AfterID = lastID(records, query.AfterID)
+	}
+}
+
+func lastID(records []eventstore.Record, fallback int64) int64 {
+	if len(records) == 0 {
+		return fallback
+	}
+	return records[len(records)-1].ID
+}
+
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// openEvents opens the database read only and builds the query of the flags.
+func openEvents(limit int) (*eventstore.Store, eventstore.Query, error) {
+	if eventsOutput != "table" && eventsOutput != "json" {
+		return nil, eventstore.Query{}, fmt.Errorf("invalid output %q: expected table or json", eventsOutput)
+	}
+	now := time.Now()
+	since, err := parseEventsTime(eventsSince, now)
+	if err != nil {
+		return nil, eventstore.Query{}, fmt.Errorf("invalid since: %w", err)
+	}
+	until, err := parseEventsTime(eventsUntil, now)
+	if err != nil {
+		return nil, eventstore.Query{}, fmt.Errorf("invalid until: %w", err)
+	}
+
+	path, err := eventsDatabase()
+	if err != nil {
+		return nil, eventstore.Query{}, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, eventstore.Query{}, fmt.Errorf("opening events database: %w", err)
+	}
+	store, err := eventstore.Open(path, eventstore.Options{ReadOnly: true})
+	if err != nil {
+		return nil, eventstore.Query{}, err
+	}
+	return store, eventstore.Query{
+		Since:     since,
+		Until:     until,
+		SourceIP:  eventsSourceIP,
+		Protocol:  eventsProtocol,
+		SessionID: eventsSessionID,
+		Status:    eventsStatus,
+		Limit:     limit,
+	}, nil
+}
+
+// eventsDatabase returns --db, or the path of the sqlite sink of the core configuration.
+func eventsDatabase() (string, error) {
+	if eventsDB != "" {
+		return eventsDB, nil
+	}
+	coreConf, err := parser.Init(rootConfCore, rootConfServices).ReadConfigurationsCore()
+	if err != nil {
+		return "", fmt.Errorf("reading core config: %w", err)
+	}
+	for _, sink := range coreConf.Core.Tracings.Sinks {
+		if sink.Type == "sqlite" && sink.SQLite.Path != "" {
+			return sink.SQLite.Path, nil
+		}
+	}
+	return "", errors.New("no sqlite sink configured, set --db")
+}
+
+// parseEventsTime parses a duration before now, e.g. 1h, or an RFC 3339 date; empty means no bound.
+func parseEventsTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func printEvents(out io.Writer, records []eventstore.Record, header bool) error {
+	if eventsOutput == "json" {
+		encoder := json.NewEncoder(out)
+		for _, record := range records {
+			if err := encoder.Encode(record.Event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	const format = "%-20s %-8s %-12s %-16s %-36s %s\n"
+	if header {
+		fmt.Fprintf(out, format, "TIME", "PROTOCOL", "STATUS", "SOURCE", "SESSION", "DETAILS")
+	}
+	for _, record := range records {
+		event := record.Event
+		fmt.Fprintf(out, format, event.DateTime, event.Protocol, event.Status, event.SourceIp, event.ID, eventDetails(event))
+	}
+	return nil
+}
+
+// eventDetails summarizes the event on a single line: the command, the request, or the message.
+func eventDetails(event tracer.Event) string {
+	var details string
+	switch {
+	case event.Command != "":
+		details = event.Command
+	case event.RequestURI != "":
+		details = strings.TrimSpace(event.HTTPMethod + " " + event.RequestURI)
+	case event.User != "":
+		details = fmt.Sprintf("%s user=%s password=%s", event.Msg, event.User, event.Password)
+	default:
+		details = event.Msg
+	}
+	return strings.Join(strings.Fields(details), " ")
+}
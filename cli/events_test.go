@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/eventstore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+func TestQueryEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := eventstore.Open(path, eventstore.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	err = store.Insert(context.Background(),
+		tracer.Event{ID: "s1", Protocol: "SSH", Status: "Interaction", SourceIp: "192.0.2.1", DateTime: "2024-05-01T10:00:00Z", Command: "uname -a"},
+		tracer.Event{ID: "h1", Protocol: "HTTP", Status: "Stateless", SourceIp: "198.51.100.7", DateTime: "2024-05-01T10:00:05Z", HTTPMethod: "GET", RequestURI: "/.env"},
+	)
+	store.Close()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	eventsDB = path
+	eventsLimit = eventstore.DefaultLimit
+	defer func() {
+		eventsDB, eventsProtocol, eventsOutput = "", "", "table"
+	}()
+
+	var out bytes.Buffer
+	eventsQueryCmd.SetOut(&out)
+	defer eventsQueryCmd.SetOut(nil)
+
+	eventsOutput = "table"
+	if err := queryEvents(eventsQueryCmd, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "TIME") || !strings.HasSuffix(lines[1], "uname -a") || !strings.HasSuffix(lines[2], "GET /.env") {
+		t.Errorf("unexpected table output: %q", out.String())
+	}
+
+	out.Reset()
+	eventsOutput = "json"
+	eventsProtocol = "http"
+	if err := queryEvents(eventsQueryCmd, nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"RequestURI":"/.env"`) {
+		t.Errorf("unexpected json output: %q", out.String())
+	}
+
+	eventsOutput = "yaml"
+	if err := queryEvents(eventsQueryCmd, nil); err == nil {
+		t.Error("expected error for an unknown output")
+	}
+}
+
+func TestQueryEvents_NoDatabase(t *testing.T) {
+	eventsDB = filepath.Join(t.TempDir(), "missing.db")
+	defer func() { eventsDB = "" }()
+
+	if err := queryEvents(eventsQueryCmd, nil); err == nil {
+		t.Error("expected error for a missing database")
+	}
+
+	eventsDB = ""
+	rootConfCore = "../configurations/beelzebub.yaml"
+	err := queryEvents(eventsQueryCmd, nil)
+	if err == nil || !strings.Contains(err.Error(), "no sqlite sink configured") {
+		t.Errorf("expected no sqlite sink error, got: %v", err)
+	}
+}
+
+func TestParseEventsTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, err := parseEventsTime("90m", now); err != nil || !got.Equal(now.Add(-90*time.Minute)) {
+		t.Errorf("parseEventsTime(90m) = %v, %v", got, err)
+	}
+	if got, err := parseEventsTime("2024-05-01T10:00:00Z", now); err != nil || !got.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseEventsTime(date) = %v, %v", got, err)
+	}
+	if got, err := parseEventsTime("", now); err != nil || !got.IsZero() {
+		t.Errorf("parseEventsTime(empty) = %v, %v", got, err)
+	}
+	if _, err := parseEventsTime("yesterday", now); err == nil {
+		t.Error("expected error for an invalid time")
+	}
+}
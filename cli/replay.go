@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/recording"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayDir       string
+	replaySpeed     float64
+	replayIdleLimit float64
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session-id>",
+	Short: "Replay a recorded interactive session",
+	Long:  "Play back in the terminal the asciicast recording of an SSH or TELNET session, at real or accelerated speed.",
+	Args:  cobra.ExactArgs(1),
+	RunE:  replaySession,
+}
+
+func init() {
+	replayCmd.Flags().StringVarP(&replayDir, "dir", "d", "", "Recordings directory (default: the recording paths of the configured services)")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Playback speed multiplier")
+	replayCmd.Flags().Float64Var(&replayIdleLimit, "idle-limit", 0, "Cap the pauses between frames to this many seconds (0 to disable)")
+}
+
+func replaySession(cmd *cobra.Command, args []string) error {
+	if replaySpeed <= 0 {
+		return fmt.Errorf("invalid speed %v: must be greater than zero", replaySpeed)
+	}
+
+	recordingPath, err := findRecording(args[0])
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(recordingPath)
+	if err != nil {
+		return fmt.Errorf("opening recording: %w", err)
+	}
+	defer file.Close()
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return recording.Play(ctx, file, cmd.OutOrStdout(), recording.PlayOptions{
+		Speed:     replaySpeed,
+		IdleLimit: time.Duration(replayIdleLimit * float64(time.Second)),
+	})
+}
+
+// findRecording looks for the recording of the session in --dir or in the recording paths of the configured services.
+func findRecording(sessionID string) (string, error) {
+	for _, dir := range recordingDirectories() {
+		recordingPath, err := recording.Path(dir, sessionID)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(recordingPath); err == nil {
+			return recordingPath, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("reading recording: %w", err)
+		}
+	}
+	return "", fmt.Errorf("recording of session %q not found", sessionID)
+}
+
+func recordingDirectories() []string {
+	if replayDir != "" {
+		return []string{replayDir}
+	}
+
+	var dirs []string
+	seen := map[string]bool{}
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	services, err := parser.Init(rootConfCore, rootConfServices).ReadConfigurationsServices()
+	if err != nil {
+		log.Debugf("reading services config: %s", err.Error())
+	}
+	for _, svc := range services {
+		if svc.Recording.Enabled {
+			add(recording.Directory(svc.Recording.Path))
+		}
+	}
+	add(recording.DefaultDirectory)
+	return dirs
+}
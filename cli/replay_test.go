@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRecording = `{"version":2,"width":80,"height":24,"timestamp":1760669641}
+[0.1,"o","root@ubuntu:~$ "]
+[0.3,"i","id\r"]
+[0.4,"o","id\r\n"]
+[0.5,"o","uid=0(root) gid=0(root) groups=0(root)\r\n"]
+`
+
+func TestReplaySession_ServiceRecordingPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	recordingsDir := filepath.Join(tmpDir, "recordings")
+	os.MkdirAll(recordingsDir, 0700)
+	os.WriteFile(filepath.Join(recordingsDir, "8c1f.cast"), []byte(testRecording), 0600)
+
+	servicesDir := filepath.Join(tmpDir, "services")
+	os.MkdirAll(servicesDir, 0700)
+	yamlContent := `
+apiVersion: v1
+protocol: ssh
+address: ":2222"
+recording:
+  enabled: true
+  path: "` + recordingsDir + `"
+`
+	os.WriteFile(filepath.Join(servicesDir, "ssh.yaml"), []byte(yamlContent), 0644)
+
+	rootConfCore = "../configurations/beelzebub.yaml"
+	rootConfServices = servicesDir
+	replayDir = ""
+	replaySpeed = 100
+	replayIdleLimit = 0
+
+	var out bytes.Buffer
+	replayCmd.SetOut(&out)
+	defer replayCmd.SetOut(nil)
+
+	if err := replaySession(replayCmd, []string{"8c1f"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got, want := out.String(), "root@ubuntu:~$ id\r\nuid=0(root) gid=0(root) groups=0(root)\r\n"; got != want {
+		t.Errorf("replay output = %q, want %q", got, want)
+	}
+}
+
+func TestReplaySession_Dir(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "8c1f.cast"), []byte(testRecording), 0600)
+
+	replayDir = tmpDir
+	replaySpeed = 100
+	defer func() { replayDir = "" }()
+
+	var out bytes.Buffer
+	replayCmd.SetOut(&out)
+	defer replayCmd.SetOut(nil)
+
+	if err := replaySession(replayCmd, []string{"8c1f"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "uid=0(root)") {
+		t.Errorf("expected replay output to contain the command output, got: %q", out.String())
+	}
+
+	err := replaySession(replayCmd, []string{"missing"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found error, got: %v", err)
+	}
+
+	if err := replaySession(replayCmd, []string{"../8c1f"}); err == nil {
+		t.Error("expected error for a session ID with a path")
+	}
+
+	replaySpeed = 0
+	if err := replaySession(replayCmd, []string{"8c1f"}); err == nil {
+		t.Error("expected error for zero speed")
+	}
+}
@@ -49,4 +49,6 @@ func init() {
 	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(pluginCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(eventsCmd)
 }
@@ -1,15 +1,18 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"runtime/debug"
 	"syscall"
+	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/builder"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -58,14 +61,58 @@ func runBeelzebub(cmd *cobra.Command, _ []string) error {
 	}
 
 	if err = beelzebubBuilder.Run(); err != nil {
+		beelzebubBuilder.Close()
 		return fmt.Errorf("starting services: %w", err)
 	}
-	defer beelzebubBuilder.Close()
+
+	reloadServices := func() {
+		servicesConfiguration, err := p.ReadConfigurationsServices()
+		if err != nil {
+			log.Errorf("Error reading services config, keeping the running services: %s", err.Error())
+			return
+		}
+		if err := beelzebubBuilder.Reload(servicesConfiguration); err != nil {
+			log.Error(err.Error())
+		}
+	}
+
+	// With beelzebub-cloud enabled the services are reloaded by the cloud polling, local configurations are ignored.
+	localReload := !coreConfigurations.Core.BeelzebubCloud.Enabled
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	changed := make(chan struct{}, 1)
+	if interval := coreConfigurations.Core.Lifecycle.WatchServicesIntervalSeconds; interval > 0 && localReload {
+		go builder.WatchDirectory(watchCtx, rootConfServices, time.Duration(interval)*time.Second, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-quit
-	fmt.Fprintf(cmd.OutOrStdout(), "\nReceived signal %s, shutting down...\n", sig)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
 
-	return nil
+	for {
+		select {
+		case <-reload:
+			if localReload {
+				log.Info("Received SIGHUP, reloading services")
+				reloadServices()
+			}
+		case <-changed:
+			log.Info("Services directory changed, reloading services")
+			reloadServices()
+		case sig := <-quit:
+			fmt.Fprintf(cmd.OutOrStdout(), "\nReceived signal %s, shutting down...\n", sig)
+			if err = beelzebubBuilder.Close(); err != nil {
+				return fmt.Errorf("shutting down: %w", err)
+			}
+			return nil
+		}
+	}
 }
@@ -37,6 +37,11 @@ func validateConfigurations(_ *cobra.Command, _ []string) error {
 	printField("Prometheus", formatOptional(coreConf.Core.Prometheus.Port+coreConf.Core.Prometheus.Path))
 	printField("RabbitMQ", formatBool(coreConf.Core.Tracings.RabbitMQ.Enabled))
 	printField("Beelzebub Cloud", formatBool(coreConf.Core.BeelzebubCloud.Enabled))
+	sinkTypes := []string{}
+	for _, sink := range coreConf.Core.Tracings.Sinks {
+		sinkTypes = append(sinkTypes, sink.Type)
+	}
+	printField("Sinks", formatOptional(strings.Join(sinkTypes, ", ")))
 
 	services, err := p.ReadConfigurationsServices()
 	if err != nil {
@@ -1,37 +1,91 @@
 package builder
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/MCP"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/TELNET"
 
+	"github.com/beelzebub-labs/beelzebub/v3/internal/eventstore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/HTTP"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/SSH"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/TCP"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/rotation"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/sinks"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/telemetry"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	amqp "github.com/rabbitmq/amqp091-go"
 	log "github.com/sirupsen/logrus"
 )
 
-const RabbitmqQueueName = "event"
+// The types of the sinks, see parser.Sink.
+const (
+	stdoutSink         = "stdout"
+	fileSink           = "file"
+	sqliteSink         = "sqlite"
+	rabbitMQSink       = "rabbitmq"
+	syslogSink         = "syslog"
+	webhookSink        = "webhook"
+	elasticsearchSink  = "elasticsearch"
+	splunkSink         = "splunk"
+	kafkaSink          = "kafka"
+	openTelemetrySink  = "opentelemetry"
+	beelzebubCloudSink = "beelzebub-cloud"
+)
+
+var sinkTypes = []string{stdoutSink, fileSink, sqliteSink, rabbitMQSink, syslogSink, webhookSink, elasticsearchSink, splunkSink, kafkaSink, openTelemetrySink, beelzebubCloudSink}
+
+// instrumentationScope is the name of the OpenTelemetry logger and tracer of the events.
+const instrumentationScope = "github.com/beelzebub-labs/beelzebub/v3"
+
+// defaultBatchSizes are the batch sizes of the sinks batching by default.
+var defaultBatchSizes = map[string]int{
+	elasticsearchSink: sinks.DefaultElasticsearchBatchSize,
+	splunkSink:        sinks.DefaultSplunkBatchSize,
+	kafkaSink:         sinks.DefaultKafkaBatchSize,
+}
+
+// DefaultShutdownGracePeriod is used when the core configuration does not set lifecycle.shutdownGracePeriodSeconds.
+const DefaultShutdownGracePeriod = 10 * time.Second
 
 type Builder struct {
 	beelzebubServicesConfiguration []parser.BeelzebubServiceConfiguration
 	beelzebubCoreConfigurations    *parser.BeelzebubCoreConfigurations
 	traceStrategy                  tracer.Strategy
-	rabbitMQChannel                *amqp.Channel
-	rabbitMQConnection             *amqp.Connection
-	logsFile                       *os.File
+	sinks                          *sinks.Dispatcher
+	logsFile                       *rotation.Writer
+	protocolManager                *protocols.ProtocolManager
+	prometheusServer               *http.Server
+	// registry holds the metrics exposed by the Prometheus endpoint, instead of the global registry.
+	registry *prometheus.Registry
+	// telemetry exports to OpenTelemetry, nil when disabled.
+	telemetry *telemetry.Providers
+	// beelzebubCloud polls the honeypots configurations of beelzebub cloud, nil when disabled.
+	beelzebubCloud io.Closer
+	// services are the running services indexed by the hash code of their configuration.
+	services      map[string]runningService
+	servicesMutex sync.Mutex
+	// stopped is set by Shutdown, under servicesMutex, so that a late reload does not start services again.
+	stopped      bool
+	shutdownOnce sync.Once
+	shutdownErr  error
 }
 
 func (b *Builder) setTraceStrategy(traceStrategy tracer.Strategy) {
@@ -42,7 +96,7 @@ func (b *Builder) buildLogger(configurations parser.Logging) error {
 	output := io.Writer(os.Stdout)
 
 	if configurations.LogsPath != "" {
-		logsFile, err := os.OpenFile(configurations.LogsPath, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0666)
+		logsFile, err := rotation.Open(configurations.LogsPath, rotationOptions(configurations.LogsRotation))
 		if err != nil {
 			return err
 		}
@@ -64,44 +118,429 @@ func (b *Builder) buildLogger(configurations parser.Logging) error {
 	return nil
 }
 
-func (b *Builder) buildRabbitMQ(rabbitMQURI string) error {
-	rabbitMQConnection, err := amqp.Dial(rabbitMQURI)
+// buildOpenTelemetry creates the OTLP exporters of the opentelemetry sink and of the metrics, when enabled.
+func (b *Builder) buildOpenTelemetry(configurations parser.OpenTelemetry) error {
+	if !configurations.Enabled {
+		return nil
+	}
+	options := telemetry.Options{
+		Protocol:        configurations.Protocol,
+		Endpoint:        configurations.Endpoint,
+		Insecure:        configurations.Insecure,
+		Headers:         configurations.Headers,
+		ServiceName:     configurations.ServiceName,
+		MetricsInterval: time.Duration(configurations.MetricsIntervalSeconds) * time.Second,
+	}
+	if !configurations.Insecure {
+		var err error
+		if options.TLS, err = sinks.TLSConfig(tlsOptions(configurations.TLS)); err != nil {
+			return err
+		}
+	}
+	providers, err := telemetry.Setup(context.Background(), options)
 	if err != nil {
 		return err
 	}
+	b.telemetry = providers
+	return nil
+}
+
+// buildSinks creates the tracing backends, when none is configured they are derived from the legacy settings:
+// stdout, plus rabbit-mq, beelzebub-cloud and opentelemetry when enabled.
+func (b *Builder) buildSinks(beelzebubCoreConfigurations *parser.BeelzebubCoreConfigurations) error {
+	sinksConfigurations := beelzebubCoreConfigurations.Core.Tracings.Sinks
+	if len(sinksConfigurations) == 0 {
+		sinksConfigurations = append(sinksConfigurations, parser.Sink{Type: stdoutSink})
+		if beelzebubCoreConfigurations.Core.Tracings.RabbitMQ.Enabled {
+			sinksConfigurations = append(sinksConfigurations, parser.Sink{Type: rabbitMQSink, RabbitMQ: beelzebubCoreConfigurations.Core.Tracings.RabbitMQ})
+		}
+		if beelzebubCoreConfigurations.Core.BeelzebubCloud.Enabled {
+			sinksConfigurations = append(sinksConfigurations, parser.Sink{Type: beelzebubCloudSink})
+		}
+		if beelzebubCoreConfigurations.Core.OpenTelemetry.Enabled {
+			sinksConfigurations = append(sinksConfigurations, parser.Sink{Type: openTelemetrySink})
+		}
+	}
+
+	var configs []sinks.Config
+	names := make(map[string]bool)
+	for _, sinkConfiguration := range sinksConfigurations {
+		name := sinkConfiguration.Name
+		if name == "" {
+			name = sinkConfiguration.Type
+		}
+		if names[name] {
+			closeSinks(configs)
+			return fmt.Errorf("sink %s is configured twice, set a unique name", name)
+		}
+		names[name] = true
+
+		sink, err := newSink(sinkConfiguration, beelzebubCoreConfigurations, b.telemetry)
+		if err != nil {
+			closeSinks(configs)
+			return fmt.Errorf("error during init sink %s: %w", name, err)
+		}
+		if _, ok := sink.(sinks.BatchSink); !ok && sinkConfiguration.Batch.Size > 1 {
+			sink.Close()
+			closeSinks(configs)
+			return fmt.Errorf("the %s sink %s does not support batching", sinkConfiguration.Type, name)
+		}
+
+		spoolConfiguration := sinkConfiguration.Spool
+		if spoolConfiguration.Dir == "" && sinkConfiguration.Type != stdoutSink {
+			spoolConfiguration = beelzebubCoreConfigurations.Core.Tracings.Spool
+		}
+		var spool *sinks.Spool
+		if spoolConfiguration.Dir != "" {
+			if spool, err = buildSpool(spoolConfiguration, name); err != nil {
+				sink.Close()
+				closeSinks(configs)
+				return fmt.Errorf("error during init spool of sink %s: %w", name, err)
+			}
+		}
+
+		batchSize := sinkConfiguration.Batch.Size
+		if batchSize == 0 {
+			batchSize = defaultBatchSizes[sinkConfiguration.Type]
+		}
+
+		configs = append(configs, sinks.Config{
+			Name: name,
+			Sink: sink,
+			Filter: sinks.Filter{
+				Protocols: sinkConfiguration.Filter.Protocols,
+				Statuses:  sinkConfiguration.Filter.Statuses,
+				Services:  sinkConfiguration.Filter.Services,
+			},
+			Retry: sinks.Retry{
+				MaxAttempts:    sinkConfiguration.Retry.MaxAttempts,
+				InitialBackoff: time.Duration(sinkConfiguration.Retry.InitialBackoffMilliseconds) * time.Millisecond,
+				MaxBackoff:     time.Duration(sinkConfiguration.Retry.MaxBackoffMilliseconds) * time.Millisecond,
+			},
+			Batch: sinks.Batch{
+				Size:     batchSize,
+				Interval: time.Duration(sinkConfiguration.Batch.IntervalMilliseconds) * time.Millisecond,
+			},
+			RateLimit: sinks.RateLimit{
+				PerSecond: sinkConfiguration.RateLimit.RequestsPerSecond,
+				Burst:     sinkConfiguration.RateLimit.Burst,
+			},
+			QueueSize: sinkConfiguration.QueueSize,
+			Spool:     spool,
+		})
+	}
+
+	b.sinks = sinks.NewDispatcher(configs)
+	return nil
+}
 
-	b.rabbitMQChannel, err = rabbitMQConnection.Channel()
+func newSink(sinkConfiguration parser.Sink, beelzebubCoreConfigurations *parser.BeelzebubCoreConfigurations, providers *telemetry.Providers) (sinks.Sink, error) {
+	switch sinkConfiguration.Type {
+	case stdoutSink:
+		return sinks.Stdout{}, nil
+	case fileSink:
+		if sinkConfiguration.File.Path == "" {
+			return nil, errors.New("the file sink requires a path")
+		}
+		return sinks.NewFile(sinkConfiguration.File.Path, rotationOptions(sinkConfiguration.File.Rotation))
+	case sqliteSink:
+		return sinks.NewSQLite(sinkConfiguration.SQLite.Path, eventstore.Options{
+			MaxAge:    time.Duration(sinkConfiguration.SQLite.RetentionHours) * time.Hour,
+			MaxEvents: sinkConfiguration.SQLite.MaxEvents,
+		})
+	case rabbitMQSink:
+		rabbitMQ := sinkConfiguration.RabbitMQ
+		tlsConfig, err := sinks.TLSConfig(tlsOptions(rabbitMQ.TLS))
+		if err != nil {
+			return nil, err
+		}
+		return sinks.NewRabbitMQ(sinks.RabbitMQOptions{
+			URI:               rabbitMQ.URI,
+			Exchange:          rabbitMQ.Exchange,
+			ExchangeType:      rabbitMQ.ExchangeType,
+			RoutingKey:        rabbitMQ.RoutingKey,
+			Queue:             rabbitMQ.Queue,
+			Durable:           rabbitMQ.Durable,
+			PublisherConfirms: rabbitMQ.PublisherConfirms,
+			TLS:               tlsConfig,
+		})
+	case syslogSink:
+		syslog := sinkConfiguration.Syslog
+		var tlsConfig *tls.Config
+		if syslog.Network == "tls" {
+			var err error
+			if tlsConfig, err = sinks.TLSConfig(tlsOptions(syslog.TLS)); err != nil {
+				return nil, err
+			}
+		}
+		return sinks.NewSyslog(sinks.SyslogOptions{
+			Network:  syslog.Network,
+			Address:  syslog.Address,
+			Format:   syslog.Format,
+			Facility: syslog.Facility,
+			Hostname: syslog.Hostname,
+			AppName:  syslog.AppName,
+			TLS:      tlsConfig,
+		})
+	case webhookSink:
+		webhook := sinkConfiguration.Webhook
+		tlsConfig, err := sinks.TLSConfig(tlsOptions(webhook.TLS))
+		if err != nil {
+			return nil, err
+		}
+		return sinks.NewWebhook(sinks.WebhookOptions{
+			URL:       webhook.URL,
+			Method:    webhook.Method,
+			Headers:   webhook.Headers,
+			Body:      webhook.Body,
+			BatchBody: webhook.BatchBody,
+			Secret:    webhook.Secret,
+			Timeout:   time.Duration(webhook.TimeoutSeconds) * time.Second,
+			TLS:       tlsConfig,
+		})
+	case elasticsearchSink:
+		elasticsearch := sinkConfiguration.Elasticsearch
+		tlsConfig, err := sinks.TLSConfig(tlsOptions(elasticsearch.TLS))
+		if err != nil {
+			return nil, err
+		}
+		return sinks.NewElasticsearch(sinks.ElasticsearchOptions{
+			URL:             elasticsearch.URL,
+			Index:           elasticsearch.Index,
+			Username:        elasticsearch.Username,
+			Password:        elasticsearch.Password,
+			APIKey:          elasticsearch.APIKey,
+			OpenSearch:      elasticsearch.OpenSearch,
+			TemplateName:    elasticsearch.TemplateName,
+			IndexPattern:    elasticsearch.IndexPattern,
+			DisableTemplate: elasticsearch.DisableTemplate,
+			Timeout:         time.Duration(elasticsearch.TimeoutSeconds) * time.Second,
+			TLS:             tlsConfig,
+		})
+	case splunkSink:
+		splunk := sinkConfiguration.Splunk
+		tlsConfig, err := sinks.TLSConfig(tlsOptions(splunk.TLS))
+		if err != nil {
+			return nil, err
+		}
+		services := make(map[string]sinks.SplunkMetadata)
+		for _, service := range splunk.Services {
+			services[service.Service] = sinks.SplunkMetadata{
+				Host:       service.Host,
+				Source:     service.Source,
+				Sourcetype: service.Sourcetype,
+				Index:      service.Index,
+			}
+		}
+		return sinks.NewSplunk(sinks.SplunkOptions{
+			URL:   splunk.URL,
+			Token: splunk.Token,
+			SplunkMetadata: sinks.SplunkMetadata{
+				Host:       splunk.Host,
+				Source:     splunk.Source,
+				Sourcetype: splunk.Sourcetype,
+				Index:      splunk.Index,
+			},
+			Services:   services,
+			IndexerAck: splunk.IndexerAck,
+			AckTimeout: time.Duration(splunk.AckTimeoutSeconds) * time.Second,
+			Timeout:    time.Duration(splunk.TimeoutSeconds) * time.Second,
+			TLS:        tlsConfig,
+		})
+	case kafkaSink:
+		kafka := sinkConfiguration.Kafka
+		options := sinks.KafkaOptions{
+			Brokers:         kafka.Brokers,
+			Topic:           kafka.Topic,
+			ClientID:        kafka.ClientID,
+			Compression:     kafka.Compression,
+			Idempotent:      kafka.Idempotent,
+			DeliveryTimeout: time.Duration(kafka.DeliveryTimeoutSeconds) * time.Second,
+		}
+		if kafka.SASL.Mechanism != "" {
+			options.SASL = &sinks.KafkaSASL{
+				Mechanism: kafka.SASL.Mechanism,
+				Username:  kafka.SASL.Username,
+				Password:  kafka.SASL.Password,
+			}
+		}
+		if kafka.EnableTLS {
+			var err error
+			if options.TLS, err = sinks.TLSConfig(tlsOptions(kafka.TLS)); err != nil {
+				return nil, err
+			}
+		}
+		return sinks.NewKafka(options)
+	case openTelemetrySink:
+		if providers == nil {
+			return nil, errors.New("the opentelemetry sink requires openTelemetry to be enabled in the core configuration")
+		}
+		return sinks.NewOpenTelemetry(sinks.OpenTelemetryOptions{
+			Logger:           providers.LoggerProvider.Logger(instrumentationScope),
+			Tracer:           providers.TracerProvider.Tracer(instrumentationScope),
+			InteractionSpans: sinkConfiguration.OpenTelemetry.InteractionSpans,
+			SessionTimeout:   time.Duration(sinkConfiguration.OpenTelemetry.SessionTimeoutMinutes) * time.Minute,
+		})
+	case beelzebubCloudSink:
+		conf := beelzebubCoreConfigurations.Core.BeelzebubCloud
+		return sinks.NewBeelzebubCloud(conf.URI, conf.AuthToken), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q, expected one of %s", sinkConfiguration.Type, strings.Join(sinkTypes, ", "))
+	}
+}
+
+func rotationOptions(rotationConfiguration parser.Rotation) rotation.Options {
+	return rotation.Options{
+		MaxSize:    int64(rotationConfiguration.MaxSizeMegabytes) << 20,
+		Interval:   time.Duration(rotationConfiguration.IntervalHours) * time.Hour,
+		Compress:   rotationConfiguration.Compress,
+		MaxBackups: rotationConfiguration.MaxBackups,
+	}
+}
+
+func tlsOptions(tlsConfiguration parser.SinkTLS) sinks.TLSOptions {
+	return sinks.TLSOptions{
+		CACertPath:         tlsConfiguration.CACertPath,
+		CertPath:           tlsConfiguration.CertPath,
+		KeyPath:            tlsConfiguration.KeyPath,
+		ServerName:         tlsConfiguration.ServerName,
+		InsecureSkipVerify: tlsConfiguration.InsecureSkipVerify,
+	}
+}
+
+// buildSpool opens the spool of the sink, in a subdirectory named after it so that the sinks can share a directory.
+func buildSpool(spoolConfiguration parser.SinkSpool, sinkName string) (*sinks.Spool, error) {
+	if filepath.Base(sinkName) != sinkName || sinkName == "." || sinkName == ".." {
+		return nil, fmt.Errorf("invalid sink name %q for a spool directory", sinkName)
+	}
+	return sinks.OpenSpool(filepath.Join(spoolConfiguration.Dir, sinkName), sinks.SpoolOptions{
+		SegmentSize: int64(spoolConfiguration.SegmentSizeMegabytes) << 20,
+		MaxSize:     int64(spoolConfiguration.MaxSizeMegabytes) << 20,
+	})
+}
+
+func closeSinks(configs []sinks.Config) {
+	for _, config := range configs {
+		config.Sink.Close()
+		if config.Spool != nil {
+			config.Spool.Close()
+		}
+	}
+}
+
+// buildTracerQueue configures the events queue of the tracer, so that a slow tracing backend never slows down the honeypots.
+func (b *Builder) buildTracerQueue(configurations parser.Queue) error {
+	overflowPolicy, err := tracer.ParseOverflowPolicy(configurations.OverflowPolicy)
 	if err != nil {
 		return err
 	}
+	return tracer.GetInstance(b.traceStrategy).Configure(tracer.QueueOptions{
+		Size:           configurations.Size,
+		Workers:        configurations.Workers,
+		OverflowPolicy: overflowPolicy,
+		SpillDir:       configurations.SpillDir,
+		MaxSpillBytes:  configurations.MaxSpillBytes,
+	})
+}
 
-	//creates a queue if it doesn't already exist, or ensures that an existing queue matches the same parameters.
-	if _, err = b.rabbitMQChannel.QueueDeclare(RabbitmqQueueName, false, false, false, false, nil); err != nil {
+// buildMetrics registers the metrics of the runtime, the tracer, the sinks and the services on a dedicated registry,
+// the protocol manager must be initialized.
+func (b *Builder) buildMetrics() error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collectors.NewGoCollector()); err != nil {
+		return fmt.Errorf("error during register go metrics: %w", err)
+	}
+	if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return fmt.Errorf("error during register process metrics: %w", err)
+	}
+	if err := tracer.GetInstance(b.traceStrategy).Register(registry); err != nil {
 		return err
 	}
-
-	b.rabbitMQConnection = rabbitMQConnection
+	if b.sinks != nil {
+		if err := b.sinks.Register(registry); err != nil {
+			return err
+		}
+	}
+	b.protocolManager.SetMetrics(metrics.New(registry))
+	b.registry = registry
 	return nil
 }
 
+func (b *Builder) shutdownGracePeriod() time.Duration {
+	if b.beelzebubCoreConfigurations != nil && b.beelzebubCoreConfigurations.Core.Lifecycle.ShutdownGracePeriodSeconds > 0 {
+		return time.Duration(b.beelzebubCoreConfigurations.Core.Lifecycle.ShutdownGracePeriodSeconds) * time.Second
+	}
+	return DefaultShutdownGracePeriod
+}
+
+// Close stops the services, giving the in-flight sessions the configured grace period to terminate, see Shutdown.
 func (b *Builder) Close() error {
-	// Close log file if it was opened
-	if b.logsFile != nil {
-		if err := b.logsFile.Close(); err != nil {
-			return err
+	ctx, cancel := context.WithTimeout(context.Background(), b.shutdownGracePeriod())
+	defer cancel()
+	return b.Shutdown(ctx)
+}
+
+// Shutdown stops the services and drains their sessions, flushes the tracer queue and releases the resources.
+// When ctx expires, the remaining sessions are forcibly closed and the events still queued are lost.
+// Shutdown is idempotent, the following calls return the error of the first one.
+func (b *Builder) Shutdown(ctx context.Context) error {
+	b.shutdownOnce.Do(func() {
+		b.shutdownErr = b.shutdown(ctx)
+	})
+	return b.shutdownErr
+}
+
+func (b *Builder) shutdown(ctx context.Context) error {
+	if b.beelzebubCloud != nil {
+		b.beelzebubCloud.Close()
+	}
+
+	b.servicesMutex.Lock()
+	b.stopped = true
+	hashCodes := make([]string, 0, len(b.services))
+	for hashCode := range b.services {
+		hashCodes = append(hashCodes, hashCode)
+	}
+	b.stopServices(ctx, hashCodes)
+	b.servicesMutex.Unlock()
+
+	if b.prometheusServer != nil {
+		if err := b.prometheusServer.Shutdown(ctx); err != nil {
+			b.prometheusServer.Close()
 		}
 	}
 
-	// Close RabbitMQ connections
-	if b.rabbitMQConnection != nil {
-		if err := b.rabbitMQChannel.Close(); err != nil {
-			return err
+	if b.protocolManager != nil {
+		if err := tracer.GetInstance(b.traceStrategy).Flush(ctx); err != nil {
+			log.Warnf("Error during flush events: %s", err.Error())
 		}
-		if err := b.rabbitMQConnection.Close(); err != nil {
-			return err
+	}
+
+	// Every step runs even when a previous one failed, so that the telemetry is flushed and the log file closed.
+	var errs []error
+
+	// Deliver the events queued by the sinks, then close their connections
+	if b.sinks != nil {
+		if err := b.sinks.Close(ctx); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return nil
+
+	// Export the pending log records, spans and metrics
+	if b.telemetry != nil {
+		if err := b.telemetry.Shutdown(ctx); err != nil {
+			log.Warnf("Error during shutdown opentelemetry: %s", err.Error())
+		}
+	}
+
+	// Close log file if it was opened, the following log lines go to the standard output only
+	if b.logsFile != nil {
+		log.SetOutput(os.Stdout)
+		if err := b.logsFile.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (b *Builder) Run() error {
@@ -113,31 +552,39 @@ func (b *Builder) Run() error {
 ██   ██ ██      ██      ██       ███    ██      ██   ██ ██    ██ ██   ██ 
 ██████  ███████ ███████ ███████ ███████ ███████ ██████   ██████  ██████  
 Deception runtime framework, happy hacking!`)
+	// Init Tracer strategies, and set the trace strategy default HTTP
+	b.protocolManager = protocols.InitProtocolManager(b.traceStrategy, &HTTP.HTTPStrategy{})
+
+	if err := b.buildTracerQueue(b.beelzebubCoreConfigurations.Core.Tracings.Queue); err != nil {
+		return err
+	}
+
+	if err := b.buildMetrics(); err != nil {
+		return err
+	}
+
 	// Init Prometheus openmetrics
-	go func() {
-		if (b.beelzebubCoreConfigurations.Core.Prometheus != parser.Prometheus{}) {
-			http.Handle(b.beelzebubCoreConfigurations.Core.Prometheus.Path, promhttp.Handler())
+	if (b.beelzebubCoreConfigurations.Core.Prometheus != parser.Prometheus{}) {
+		serveMux := http.NewServeMux()
+		serveMux.Handle(b.beelzebubCoreConfigurations.Core.Prometheus.Path, promhttp.InstrumentMetricHandler(b.registry, promhttp.HandlerFor(b.registry, promhttp.HandlerOpts{})))
+		b.prometheusServer = &http.Server{Addr: b.beelzebubCoreConfigurations.Core.Prometheus.Port, Handler: serveMux}
 
-			if err := http.ListenAndServe(b.beelzebubCoreConfigurations.Core.Prometheus.Port, nil); err != nil {
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				log.Fatalf("Error init Prometheus: %s", err.Error())
 			}
-		}
-	}()
-
-	// Init Protocol strategies
-	secureShellStrategy := &SSH.SSHStrategy{}
-	hypertextTransferProtocolStrategy := &HTTP.HTTPStrategy{}
-	transmissionControlProtocolStrategy := &TCP.TCPStrategy{}
-	modelContextProtocolStrategy := &MCP.MCPStrategy{}
-	telnetStrategy := &TELNET.TelnetStrategy{}
-
-	// Init Tracer strategies, and set the trace strategy default HTTP
-	protocolManager := protocols.InitProtocolManager(b.traceStrategy, hypertextTransferProtocolStrategy)
+		}(b.prometheusServer)
+	}
 
 	if b.beelzebubCoreConfigurations.Core.BeelzebubCloud.Enabled {
 		conf := b.beelzebubCoreConfigurations.Core.BeelzebubCloud
 
-		beelzebubCloud := plugins.InitBeelzebubCloud(conf.URI, conf.AuthToken, true)
+		beelzebubCloud := plugins.InitBeelzebubCloud(conf.URI, conf.AuthToken, func(servicesConfiguration []parser.BeelzebubServiceConfiguration) {
+			if err := b.Reload(servicesConfiguration); err != nil {
+				log.Errorf("Error during reload beelzebub cloud configurations: %s", err.Error())
+			}
+		})
+		b.beelzebubCloud = beelzebubCloud
 
 		if honeypotsConfiguration, _, err := beelzebubCloud.GetHoneypotsConfigurations(); err != nil {
 			return err
@@ -149,34 +596,70 @@ Deception runtime framework, happy hacking!`)
 		}
 	}
 
+	b.servicesMutex.Lock()
+	defer b.servicesMutex.Unlock()
 	for _, beelzebubServiceConfiguration := range b.beelzebubServicesConfiguration {
-		switch beelzebubServiceConfiguration.Protocol {
-		case "http":
-			protocolManager.SetProtocolStrategy(hypertextTransferProtocolStrategy)
-		case "ssh":
-			protocolManager.SetProtocolStrategy(secureShellStrategy)
-		case "tcp":
-			protocolManager.SetProtocolStrategy(transmissionControlProtocolStrategy)
-		case "mcp":
-			protocolManager.SetProtocolStrategy(modelContextProtocolStrategy)
-		case "telnet":
-			protocolManager.SetProtocolStrategy(telnetStrategy)
-		default:
-			log.Fatalf("protocol %s not managed", beelzebubServiceConfiguration.Protocol)
+		if err := b.startService(beelzebubServiceConfiguration); err != nil {
+			return err
 		}
+	}
 
-		if err := protocolManager.InitService(beelzebubServiceConfiguration); err != nil {
-			return fmt.Errorf("error during init protocol: %s, %s", beelzebubServiceConfiguration.Protocol, err.Error())
-		}
+	return nil
+}
+
+// startService initializes a dedicated protocol strategy for the service, so that it can be stopped independently.
+// The caller must hold servicesMutex.
+func (b *Builder) startService(beelzebubServiceConfiguration parser.BeelzebubServiceConfiguration) error {
+	hashCode, err := beelzebubServiceConfiguration.HashCode()
+	if err != nil {
+		return err
+	}
+	if _, exists := b.services[hashCode]; exists {
+		log.Warnf("Service %s %s is configured twice, skipping", beelzebubServiceConfiguration.Protocol, beelzebubServiceConfiguration.Address)
+		return nil
 	}
 
+	strategy, err := newServiceStrategy(beelzebubServiceConfiguration.Protocol)
+	if err != nil {
+		return err
+	}
+
+	b.protocolManager.SetProtocolStrategy(strategy)
+	if err := b.protocolManager.InitService(beelzebubServiceConfiguration); err != nil {
+		return fmt.Errorf("error during init protocol: %s, %s", beelzebubServiceConfiguration.Protocol, err.Error())
+	}
+	if b.services == nil {
+		b.services = make(map[string]runningService)
+	}
+	b.services[hashCode] = runningService{configuration: beelzebubServiceConfiguration, strategy: strategy}
 	return nil
 }
 
+// newServiceStrategy returns the strategy of the protocol, or an error when the protocol is not managed.
+func newServiceStrategy(protocol string) (protocols.ServiceStrategy, error) {
+	switch protocol {
+	case "http":
+		return &HTTP.HTTPStrategy{}, nil
+	case "ssh":
+		return &SSH.SSHStrategy{}, nil
+	case "tcp":
+		return &TCP.TCPStrategy{}, nil
+	case "mcp":
+		return &MCP.MCPStrategy{}, nil
+	case "telnet":
+		return &TELNET.TelnetStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("protocol %s not managed", protocol)
+	}
+}
+
 func (b *Builder) build() *Builder {
 	return &Builder{
 		beelzebubServicesConfiguration: b.beelzebubServicesConfiguration,
 		traceStrategy:                  b.traceStrategy,
+		sinks:                          b.sinks,
+		logsFile:                       b.logsFile,
+		telemetry:                      b.telemetry,
 		beelzebubCoreConfigurations:    b.beelzebubCoreConfigurations,
 	}
 }
@@ -1,12 +1,19 @@
 package builder
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/beelzebub-labs/beelzebub/v3/internal/eventstore"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols/strategies/HTTP"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/sinks"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 	"github.com/stretchr/testify/assert"
 )
@@ -47,6 +54,34 @@ func TestBuilderClose_LogFile(t *testing.T) {
 	assert.Contains(t, err.Error(), "file already closed")
 }
 
+// failingCloseSink is a sink whose Close fails.
+type failingCloseSink struct{}
+
+func (failingCloseSink) Send(ctx context.Context, event tracer.Event) error {
+	return nil
+}
+
+func (failingCloseSink) Close() error {
+	return errors.New("mock close error")
+}
+
+func TestBuilderClose_SinkError(t *testing.T) {
+	builder := NewBuilder()
+	if err := builder.buildLogger(parser.Logging{LogsPath: filepath.Join(t.TempDir(), "test.log")}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	builder.sinks = sinks.NewDispatcher([]sinks.Config{{Name: "failing", Sink: failingCloseSink{}}})
+
+	err := builder.Close()
+	if err == nil || !strings.Contains(err.Error(), "mock close error") {
+		t.Errorf("expected the sink close error, got %v", err)
+	}
+	// The following steps run despite the error of the sinks.
+	if _, err := builder.logsFile.WriteString("test"); err == nil {
+		t.Errorf("expected the log file to be closed")
+	}
+}
+
 func TestBuilderClose_NoLogFile(t *testing.T) {
 	// Create a builder without opening a log file
 	builder := NewBuilder()
@@ -141,15 +176,358 @@ func TestBuilderRun_AllProtocols(t *testing.T) {
 	time.Sleep(100 * time.Millisecond) // Wait a bit to let go funcs run and cover lines inside
 }
 
+func TestBuilderShutdown_AllProtocols(t *testing.T) {
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubCoreConfigurations.Core.Lifecycle.ShutdownGracePeriodSeconds = 1
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{
+		{Protocol: "http", Address: "127.0.0.1:0"},
+		{Protocol: "ssh", Address: "127.0.0.1:0"},
+		{Protocol: "tcp", Address: "127.0.0.1:0"},
+		{Protocol: "telnet", Address: "127.0.0.1:0"},
+		{Protocol: "mcp", Address: "127.0.0.1:0"},
+	}
+	b.traceStrategy = func(event tracer.Event) {}
+
+	assert.NoError(t, b.Run())
+	assert.Len(t, b.services, 5)
+
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, b.Close())
+	assert.Empty(t, b.services)
+}
+
 func TestBuilderRun_UnknownProtocol(t *testing.T) {
-	// We cannot easily test unknown protocol because it calls log.Fatalf
-	// which causes the test to exit.
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{{Protocol: "gopher", Address: "127.0.0.1:0"}}
+	b.traceStrategy = func(event tracer.Event) {}
+	defer b.Close()
+
+	if err := b.Run(); err == nil || !strings.Contains(err.Error(), "protocol gopher not managed") {
+		t.Errorf("expected protocol not managed error, got %v", err)
+	}
 }
 
-func TestBuildRabbitMQ_InvalidURI(t *testing.T) {
+func TestBuilderShutdown_Idempotent(t *testing.T) {
 	b := NewBuilder()
-	err := b.buildRabbitMQ("invalid-uri")
-	if err == nil {
-		t.Errorf("expected error building RabbitMQ with invalid URI")
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{{Protocol: "tcp", Address: "127.0.0.1:0"}}
+	b.traceStrategy = func(event tracer.Event) {}
+	if err := b.Run(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("expected no error on the second close, got %v", err)
+	}
+	if err := b.Reload([]parser.BeelzebubServiceConfiguration{{Protocol: "tcp", Address: "127.0.0.1:0"}}); err == nil {
+		t.Errorf("expected the reload after shutdown to be refused")
+	}
+	if len(b.services) != 0 {
+		t.Errorf("expected no running services, got %v", b.services)
+	}
+}
+
+func TestBuildSinks_Legacy(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.BeelzebubCloud.Enabled = true
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.RabbitMQ.Enabled = true
+	coreConfig.Core.Tracings.RabbitMQ.URI = "invalid-uri"
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error building RabbitMQ sink with invalid URI")
+	}
+}
+
+func TestBuildSinks_DuplicateName(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Type: "stdout"}, {Type: "stdout"}}
+
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for sinks with the same name")
+	}
+
+	coreConfig.Core.Tracings.Sinks[1].Name = "stdout-ssh"
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestBuildTracerQueue(t *testing.T) {
+	b := NewBuilder()
+	b.traceStrategy = func(event tracer.Event) {}
+
+	if err := b.buildTracerQueue(parser.Queue{OverflowPolicy: "drop-all"}); err == nil {
+		t.Errorf("expected error for unknown overflow policy")
+	}
+	if err := b.buildTracerQueue(parser.Queue{OverflowPolicy: "spill-to-disk"}); err == nil {
+		t.Errorf("expected error for spill-to-disk without spill directory")
+	}
+	if err := b.buildTracerQueue(parser.Queue{Size: 100, Workers: 2, OverflowPolicy: "drop-oldest"}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestBuildSinks_Spool(t *testing.T) {
+	spoolDir := t.TempDir()
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.BeelzebubCloud.Enabled = true
+	coreConfig.Core.Tracings.Spool.Dir = spoolDir
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	// Only the remote sinks are spooled by default.
+	if _, err := os.Stat(filepath.Join(spoolDir, "beelzebub-cloud")); err != nil {
+		t.Errorf("expected spool directory of beelzebub-cloud sink, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(spoolDir, "stdout")); !os.IsNotExist(err) {
+		t.Errorf("expected no spool directory for stdout sink")
+	}
+
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Name: "../escape", Type: "stdout", Spool: parser.SinkSpool{Dir: spoolDir}}}
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for sink name escaping the spool directory")
+	}
+}
+
+func TestBuildSinks_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Type: "sqlite", SQLite: parser.SinkSQLite{Path: path, RetentionHours: 24}}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
 	}
+	b.sinks.Dispatch(tracer.Event{ID: "mockID", Protocol: tracer.SSH.String(), Status: tracer.Start.String()})
+	if err := b.sinks.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	store, err := eventstore.Open(path, eventstore.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer store.Close()
+	records, err := store.Query(context.Background(), eventstore.Query{Protocol: "ssh"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(records) != 1 || records[0].Event.ID != "mockID" {
+		t.Errorf("expected the dispatched event to be stored, got %v", records)
+	}
+
+	coreConfig.Core.Tracings.Sinks[0].SQLite.Path = ""
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for sqlite sink without path")
+	}
+}
+
+func TestBuildSinks_Syslog(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{
+		Type:   "syslog",
+		Syslog: parser.SinkSyslog{Network: "udp", Address: "127.0.0.1:514", Format: "cef"},
+	}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].Syslog.Format = "xml"
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for unknown syslog format")
+	}
+}
+
+func TestBuildSinks_Webhook(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{
+		Type:    "webhook",
+		Webhook: parser.SinkWebhook{URL: "https://hooks.example.com/beelzebub", Body: `{"text": {{json .Command}}}`},
+		Batch:   parser.SinkBatch{Size: 10},
+	}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].Webhook.URL = ""
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for webhook sink without url")
+	}
+
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Type: "stdout", Batch: parser.SinkBatch{Size: 10}}}
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for batching on a sink without batches")
+	}
+}
+
+func TestBuildSinks_Elasticsearch(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{
+		Type:          "elasticsearch",
+		Elasticsearch: parser.SinkElasticsearch{URL: "http://localhost:9200", Index: `beelzebub-{{date "2006.01" .DateTime}}`},
+	}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].Elasticsearch.Index = "{{.Unknown"
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for invalid index template")
+	}
+}
+
+func TestBuildSinks_Splunk(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{
+		Type: "splunk",
+		Splunk: parser.SinkSplunk{
+			URL:      "https://splunk.example.com:8088",
+			Token:    "token",
+			Services: []parser.SinkSplunkService{{Service: ":22", Index: "honeypot_ssh"}},
+		},
+	}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].Splunk.Token = ""
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for splunk sink without token")
+	}
+}
+
+func TestBuildSinks_Kafka(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{
+		Type: "kafka",
+		Kafka: parser.SinkKafka{
+			Brokers:     []string{"localhost:9092"},
+			Compression: "lz4",
+			SASL:        parser.SinkKafkaSASL{Mechanism: "SCRAM-SHA-256", Username: "beelzebub", Password: "secret"},
+		},
+	}}
+
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].Kafka.SASL.Mechanism = "GSSAPI"
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error for unknown sasl mechanism")
+	}
+}
+
+func TestBuildSinks_OpenTelemetry(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Type: "opentelemetry"}}
+
+	if err := b.buildSinks(coreConfig); err == nil {
+		t.Errorf("expected error when openTelemetry is disabled")
+	}
+
+	coreConfig.Core.OpenTelemetry = parser.OpenTelemetry{Enabled: true, Protocol: "http", Endpoint: "localhost:4318", Insecure: true}
+	if err := b.buildOpenTelemetry(coreConfig.Core.OpenTelemetry); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.telemetry.Shutdown(context.Background())
+
+	coreConfig.Core.Tracings.Sinks[0].OpenTelemetry = parser.SinkOpenTelemetry{InteractionSpans: true, SessionTimeoutMinutes: 5}
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer b.sinks.Close(context.Background())
+
+	coreConfig.Core.OpenTelemetry.Protocol = "udp"
+	if err := NewBuilder().buildOpenTelemetry(coreConfig.Core.OpenTelemetry); err == nil {
+		t.Errorf("expected error for unknown protocol")
+	}
+}
+
+func TestBuildMetrics(t *testing.T) {
+	b := NewBuilder()
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	if err := b.buildSinks(coreConfig); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	b.traceStrategy = b.sinks.Dispatch
+	b.protocolManager = protocols.InitProtocolManager(b.traceStrategy, &HTTP.HTTPStrategy{})
+
+	if err := b.buildMetrics(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	b.sinks.Dispatch(tracer.Event{ID: "mockID", Protocol: tracer.HTTP.String(), Status: tracer.Stateless.String()})
+	if err := b.sinks.Close(context.Background()); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	// The metrics of the services are registered once observed.
+	b.protocolManager.SetProtocolStrategy(&tracerStrategy{})
+	if err := b.protocolManager.InitService(parser.BeelzebubServiceConfiguration{Description: "honeypot", Address: ":8080", Protocol: "http"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	families, err := b.registry.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	names := make(map[string]bool)
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{"go_goroutines", "beelzebub_events_total", "beelzebub_sink_events_total", "beelzebub_service_events_total"} {
+		if !names[name] {
+			t.Errorf("expected metric %s to be registered, got %v", name, names)
+		}
+	}
+
+	// A second builder has its own registry.
+	other := NewBuilder()
+	other.traceStrategy = b.traceStrategy
+	other.protocolManager = protocols.InitProtocolManager(other.traceStrategy, &HTTP.HTTPStrategy{})
+	if err := other.buildMetrics(); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+// tracerStrategy traces an event as soon as the service is initialized.
+type tracerStrategy struct{}
+
+func (tracerStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
+	tr.TraceEvent(tracer.Event{ID: "mockID", Protocol: tracer.HTTP.String(), Status: tracer.Stateless.String()})
+	return nil
+}
+
+func (tracerStrategy) Shutdown(ctx context.Context) error {
+	return nil
 }
@@ -2,13 +2,8 @@ package builder
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 
-	amqp "github.com/rabbitmq/amqp091-go"
-	log "github.com/sirupsen/logrus"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 )
 
 type Director struct {
@@ -28,71 +23,17 @@ func (d *Director) BuildBeelzebub(beelzebubCoreConfigurations *parser.BeelzebubC
 		return nil, err
 	}
 
-	d.builder.setTraceStrategy(d.standardOutStrategy)
-
-	if beelzebubCoreConfigurations.Core.Tracings.RabbitMQ.Enabled {
-		d.builder.setTraceStrategy(d.rabbitMQTraceStrategy)
-		err := d.builder.buildRabbitMQ(beelzebubCoreConfigurations.Core.Tracings.RabbitMQ.URI)
-		if err != nil {
-			return nil, err
-		}
+	if err := d.builder.buildOpenTelemetry(beelzebubCoreConfigurations.Core.OpenTelemetry); err != nil {
+		return nil, err
 	}
 
-	if beelzebubCoreConfigurations.Core.BeelzebubCloud.Enabled {
-		d.builder.setTraceStrategy(d.beelzebubCloudStrategy)
+	if err := d.builder.buildSinks(beelzebubCoreConfigurations); err != nil {
+		if d.builder.telemetry != nil {
+			d.builder.telemetry.Shutdown(context.Background())
+		}
+		return nil, err
 	}
+	d.builder.setTraceStrategy(d.builder.sinks.Dispatch)
 
 	return d.builder.build(), nil
 }
-
-func (d *Director) standardOutStrategy(event tracer.Event) {
-	log.WithFields(log.Fields{
-		"status": event.Status,
-		"event":  event,
-	}).Info("New Event")
-}
-
-func (d *Director) beelzebubCloudStrategy(event tracer.Event) {
-	log.WithFields(log.Fields{
-		"status": event.Status,
-		"event":  event,
-	}).Info("New Event")
-
-	conf := d.builder.beelzebubCoreConfigurations.Core.BeelzebubCloud
-
-	beelzebubCloud := plugins.InitBeelzebubCloud(conf.URI, conf.AuthToken, false)
-
-	result, err := beelzebubCloud.SendEvent(event)
-	if err != nil {
-		log.Error(err.Error())
-	} else {
-		log.WithFields(log.Fields{
-			"status": result,
-			"event":  event,
-		}).Debug("Event published on beelzebub cloud")
-	}
-}
-
-func (d *Director) rabbitMQTraceStrategy(event tracer.Event) {
-	log.WithFields(log.Fields{
-		"status": event.Status,
-		"event":  event,
-	}).Info("New Event")
-
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
-		log.Error(err.Error())
-		return
-	}
-
-	publishing := amqp.Publishing{ContentType: "application/json", Body: eventJSON}
-
-	if err = d.builder.rabbitMQChannel.PublishWithContext(context.TODO(), "", RabbitmqQueueName, false, false, publishing); err != nil {
-		log.Error(err.Error())
-	} else {
-		log.WithFields(log.Fields{
-			"status": event.Status,
-			"event":  event,
-		}).Debug("Event published")
-	}
-}
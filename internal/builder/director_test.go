@@ -1,6 +1,9 @@
 package builder
 
 import (
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
@@ -27,6 +30,20 @@ func TestBuildBeelzebub_Standard(t *testing.T) {
 	assert.NotNil(t, result.traceStrategy)
 }
 
+func TestBuildBeelzebub_ShutdownClosesLogFile(t *testing.T) {
+	d := NewDirector(NewBuilder())
+	configurations := &parser.BeelzebubCoreConfigurations{}
+	configurations.Core.Logging.LogsPath = filepath.Join(t.TempDir(), "beelzebub.log")
+
+	result, err := d.BuildBeelzebub(configurations, nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.logsFile, "the built builder carries the log file")
+
+	require.NoError(t, result.Shutdown(context.Background()))
+	_, err = result.logsFile.WriteString("test")
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
 func TestBuildBeelzebub_StoresServicesConfig(t *testing.T) {
 	b := NewBuilder()
 	d := NewDirector(b)
@@ -54,22 +71,23 @@ func TestBuildBeelzebub_BeelzebubCloud(t *testing.T) {
 
 	require.NoError(t, err)
 	assert.NotNil(t, result.traceStrategy)
+	require.NotNil(t, result.sinks)
+	defer result.sinks.Close(context.Background())
 
 	// Verify the strategy is callable without panicking.
-	d.beelzebubCloudStrategy(tracer.Event{})
+	result.traceStrategy(tracer.Event{})
 }
 
-func TestStandardOutStrategy(t *testing.T) {
+func TestBuildBeelzebub_UnknownSink(t *testing.T) {
 	d := NewDirector(NewBuilder())
 
-	// Verify the strategy handles a complete event without panicking.
-	d.standardOutStrategy(tracer.Event{
-		Protocol: "SSH",
-		Status:   "Stateless",
-		ID:       "test-id",
-		User:     "root",
-		Password: "secret",
-	})
+	coreConfig := &parser.BeelzebubCoreConfigurations{}
+	coreConfig.Core.Tracings.Sinks = []parser.Sink{{Type: "carrier-pigeon"}}
+
+	result, err := d.BuildBeelzebub(coreConfig, nil)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
 }
 
 // Cannot easily test RabbitMQ connection in unit tests without a mock
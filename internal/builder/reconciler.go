@@ -0,0 +1,172 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type runningService struct {
+	configuration parser.BeelzebubServiceConfiguration
+	strategy      protocols.ServiceStrategy
+}
+
+// reconciliation is the set of changes needed to move from the running services to the desired ones.
+type reconciliation struct {
+	// toStop are the hash codes of the running services no longer desired.
+	toStop []string
+	// toStart are the desired services not running yet.
+	toStart []parser.BeelzebubServiceConfiguration
+}
+
+// reconcile diffs the running services against the desired configurations using HashCode: a service whose configuration
+// is unchanged is left untouched, while a changed service is replaced, that is stopped and started again.
+func reconcile(running map[string]runningService, desired []parser.BeelzebubServiceConfiguration) (reconciliation, error) {
+	var result reconciliation
+	desiredHashCodes := make(map[string]bool, len(desired))
+
+	for _, servConf := range desired {
+		hashCode, err := servConf.HashCode()
+		if err != nil {
+			return reconciliation{}, err
+		}
+		if desiredHashCodes[hashCode] {
+			continue
+		}
+		desiredHashCodes[hashCode] = true
+		if _, ok := running[hashCode]; !ok {
+			result.toStart = append(result.toStart, servConf)
+		}
+	}
+
+	for hashCode := range running {
+		if !desiredHashCodes[hashCode] {
+			result.toStop = append(result.toStop, hashCode)
+		}
+	}
+	return result, nil
+}
+
+// Reload reconciles the running services with the given configurations, without dropping the established sessions of the unchanged services.
+// Removed and changed services are drained within the shutdown grace period before the new ones are started, so that a replaced service can bind the same address;
+// when a replacement fails to start, the previous configuration is restored on its address and the error names the affected service.
+func (b *Builder) Reload(servicesConfiguration []parser.BeelzebubServiceConfiguration) error {
+	b.servicesMutex.Lock()
+	defer b.servicesMutex.Unlock()
+
+	if b.stopped {
+		return errors.New("error during reload: beelzebub is shutting down")
+	}
+	changes, err := reconcile(b.services, servicesConfiguration)
+	if err != nil {
+		return err
+	}
+	// A configuration that cannot be started rejects the whole reload before any service is stopped.
+	for _, servConf := range changes.toStart {
+		if _, err := newServiceStrategy(servConf.Protocol); err != nil {
+			log.WithFields(log.Fields{
+				"protocol":    servConf.Protocol,
+				"address":     servConf.Address,
+				"description": servConf.Description,
+			}).Error("Service configuration rejected, keeping the running services")
+			return fmt.Errorf("error during reload: %w", err)
+		}
+	}
+	if len(changes.toStop) == 0 && len(changes.toStart) == 0 {
+		log.Debug("Services configurations unchanged")
+		return nil
+	}
+
+	// The stopped configurations are kept by address, so that a replacement failing to start gives the port back to them.
+	stoppedServices := make(map[string]parser.BeelzebubServiceConfiguration, len(changes.toStop))
+	for _, hashCode := range changes.toStop {
+		servConf := b.services[hashCode].configuration
+		stoppedServices[servConf.Address] = servConf
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.shutdownGracePeriod())
+	defer cancel()
+	b.stopServices(ctx, changes.toStop)
+
+	adopted := slices.Clone(servicesConfiguration)
+	var errs []error
+	for _, servConf := range changes.toStart {
+		previous, replaced := stoppedServices[servConf.Address]
+		if err := b.startService(servConf); err != nil {
+			errs = append(errs, fmt.Errorf("service %s %s: %w", servConf.Protocol, servConf.Address, err))
+			adopted = slices.DeleteFunc(adopted, func(c parser.BeelzebubServiceConfiguration) bool { return sameService(c, servConf) })
+			if replaced {
+				delete(stoppedServices, servConf.Address)
+				errs = append(errs, b.restoreService(previous))
+				adopted = append(adopted, previous)
+			}
+			continue
+		}
+		action := "started"
+		if replaced {
+			action = "replaced"
+		}
+		log.WithFields(log.Fields{
+			"protocol": servConf.Protocol,
+			"address":  servConf.Address,
+		}).Infof("Service %s", action)
+	}
+
+	b.beelzebubServicesConfiguration = adopted
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error during reload: %w", err)
+	}
+	return nil
+}
+
+// sameService reports whether the configurations have the same HashCode, that is whether they describe the same service.
+func sameService(a, b parser.BeelzebubServiceConfiguration) bool {
+	hashCodeA, errA := a.HashCode()
+	hashCodeB, errB := b.HashCode()
+	return errA == nil && errB == nil && hashCodeA == hashCodeB
+}
+
+// restoreService starts again the previous configuration of a service whose replacement failed to start, so that its address does not go dark.
+// The caller must hold servicesMutex.
+func (b *Builder) restoreService(servConf parser.BeelzebubServiceConfiguration) error {
+	fields := log.Fields{
+		"protocol": servConf.Protocol,
+		"address":  servConf.Address,
+	}
+	if err := b.startService(servConf); err != nil {
+		log.WithFields(fields).Errorf("Error during restore of the previous service: %s", err.Error())
+		return fmt.Errorf("service %s %s: error during restore of the previous configuration: %w", servConf.Protocol, servConf.Address, err)
+	}
+	log.WithFields(fields).Warn("Service replacement failed, previous configuration restored")
+	return nil
+}
+
+// stopServices stops concurrently the services identified by the hash codes, so that they share the same grace period.
+// The caller must hold servicesMutex.
+func (b *Builder) stopServices(ctx context.Context, hashCodes []string) {
+	var wg sync.WaitGroup
+	for _, hashCode := range hashCodes {
+		service := b.services[hashCode]
+		delete(b.services, hashCode)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := service.strategy.Shutdown(ctx); err != nil {
+				log.Warnf("Error during shutdown service %s %s: %s", service.configuration.Protocol, service.configuration.Address, err.Error())
+			}
+			log.WithFields(log.Fields{
+				"protocol": service.configuration.Protocol,
+				"address":  service.configuration.Address,
+			}).Info("Service stopped")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,123 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustHashCode(t *testing.T, servConf parser.BeelzebubServiceConfiguration) string {
+	hashCode, err := servConf.HashCode()
+	require.NoError(t, err)
+	return hashCode
+}
+
+func TestReconcile(t *testing.T) {
+	unchanged := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:1", Description: "unchanged"}
+	removed := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:2", Description: "removed"}
+	added := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:3", Description: "added"}
+
+	running := map[string]runningService{
+		mustHashCode(t, unchanged): {configuration: unchanged},
+		mustHashCode(t, removed):   {configuration: removed},
+	}
+
+	changes, err := reconcile(running, []parser.BeelzebubServiceConfiguration{unchanged, added, added})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{mustHashCode(t, removed)}, changes.toStop)
+	assert.Equal(t, []parser.BeelzebubServiceConfiguration{added}, changes.toStart)
+}
+
+func TestReconcile_NoChanges(t *testing.T) {
+	servConf := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:1"}
+	running := map[string]runningService{mustHashCode(t, servConf): {configuration: servConf}}
+
+	changes, err := reconcile(running, []parser.BeelzebubServiceConfiguration{servConf})
+	require.NoError(t, err)
+
+	assert.Empty(t, changes.toStop)
+	assert.Empty(t, changes.toStart)
+}
+
+func TestBuilderReload(t *testing.T) {
+	unchanged := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:0", Description: "unchanged"}
+	replaced := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:0", Description: "before"}
+
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{unchanged, replaced}
+	b.traceStrategy = func(event tracer.Event) {}
+	require.NoError(t, b.Run())
+	defer b.Close()
+
+	unchangedStrategy := b.services[mustHashCode(t, unchanged)].strategy
+
+	replacement := replaced
+	replacement.Description = "after"
+	require.NoError(t, b.Reload([]parser.BeelzebubServiceConfiguration{unchanged, replacement}))
+
+	assert.Len(t, b.services, 2)
+	assert.Same(t, unchangedStrategy, b.services[mustHashCode(t, unchanged)].strategy)
+	assert.NotContains(t, b.services, mustHashCode(t, replaced))
+	assert.Contains(t, b.services, mustHashCode(t, replacement))
+	assert.Equal(t, []parser.BeelzebubServiceConfiguration{unchanged, replacement}, b.beelzebubServicesConfiguration)
+}
+
+func TestBuilderReload_InitError(t *testing.T) {
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.traceStrategy = func(event tracer.Event) {}
+	require.NoError(t, b.Run())
+	defer b.Close()
+
+	err := b.Reload([]parser.BeelzebubServiceConfiguration{{Protocol: "tcp", Address: "invalid-address"}})
+	assert.ErrorContains(t, err, "error during reload")
+	assert.Empty(t, b.services)
+}
+
+func TestBuilderReload_UnknownProtocol(t *testing.T) {
+	running := parser.BeelzebubServiceConfiguration{Protocol: "tcp", Address: "127.0.0.1:0", Description: "running"}
+
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{running}
+	b.traceStrategy = func(event tracer.Event) {}
+	require.NoError(t, b.Run())
+	defer b.Close()
+	runningStrategy := b.services[mustHashCode(t, running)].strategy
+
+	// The running service is removed by the new configuration, which is rejected as a whole.
+	err := b.Reload([]parser.BeelzebubServiceConfiguration{{Protocol: "tpc", Address: "127.0.0.1:0"}})
+	assert.ErrorContains(t, err, "protocol tpc not managed")
+	assert.Len(t, b.services, 1)
+	assert.Same(t, runningStrategy, b.services[mustHashCode(t, running)].strategy)
+	assert.Equal(t, []parser.BeelzebubServiceConfiguration{running}, b.beelzebubServicesConfiguration)
+}
+
+func TestBuilderReload_ReplacementInitError(t *testing.T) {
+	running := parser.BeelzebubServiceConfiguration{Protocol: "ssh", Address: "127.0.0.1:0", Description: "running"}
+
+	b := NewBuilder()
+	b.beelzebubCoreConfigurations = &parser.BeelzebubCoreConfigurations{}
+	b.beelzebubServicesConfiguration = []parser.BeelzebubServiceConfiguration{running}
+	b.traceStrategy = func(event tracer.Event) {}
+	require.NoError(t, b.Run())
+	defer b.Close()
+
+	// The replacement passes the protocol validation but its Init fails, after the running service is stopped.
+	invalid := running
+	invalid.Description = "invalid"
+	invalid.Algorithms.Ciphers = []string{"rot13"}
+	err := b.Reload([]parser.BeelzebubServiceConfiguration{invalid})
+	assert.ErrorContains(t, err, "error during reload")
+	assert.ErrorContains(t, err, "service ssh 127.0.0.1:0")
+
+	assert.Len(t, b.services, 1)
+	assert.Contains(t, b.services, mustHashCode(t, running))
+	assert.NotContains(t, b.services, mustHashCode(t, invalid))
+	assert.Equal(t, []parser.BeelzebubServiceConfiguration{running}, b.beelzebubServicesConfiguration)
+}
@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WatchDirectory polls the YAML files of dir every interval and invokes onChange when a file is added, removed or modified, until ctx is done.
+// Polling is used instead of filesystem notifications, so that it works on every platform and on mounted volumes like Kubernetes ConfigMaps.
+func WatchDirectory(ctx context.Context, dir string, interval time.Duration, onChange func()) {
+	lastFingerprint, err := directoryFingerprint(dir)
+	if err != nil {
+		log.Warnf("Error watching directory %s: %s", dir, err.Error())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := directoryFingerprint(dir)
+		if err != nil {
+			log.Warnf("Error watching directory %s: %s", dir, err.Error())
+			continue
+		}
+		if fingerprint != lastFingerprint {
+			log.Debugf("Directory %s changed", dir)
+			lastFingerprint = fingerprint
+			onChange()
+		}
+	}
+}
+
+// directoryFingerprint hashes name, size and modification time of the YAML files in dir.
+func directoryFingerprint(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	hash := sha256.New()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s|%d|%d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
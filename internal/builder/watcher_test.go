@@ -0,0 +1,58 @@
+package builder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryFingerprint(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ssh.yaml"), []byte("protocol: ssh"), 0644))
+
+	first, err := directoryFingerprint(dir)
+	require.NoError(t, err)
+
+	// Files that are not YAML are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644))
+	second, err := directoryFingerprint(dir)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "http.yaml"), []byte("protocol: http"), 0644))
+	third, err := directoryFingerprint(dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, third)
+}
+
+func TestDirectoryFingerprint_NotFound(t *testing.T) {
+	_, err := directoryFingerprint(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestWatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ssh.yaml"), []byte("protocol: ssh"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go WatchDirectory(ctx, dir, 10*time.Millisecond, func() {
+		changed <- struct{}{}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "http.yaml"), []byte("protocol: http"), 0644))
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for directory change")
+	}
+}
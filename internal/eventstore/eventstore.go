@@ -0,0 +1,291 @@
+// Package eventstore is responsible for persisting the events in a local SQLite database, so that a standalone sensor
+// can be queried without an external stack
+package eventstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	log "github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DefaultLimit is the number of events returned by a query when it does not set one.
+	DefaultLimit = 100
+	// DefaultPurgeInterval is the interval between two runs of the retention policy.
+	DefaultPurgeInterval = 10 * time.Minute
+	// busyTimeout is the wait of a connection for the lock held by another process, e.g. the CLI reading the
+	// database of a running sensor.
+	busyTimeout = 5 * time.Second
+)
+
+// schema indexes the fields the events are queried by; the event itself is stored as JSON.
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	time INTEGER NOT NULL,
+	source_ip TEXT NOT NULL,
+	protocol TEXT NOT NULL,
+	session_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	event TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_time ON events (time);
+CREATE INDEX IF NOT EXISTS events_source_ip ON events (source_ip, time);
+CREATE INDEX IF NOT EXISTS events_protocol ON events (protocol, time);
+CREATE INDEX IF NOT EXISTS events_session_id ON events (session_id);
+`
+
+// Options are the settings of the store, zero values disable the corresponding retention.
+type Options struct {
+	// MaxAge removes the events older than the given duration.
+	MaxAge time.Duration
+	// MaxEvents removes the oldest events beyond the given number.
+	MaxEvents int
+	// PurgeInterval is the interval between two runs of the retention policy, DefaultPurgeInterval when zero.
+	PurgeInterval time.Duration
+	// ReadOnly opens an existing database without the retention policy, e.g. for the queries of the CLI.
+	ReadOnly bool
+}
+
+// Record is a stored event, ID increases with the insertion order.
+type Record struct {
+	ID    int64
+	Event tracer.Event
+}
+
+// Query selects the stored events, the zero values match any event.
+type Query struct {
+	Since     time.Time
+	Until     time.Time
+	SourceIP  string
+	Protocol  string
+	SessionID string
+	Status    string
+	// AfterID returns the events inserted after the given record, from the oldest; otherwise the latest events are
+	// returned. Both are in insertion order.
+	AfterID int64
+	// Limit is the maximum number of events returned, DefaultLimit when zero.
+	Limit int
+}
+
+// Store persists the events in SQLite, it is safe for concurrent use.
+type Store struct {
+	db      *sql.DB
+	options Options
+
+	stop chan struct{}
+	done sync.WaitGroup
+
+	now func() time.Time
+}
+
+// Open opens the database, creating it unless ReadOnly, and starts the retention policy.
+func Open(path string, options Options) (*Store, error) {
+	if path == "" {
+		return nil, errors.New("the event store requires a path")
+	}
+	dsn := url.Values{}
+	dsn.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	if options.ReadOnly {
+		dsn.Add("mode", "ro")
+	} else {
+		// WAL lets the CLI read the database while the sensor writes it.
+		dsn.Add("_pragma", "journal_mode(WAL)")
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?"+dsn.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error during open event store %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error during open event store %s: %w", path, err)
+	}
+	if !options.ReadOnly {
+		if _, err := db.Exec(schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error during create event store schema: %w", err)
+		}
+	}
+
+	if options.PurgeInterval <= 0 {
+		options.PurgeInterval = DefaultPurgeInterval
+	}
+	store := &Store{db: db, options: options, stop: make(chan struct{}), now: time.Now}
+	if !options.ReadOnly && (options.MaxAge > 0 || options.MaxEvents > 0) {
+		// The events left by a previous run are purged right away.
+		store.purge()
+		store.done.Add(1)
+		go store.purgeLoop()
+	}
+	return store, nil
+}
+
+// Insert stores the events in a single transaction.
+func (store *Store) Insert(ctx context.Context, events ...tracer.Event) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error during begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.PrepareContext(ctx, `INSERT INTO events (time, source_ip, protocol, session_id, status, event) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("error during prepare insert: %w", err)
+	}
+	defer statement.Close()
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error during marshal event: %w", err)
+		}
+		timestamp, err := time.Parse(time.RFC3339, event.DateTime)
+		if err != nil {
+			timestamp = store.now()
+		}
+		if _, err := statement.ExecContext(ctx, timestamp.Unix(), event.SourceIp, event.Protocol, event.ID, event.Status, string(data)); err != nil {
+			return fmt.Errorf("error during insert event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error during commit events: %w", err)
+	}
+	return nil
+}
+
+// Query returns the events matching the query, in insertion order.
+func (store *Store) Query(ctx context.Context, query Query) ([]Record, error) {
+	var conditions []string
+	var args []any
+	if !query.Since.IsZero() {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, query.Since.Unix())
+	}
+	if !query.Until.IsZero() {
+		conditions = append(conditions, "time <= ?")
+		args = append(args, query.Until.Unix())
+	}
+	if query.SourceIP != "" {
+		conditions = append(conditions, "source_ip = ?")
+		args = append(args, query.SourceIP)
+	}
+	if query.Protocol != "" {
+		// The protocols are traced in upper case, e.g. SSH.
+		conditions = append(conditions, "protocol = ?")
+		args = append(args, strings.ToUpper(query.Protocol))
+	}
+	if query.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, query.SessionID)
+	}
+	if query.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, query.Status)
+	}
+	order := "DESC"
+	if query.AfterID > 0 {
+		conditions = append(conditions, "id > ?")
+		args = append(args, query.AfterID)
+		order = "ASC"
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	statement := "SELECT id, event FROM events"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY id " + order + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := store.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error during query events: %w", err)
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var data string
+		if err := rows.Scan(&record.ID, &data); err != nil {
+			return nil, fmt.Errorf("error during scan event: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &record.Event); err != nil {
+			return nil, fmt.Errorf("error during unmarshal event %d: %w", record.ID, err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during query events: %w", err)
+	}
+	if order == "DESC" {
+		slices.Reverse(records)
+	}
+	return records, nil
+}
+
+// Purge applies the retention policy, it returns the number of events removed.
+func (store *Store) Purge(ctx context.Context) (int64, error) {
+	var removed int64
+	if store.options.MaxAge > 0 {
+		result, err := store.db.ExecContext(ctx, `DELETE FROM events WHERE time < ?`, store.now().Add(-store.options.MaxAge).Unix())
+		if err != nil {
+			return removed, fmt.Errorf("error during purge old events: %w", err)
+		}
+		count, _ := result.RowsAffected()
+		removed += count
+	}
+	if store.options.MaxEvents > 0 {
+		result, err := store.db.ExecContext(ctx, `DELETE FROM events WHERE id <= (SELECT id FROM events ORDER BY id DESC LIMIT 1 OFFSET ?)`, store.options.MaxEvents)
+		if err != nil {
+			return removed, fmt.Errorf("error during purge exceeding events: %w", err)
+		}
+		count, _ := result.RowsAffected()
+		removed += count
+	}
+	return removed, nil
+}
+
+func (store *Store) purgeLoop() {
+	defer store.done.Done()
+	ticker := time.NewTicker(store.options.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-store.stop:
+			return
+		case <-ticker.C:
+			store.purge()
+		}
+	}
+}
+
+func (store *Store) purge() {
+	if removed, err := store.Purge(context.Background()); err != nil {
+		log.Errorf("Error during purge event store: %s", err.Error())
+	} else if removed > 0 {
+		log.Debugf("Event store purged %d events", removed)
+	}
+}
+
+// Close stops the retention policy and closes the database.
+func (store *Store) Close() error {
+	close(store.stop)
+	store.done.Wait()
+	return store.db.Close()
+}
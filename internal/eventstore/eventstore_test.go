@@ -0,0 +1,98 @@
+package eventstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvents() []tracer.Event {
+	return []tracer.Event{
+		{ID: "s1", Protocol: "SSH", Status: "Start", SourceIp: "192.0.2.1", DateTime: "2024-05-01T10:00:00Z", Msg: "New SSH Session"},
+		{ID: "s1", Protocol: "SSH", Status: "Interaction", SourceIp: "192.0.2.1", DateTime: "2024-05-01T10:00:05Z", Command: "uname -a"},
+		{ID: "h1", Protocol: "HTTP", Status: "Stateless", SourceIp: "198.51.100.7", DateTime: "2024-05-01T11:00:00Z", RequestURI: "/.env"},
+		{ID: "s1", Protocol: "SSH", Status: "End", SourceIp: "192.0.2.1", DateTime: "2024-05-01T12:00:00Z"},
+	}
+}
+
+func ids(records []Record) []int64 {
+	var result []int64
+	for _, record := range records {
+		result = append(result, record.ID)
+	}
+	return result
+}
+
+func TestOpen_InvalidPath(t *testing.T) {
+	_, err := Open("", Options{})
+	assert.ErrorContains(t, err, "requires a path")
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing.db"), Options{ReadOnly: true})
+	assert.Error(t, err, "a read only store is not created")
+}
+
+func TestStore_Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	store, err := Open(path, Options{})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Insert(context.Background(), testEvents()...))
+
+	records, err := store.Query(context.Background(), Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids(records))
+	assert.Equal(t, testEvents()[1], records[1].Event)
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []int64
+	}{
+		{"protocol is case insensitive", Query{Protocol: "ssh"}, []int64{1, 2, 4}},
+		{"source ip", Query{SourceIP: "198.51.100.7"}, []int64{3}},
+		{"session", Query{SessionID: "s1", Status: "Interaction"}, []int64{2}},
+		{"time range", Query{Since: time.Date(2024, 5, 1, 10, 0, 5, 0, time.UTC), Until: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)}, []int64{2, 3}},
+		{"latest events", Query{Limit: 2}, []int64{3, 4}},
+		{"after a record", Query{AfterID: 1, Limit: 2}, []int64{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.Query(context.Background(), tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ids(records))
+		})
+	}
+
+	reader, err := Open(path, Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer reader.Close()
+	records, err = reader.Query(context.Background(), Query{SessionID: "h1"})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, ids(records))
+	assert.Error(t, reader.Insert(context.Background(), testEvents()[0]))
+}
+
+func TestStore_Purge(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "events.db"), Options{MaxAge: 90 * time.Minute, MaxEvents: 2, PurgeInterval: time.Hour})
+	require.NoError(t, err)
+	defer store.Close()
+	store.now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	events := append(testEvents(), tracer.Event{ID: "h2", Protocol: "HTTP", Status: "Stateless", DateTime: "2024-05-01T12:00:00Z"})
+	require.NoError(t, store.Insert(context.Background(), events...))
+
+	removed, err := store.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed, "two events are too old, then one exceeds the maximum")
+
+	records, err := store.Query(context.Background(), Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 5}, ids(records))
+}
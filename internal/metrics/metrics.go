@@ -0,0 +1,134 @@
+// Package metrics is responsible for the Prometheus metrics of the honeypot services, labeled by service
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The authentication methods of the auth metrics.
+const (
+	PasswordAuth            = "password"
+	PublicKeyAuth           = "publickey"
+	KeyboardInteractiveAuth = "keyboard-interactive"
+)
+
+// serviceLabels identify a service: its description, its address and its protocol.
+var serviceLabels = []string{"service", "address", "protocol"}
+
+// Metrics are the per-service metrics, registered on the registry given to New so that several instances, e.g. one per
+// test, do not collide.
+type Metrics struct {
+	eventsTotal        *prometheus.CounterVec
+	activeSessions     *prometheus.GaugeVec
+	sessionDuration    *prometheus.HistogramVec
+	authAttemptsTotal  *prometheus.CounterVec
+	authSuccessesTotal *prometheus.CounterVec
+	pluginDuration     *prometheus.HistogramVec
+}
+
+// New creates the metrics and registers them on registerer, it panics if they are already registered.
+func New(registerer prometheus.Registerer) *Metrics {
+	factory := promauto.With(registerer)
+	return &Metrics{
+		eventsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "beelzebub",
+			Name:      "service_events_total",
+			Help:      "The total number of events, by service, status and handler",
+		}, []string{"service", "address", "protocol", "status", "handler"}),
+		activeSessions: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "beelzebub",
+			Name:      "service_active_sessions",
+			Help:      "The number of interactive sessions in progress, by service",
+		}, serviceLabels),
+		sessionDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "beelzebub",
+			Name:      "service_session_duration_seconds",
+			Help:      "The duration of the interactive sessions, by service",
+			// From 1 second to about 2 hours.
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		}, serviceLabels),
+		authAttemptsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "beelzebub",
+			Name:      "service_auth_attempts_total",
+			Help:      "The total number of authentication attempts, by service and method: password, publickey or keyboard-interactive",
+		}, []string{"service", "address", "protocol", "method"}),
+		authSuccessesTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "beelzebub",
+			Name:      "service_auth_successes_total",
+			Help:      "The total number of successful authentications, by service and method: password, publickey or keyboard-interactive",
+		}, []string{"service", "address", "protocol", "method"}),
+		pluginDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "beelzebub",
+			Name:      "plugin_duration_seconds",
+			Help:      "The execution time of the plugins, by service, plugin and result: success or error",
+			// From 10 milliseconds to about 20 seconds, the LLM plugins being the slowest.
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"service", "address", "protocol", "plugin", "result"}),
+	}
+}
+
+// Service returns the metrics of a service, a nil Metrics returns a nil Service, whose methods do nothing.
+func (metrics *Metrics) Service(description, address, protocol string) *Service {
+	if metrics == nil {
+		return nil
+	}
+	return &Service{metrics: metrics, labels: []string{description, address, protocol}}
+}
+
+// Service records the metrics of a honeypot service, the methods of a nil Service do nothing so that the strategies
+// run without metrics, e.g. in the tests.
+type Service struct {
+	metrics *Metrics
+	labels  []string
+}
+
+func (service *Service) with(values ...string) []string {
+	return append(append(make([]string, 0, len(service.labels)+len(values)), service.labels...), values...)
+}
+
+// ObserveEvent counts an event traced by the service.
+func (service *Service) ObserveEvent(status, handler string) {
+	if service == nil {
+		return
+	}
+	service.metrics.eventsTotal.WithLabelValues(service.with(status, handler)...).Inc()
+}
+
+// SessionStarted counts an interactive session in progress, the returned function ends it and records its duration.
+func (service *Service) SessionStarted() (ended func()) {
+	if service == nil {
+		return func() {}
+	}
+	started := time.Now()
+	service.metrics.activeSessions.WithLabelValues(service.labels...).Inc()
+	return func() {
+		service.metrics.activeSessions.WithLabelValues(service.labels...).Dec()
+		service.metrics.sessionDuration.WithLabelValues(service.labels...).Observe(time.Since(started).Seconds())
+	}
+}
+
+// AuthAttempt counts an authentication attempt with the given method, and its success.
+func (service *Service) AuthAttempt(method string, success bool) {
+	if service == nil {
+		return
+	}
+	service.metrics.authAttemptsTotal.WithLabelValues(service.with(method)...).Inc()
+	if success {
+		service.metrics.authSuccessesTotal.WithLabelValues(service.with(method)...).Inc()
+	}
+}
+
+// ObservePlugin records the execution time of a plugin started at the given time, err being its result.
+func (service *Service) ObservePlugin(plugin string, started time.Time, err error) {
+	if service == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	service.metrics.pluginDuration.WithLabelValues(service.with(plugin, result)...).Observe(time.Since(started).Seconds())
+}
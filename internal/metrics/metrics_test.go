@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := New(registry)
+	service := metrics.Service("admin panel", ":8080", "http")
+	other := metrics.Service("wordpress", ":8081", "http")
+
+	service.ObserveEvent("Stateless", "login")
+	service.ObserveEvent("Stateless", "login")
+	other.ObserveEvent("Stateless", "login")
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.eventsTotal.WithLabelValues("admin panel", ":8080", "http", "Stateless", "login")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.eventsTotal.WithLabelValues("wordpress", ":8081", "http", "Stateless", "login")))
+
+	ended := service.SessionStarted()
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.activeSessions.WithLabelValues("admin panel", ":8080", "http")))
+	ended()
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.activeSessions.WithLabelValues("admin panel", ":8080", "http")))
+
+	service.AuthAttempt(PasswordAuth, false)
+	service.AuthAttempt(PasswordAuth, true)
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.authAttemptsTotal.WithLabelValues("admin panel", ":8080", "http", PasswordAuth)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.authSuccessesTotal.WithLabelValues("admin panel", ":8080", "http", PasswordAuth)))
+
+	service.ObservePlugin("LLMHoneypot", time.Now(), nil)
+	service.ObservePlugin("LLMHoneypot", time.Now(), errors.New("timeout"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	histograms := make(map[string]uint64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			if histogram := metric.GetHistogram(); histogram != nil {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "result" {
+						histograms[family.GetName()+"/"+label.GetValue()] = histogram.GetSampleCount()
+					}
+				}
+				if family.GetName() == "beelzebub_service_session_duration_seconds" {
+					histograms[family.GetName()] = histogram.GetSampleCount()
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]uint64{
+		"beelzebub_service_session_duration_seconds": 1,
+		"beelzebub_plugin_duration_seconds/success":  1,
+		"beelzebub_plugin_duration_seconds/error":    1,
+	}, histograms)
+}
+
+func TestNew_Registries(t *testing.T) {
+	// Every registry has its own metrics, so that they do not collide.
+	first := New(prometheus.NewRegistry())
+	second := New(prometheus.NewRegistry())
+
+	first.Service("ssh", ":22", "ssh").ObserveEvent("Start", "")
+	assert.Equal(t, 0.0, testutil.ToFloat64(second.eventsTotal.WithLabelValues("ssh", ":22", "ssh", "Start", "")))
+
+	assert.Panics(t, func() {
+		registry := prometheus.NewRegistry()
+		New(registry)
+		New(registry)
+	})
+}
+
+func TestService_Nil(t *testing.T) {
+	var metrics *Metrics
+	service := metrics.Service("ssh", ":22", "ssh")
+	assert.Nil(t, service)
+
+	assert.NotPanics(t, func() {
+		service.ObserveEvent("Start", "")
+		service.SessionStarted()()
+		service.AuthAttempt(PasswordAuth, true)
+		service.ObservePlugin("LLMHoneypot", time.Now(), nil)
+	})
+}
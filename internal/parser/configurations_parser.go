@@ -24,6 +24,8 @@ type BeelzebubCoreConfigurations struct {
 		Tracings       Tracings       `yaml:"tracings"`
 		Prometheus     Prometheus     `yaml:"prometheus"`
 		BeelzebubCloud BeelzebubCloud `yaml:"beelzebub-cloud"`
+		Lifecycle      Lifecycle      `yaml:"lifecycle"`
+		OpenTelemetry  OpenTelemetry  `yaml:"openTelemetry"`
 	}
 }
 
@@ -33,11 +35,279 @@ type Logging struct {
 	DebugReportCaller   bool   `yaml:"debugReportCaller"`
 	LogDisableTimestamp bool   `yaml:"logDisableTimestamp"`
 	LogsPath            string `yaml:"logsPath,omitempty"`
+	// LogsRotation rotates the file of LogsPath.
+	LogsRotation Rotation `yaml:"logsRotation"`
+}
+
+// Rotation is the struct that contains the rotation settings of a file, zero values disable the corresponding rotation
+type Rotation struct {
+	// MaxSizeMegabytes rotates the file once it exceeds the given size.
+	MaxSizeMegabytes int `yaml:"maxSizeMegabytes"`
+	// IntervalHours rotates the file when a new period starts, e.g. 24 for every day at midnight UTC.
+	IntervalHours int `yaml:"intervalHours"`
+	// Compress gzips the rotated files.
+	Compress bool `yaml:"compress"`
+	// MaxBackups is the number of rotated files kept, the oldest ones are removed.
+	MaxBackups int `yaml:"maxBackups"`
+}
+
+// OpenTelemetry is the struct that contains the configurations of the OTLP export of the logs, the traces and the metrics
+type OpenTelemetry struct {
+	Enabled bool `yaml:"enabled"`
+	// Protocol is grpc or http, grpc by default.
+	Protocol string `yaml:"protocol"`
+	// Endpoint is the host:port of the collector, localhost:4317 for grpc and localhost:4318 for http by default.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure disables TLS.
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+	// ServiceName is the service.name of the resource, beelzebub by default.
+	ServiceName string `yaml:"serviceName"`
+	// MetricsIntervalSeconds is the export interval of the metrics, 60 by default.
+	MetricsIntervalSeconds int     `yaml:"metricsIntervalSeconds"`
+	TLS                    SinkTLS `yaml:"tls"`
+}
+
+// Lifecycle is the struct that contains the configurations of the services lifecycle
+type Lifecycle struct {
+	// ShutdownGracePeriodSeconds is the time given to the in-flight sessions to terminate on shutdown, before being closed.
+	ShutdownGracePeriodSeconds int `yaml:"shutdownGracePeriodSeconds"`
+	// WatchServicesIntervalSeconds enables the hot reload of the services directory, polled at the given interval. Zero disables it.
+	WatchServicesIntervalSeconds int `yaml:"watchServicesIntervalSeconds"`
 }
 
 // Tracings is the struct that contains the configurations of the tracings
 type Tracings struct {
 	RabbitMQ `yaml:"rabbit-mq"`
+	Queue    Queue `yaml:"queue"`
+	// Sinks are the tracing backends receiving the events, when empty they are derived from rabbit-mq and beelzebub-cloud.
+	Sinks []Sink `yaml:"sinks"`
+	// Spool is the default spool of the remote sinks, the ones other than stdout.
+	Spool SinkSpool `yaml:"spool"`
+}
+
+// Sink is the struct that contains the configurations of a tracing backend, every event is delivered to all the matching sinks
+type Sink struct {
+	// Name identifies the sink in the logs and in the metrics, the type when empty.
+	Name string `yaml:"name"`
+	// Type is the kind of backend: stdout, file, sqlite, rabbitmq, syslog, webhook, elasticsearch, splunk, kafka, opentelemetry,
+	// which uses the openTelemetry exporters, or beelzebub-cloud, which uses the beelzebub-cloud credentials.
+	Type   string     `yaml:"type"`
+	Filter SinkFilter `yaml:"filter"`
+	Retry  SinkRetry  `yaml:"retry"`
+	// Batch groups the events of the sinks delivering several events at once: sqlite, webhook, elasticsearch, splunk and kafka.
+	Batch     SinkBatch     `yaml:"batch"`
+	RateLimit SinkRateLimit `yaml:"rateLimit"`
+	// QueueSize is the number of events waiting for the sink, zero means the default of 1000.
+	QueueSize int `yaml:"queueSize"`
+	// RabbitMQ configures the rabbitmq sink, enabled is ignored.
+	RabbitMQ RabbitMQ    `yaml:"rabbit-mq"`
+	Syslog   SinkSyslog  `yaml:"syslog"`
+	File     SinkFile    `yaml:"file"`
+	SQLite   SinkSQLite  `yaml:"sqlite"`
+	Webhook  SinkWebhook `yaml:"webhook"`
+	// Elasticsearch configures the elasticsearch sink, for Elasticsearch and OpenSearch.
+	Elasticsearch SinkElasticsearch `yaml:"elasticsearch"`
+	Splunk        SinkSplunk        `yaml:"splunk"`
+	Kafka         SinkKafka         `yaml:"kafka"`
+	OpenTelemetry SinkOpenTelemetry `yaml:"openTelemetry"`
+	// Spool overrides the default spool of the tracings.
+	Spool SinkSpool `yaml:"spool"`
+}
+
+// SinkFile is the struct that contains the configurations of the file sink, writing the events as JSON lines
+type SinkFile struct {
+	Path     string   `yaml:"path"`
+	Rotation Rotation `yaml:"rotation"`
+}
+
+// SinkSQLite is the struct that contains the configurations of the sqlite sink, storing the events in a local database
+// queried by the events commands
+type SinkSQLite struct {
+	Path string `yaml:"path"`
+	// RetentionHours removes the events older than the given time, zero keeps them.
+	RetentionHours int `yaml:"retentionHours"`
+	// MaxEvents removes the oldest events beyond the given number, zero keeps them.
+	MaxEvents int `yaml:"maxEvents"`
+}
+
+// SinkWebhook is the struct that contains the configurations of the webhook sink, sending the events to an HTTP endpoint
+type SinkWebhook struct {
+	URL string `yaml:"url"`
+	// Method is the HTTP method of the requests, POST when empty.
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	// Body is a Go template rendered with the event, e.g. {"text": {{json .Command}}}, the JSON event when empty.
+	Body string `yaml:"body"`
+	// BatchBody is a Go template rendered with the events of a batch, the bodies of the events one per line when empty.
+	BatchBody string `yaml:"batchBody"`
+	// Secret signs the body with HMAC-SHA256 in the X-Beelzebub-Signature-256 header.
+	Secret         string  `yaml:"secret"`
+	TimeoutSeconds int     `yaml:"timeoutSeconds"`
+	TLS            SinkTLS `yaml:"tls"`
+}
+
+// SinkElasticsearch is the struct that contains the configurations of the elasticsearch sink, indexing the events with the _bulk API
+type SinkElasticsearch struct {
+	URL string `yaml:"url"`
+	// Index is a Go template rendered with the event, beelzebub-{{date "2006.01.02" .DateTime}} by default.
+	Index    string `yaml:"index"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	APIKey   string `yaml:"apiKey"`
+	// OpenSearch maps HeadersMap as flat_object instead of flattened.
+	OpenSearch bool `yaml:"openSearch"`
+	// TemplateName is the name of the installed index template, beelzebub by default.
+	TemplateName string `yaml:"templateName"`
+	// IndexPattern selects the indices of the index template, beelzebub-* by default.
+	IndexPattern string `yaml:"indexPattern"`
+	// DisableTemplate leaves the index template to the administrators of the cluster.
+	DisableTemplate bool    `yaml:"disableTemplate"`
+	TimeoutSeconds  int     `yaml:"timeoutSeconds"`
+	TLS             SinkTLS `yaml:"tls"`
+}
+
+// SinkSplunk is the struct that contains the configurations of the splunk sink, sending the events to an HTTP Event Collector
+type SinkSplunk struct {
+	// URL is the base URL of the HTTP Event Collector, e.g. https://splunk.example.com:8088.
+	URL   string `yaml:"url"`
+	Token string `yaml:"token"`
+	// Host, Source, Sourcetype and Index are the metadata of the events, the defaults of the token when empty.
+	Host       string `yaml:"host"`
+	Source     string `yaml:"source"`
+	Sourcetype string `yaml:"sourcetype"`
+	Index      string `yaml:"index"`
+	// Services override the metadata of the events of some services.
+	Services []SinkSplunkService `yaml:"services"`
+	// IndexerAck counts an event as delivered only once indexed, it requires the indexer acknowledgement on the token.
+	IndexerAck bool `yaml:"indexerAck"`
+	// AckTimeoutSeconds is the longest wait for the indexer acknowledgement, 60 by default.
+	AckTimeoutSeconds int     `yaml:"ackTimeoutSeconds"`
+	TimeoutSeconds    int     `yaml:"timeoutSeconds"`
+	TLS               SinkTLS `yaml:"tls"`
+}
+
+// SinkSplunkService is the struct that contains the Splunk metadata of the events of a service
+type SinkSplunkService struct {
+	// Service is the address or the description of the service.
+	Service    string `yaml:"service"`
+	Host       string `yaml:"host"`
+	Source     string `yaml:"source"`
+	Sourcetype string `yaml:"sourcetype"`
+	Index      string `yaml:"index"`
+}
+
+// SinkKafka is the struct that contains the configurations of the kafka sink, producing the events keyed by SourceIp
+type SinkKafka struct {
+	// Brokers are the host:port of the seed brokers.
+	Brokers []string `yaml:"brokers"`
+	// Topic is a Go template rendered with the event, e.g. "beelzebub-{{lower .Protocol}}", beelzebub-events by default.
+	Topic    string `yaml:"topic"`
+	ClientID string `yaml:"clientID"`
+	// Compression is the codec of the record batches: none, gzip, snappy, lz4 or zstd, none by default.
+	Compression string `yaml:"compression"`
+	// Idempotent enables the idempotent producer, acknowledged by all the in-sync replicas.
+	Idempotent bool          `yaml:"idempotent"`
+	SASL       SinkKafkaSASL `yaml:"sasl"`
+	// DeliveryTimeoutSeconds bounds the retries of a record by the client before the retry policy of the sink, 30 by default.
+	DeliveryTimeoutSeconds int     `yaml:"deliveryTimeoutSeconds"`
+	EnableTLS              bool    `yaml:"enableTLS"`
+	TLS                    SinkTLS `yaml:"tls"`
+}
+
+// SinkKafkaSASL is the struct that contains the SASL credentials of the kafka sink
+type SinkKafkaSASL struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512, empty disables the authentication.
+	Mechanism string `yaml:"mechanism"`
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// SinkOpenTelemetry is the struct that contains the configurations of the opentelemetry sink, exporting the events as
+// log records and the sessions as traces
+type SinkOpenTelemetry struct {
+	// InteractionSpans records the Interaction events as child spans of the session, otherwise as span events.
+	InteractionSpans bool `yaml:"interactionSpans"`
+	// SessionTimeoutMinutes ends the span of a session without events for the given time, 30 by default.
+	SessionTimeoutMinutes int `yaml:"sessionTimeoutMinutes"`
+}
+
+// SinkBatch is the struct that contains the batching of the events of a sink
+type SinkBatch struct {
+	// Size is the maximum number of events of a batch, zero or one disables the batching; 500 by default for elasticsearch, 100 for splunk and kafka.
+	Size int `yaml:"size"`
+	// IntervalMilliseconds is the longest wait of an event for its batch to fill, 5000 by default.
+	IntervalMilliseconds int `yaml:"intervalMilliseconds"`
+}
+
+// SinkRateLimit is the struct that contains the rate limit of the requests to a sink, a batch being a single request
+type SinkRateLimit struct {
+	// RequestsPerSecond is the sustained rate of requests, zero disables the limit.
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	// Burst is the number of requests sent at once above the rate, 1 by default.
+	Burst int `yaml:"burst"`
+}
+
+// SinkSyslog is the struct that contains the configurations of the syslog sink, sending RFC 5424 messages
+type SinkSyslog struct {
+	// Network is udp, tcp or tls.
+	Network string `yaml:"network"`
+	// Address is the host:port of the syslog collector.
+	Address string `yaml:"address"`
+	// Format is the body of the messages: json, cef or leef, json when empty.
+	Format string `yaml:"format"`
+	// Facility is the syslog facility code, 16 (local0) when zero.
+	Facility int `yaml:"facility"`
+	// Hostname identifies the sensor, the hostname of the machine when empty.
+	Hostname string  `yaml:"hostname"`
+	AppName  string  `yaml:"appName"`
+	TLS      SinkTLS `yaml:"tls"`
+}
+
+// SinkSpool is the struct that contains the configurations of the on-disk spool of a sink: the events are written to disk
+// before the delivery and removed once delivered, so that none is lost while the sink is unreachable
+type SinkSpool struct {
+	// Dir enables the spool, the events of a sink are stored in a subdirectory named after the sink.
+	Dir string `yaml:"dir"`
+	// SegmentSizeMegabytes is the size of a spool file, 16 by default.
+	SegmentSizeMegabytes int `yaml:"segmentSizeMegabytes"`
+	// MaxSizeMegabytes caps the size of the spool of a sink, the oldest files are evicted first, 1024 by default.
+	MaxSizeMegabytes int `yaml:"maxSizeMegabytes"`
+}
+
+// SinkFilter selects the events delivered to a sink, an empty list matches any value
+type SinkFilter struct {
+	// Protocols are the protocols of the events, e.g. ssh.
+	Protocols []string `yaml:"protocols"`
+	// Statuses are the statuses of the events: Start, End, Stateless, Interaction, PortForward or Download.
+	Statuses []string `yaml:"statuses"`
+	// Services are the addresses or the descriptions of the services tracing the events.
+	Services []string `yaml:"services"`
+}
+
+// SinkRetry is the struct that contains the retry policy of a sink, zero values mean the defaults
+type SinkRetry struct {
+	// MaxAttempts is the number of delivery attempts of an event, 3 by default, unlimited with a spool.
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoffMilliseconds is the pause before the first retry, doubled on every retry, 1000 by default.
+	InitialBackoffMilliseconds int `yaml:"initialBackoffMilliseconds"`
+	// MaxBackoffMilliseconds caps the pause between two retries, 30000 by default.
+	MaxBackoffMilliseconds int `yaml:"maxBackoffMilliseconds"`
+}
+
+// Queue is the struct that contains the configurations of the events queue, between the honeypots and the tracing backend
+type Queue struct {
+	// Size is the capacity of the queue, zero means the default of 10000 events.
+	Size int `yaml:"size"`
+	// Workers is the number of goroutines delivering the events, zero means the default of 5.
+	Workers int `yaml:"workers"`
+	// OverflowPolicy is applied when the queue is full: block, drop-newest, drop-oldest or spill-to-disk; drop-newest when
+	// empty, so that tracing never blocks the honeypots, set block to wait for the tracing backend instead.
+	OverflowPolicy string `yaml:"overflowPolicy"`
+	// SpillDir is the directory of the events spilled to disk, required by the spill-to-disk policy.
+	SpillDir string `yaml:"spillDir"`
+	// MaxSpillBytes is the maximum size of the spill file, zero means the default of 1 GiB; once reached, the events are dropped.
+	MaxSpillBytes int64 `yaml:"maxSpillBytes"`
 }
 
 type BeelzebubCloud struct {
@@ -48,6 +318,28 @@ type BeelzebubCloud struct {
 type RabbitMQ struct {
 	Enabled bool   `yaml:"enabled"`
 	URI     string `yaml:"uri"`
+	// Exchange receives the events, when empty they are published on the queue through the default exchange.
+	Exchange string `yaml:"exchange"`
+	// ExchangeType is the type of the exchange: direct, fanout, topic or headers, topic when empty.
+	ExchangeType string `yaml:"exchangeType"`
+	// RoutingKey is a Go template rendered with the event, e.g. "beelzebub.{{lower .Protocol}}.{{lower .Status}}", the queue name when empty.
+	RoutingKey string `yaml:"routingKey"`
+	// Queue is declared when no exchange is set, "event" when empty.
+	Queue string `yaml:"queue"`
+	// Durable declares a durable exchange or queue and publishes persistent messages.
+	Durable bool `yaml:"durable"`
+	// PublisherConfirms counts an event as delivered only once the broker acknowledges it.
+	PublisherConfirms bool    `yaml:"publisherConfirms"`
+	TLS               SinkTLS `yaml:"tls"`
+}
+
+// SinkTLS is the struct that contains the TLS configurations of the connection to a sink, e.g. the client certificate
+type SinkTLS struct {
+	CACertPath         string `yaml:"caCertPath"`
+	CertPath           string `yaml:"certPath"`
+	KeyPath            string `yaml:"keyPath"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
 }
 type Prometheus struct {
 	Path string `yaml:"path"`
@@ -67,6 +359,8 @@ type Plugin struct {
 	RateLimitEnabled        bool   `yaml:"rateLimitEnabled"`
 	RateLimitRequests       int    `yaml:"rateLimitRequests"`
 	RateLimitWindowSeconds  int    `yaml:"rateLimitWindowSeconds"`
+	// FilesystemSnapshot is the directory or tarball (.tar, .tar.gz, .tgz) seeding the virtual filesystem of ShellHoneypot.
+	FilesystemSnapshot string `yaml:"filesystemSnapshot" json:",omitempty"`
 }
 
 // BeelzebubServiceConfiguration is the struct that contains the configurations of the honeypot service
@@ -91,6 +385,106 @@ type BeelzebubServiceConfiguration struct {
 	// headers are ignored and the immediate TCP peer is used as source IP.
 	TrustedProxies     []string     `yaml:"trustedProxies,omitempty" json:",omitempty"`
 	TrustedProxiesNets []*net.IPNet `yaml:"-" json:"-"`
+	// HostKeys are the paths of the SSH host keys; missing keys are generated and persisted on first run,
+	// so that the fingerprint advertised by the honeypot does not change across restarts.
+	HostKeys SSHHostKeys `yaml:"hostKeys,omitempty" json:",omitzero"`
+	// Algorithms restricts the SSH algorithms advertised by the server, e.g. to mimic a specific OpenSSH release.
+	Algorithms SSHAlgorithms `yaml:"algorithms,omitempty" json:",omitzero"`
+	// PublicKeyAuth enables the SSH public key authentication, every offered key is traced.
+	PublicKeyAuth SSHPublicKeyAuth `yaml:"publicKeyAuth,omitempty" json:",omitzero"`
+	// KeyboardInteractive enables the SSH keyboard-interactive authentication, e.g. to ask for an OTP after the password.
+	KeyboardInteractive SSHKeyboardInteractive `yaml:"keyboardInteractive,omitempty" json:",omitzero"`
+	// FileTransfer enables the SFTP subsystem and the scp command, backed by an in-memory filesystem.
+	FileTransfer SSHFileTransfer `yaml:"fileTransfer,omitempty" json:",omitzero"`
+	// PortForwarding accepts the port forwarding requests, e.g. "ssh -D" and "ssh -L", without opening any real connection.
+	PortForwarding SSHPortForwarding `yaml:"portForwarding,omitempty" json:",omitzero"`
+	// Recording records the interactive SSH and TELNET sessions as asciicast v2 files, named by session ID.
+	Recording SessionRecording `yaml:"recording,omitempty" json:",omitzero"`
+}
+
+// SSHHostKeys is the struct that contains the paths of the SSH host keys, by key type
+type SSHHostKeys struct {
+	RSA     string `yaml:"rsa"`
+	ECDSA   string `yaml:"ecdsa"`
+	Ed25519 string `yaml:"ed25519"`
+}
+
+// SSHPublicKeyAuth is the struct that contains the acceptance policy of the SSH public key authentication
+type SSHPublicKeyAuth struct {
+	Enabled bool `yaml:"enabled"`
+	// AcceptedFingerprints are the SHA256 fingerprints of the accepted keys, e.g. "SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s".
+	AcceptedFingerprints []string `yaml:"acceptedFingerprints"`
+	// AcceptAfterAttempts accepts any key once the client has offered that number of distinct keys, zero disables the policy.
+	AcceptAfterAttempts int `yaml:"acceptAfterAttempts"`
+}
+
+// SSHKeyboardInteractive is the struct that contains the prompts of the SSH keyboard-interactive authentication
+type SSHKeyboardInteractive struct {
+	Enabled     bool   `yaml:"enabled"`
+	Instruction string `yaml:"instruction"`
+	// Prompts are asked in order, one per round; without prompts, the password is asked and matched against passwordRegex.
+	Prompts []SSHPrompt `yaml:"prompts"`
+}
+
+// SSHPrompt is the struct that contains a single keyboard-interactive prompt
+type SSHPrompt struct {
+	Prompt string `yaml:"prompt"`
+	Echo   bool   `yaml:"echo"`
+	// Regex must match the answer for the authentication to succeed, an empty regex accepts any answer.
+	Regex string `yaml:"regex"`
+}
+
+// SSHFileTransfer is the struct that contains the configuration of the SFTP and SCP file transfers
+type SSHFileTransfer struct {
+	Enabled bool `yaml:"enabled"`
+	// QuarantineDir is the directory where the uploaded files are stored, named by SHA256; when empty uploads are only traced.
+	QuarantineDir string `yaml:"quarantineDir"`
+	// MaxUploadSizeBytes limits the size of a single uploaded file, zero means the default of 64 MiB.
+	MaxUploadSizeBytes int64 `yaml:"maxUploadSizeBytes"`
+	// MaxConnectionUploadBytes limits the total size of the files uploaded on a connection, zero means the default of 256 MiB.
+	MaxConnectionUploadBytes int64 `yaml:"maxConnectionUploadBytes"`
+	// MaxTotalUploadBytes limits the total size of the files uploaded on the connections in progress, zero means the default of 1 GiB.
+	MaxTotalUploadBytes int64 `yaml:"maxTotalUploadBytes"`
+	// DecoyFiles are the files available for download.
+	DecoyFiles []SSHDecoyFile `yaml:"decoyFiles"`
+}
+
+// SSHDecoyFile is the struct that contains a file served by the in-memory filesystem
+type SSHDecoyFile struct {
+	// Path is the absolute path of the file in the in-memory filesystem.
+	Path string `yaml:"path"`
+	// Source is the local file with the content of the decoy, Content is used when empty.
+	Source  string `yaml:"source"`
+	Content string `yaml:"content"`
+}
+
+// SSHPortForwarding is the struct that contains the configuration of the captured port forwarding requests
+type SSHPortForwarding struct {
+	Enabled bool `yaml:"enabled"`
+	// CaptureBytes is the number of bytes captured from every forwarded channel, zero means the default of 4 KiB.
+	CaptureBytes int `yaml:"captureBytes"`
+	// CaptureTimeoutSeconds is how long a forwarded channel is kept open waiting for data, zero means the default of 10 seconds.
+	CaptureTimeoutSeconds int `yaml:"captureTimeoutSeconds"`
+}
+
+// SessionRecording is the struct that contains the configuration of the interactive sessions recording
+type SessionRecording struct {
+	Enabled bool `yaml:"enabled"`
+	// Path is the directory of the recordings, when empty the recordings are stored in ./recordings.
+	Path string `yaml:"path"`
+	// MaxSizeMegabytes stops recording a session once its recording reaches the given size.
+	MaxSizeMegabytes int `yaml:"maxSizeMegabytes"`
+	// MaxRecordings is the number of recordings kept in Path, the oldest ones are removed.
+	MaxRecordings int `yaml:"maxRecordings"`
+	// MaxAgeDays removes the recordings older than the given number of days.
+	MaxAgeDays int `yaml:"maxAgeDays"`
+}
+
+// SSHAlgorithms is the struct that contains the SSH algorithms advertised by the server, empty lists keep the defaults
+type SSHAlgorithms struct {
+	KeyExchanges []string `yaml:"kex"`
+	Ciphers      []string `yaml:"ciphers"`
+	MACs         []string `yaml:"macs"`
 }
 
 func (bsc BeelzebubServiceConfiguration) HashCode() (string, error) {
@@ -187,7 +581,9 @@ func (bp configurationsParser) ReadConfigurationsCore() (*BeelzebubCoreConfigura
 //	BEELZEBUB_LOGGING_LOG_DISABLE_TIMESTAMP, BEELZEBUB_LOGGING_LOGS_PATH,
 //	BEELZEBUB_RABBITMQ_ENABLED, BEELZEBUB_RABBITMQ_URI,
 //	BEELZEBUB_PROMETHEUS_PATH, BEELZEBUB_PROMETHEUS_PORT,
-//	BEELZEBUB_CLOUD_ENABLED, BEELZEBUB_CLOUD_URI, BEELZEBUB_CLOUD_AUTH_TOKEN
+//	BEELZEBUB_CLOUD_ENABLED, BEELZEBUB_CLOUD_URI, BEELZEBUB_CLOUD_AUTH_TOKEN,
+//	BEELZEBUB_SHUTDOWN_GRACE_PERIOD_SECONDS, BEELZEBUB_WATCH_SERVICES_INTERVAL_SECONDS,
+//	BEELZEBUB_OPENTELEMETRY_ENABLED, BEELZEBUB_OPENTELEMETRY_ENDPOINT
 func applyEnvOverrides(cfg *BeelzebubCoreConfigurations) {
 	if v := os.Getenv("BEELZEBUB_LOGGING_DEBUG"); v != "" {
 		cfg.Core.Logging.Debug = parseBool(v)
@@ -222,6 +618,18 @@ func applyEnvOverrides(cfg *BeelzebubCoreConfigurations) {
 	if v := os.Getenv("BEELZEBUB_CLOUD_AUTH_TOKEN"); v != "" {
 		cfg.Core.BeelzebubCloud.AuthToken = v
 	}
+	if v := os.Getenv("BEELZEBUB_SHUTDOWN_GRACE_PERIOD_SECONDS"); v != "" {
+		cfg.Core.Lifecycle.ShutdownGracePeriodSeconds = parseInt(v)
+	}
+	if v := os.Getenv("BEELZEBUB_WATCH_SERVICES_INTERVAL_SECONDS"); v != "" {
+		cfg.Core.Lifecycle.WatchServicesIntervalSeconds = parseInt(v)
+	}
+	if v := os.Getenv("BEELZEBUB_OPENTELEMETRY_ENABLED"); v != "" {
+		cfg.Core.OpenTelemetry.Enabled = parseBool(v)
+	}
+	if v := os.Getenv("BEELZEBUB_OPENTELEMETRY_ENDPOINT"); v != "" {
+		cfg.Core.OpenTelemetry.Endpoint = v
+	}
 }
 
 func parseBool(v string) bool {
@@ -229,6 +637,11 @@ func parseBool(v string) bool {
 	return b
 }
 
+func parseInt(v string) int {
+	i, _ := strconv.Atoi(v)
+	return i
+}
+
 func isNotFound(err error) bool {
 	return os.IsNotExist(err)
 }
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
@@ -47,8 +47,14 @@ type beelzebubCloud struct {
 	AuthToken       string
 	client          *resty.Client
 	PollingInterval time.Duration
+	// stop ends the polling of the honeypots configurations.
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
+// ConfigurationsChangedHandler receives the new honeypots configurations when they change on beelzebub cloud.
+type ConfigurationsChangedHandler func(servicesConfiguration []parser.BeelzebubServiceConfiguration)
+
 type HoneypotConfigResponseDTO struct {
 	ID            string `json:"id"`
 	Config        string `json:"config"`
@@ -56,19 +62,17 @@ type HoneypotConfigResponseDTO struct {
 	LastUpdatedOn string `json:"lastUpdatedOn"`
 }
 
-func InitBeelzebubCloud(uri, authToken string, enableVerifyConfigurationsChanged bool) *beelzebubCloud {
+// InitBeelzebubCloud returns a beelzebub cloud client, when onConfigurationsChanged is not nil the honeypots configurations are polled and the handler is invoked on every change.
+func InitBeelzebubCloud(uri, authToken string, onConfigurationsChanged ConfigurationsChangedHandler) *beelzebubCloud {
 	beelzebubCloud := &beelzebubCloud{
 		URI:             uri,
 		AuthToken:       authToken,
 		client:          resty.New(),
 		PollingInterval: 15 * time.Second,
+		stop:            make(chan struct{}),
 	}
-	if enableVerifyConfigurationsChanged {
-		go func() {
-			if err := beelzebubCloud.verifyConfigurationsChanged(); err != nil {
-				log.Fatalf("Error verify configurations changed: %s", err.Error())
-			}
-		}()
+	if onConfigurationsChanged != nil {
+		go beelzebubCloud.verifyConfigurationsChanged(onConfigurationsChanged)
 	}
 	return beelzebubCloud
 }
@@ -157,27 +161,41 @@ func (beelzebubCloud *beelzebubCloud) GetHoneypotsConfigurations() ([]parser.Bee
 	return servicesConfiguration, localHashBuilder.String(), nil
 }
 
-var exitFunction func(code int) = os.Exit
-
-func (beelzebubCloud *beelzebubCloud) verifyConfigurationsChanged() error {
+// verifyConfigurationsChanged polls the honeypots configurations, errors are logged and the polling goes on, so that a flaky link does not stop the running services.
+func (beelzebubCloud *beelzebubCloud) verifyConfigurationsChanged(onConfigurationsChanged ConfigurationsChangedHandler) {
 	var lastConfigurationsHash = ""
 	for {
 		log.Debug("Checking configurations...")
-		_, configurationsHash, err := beelzebubCloud.GetHoneypotsConfigurations()
+		servicesConfiguration, configurationsHash, err := beelzebubCloud.GetHoneypotsConfigurations()
 		if err != nil {
-			return err
-		}
-		if len(lastConfigurationsHash) == 0 {
-			lastConfigurationsHash = configurationsHash
+			log.Errorf("Error verify configurations changed: %s", err.Error())
+		} else {
+			if len(lastConfigurationsHash) == 0 {
+				lastConfigurationsHash = configurationsHash
+			}
+			if lastConfigurationsHash != configurationsHash {
+				log.Debug("Configurations changed.")
+				lastConfigurationsHash = configurationsHash
+				onConfigurationsChanged(servicesConfiguration)
+			}
 		}
-		if lastConfigurationsHash != configurationsHash {
-			log.Debug("Configurations changed.")
-			exitFunction(0)
+		select {
+		case <-beelzebubCloud.stop:
+			return
+		case <-time.After(beelzebubCloud.PollingInterval):
 		}
-		time.Sleep(beelzebubCloud.PollingInterval)
 	}
 }
 
+// Close stops the polling of the honeypots configurations, the handler is not invoked after Close returns unless
+// it was already running.
+func (beelzebubCloud *beelzebubCloud) Close() error {
+	beelzebubCloud.stopOnce.Do(func() {
+		close(beelzebubCloud.stop)
+	})
+	return nil
+}
+
 func (beelzebubCloud *beelzebubCloud) mapToEventDTO(event tracer.Event) (EventDTO, error) {
 	eventDTO := EventDTO{
 		DateTime:        event.DateTime,
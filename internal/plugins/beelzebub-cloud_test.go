@@ -16,7 +16,7 @@ import (
 )
 
 func TestBuildSendEventFailValidation(t *testing.T) {
-	beelzebubCloud := InitBeelzebubCloud("", "", false)
+	beelzebubCloud := InitBeelzebubCloud("", "", nil)
 
 	_, err := beelzebubCloud.SendEvent(tracer.Event{})
 
@@ -41,7 +41,7 @@ func TestBuildSendEventWithResults(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -66,7 +66,7 @@ func TestBuildSendEventErro(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -100,7 +100,7 @@ func TestGetHoneypotsConfigurationsWithResults(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -137,7 +137,7 @@ func TestGetHoneypotsConfigurationsWithResults(t *testing.T) {
 
 func TestGetHoneypotsConfigurationsWithErrorValidation(t *testing.T) {
 	//Given
-	beelzebubCloud := InitBeelzebubCloud("", "", false)
+	beelzebubCloud := InitBeelzebubCloud("", "", nil)
 
 	//When
 	result, _, err := beelzebubCloud.GetHoneypotsConfigurations()
@@ -161,7 +161,7 @@ func TestGetHoneypotsConfigurationsWithErrorAPI(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -190,7 +190,7 @@ func TestGetHoneypotsConfigurationsWithErrorUnmarshal(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -225,7 +225,7 @@ func TestGetHoneypotsConfigurationsWithErrorDeserializeYaml(t *testing.T) {
 		},
 	)
 
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 
 	//When
@@ -268,26 +268,23 @@ func TestVerifyConfigurationsChanged(t *testing.T) {
 		},
 	)
 
-	exitInvoked := false
-	exitCalled := make(chan bool, 1)
+	changed := make(chan []parser.BeelzebubServiceConfiguration, 1)
 
-	exitFunction = func(c int) {
-		exitInvoked = true
-		exitCalled <- true
-	}
-
-	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud(uri, "sdjdnklfjndslkjanfk", nil)
 	beelzebubCloud.client = client
 	beelzebubCloud.PollingInterval = 50 * time.Millisecond
 
-	go beelzebubCloud.verifyConfigurationsChanged()
+	go beelzebubCloud.verifyConfigurationsChanged(func(servicesConfiguration []parser.BeelzebubServiceConfiguration) {
+		changed <- servicesConfiguration
+	})
 
 	select {
-	case <-exitCalled:
-		assert.True(t, exitInvoked)
+	case servicesConfiguration := <-changed:
 		assert.Greater(t, callCount, 1)
+		assert.Len(t, servicesConfiguration, 1)
+		assert.Equal(t, "SSH interactive ChatGPT MODIFIED", servicesConfiguration[0].Description)
 	case <-time.After(2 * time.Second):
-		t.Fatal("timeout waiting for exitFunction")
+		t.Fatal("timeout waiting for configurations changed handler")
 	}
 }
 
@@ -296,7 +293,7 @@ func TestMapToEventDTO_WithHeaders(t *testing.T) {
 		DateTime: "2025-05-01T16:18:13Z",
 		Headers:  `[Key: Content-Type, values: application/json]`,
 	}
-	beelzebubCloud := InitBeelzebubCloud("localhost:8081", "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud("localhost:8081", "sdjdnklfjndslkjanfk", nil)
 	eventDTO, err := beelzebubCloud.mapToEventDTO(event)
 
 	assert.Nil(t, err)
@@ -329,7 +326,7 @@ func TestMapToEventDTO(t *testing.T) {
 		SourcePort:      "12345",
 		TLSServerName:   "beelzebub-honeypot.com",
 	}
-	beelzebubCloud := InitBeelzebubCloud("localhost:8081", "sdjdnklfjndslkjanfk", false)
+	beelzebubCloud := InitBeelzebubCloud("localhost:8081", "sdjdnklfjndslkjanfk", nil)
 	eventDTO, err := beelzebubCloud.mapToEventDTO(event)
 	assert.Nil(t, err)
 
@@ -358,3 +355,22 @@ func TestMapToEventDTO(t *testing.T) {
 		TLSServerName:   "beelzebub-honeypot.com",
 	}, eventDTO)
 }
+
+func TestVerifyConfigurationsChanged_Close(t *testing.T) {
+	beelzebubCloud := InitBeelzebubCloud("", "", nil)
+	beelzebubCloud.PollingInterval = time.Hour
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		beelzebubCloud.verifyConfigurationsChanged(func(servicesConfiguration []parser.BeelzebubServiceConfiguration) {})
+	}()
+	assert.NoError(t, beelzebubCloud.Close())
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the polling to stop")
+	}
+	assert.NoError(t, beelzebubCloud.Close())
+}
@@ -40,5 +40,6 @@ func ConfigFromServiceConf(servConf parser.BeelzebubServiceConfiguration) plugin
 		RateLimitWindowSeconds:  servConf.Plugin.RateLimitWindowSeconds,
 		ServerVersion:           servConf.ServerVersion,
 		ServerName:              servConf.ServerName,
+		FilesystemSnapshot:      servConf.Plugin.FilesystemSnapshot,
 	}
 }
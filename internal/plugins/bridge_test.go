@@ -76,6 +76,7 @@ func TestConfigFromServiceConf(t *testing.T) {
 			RateLimitEnabled:        true,
 			RateLimitRequests:       10,
 			RateLimitWindowSeconds:  60,
+			FilesystemSnapshot:      "/var/lib/beelzebub/rootfs.tar.gz",
 		},
 	}
 
@@ -95,6 +96,7 @@ func TestConfigFromServiceConf(t *testing.T) {
 	assert.Equal(t, 60, cfg.RateLimitWindowSeconds)
 	assert.Equal(t, "Apache/2.4.41", cfg.ServerVersion)
 	assert.Equal(t, "test-server", cfg.ServerName)
+	assert.Equal(t, "/var/lib/beelzebub/rootfs.tar.gz", cfg.FilesystemSnapshot)
 }
 
 func TestConfigFromServiceConf_Defaults(t *testing.T) {
@@ -0,0 +1,471 @@
+package plugins
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+)
+
+const DownloadPluginName = "DownloadHoneypot"
+
+// download is the intent of a command to fetch a remote file, e.g. the dropper of a botnet.
+type download struct {
+	Tool string
+	URL  string
+	// Method is the HTTP method, empty for TFTP and FTP.
+	Method string
+	// OutputFile is the local file the download is saved to, "-" for the standard output.
+	OutputFile string
+}
+
+// emulateDownload emulates a download command, printing the output of a successful download without any network
+// I/O; it returns the downloads found in the command, and false when the command fails.
+func emulateDownload(command shell.Command, now time.Time) (string, []download, bool) {
+	if len(command.Args) == 0 {
+		return "", nil, true
+	}
+	tool := path.Base(command.Args[0])
+	args := command.Args[1:]
+	busybox := false
+	if tool == "busybox" {
+		if len(args) == 0 {
+			return "BusyBox v1.36.1 (2023-11-07 18:53:09 UTC) multi-call binary.", nil, true
+		}
+		tool, args, busybox = args[0], args[1:], true
+	}
+
+	switch tool {
+	case "wget":
+		return emulateWget(args, busybox, now)
+	case "curl":
+		if busybox {
+			return "curl: applet not found", nil, false
+		}
+		return emulateCurl(args)
+	case "tftp":
+		return emulateTFTP(args)
+	case "ftpget":
+		return emulateFTPGet(args)
+	}
+	if busybox {
+		return tool + ": applet not found", nil, false
+	}
+	return tool + ": command not found", nil, false
+}
+
+// wgetOptionsWithArgument are the short options of wget taking an argument.
+const wgetOptionsWithArgument = "OPUoatTwYeiBh"
+
+// wgetLongOptionsWithArgument are the long options of wget taking an argument.
+var wgetLongOptionsWithArgument = map[string]bool{
+	"output-document": true, "directory-prefix": true, "method": true, "post-data": true, "post-file": true,
+	"user-agent": true, "header": true, "tries": true, "timeout": true, "output-file": true, "append-output": true,
+	"body-data": true, "body-file": true, "user": true, "password": true, "referer": true, "execute": true,
+	"input-file": true, "wait": true,
+}
+
+func emulateWget(args []string, busybox bool, now time.Time) (string, []download, bool) {
+	var urls []string
+	var outputFile, prefix string
+	method := "GET"
+	quiet := false
+	setOption := func(name, value string) {
+		switch name {
+		case "O", "output-document":
+			outputFile = value
+		case "P", "directory-prefix":
+			prefix = value
+		case "method":
+			method = strings.ToUpper(value)
+		case "post-data", "post-file":
+			method = "POST"
+		}
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			switch {
+			case wgetLongOptionsWithArgument[name]:
+				if !hasValue && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				setOption(name, value)
+			case name == "quiet":
+				quiet = true
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				option := arg[j]
+				if option == 'q' {
+					quiet = true
+				}
+				if !strings.ContainsRune(wgetOptionsWithArgument, rune(option)) {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				setOption(string(option), value)
+				break
+			}
+		default:
+			urls = append(urls, arg)
+		}
+	}
+
+	if len(urls) == 0 {
+		if busybox {
+			return "BusyBox v1.36.1 (2023-11-07 18:53:09 UTC) multi-call binary.\n\nUsage: wget [-cqS] [--spider] [-O FILE] [-o LOGFILE] [--header STR]\n\t[--post-data STR | --post-file FILE] [-Y on/off]\n\t[-P DIR] [-U AGENT] [-T SEC] URL...", nil, false
+		}
+		return "wget: missing URL\nUsage: wget [OPTION]... [URL]...\n\nTry `wget --help' for more options.", nil, false
+	}
+
+	var downloads []download
+	var output []string
+	for _, rawURL := range urls {
+		if !strings.Contains(rawURL, "://") {
+			rawURL = "http://" + rawURL
+		}
+		name := outputFile
+		if name == "" {
+			name = remoteFileName(rawURL)
+			if prefix != "" {
+				name = path.Join(prefix, name)
+			}
+		}
+		downloads = append(downloads, download{Tool: "wget", URL: rawURL, Method: method, OutputFile: name})
+		if quiet {
+			continue
+		}
+		if busybox {
+			output = append(output, busyboxWgetProgress(rawURL, name))
+		} else {
+			output = append(output, wgetProgress(rawURL, name, now))
+		}
+	}
+	return strings.Join(output, "\n"), downloads, true
+}
+
+// curlOptionsWithArgument are the short options of curl taking an argument.
+const curlOptionsWithArgument = "oXdFAHuemxwTbcrKCEyYzU"
+
+// curlLongOptionsWithArgument are the long options of curl taking an argument.
+var curlLongOptionsWithArgument = map[string]bool{
+	"output": true, "request": true, "data": true, "data-binary": true, "data-raw": true, "data-urlencode": true,
+	"form": true, "user-agent": true, "header": true, "user": true, "referer": true, "max-time": true,
+	"connect-timeout": true, "proxy": true, "retry": true, "write-out": true, "upload-file": true, "cookie": true,
+	"cookie-jar": true, "url": true, "output-dir": true,
+}
+
+func emulateCurl(args []string) (string, []download, bool) {
+	var urls []string
+	var outputFile, method, outputDir string
+	remoteName, silent := false, false
+	setOption := func(name, value string) {
+		switch name {
+		case "o", "output":
+			outputFile = value
+		case "X", "request":
+			method = strings.ToUpper(value)
+		case "d", "F", "data", "data-binary", "data-raw", "data-urlencode", "form":
+			if method == "" {
+				method = "POST"
+			}
+		case "T", "upload-file":
+			if method == "" {
+				method = "PUT"
+			}
+		case "url":
+			urls = append(urls, value)
+		case "output-dir":
+			outputDir = value
+		}
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			switch {
+			case curlLongOptionsWithArgument[name]:
+				if !hasValue && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				setOption(name, value)
+			case name == "remote-name":
+				remoteName = true
+			case name == "silent":
+				silent = true
+			case name == "head":
+				method = "HEAD"
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				option := arg[j]
+				switch option {
+				case 'O':
+					remoteName = true
+				case 's':
+					silent = true
+				case 'I':
+					method = "HEAD"
+				}
+				if !strings.ContainsRune(curlOptionsWithArgument, rune(option)) {
+					continue
+				}
+				value := arg[j+1:]
+				if value == "" && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+				setOption(string(option), value)
+				break
+			}
+		default:
+			urls = append(urls, arg)
+		}
+	}
+
+	if len(urls) == 0 {
+		return "curl: try 'curl --help' or 'curl --manual' for more information", nil, false
+	}
+	if method == "" {
+		method = "GET"
+	}
+
+	var downloads []download
+	var output []string
+	for i, rawURL := range urls {
+		if !strings.Contains(rawURL, "://") {
+			rawURL = "http://" + rawURL
+		}
+		name := "-"
+		switch {
+		case i == 0 && outputFile != "":
+			name = outputFile
+		case remoteName:
+			name = remoteFileName(rawURL)
+		}
+		if name != "-" && outputDir != "" {
+			name = path.Join(outputDir, name)
+		}
+		downloads = append(downloads, download{Tool: "curl", URL: rawURL, Method: method, OutputFile: name})
+		// The progress meter is shown when the body is not written to the terminal.
+		if !silent && name != "-" {
+			output = append(output, curlProgress(rawURL))
+		}
+	}
+	return strings.Join(output, "\n"), downloads, true
+}
+
+// emulateTFTP emulates the BusyBox tftp, "tftp -g -r FILE [-l LOCAL] HOST [PORT]", and the tftp-hpa form
+// "tftp HOST [PORT] -c get FILE [LOCAL]".
+func emulateTFTP(args []string) (string, []download, bool) {
+	var operands []string
+	var remote, local string
+	get := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-g":
+			get = true
+		case (arg == "-r" || arg == "-l" || arg == "-b" || arg == "-m") && i+1 < len(args):
+			i++
+			switch arg {
+			case "-r":
+				remote = args[i]
+			case "-l":
+				local = args[i]
+			}
+		case arg == "-c":
+			if i+2 < len(args) && args[i+1] == "get" {
+				get = true
+				remote = args[i+2]
+				if i+3 < len(args) {
+					local = args[i+3]
+				}
+			}
+			i = len(args)
+		case strings.HasPrefix(arg, "-"):
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	if len(operands) == 0 || (remote == "" && local == "") {
+		return "BusyBox v1.36.1 (2023-11-07 18:53:09 UTC) multi-call binary.\n\nUsage: tftp [OPTIONS] HOST [PORT]", nil, false
+	}
+	if !get {
+		return "", nil, true
+	}
+	if remote == "" {
+		remote = local
+	}
+	if local == "" {
+		local = path.Base(remote)
+	}
+	host := operands[0]
+	if len(operands) > 1 {
+		host = net.JoinHostPort(host, operands[1])
+	}
+	return "", []download{{Tool: "tftp", URL: "tftp://" + host + "/" + strings.TrimPrefix(remote, "/"), OutputFile: local}}, true
+}
+
+// emulateFTPGet emulates the BusyBox ftpget, "ftpget [-u USER] [-p PASS] [-P PORT] HOST [LOCAL_FILE] REMOTE_FILE".
+func emulateFTPGet(args []string) (string, []download, bool) {
+	var operands []string
+	var user, port string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case (arg == "-u" || arg == "-p" || arg == "-P") && i+1 < len(args):
+			i++
+			switch arg {
+			case "-u":
+				user = args[i]
+			case "-P":
+				port = args[i]
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	if len(operands) < 2 {
+		return "BusyBox v1.36.1 (2023-11-07 18:53:09 UTC) multi-call binary.\n\nUsage: ftpget [OPTIONS] HOST [LOCAL_FILE] REMOTE_FILE", nil, false
+	}
+
+	host, remote := operands[0], operands[len(operands)-1]
+	local := path.Base(remote)
+	if len(operands) > 2 {
+		local = operands[1]
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	if user != "" {
+		host = url.User(user).String() + "@" + host
+	}
+	return "", []download{{Tool: "ftpget", URL: "ftp://" + host + "/" + strings.TrimPrefix(remote, "/"), OutputFile: local}}, true
+}
+
+// remoteFileName returns the name a download is saved to by default, the last segment of the URL path.
+func remoteFileName(rawURL string) string {
+	if parsed, err := url.Parse(rawURL); err == nil {
+		if name := path.Base(parsed.Path); name != "/" && name != "." {
+			return name
+		}
+	}
+	return "index.html"
+}
+
+// fakeDownload returns the size and the address of the server of a download, derived from the URL so that the
+// same dropper always looks the same.
+func fakeDownload(rawURL string) (host, address, port string, size int) {
+	hash := fnv.New32a()
+	hash.Write([]byte(rawURL))
+	sum := hash.Sum32()
+	size = 1024 + int(sum%98304)
+
+	port = "80"
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = parsed.Hostname()
+		if parsed.Port() != "" {
+			port = parsed.Port()
+		} else if parsed.Scheme == "https" {
+			port = "443"
+		}
+	}
+	address = host
+	if net.ParseIP(host) == nil {
+		address = fmt.Sprintf("%d.%d.%d.%d", 45+sum%140, sum>>8%256, sum>>16%256, 1+sum>>24%254)
+	}
+	return host, address, port, size
+}
+
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".sh":
+		return "text/x-sh"
+	case ".pl", ".py", ".txt":
+		return "text/plain"
+	case ".html", ".htm":
+		return "text/html"
+	}
+	return "application/octet-stream"
+}
+
+func wgetProgress(rawURL, name string, now time.Time) string {
+	host, address, port, size := fakeDownload(rawURL)
+	timestamp := now.Format("2006-01-02 15:04:05")
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "--%s--  %s\n", timestamp, rawURL)
+	if host == address {
+		fmt.Fprintf(&output, "Connecting to %s:%s... connected.\n", address, port)
+	} else {
+		fmt.Fprintf(&output, "Resolving %s (%s)... %s\n", host, host, address)
+		fmt.Fprintf(&output, "Connecting to %s (%s)|%s|:%s... connected.\n", host, host, address, port)
+	}
+	output.WriteString("HTTP request sent, awaiting response... 200 OK\n")
+	fmt.Fprintf(&output, "Length: %d (%dK) [%s]\n", size, (size+512)/1024, contentType(path.Base(name)))
+	saving := name
+	if name == "-" {
+		saving = "STDOUT"
+	}
+	fmt.Fprintf(&output, "Saving to: ‘%s’\n\n", saving)
+	fmt.Fprintf(&output, "%-20s100%%[===================>] %7.2fK  --.-KB/s    in 0.04s   \n\n", path.Base(saving), float64(size)/1024)
+	if name == "-" {
+		fmt.Fprintf(&output, "%s (%.2f MB/s) - written to stdout [%d/%d]", timestamp, float64(size)/0.04/1024/1024, size, size)
+	} else {
+		fmt.Fprintf(&output, "%s (%.2f MB/s) - ‘%s’ saved [%d/%d]", timestamp, float64(size)/0.04/1024/1024, name, size, size)
+	}
+	return output.String()
+}
+
+func busyboxWgetProgress(rawURL, name string) string {
+	_, address, port, size := fakeDownload(rawURL)
+
+	var output strings.Builder
+	fmt.Fprintf(&output, "Connecting to %s (%s:%s)\n", address, address, port)
+	if name == "-" {
+		output.WriteString("writing to stdout\n")
+	} else {
+		fmt.Fprintf(&output, "saving to '%s'\n", name)
+	}
+	fmt.Fprintf(&output, "%-20s 100%% |%s| %6d  0:00:00 ETA", path.Base(name), strings.Repeat("*", 32), size)
+	if name != "-" {
+		fmt.Fprintf(&output, "\n'%s' saved", name)
+	}
+	return output.String()
+}
+
+func curlProgress(rawURL string) string {
+	_, _, _, size := fakeDownload(rawURL)
+	speed := curlSize(size * 8)
+	return "  % Total    % Received % Xferd  Average Speed   Time    Time     Time  Current\n" +
+		"                                 Dload  Upload   Total   Spent    Left  Speed\n" +
+		fmt.Sprintf("100 %5s  100 %5s    0     0  %5s      0 --:--:-- --:--:-- --:--:-- %5s", curlSize(size), curlSize(size), speed, speed)
+}
+
+// curlSize formats a size the way the curl progress meter does, in at most 5 characters.
+func curlSize(size int) string {
+	switch {
+	case size < 100000:
+		return strconv.Itoa(size)
+	case size < 10000*1024:
+		return strconv.Itoa(size/1024) + "k"
+	default:
+		return fmt.Sprintf("%.1fM", float64(size)/1024/1024)
+	}
+}
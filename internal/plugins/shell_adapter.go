@@ -0,0 +1,39 @@
+package plugins
+
+import (
+	"context"
+
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+)
+
+// shellPlugin is the registry adapter for ShellHoneypot.
+// The sessions are keyed by the session ID of the request, or by protocol and client IP when the protocol has none.
+type shellPlugin struct {
+	shellHoneypot *ShellHoneypot
+}
+
+func (s *shellPlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{
+		Name:        ShellPluginName,
+		Description: "Stateful shell over a per-session virtual filesystem — cd, ls, cat, echo with redirection, mkdir, rm and more",
+		Version:     "1.0.0",
+		Author:      "beelzebub",
+	}
+}
+
+func (s *shellPlugin) Execute(_ context.Context, req plugin.CommandRequest) (string, error) {
+	sessionKey := req.SessionID
+	if sessionKey == "" {
+		sessionKey = req.Protocol + req.ClientIP
+	}
+	return s.shellHoneypot.Execute(sessionKey, req.User, req.Config.ServerName, req.Config.FilesystemSnapshot, req.Command)
+}
+
+// EndSession releases the virtual filesystem of the session.
+func (s *shellPlugin) EndSession(sessionID string) {
+	s.shellHoneypot.EndSession(sessionID)
+}
+
+func init() {
+	plugin.Register(&shellPlugin{shellHoneypot: NewShellHoneypot()})
+}
@@ -0,0 +1,273 @@
+package plugins
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runShell runs the command lines in a single session, and returns the output of the last one.
+func runShell(t *testing.T, shellHoneypot *ShellHoneypot, commandLines ...string) string {
+	var output string
+	for _, commandLine := range commandLines {
+		var err error
+		output, err = shellHoneypot.Execute("session", "root", "web01", "", commandLine)
+		require.NoError(t, err)
+	}
+	return output
+}
+
+func TestShellHoneypot_StatefulFilesystem(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	assert.Equal(t, "/root", runShell(t, shellHoneypot, "pwd"))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "cd /tmp"))
+	assert.Equal(t, "/tmp", runShell(t, shellHoneypot, "pwd"))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "echo foo > x"))
+	assert.Equal(t, "x", runShell(t, shellHoneypot, "ls"))
+	assert.Equal(t, "foo", runShell(t, shellHoneypot, "cat x"))
+	runShell(t, shellHoneypot, "echo 'bar baz' >> /tmp/x")
+	assert.Equal(t, "foo\nbar baz", runShell(t, shellHoneypot, "cat /tmp/x"))
+
+	runShell(t, shellHoneypot, "mkdir -p .cache/bot", "touch .cache/bot/run", "cd .cache")
+	assert.Equal(t, "/tmp/.cache", runShell(t, shellHoneypot, "pwd"))
+	assert.Equal(t, "bot", runShell(t, shellHoneypot, "ls"))
+	runShell(t, shellHoneypot, "cd ..")
+	assert.Equal(t, "x", runShell(t, shellHoneypot, "ls"))
+	assert.Equal(t, ".  ..  .cache  x", runShell(t, shellHoneypot, "ls -a"))
+
+	assert.Equal(t, "rm: cannot remove '.cache': Is a directory", runShell(t, shellHoneypot, "rm .cache"))
+	runShell(t, shellHoneypot, "rm -rf .cache x")
+	assert.Equal(t, ".  ..", runShell(t, shellHoneypot, "ls -a"))
+
+	runShell(t, shellHoneypot, "cd")
+	assert.Equal(t, "/root", runShell(t, shellHoneypot, "pwd"))
+}
+
+func TestShellHoneypot_SessionsAreIsolated(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	_, err := shellHoneypot.Execute("first", "root", "", "", "touch /tmp/dropped")
+	require.NoError(t, err)
+
+	output, err := shellHoneypot.Execute("second", "root", "", "", "ls /tmp/dropped")
+	require.NoError(t, err)
+	assert.Equal(t, "ls: cannot access '/tmp/dropped': No such file or directory", output)
+
+	output, err = shellHoneypot.Execute("first", "root", "", "", "ls /tmp/dropped")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/dropped", output)
+}
+
+func TestShellHoneypot_SessionExpiry(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	runShell(t, shellHoneypot, "touch /tmp/dropped")
+	shellHoneypot.sessions["session"].lastSeen = time.Now().Add(-2 * MaxShellSessionAge)
+	shellHoneypot.lastSweep = time.Time{}
+
+	assert.Equal(t, "ls: cannot access '/tmp/dropped': No such file or directory", runShell(t, shellHoneypot, "ls /tmp/dropped"))
+}
+
+func TestShellHoneypot_EndSession(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	runShell(t, shellHoneypot, "touch /tmp/dropped")
+	shellHoneypot.EndSession("session")
+	assert.Empty(t, shellHoneypot.sessions)
+
+	assert.Equal(t, "ls: cannot access '/tmp/dropped': No such file or directory", runShell(t, shellHoneypot, "ls /tmp/dropped"))
+}
+
+func TestShellHoneypot_Quota(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	runShell(t, shellHoneypot, "echo x > a")
+	var output string
+	for i := 0; i < 64 && output == ""; i++ {
+		output = runShell(t, shellHoneypot, "cat a >> a")
+	}
+	// The file doubles on every command, until the quota of the session stops it.
+	assert.Equal(t, "cat: write error: No space left on device", output)
+	assert.LessOrEqual(t, shellHoneypot.sessions["session"].quota.Used(), MaxShellSessionBytes)
+	assert.Equal(t, shellHoneypot.sessions["session"].quota.Used(), shellHoneypot.quota.Used())
+
+	// Removing the file gives the space back, ending the session releases it.
+	assert.Empty(t, runShell(t, shellHoneypot, "rm a", "echo x > a"))
+	shellHoneypot.EndSession("session")
+	assert.Zero(t, shellHoneypot.quota.Used())
+}
+
+func TestShellHoneypot_MaxSessions(t *testing.T) {
+	defer func(maxShellSessions int) { MaxShellSessions = maxShellSessions }(MaxShellSessions)
+	MaxShellSessions = 2
+	shellHoneypot := NewShellHoneypot()
+
+	for _, sessionKey := range []string{"first", "second", "first", "third"} {
+		_, err := shellHoneypot.Execute(sessionKey, "root", "", "", "touch /tmp/"+sessionKey)
+		require.NoError(t, err)
+	}
+	// The least recently used session is dropped to make room for the third one.
+	assert.Len(t, shellHoneypot.sessions, 2)
+	assert.Contains(t, shellHoneypot.sessions, "first")
+	assert.Contains(t, shellHoneypot.sessions, "third")
+}
+
+func TestShellHoneypot_Errors(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	tests := []struct {
+		commandLine string
+		expected    string
+	}{
+		{"cd /missing", "bash: cd: /missing: No such file or directory"},
+		{"cd /etc/passwd", "bash: cd: /etc/passwd: Not a directory"},
+		{"cd a b", "bash: cd: too many arguments"},
+		{"cat /missing /etc", "cat: /missing: No such file or directory\ncat: /etc: Is a directory"},
+		{"mkdir /tmp", "mkdir: cannot create directory '/tmp': File exists"},
+		{"mkdir /a/b", "mkdir: cannot create directory '/a/b': No such file or directory"},
+		{"rm /missing", "rm: cannot remove '/missing': No such file or directory"},
+		{"rm -f /missing", ""},
+		{"rm -rf /", "rm: it is dangerous to operate recursively on '/'\nrm: use --no-preserve-root to override this failsafe"},
+		{"touch /missing/file", "touch: cannot touch '/missing/file': No such file or directory"},
+		{"chmod 999 /etc/passwd", "chmod: invalid mode: '999'\nTry 'chmod --help' for more information."},
+		{"chmod 755", "chmod: missing operand after '755'\nTry 'chmod --help' for more information."},
+		{"echo x > /missing/file", "bash: /missing/file: No such file or directory"},
+		{"wget http://example.com", "wget: command not found"},
+		{`echo "unterminated`, "bash: unexpected EOF while looking for matching `\"'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.commandLine, func(t *testing.T) {
+			assert.Equal(t, tt.expected, runShell(t, shellHoneypot, tt.commandLine))
+		})
+	}
+}
+
+func TestShellHoneypot_CommandLines(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	assert.Equal(t, "dropped", runShell(t, shellHoneypot, "cd /tmp && echo dropped > .x; cat .x"))
+	assert.Equal(t, "/tmp", runShell(t, shellHoneypot, "pwd"))
+	assert.Equal(t, "fallback", runShell(t, shellHoneypot, "ls /missing 2>/dev/null || echo fallback"))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "ls /missing >/dev/null 2>&1"))
+	assert.Equal(t, "ls: cannot access '/missing': No such file or directory", runShell(t, shellHoneypot, "ls /missing 2>&1 >/dev/null"))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "ls /missing &> log"))
+	assert.Equal(t, "ls: cannot access '/missing': No such file or directory", runShell(t, shellHoneypot, "cat log"))
+	assert.Equal(t, "/tmp/root", runShell(t, shellHoneypot, "mkdir $(whoami) && cd `whoami` && pwd"))
+	assert.Equal(t, "bash: syntax error near unexpected token `;'", runShell(t, shellHoneypot, "; ls"))
+}
+
+func TestShellHoneypot_Builtins(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	assert.Equal(t, "root", runShell(t, shellHoneypot, "whoami"))
+	assert.Equal(t, "uid=0(root) gid=0(root) groups=0(root)", runShell(t, shellHoneypot, "id"))
+	assert.Equal(t, "Linux", runShell(t, shellHoneypot, "uname"))
+	assert.Equal(t, "web01 x86_64", runShell(t, shellHoneypot, "uname -nm"))
+	assert.Equal(t, "Linux web01 5.15.0-91-generic #101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023 x86_64 x86_64 x86_64 GNU/Linux", runShell(t, shellHoneypot, "uname -a"))
+	assert.Equal(t, "web01", runShell(t, shellHoneypot, "cat /etc/hostname"))
+	assert.Equal(t, "a\tb", runShell(t, shellHoneypot, `echo -e "a\tb"`))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "echo -n"))
+	assert.Equal(t, "", runShell(t, shellHoneypot, "ls /missing 2>/dev/null"))
+
+	output, err := shellHoneypot.Execute("other", "admin", "", "", "id")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=1000(admin) gid=1000(admin) groups=1000(admin),4(adm),27(sudo)", output)
+	output, err = shellHoneypot.Execute("other", "admin", "", "", "pwd")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/admin", output)
+}
+
+func TestShellHoneypot_LongListingAndChmod(t *testing.T) {
+	shellHoneypot := NewShellHoneypot()
+
+	runShell(t, shellHoneypot, "echo '#!/bin/sh' > /tmp/run.sh", "chmod +x /tmp/run.sh")
+	listing := runShell(t, shellHoneypot, "ls -l /tmp")
+	lines := strings.Split(listing, "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "total 4", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "-rwxr-xr-x 1 root root 10 "), lines[1])
+	assert.True(t, strings.HasSuffix(lines[1], " run.sh"), lines[1])
+
+	runShell(t, shellHoneypot, "chmod 600 /tmp/run.sh")
+	assert.True(t, strings.HasPrefix(runShell(t, shellHoneypot, "ls -l /tmp/run.sh"), "-rw------- 1 root root 10 "))
+
+	assert.True(t, strings.HasPrefix(runShell(t, shellHoneypot, "ls -ld /tmp"), "drwxrwxrwt 2 root root 4096 "))
+	runShell(t, shellHoneypot, "chmod o-x /tmp")
+	assert.True(t, strings.HasPrefix(runShell(t, shellHoneypot, "ls -ld /tmp"), "drwxrwxrwT 2 root root 4096 "))
+}
+
+func TestParseChmodMode(t *testing.T) {
+	tests := []struct {
+		spec     string
+		current  fs.FileMode
+		expected fs.FileMode
+		ok       bool
+	}{
+		{"755", 0644, 0755, true},
+		{"+x", 0644, 0755, true},
+		{"u+x", 0644, 0744, true},
+		{"go-r", 0644, 0600, true},
+		{"a=r", 0755, 0444, true},
+		{"u+x,g+w", 0644, 0764, true},
+		{"1777", 0755, fs.ModeSticky | 0777, true},
+		{"o-x", fs.ModeSticky | 0777, fs.ModeSticky | 0776, true},
+		{"z+x", 0644, 0, false},
+		{"u+q", 0644, 0, false},
+		{"17777", 0644, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			mode, ok := parseChmodMode(tt.spec, tt.current)
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				assert.Equal(t, tt.expected, mode)
+			}
+		})
+	}
+}
+
+func TestShellHoneypot_Snapshot(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "var", "www"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "var", "www", "config.php"), []byte("<?php $db_pass = 'secret';"), 0644))
+
+	shellHoneypot := NewShellHoneypot()
+	output, err := shellHoneypot.Execute("session", "root", "", root, "cat /var/www/config.php")
+	require.NoError(t, err)
+	assert.Equal(t, "<?php $db_pass = 'secret';", output)
+
+	_, err = shellHoneypot.Execute("missing", "root", "", filepath.Join(root, "missing.tar"), "ls")
+	assert.Error(t, err)
+}
+
+func TestShellPlugin_Registered(t *testing.T) {
+	commandPlugin, ok := plugin.GetCommand(ShellPluginName)
+	require.True(t, ok)
+
+	request := plugin.CommandRequest{Command: "cd /tmp", SessionID: t.Name(), Protocol: "ssh", User: "root"}
+	_, err := commandPlugin.Execute(context.Background(), request)
+	require.NoError(t, err)
+
+	request.Command = "pwd"
+	output, err := commandPlugin.Execute(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp", output)
+
+	sessionEndPlugin, ok := commandPlugin.(plugin.SessionEndPlugin)
+	require.True(t, ok)
+	sessionEndPlugin.EndSession(t.Name())
+	output, err = commandPlugin.Execute(context.Background(), request)
+	require.NoError(t, err)
+	assert.Equal(t, "/root", output, "the session starts over once ended")
+}
@@ -2,6 +2,9 @@
 package protocols
 
 import (
+	"context"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 )
@@ -9,11 +12,16 @@ import (
 // ServiceStrategy is the common interface that each protocol honeypot implements
 type ServiceStrategy interface {
 	Init(beelzebubServiceConfiguration parser.BeelzebubServiceConfiguration, tracer tracer.Tracer) error
+	// Shutdown stops accepting new connections and waits for the in-flight sessions to terminate.
+	// When ctx expires, the remaining sessions are forcibly closed.
+	Shutdown(ctx context.Context) error
 }
 
 type ProtocolManager struct {
 	strategy ServiceStrategy
 	tracer   tracer.Tracer
+	// metrics is nil without metrics.
+	metrics *metrics.Metrics
 }
 
 // InitProtocolManager is the method that initializes the protocol manager, receving the concrete tracer and the concrete service
@@ -28,7 +36,38 @@ func (pm *ProtocolManager) SetProtocolStrategy(strategy ServiceStrategy) {
 	pm.strategy = strategy
 }
 
-// InitService is the method that initializes the honeypot
+// SetMetrics records the metrics of the services initialized afterwards.
+func (pm *ProtocolManager) SetMetrics(metrics *metrics.Metrics) {
+	pm.metrics = metrics
+}
+
+// InitService is the method that initializes the honeypot, its events carry the address of the service
 func (pm *ProtocolManager) InitService(beelzebubServiceConfiguration parser.BeelzebubServiceConfiguration) error {
-	return pm.strategy.Init(beelzebubServiceConfiguration, pm.tracer)
+	return pm.strategy.Init(beelzebubServiceConfiguration, serviceTracer{
+		Tracer:  pm.tracer,
+		address: beelzebubServiceConfiguration.Address,
+		metrics: pm.metrics.Service(beelzebubServiceConfiguration.Description, beelzebubServiceConfiguration.Address, beelzebubServiceConfiguration.Protocol),
+	})
+}
+
+// serviceTracer stamps the events with the address of the service which traced them, so that the sinks can filter them,
+// and counts them in the metrics of the service.
+type serviceTracer struct {
+	tracer.Tracer
+	address string
+	metrics *metrics.Service
+}
+
+func (serviceTracer serviceTracer) TraceEvent(event tracer.Event) {
+	event.ServiceAddress = serviceTracer.address
+	serviceTracer.metrics.ObserveEvent(event.Status, event.Handler)
+	serviceTracer.Tracer.TraceEvent(event)
+}
+
+// ServiceMetrics returns the metrics of the service of a tracer given to ServiceStrategy.Init, nil without metrics.
+func ServiceMetrics(tr tracer.Tracer) *metrics.Service {
+	if serviceTracer, ok := tr.(serviceTracer); ok {
+		return serviceTracer.metrics
+	}
+	return nil
 }
@@ -1,10 +1,15 @@
 package protocols
 
 import (
+	"context"
 	"errors"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +20,10 @@ func (mockServiceStrategy mockServiceStrategyValid) Init(beelzebubServiceConfigu
 	return nil
 }
 
+func (mockServiceStrategy mockServiceStrategyValid) Shutdown(ctx context.Context) error {
+	return nil
+}
+
 type mockServiceStrategyError struct {
 }
 
@@ -22,6 +31,10 @@ func (mockServiceStrategy mockServiceStrategyError) Init(beelzebubServiceConfigu
 	return errors.New("mockError")
 }
 
+func (mockServiceStrategy mockServiceStrategyError) Shutdown(ctx context.Context) error {
+	return nil
+}
+
 func TestInitServiceManager(t *testing.T) {
 	mockTraceStrategy := func(event tracer.Event) {}
 
@@ -92,3 +105,68 @@ func (r *recorderStrategy) Init(conf parser.BeelzebubServiceConfiguration, _ tra
 	*r.captured = conf
 	return nil
 }
+
+func (r *recorderStrategy) Shutdown(_ context.Context) error {
+	return nil
+}
+
+type tracerStrategy struct {
+	tracer tracer.Tracer
+}
+
+func (s *tracerStrategy) Init(_ parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
+	s.tracer = tr
+	return nil
+}
+
+func (s *tracerStrategy) Shutdown(_ context.Context) error {
+	return nil
+}
+
+type mockTracer struct {
+	events []tracer.Event
+}
+
+func (m *mockTracer) TraceEvent(event tracer.Event) {
+	m.events = append(m.events, event)
+}
+
+func TestInitService_StampsServiceAddress(t *testing.T) {
+	strategy := &tracerStrategy{}
+	mock := &mockTracer{}
+	protocolManager := &ProtocolManager{tracer: mock, strategy: strategy}
+
+	assert.NoError(t, protocolManager.InitService(parser.BeelzebubServiceConfiguration{Address: ":2222", Protocol: "ssh"}))
+	strategy.tracer.TraceEvent(tracer.Event{ID: "1"})
+
+	assert.Equal(t, []tracer.Event{{ID: "1", ServiceAddress: ":2222"}}, mock.events)
+}
+
+func TestInitService_Metrics(t *testing.T) {
+	strategy := &tracerStrategy{}
+	mock := &mockTracer{}
+	registry := prometheus.NewRegistry()
+	protocolManager := &ProtocolManager{tracer: mock, strategy: strategy}
+	protocolManager.SetMetrics(metrics.New(registry))
+
+	assert.NoError(t, protocolManager.InitService(parser.BeelzebubServiceConfiguration{Description: "honeypot", Address: ":2222", Protocol: "ssh"}))
+	strategy.tracer.TraceEvent(tracer.Event{ID: "1", Status: tracer.Start.String()})
+	assert.NotNil(t, ServiceMetrics(strategy.tracer))
+	assert.Nil(t, ServiceMetrics(mock))
+
+	expected := `
+# HELP beelzebub_service_events_total The total number of events, by service, status and handler
+# TYPE beelzebub_service_events_total counter
+beelzebub_service_events_total{address=":2222",handler="",protocol="ssh",service="honeypot",status="Start"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "beelzebub_service_events_total"))
+}
+
+func TestInitService_WithoutMetrics(t *testing.T) {
+	strategy := &tracerStrategy{}
+	protocolManager := &ProtocolManager{tracer: &mockTracer{}, strategy: strategy}
+
+	assert.NoError(t, protocolManager.InitService(parser.BeelzebubServiceConfiguration{Address: ":2222", Protocol: "ssh"}))
+	assert.Nil(t, ServiceMetrics(strategy.tracer))
+	assert.NotPanics(t, func() { strategy.tracer.TraceEvent(tracer.Event{ID: "1"}) })
+}
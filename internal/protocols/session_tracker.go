@@ -0,0 +1,72 @@
+package protocols
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// SessionTracker keeps track of the in-flight connections of a service, so that they can be drained on shutdown.
+// The zero value is ready to use.
+type SessionTracker struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	conns    map[io.Closer]struct{}
+	draining bool
+}
+
+// Track registers a connection whose handler is about to start.
+// It returns false when the tracker is draining, in which case the connection must be refused.
+func (st *SessionTracker) Track(conn io.Closer) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.draining {
+		return false
+	}
+	if st.conns == nil {
+		st.conns = make(map[io.Closer]struct{})
+	}
+	st.conns[conn] = struct{}{}
+	st.wg.Add(1)
+	return true
+}
+
+// Untrack marks the handler of the connection as terminated.
+func (st *SessionTracker) Untrack(conn io.Closer) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.conns[conn]; !ok {
+		return
+	}
+	delete(st.conns, conn)
+	st.wg.Done()
+}
+
+// Drain refuses new connections and waits for the tracked handlers to terminate.
+// If ctx expires first, the remaining connections are closed, Drain waits for their handlers to return, and ctx.Err() is returned.
+func (st *SessionTracker) Drain(ctx context.Context) error {
+	st.mu.Lock()
+	st.draining = true
+	st.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		st.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	st.mu.Lock()
+	for conn := range st.conns {
+		conn.Close()
+	}
+	st.mu.Unlock()
+
+	<-done
+	return ctx.Err()
+}
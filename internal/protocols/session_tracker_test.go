@@ -0,0 +1,65 @@
+package protocols
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockConn struct {
+	closed chan struct{}
+}
+
+func newMockConn() *mockConn {
+	return &mockConn{closed: make(chan struct{})}
+}
+
+func (m *mockConn) Close() error {
+	close(m.closed)
+	return nil
+}
+
+func TestSessionTracker_DrainWaitsHandlers(t *testing.T) {
+	var tracker SessionTracker
+	conn := newMockConn()
+
+	assert.True(t, tracker.Track(conn))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		tracker.Untrack(conn)
+	}()
+
+	assert.NoError(t, tracker.Drain(context.Background()))
+
+	select {
+	case <-conn.closed:
+		t.Fatal("connection must not be closed when the handler terminates within the grace period")
+	default:
+	}
+}
+
+func TestSessionTracker_DrainClosesConnectionsOnTimeout(t *testing.T) {
+	var tracker SessionTracker
+	conn := newMockConn()
+
+	assert.True(t, tracker.Track(conn))
+	go func() {
+		// The handler terminates as soon as its connection is closed.
+		<-conn.closed
+		tracker.Untrack(conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, tracker.Drain(ctx), context.DeadlineExceeded)
+}
+
+func TestSessionTracker_RefusesWhileDraining(t *testing.T) {
+	var tracker SessionTracker
+
+	assert.NoError(t, tracker.Drain(context.Background()))
+	assert.False(t, tracker.Track(newMockConn()))
+}
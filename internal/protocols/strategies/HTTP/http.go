@@ -2,14 +2,18 @@ package HTTP
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
 
@@ -17,7 +21,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type HTTPStrategy struct{}
+type HTTPStrategy struct {
+	mu      sync.Mutex
+	servers []*http.Server
+}
 
 type httpResponse struct {
 	StatusCode int
@@ -25,7 +32,7 @@ type httpResponse struct {
 	Body       string
 }
 
-func (httpStrategy HTTPStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
+func (httpStrategy *HTTPStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
 	serverMux := http.NewServeMux()
 
 	serverMux.HandleFunc("/", func(responseWriter http.ResponseWriter, request *http.Request) {
@@ -62,17 +69,22 @@ func (httpStrategy HTTPStrategy) Init(servConf parser.BeelzebubServiceConfigurat
 		fmt.Fprint(responseWriter, resp.Body)
 
 	})
+	server := &http.Server{Addr: servConf.Address, Handler: serverMux}
+	httpStrategy.mu.Lock()
+	httpStrategy.servers = append(httpStrategy.servers, server)
+	httpStrategy.mu.Unlock()
+
 	go func() {
 		var err error
 		// Launch a TLS supporting server if we are supplied a TLS Key and Certificate.
 		// If relative paths are supplied, they are relative to the CWD of the binary.
 		// The can be self-signed, only the client will validate this (or not).
 		if servConf.TLSKeyPath != "" && servConf.TLSCertPath != "" {
-			err = http.ListenAndServeTLS(servConf.Address, servConf.TLSCertPath, servConf.TLSKeyPath, serverMux)
+			err = server.ListenAndServeTLS(servConf.TLSCertPath, servConf.TLSKeyPath)
 		} else {
-			err = http.ListenAndServe(servConf.Address, serverMux)
+			err = server.ListenAndServe()
 		}
-		if err != nil {
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Errorf("error during init HTTP Protocol: %v", err)
 			return
 		}
@@ -85,6 +97,23 @@ func (httpStrategy HTTPStrategy) Init(servConf parser.BeelzebubServiceConfigurat
 	return nil
 }
 
+// Shutdown gracefully stops the HTTP servers, in-flight requests are forcibly closed when ctx expires.
+func (httpStrategy *HTTPStrategy) Shutdown(ctx context.Context) error {
+	httpStrategy.mu.Lock()
+	servers := httpStrategy.servers
+	httpStrategy.servers = nil
+	httpStrategy.mu.Unlock()
+
+	var err error
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(ctx); shutdownErr != nil {
+			server.Close()
+			err = shutdownErr
+		}
+	}
+	return err
+}
+
 func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer, command parser.Command, request *http.Request) (httpResponse, error) {
 	resp := httpResponse{
 		Body:       command.Handler,
@@ -101,16 +130,22 @@ func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.
 	traceRequest(request, tr, command, servConf.Description, body, servConf.TrustedProxiesNets)
 
 	if command.Plugin != "" {
-		host, _ := realClientAddr(request, servConf.TrustedProxiesNets)
+		host, port := realClientAddr(request, servConf.TrustedProxiesNets)
+		serviceMetrics := protocols.ServiceMetrics(tr)
+		started := time.Now()
 
 		if cp, ok := plugin.GetCommand(command.Plugin); ok {
 			cmd := fmt.Sprintf("Method: %s, RequestURI: %s, Body: %s", request.Method, request.RequestURI, body)
-			output, err := cp.Execute(context.Background(), plugin.CommandRequest{
-				Command:  cmd,
-				ClientIP: host,
-				Protocol: "http",
-				Config:   plugins.ConfigFromServiceConf(servConf),
+			output, err := cp.Execute(tracer.NewContext(context.Background(), tr), plugin.CommandRequest{
+				Command:     cmd,
+				ClientIP:    host,
+				RemoteAddr:  request.RemoteAddr,
+				SourcePort:  port,
+				Description: servConf.Description,
+				Protocol:    "http",
+				Config:      plugins.ConfigFromServiceConf(servConf),
 			})
+			serviceMetrics.ObservePlugin(command.Plugin, started, err)
 			if err != nil {
 				resp.Body = "404 Not Found!"
 				return resp, fmt.Errorf("plugin %q execute error: %w", command.Plugin, err)
@@ -141,6 +176,7 @@ func buildHTTPResponse(servConf parser.BeelzebubServiceConfiguration, tr tracer.
 					resp.Headers = append(resp.Headers, fmt.Sprintf("%s: %s", k, v))
 				}
 			}
+			serviceMetrics.ObservePlugin(command.Plugin, started, nil)
 		} else {
 			log.Warnf("unknown plugin %q, skipping", command.Plugin)
 		}
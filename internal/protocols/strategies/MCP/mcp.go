@@ -2,7 +2,10 @@ package MCP
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 	"github.com/google/uuid"
@@ -16,6 +19,8 @@ import (
 type remoteAddrCtxKey struct{}
 
 type MCPStrategy struct {
+	mu      sync.Mutex
+	servers []*server.StreamableHTTPServer
 }
 
 func (mcpStrategy *MCPStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
@@ -86,14 +91,18 @@ func (mcpStrategy *MCPStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 		})
 	}
 
+	httpServer := server.NewStreamableHTTPServer(
+		mcpServer,
+		server.WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			return context.WithValue(ctx, remoteAddrCtxKey{}, r.RemoteAddr)
+		}),
+	)
+	mcpStrategy.mu.Lock()
+	mcpStrategy.servers = append(mcpStrategy.servers, httpServer)
+	mcpStrategy.mu.Unlock()
+
 	go func() {
-		httpServer := server.NewStreamableHTTPServer(
-			mcpServer,
-			server.WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
-				return context.WithValue(ctx, remoteAddrCtxKey{}, r.RemoteAddr)
-			}),
-		)
-		if err := httpServer.Start(servConf.Address); err != nil {
+		if err := httpServer.Start(servConf.Address); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Errorf("Failed to start MCP server on %s: %v", servConf.Address, err)
 			return
 		}
@@ -104,3 +113,19 @@ func (mcpStrategy *MCPStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 	}).Infof("Init service %s", servConf.Protocol)
 	return nil
 }
+
+// Shutdown gracefully stops the MCP servers.
+func (mcpStrategy *MCPStrategy) Shutdown(ctx context.Context) error {
+	mcpStrategy.mu.Lock()
+	servers := mcpStrategy.servers
+	mcpStrategy.servers = nil
+	mcpStrategy.mu.Unlock()
+
+	var err error
+	for _, httpServer := range servers {
+		if shutdownErr := httpServer.Shutdown(ctx); shutdownErr != nil {
+			err = shutdownErr
+		}
+	}
+	return err
+}
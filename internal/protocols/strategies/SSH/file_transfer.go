@@ -0,0 +1,291 @@
+package SSH
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/quarantine"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/vfs"
+
+	"github.com/gliderlabs/ssh"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultMaxUploadSizeBytes is used when fileTransfer.maxUploadSizeBytes is not set.
+	defaultMaxUploadSizeBytes = 64 << 20
+	// defaultMaxConnectionUploadBytes is used when fileTransfer.maxConnectionUploadBytes is not set.
+	defaultMaxConnectionUploadBytes = 256 << 20
+	// defaultMaxTotalUploadBytes is used when fileTransfer.maxTotalUploadBytes is not set.
+	defaultMaxTotalUploadBytes = 1 << 30
+)
+
+var (
+	errUploadTooLarge      = errors.New("file too large")
+	errUploadQuotaExceeded = errors.New("disk quota exceeded")
+)
+
+type filesystemContextKey struct{}
+
+// fileTransfer serves the SFTP subsystem and the scp command from an in-memory filesystem seeded with the decoy files,
+// the uploaded files are quarantined and never written to the in-memory filesystem of another connection.
+type fileTransfer struct {
+	template      *vfs.FS
+	quarantineDir string
+	maxUploadSize int64
+	// maxConnectionUpload bounds the uploads kept in the filesystem of a connection, quota bounds those of every
+	// connection; an upload is refused before its memory is allocated.
+	maxConnectionUpload int64
+	quota               *uploadQuota
+	description         string
+	tr                  tracer.Tracer
+}
+
+func newFileTransfer(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) (*fileTransfer, error) {
+	template := vfs.New()
+	for _, dir := range []string{"/root", "/home", "/tmp"} {
+		if err := template.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	for _, decoy := range servConf.FileTransfer.DecoyFiles {
+		content := []byte(decoy.Content)
+		if decoy.Source != "" {
+			var err error
+			if content, err = os.ReadFile(decoy.Source); err != nil {
+				return nil, fmt.Errorf("error during read decoy file %s: %w", decoy.Source, err)
+			}
+		}
+		if err := template.MkdirAll(path.Dir(vfs.Clean(decoy.Path)), 0755); err != nil {
+			return nil, fmt.Errorf("error during create decoy file %s: %w", decoy.Path, err)
+		}
+		if err := template.WriteFile(decoy.Path, content, 0644); err != nil {
+			return nil, fmt.Errorf("error during create decoy file %s: %w", decoy.Path, err)
+		}
+	}
+
+	maxUploadSize := servConf.FileTransfer.MaxUploadSizeBytes
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSizeBytes
+	}
+	maxConnectionUpload := servConf.FileTransfer.MaxConnectionUploadBytes
+	if maxConnectionUpload <= 0 {
+		maxConnectionUpload = defaultMaxConnectionUploadBytes
+	}
+	maxTotalUpload := servConf.FileTransfer.MaxTotalUploadBytes
+	if maxTotalUpload <= 0 {
+		maxTotalUpload = defaultMaxTotalUploadBytes
+	}
+	return &fileTransfer{
+		template:            template,
+		quarantineDir:       servConf.FileTransfer.QuarantineDir,
+		maxUploadSize:       maxUploadSize,
+		maxConnectionUpload: maxConnectionUpload,
+		quota:               &uploadQuota{limit: maxTotalUpload},
+		description:         servConf.Description,
+		tr:                  tr,
+	}, nil
+}
+
+// connectionFiles is the in-memory filesystem of a connection, with the quota of its uploads.
+type connectionFiles struct {
+	fsys  *vfs.FS
+	quota *uploadQuota
+}
+
+// filesystem returns the in-memory filesystem of the connection, so that a file uploaded over SFTP is visible to scp,
+// and the quota of its uploads, which is given back to the service once the connection is closed.
+func (ft *fileTransfer) filesystem(ctx ssh.Context) (*vfs.FS, *uploadQuota) {
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	if files, ok := ctx.Value(filesystemContextKey{}).(*connectionFiles); ok {
+		return files.fsys, files.quota
+	}
+	files := &connectionFiles{
+		fsys:  ft.template.Clone(),
+		quota: &uploadQuota{limit: ft.maxConnectionUpload, parent: ft.quota},
+	}
+	if err := files.fsys.MkdirAll(homeDirectory(ctx.User()), 0755); err != nil {
+		log.Warnf("error during create home directory: %s", err.Error())
+	}
+	ctx.SetValue(filesystemContextKey{}, files)
+	go func() {
+		<-ctx.Done()
+		files.quota.releaseAll()
+	}()
+	return files.fsys, files.quota
+}
+
+// uploadQuota bounds the bytes of the uploads held in memory, a reservation counts against the parent quota too.
+type uploadQuota struct {
+	mu     sync.Mutex
+	used   int64
+	limit  int64
+	parent *uploadQuota
+}
+
+// reserve accounts n more bytes, or returns errUploadQuotaExceeded when they would exceed a limit.
+func (quota *uploadQuota) reserve(n int64) error {
+	if quota == nil {
+		return nil
+	}
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+
+	if quota.used+n > quota.limit {
+		return errUploadQuotaExceeded
+	}
+	if err := quota.parent.reserve(n); err != nil {
+		return err
+	}
+	quota.used += n
+	return nil
+}
+
+func (quota *uploadQuota) release(n int64) {
+	if quota == nil {
+		return
+	}
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+
+	quota.used -= n
+	quota.parent.release(n)
+}
+
+func (quota *uploadQuota) releaseAll() {
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+
+	quota.parent.release(quota.used)
+	quota.used = 0
+}
+
+func homeDirectory(user string) string {
+	if user == "" || user == "root" {
+		return "/root"
+	}
+	return path.Join("/home", path.Base(vfs.Clean(user)))
+}
+
+// resolvePath returns the absolute path of name, relative paths are resolved from the home directory.
+func resolvePath(user, name string) string {
+	if path.IsAbs(name) {
+		return vfs.Clean(name)
+	}
+	return vfs.Clean(path.Join(homeDirectory(user), name))
+}
+
+// traceSession traces the start or the end of a file transfer session.
+func (ft *fileTransfer) traceSession(sess ssh.Session, sessionID, msg string, status tracer.Status) {
+	host, port, _ := net.SplitHostPort(sess.RemoteAddr().String())
+
+	ft.tr.TraceEvent(tracer.Event{
+		Msg:         msg,
+		Protocol:    tracer.SSH.String(),
+		RemoteAddr:  sess.RemoteAddr().String(),
+		SourceIp:    host,
+		SourcePort:  port,
+		Status:      status.String(),
+		ID:          sessionID,
+		User:        sess.User(),
+		Command:     sess.RawCommand(),
+		Description: ft.description,
+	})
+}
+
+// upload quarantines and traces a file uploaded by the client.
+func (ft *fileTransfer) upload(sess ssh.Session, sessionID, transfer, name string, data []byte) {
+	sample, err := quarantine.Store(ft.quarantineDir, data)
+	if err != nil {
+		log.Errorf("error during quarantine upload %s: %s", name, err.Error())
+	}
+	host, port, _ := net.SplitHostPort(sess.RemoteAddr().String())
+
+	ft.tr.TraceEvent(tracer.Event{
+		Msg:         fmt.Sprintf("SSH %s File Upload", transfer),
+		Protocol:    tracer.SSH.String(),
+		RemoteAddr:  sess.RemoteAddr().String(),
+		SourceIp:    host,
+		SourcePort:  port,
+		Status:      tracer.Interaction.String(),
+		ID:          sessionID,
+		User:        sess.User(),
+		Description: ft.description,
+		FileName:    name,
+		FileSize:    sample.Size,
+		FileSHA256:  sample.SHA256,
+		FileSHA1:    sample.SHA1,
+		FileMD5:     sample.MD5,
+	})
+}
+
+// download traces a file downloaded by the client.
+func (ft *fileTransfer) download(sess ssh.Session, sessionID, transfer, name string, size int64) {
+	host, port, _ := net.SplitHostPort(sess.RemoteAddr().String())
+
+	ft.tr.TraceEvent(tracer.Event{
+		Msg:         fmt.Sprintf("SSH %s File Download", transfer),
+		Protocol:    tracer.SSH.String(),
+		RemoteAddr:  sess.RemoteAddr().String(),
+		SourceIp:    host,
+		SourcePort:  port,
+		Status:      tracer.Interaction.String(),
+		ID:          sessionID,
+		User:        sess.User(),
+		Description: ft.description,
+		FileName:    name,
+		FileSize:    size,
+	})
+}
+
+// uploadBuffer collects a file written at arbitrary offsets, up to limit bytes; it grows only once quota accounts
+// for the new bytes.
+type uploadBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	limit   int64
+	quota   *uploadQuota
+	closed  bool
+	onClose func(data []byte) error
+}
+
+func (buffer *uploadBuffer) WriteAt(p []byte, off int64) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	end := off + int64(len(p))
+	if end > buffer.limit {
+		return 0, errUploadTooLarge
+	}
+	if growth := end - int64(len(buffer.data)); growth > 0 {
+		if err := buffer.quota.reserve(growth); err != nil {
+			return 0, err
+		}
+		buffer.data = append(buffer.data, make([]byte, growth)...)
+	}
+	copy(buffer.data[off:], p)
+	return len(p), nil
+}
+
+// Close hands over the content, also when the upload was interrupted, so that partial payloads are captured too.
+func (buffer *uploadBuffer) Close() error {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	if buffer.closed {
+		return nil
+	}
+	buffer.closed = true
+	return buffer.onClose(buffer.data)
+}
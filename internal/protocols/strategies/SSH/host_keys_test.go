@@ -0,0 +1,70 @@
+package SSH
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestLoadHostSigners_GeneratesAndPersists(t *testing.T) {
+	dir := t.TempDir()
+	hostKeys := parser.SSHHostKeys{
+		RSA:     filepath.Join(dir, "keys", "ssh_host_rsa_key"),
+		ECDSA:   filepath.Join(dir, "keys", "ssh_host_ecdsa_key"),
+		Ed25519: filepath.Join(dir, "keys", "ssh_host_ed25519_key"),
+	}
+
+	first, err := loadHostSigners(hostKeys)
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+	assert.Equal(t, gossh.KeyAlgoRSA, first[0].PublicKey().Type())
+	assert.Equal(t, gossh.KeyAlgoECDSA256, first[1].PublicKey().Type())
+	assert.Equal(t, gossh.KeyAlgoED25519, first[2].PublicKey().Type())
+
+	info, err := os.Stat(hostKeys.Ed25519)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The persisted keys are reused, so the fingerprints are stable across restarts.
+	second, err := loadHostSigners(hostKeys)
+	require.NoError(t, err)
+	for i := range first {
+		assert.Equal(t, gossh.FingerprintSHA256(first[i].PublicKey()), gossh.FingerprintSHA256(second[i].PublicKey()))
+	}
+}
+
+func TestLoadHostSigners_NoKeys(t *testing.T) {
+	signers, err := loadHostSigners(parser.SSHHostKeys{})
+	assert.NoError(t, err)
+	assert.Empty(t, signers)
+}
+
+func TestLoadHostSigners_InvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh_host_ed25519_key")
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0600))
+
+	_, err := loadHostSigners(parser.SSHHostKeys{Ed25519: path})
+	assert.ErrorContains(t, err, path)
+}
+
+func TestBuildAlgorithmsConfig(t *testing.T) {
+	config, err := buildAlgorithmsConfig(parser.SSHAlgorithms{
+		KeyExchanges: []string{"curve25519-sha256"},
+		Ciphers:      []string{"aes128-ctr", "arcfour256"},
+		MACs:         []string{"hmac-sha2-256"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"curve25519-sha256"}, config.KeyExchanges)
+	assert.Equal(t, []string{"aes128-ctr", "arcfour256"}, config.Ciphers)
+	assert.Equal(t, []string{"hmac-sha2-256"}, config.MACs)
+}
+
+func TestBuildAlgorithmsConfig_Unsupported(t *testing.T) {
+	_, err := buildAlgorithmsConfig(parser.SSHAlgorithms{Ciphers: []string{"rot13"}})
+	assert.ErrorContains(t, err, `unsupported cipher algorithm "rot13"`)
+}
@@ -0,0 +1,77 @@
+package SSH
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultKeyboardInteractivePrompt mimics the password prompt of OpenSSH with PAM.
+const defaultKeyboardInteractivePrompt = "Password: "
+
+// buildKeyboardInteractiveHandler asks the configured prompts one per round, tracing every answer,
+// the client is authenticated when every answer matches the regex of its prompt.
+func buildKeyboardInteractiveHandler(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) (ssh.KeyboardInteractiveHandler, error) {
+	prompts := servConf.KeyboardInteractive.Prompts
+	if len(prompts) == 0 {
+		prompts = []parser.SSHPrompt{{Prompt: defaultKeyboardInteractivePrompt, Regex: servConf.PasswordRegex}}
+	}
+
+	regexes := make([]*regexp.Regexp, len(prompts))
+	for i, prompt := range prompts {
+		if prompt.Regex == "" {
+			continue
+		}
+		rex, err := regexp.Compile(prompt.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyboard-interactive regex %q: %w", prompt.Regex, err)
+		}
+		regexes[i] = rex
+	}
+	serviceMetrics := protocols.ServiceMetrics(tr)
+
+	return func(ctx ssh.Context, challenger gossh.KeyboardInteractiveChallenge) bool {
+		host, port, _ := net.SplitHostPort(ctx.RemoteAddr().String())
+		instruction := servConf.KeyboardInteractive.Instruction
+
+		accepted := true
+		for i, prompt := range prompts {
+			answers, err := challenger(ctx.User(), instruction, []string{prompt.Prompt}, []bool{prompt.Echo})
+			if err != nil || len(answers) != 1 {
+				return false
+			}
+			// The instruction is shown once, before the first prompt.
+			instruction = ""
+
+			tr.TraceEvent(tracer.Event{
+				Msg:         "New SSH Keyboard-Interactive Login Attempt",
+				Protocol:    tracer.SSH.String(),
+				Status:      tracer.Stateless.String(),
+				User:        ctx.User(),
+				Password:    answers[0],
+				Prompt:      prompt.Prompt,
+				Client:      ctx.ClientVersion(),
+				RemoteAddr:  ctx.RemoteAddr().String(),
+				SourceIp:    host,
+				SourcePort:  port,
+				ID:          connectionID(ctx),
+				Description: servConf.Description,
+			})
+
+			if regexes[i] != nil && !regexes[i].MatchString(answers[0]) {
+				accepted = false
+			}
+		}
+		// An attempt is counted once every prompt is answered.
+		serviceMetrics.AuthAttempt(metrics.KeyboardInteractiveAuth, accepted)
+		return accepted
+	}, nil
+}
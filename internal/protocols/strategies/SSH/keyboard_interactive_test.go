@@ -0,0 +1,119 @@
+package SSH
+
+import (
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestSSHStrategy_KeyboardInteractive_MultiStepLogin(t *testing.T) {
+	mt := &mockTracer{}
+
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PasswordRegex:          "^root$",
+		KeyboardInteractive: parser.SSHKeyboardInteractive{
+			Enabled:     true,
+			Instruction: "Two-factor authentication required",
+			Prompts: []parser.SSHPrompt{
+				{Prompt: "Password: ", Regex: "^toor$"},
+				{Prompt: "Verification code: ", Echo: true, Regex: "^[0-9]{6}$"},
+			},
+		},
+	}, mt)
+
+	var questions []string
+	var instructions []string
+	client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+		User: "root",
+		Auth: []gossh.AuthMethod{
+			gossh.Password("wrong"),
+			gossh.KeyboardInteractive(func(_, instruction string, prompts []string, echos []bool) ([]string, error) {
+				questions = append(questions, prompts...)
+				instructions = append(instructions, instruction)
+				if prompts[0] == "Password: " {
+					assert.False(t, echos[0])
+					return []string{"toor"}, nil
+				}
+				assert.True(t, echos[0])
+				return []string{"123456"}, nil
+			}),
+		},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	client.Close()
+
+	assert.Equal(t, []string{"Password: ", "Verification code: "}, questions)
+	assert.Equal(t, []string{"Two-factor authentication required", ""}, instructions)
+
+	events := mt.Events()
+	require.Len(t, events, 3)
+	assert.Equal(t, "New SSH Login Attempt", events[0].Msg)
+	assert.Equal(t, "wrong", events[0].Password)
+
+	assert.Equal(t, "New SSH Keyboard-Interactive Login Attempt", events[1].Msg)
+	assert.Equal(t, "Password: ", events[1].Prompt)
+	assert.Equal(t, "toor", events[1].Password)
+	assert.Equal(t, "Verification code: ", events[2].Prompt)
+	assert.Equal(t, "123456", events[2].Password)
+	assert.Equal(t, tracer.Stateless.String(), events[2].Status)
+
+	// The password attempt and the answers of the same connection share the ID.
+	assert.NotEmpty(t, events[0].ID)
+	assert.Equal(t, events[0].ID, events[1].ID)
+	assert.Equal(t, events[0].ID, events[2].ID)
+}
+
+func TestSSHStrategy_KeyboardInteractive_DefaultPrompt(t *testing.T) {
+	mt := &mockTracer{}
+
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PasswordRegex:          "^root$",
+		KeyboardInteractive:    parser.SSHKeyboardInteractive{Enabled: true},
+	}, mt)
+
+	dial := func(answer string) error {
+		client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+			User: "root",
+			Auth: []gossh.AuthMethod{
+				gossh.RetryableAuthMethod(gossh.KeyboardInteractive(func(_, _ string, prompts []string, _ []bool) ([]string, error) {
+					assert.Equal(t, []string{defaultKeyboardInteractivePrompt}, prompts)
+					return []string{answer}, nil
+				}), 1),
+			},
+			HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		})
+		if err != nil {
+			return err
+		}
+		return client.Close()
+	}
+
+	assert.Error(t, dial("wrong"))
+	assert.NoError(t, dial("root"))
+
+	events := mt.Events()
+	require.Len(t, events, 2)
+	assert.NotEqual(t, events[0].ID, events[1].ID)
+}
+
+func TestSSHStrategy_Init_InvalidKeyboardInteractiveRegex(t *testing.T) {
+	strategy := &SSHStrategy{}
+
+	err := strategy.Init(parser.BeelzebubServiceConfiguration{
+		Address: "127.0.0.1:0",
+		KeyboardInteractive: parser.SSHKeyboardInteractive{
+			Enabled: true,
+			Prompts: []parser.SSHPrompt{{Prompt: "Password: ", Regex: "("}},
+		},
+	}, &mockTracer{})
+
+	assert.ErrorContains(t, err, "invalid keyboard-interactive regex")
+}
@@ -0,0 +1,144 @@
+package SSH
+
+import (
+	"io"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultCaptureBytes          = 4096
+	defaultCaptureTimeoutSeconds = 10
+)
+
+// directTCPIPData is the payload of a "direct-tcpip" channel open request, see RFC 4254, section 7.2.
+type directTCPIPData struct {
+	DestAddr   string
+	DestPort   uint32
+	OriginAddr string
+	OriginPort uint32
+}
+
+// tcpipForwardRequest is the payload of a "tcpip-forward" global request, see RFC 4254, section 7.1.
+type tcpipForwardRequest struct {
+	BindAddr string
+	BindPort uint32
+}
+
+type tcpipForwardResponse struct {
+	BindPort uint32
+}
+
+// portForwarding accepts the forwarding requests of the clients and traces them, no connection is ever opened:
+// the data sent through a forwarded channel is captured and the channel is closed.
+type portForwarding struct {
+	captureBytes   int
+	captureTimeout time.Duration
+	description    string
+	tr             tracer.Tracer
+	tracker        *protocols.SessionTracker
+}
+
+func newPortForwarding(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer, sshStrategy *SSHStrategy) *portForwarding {
+	captureBytes := servConf.PortForwarding.CaptureBytes
+	if captureBytes <= 0 {
+		captureBytes = defaultCaptureBytes
+	}
+	captureTimeoutSeconds := servConf.PortForwarding.CaptureTimeoutSeconds
+	if captureTimeoutSeconds <= 0 {
+		captureTimeoutSeconds = defaultCaptureTimeoutSeconds
+	}
+	return &portForwarding{
+		captureBytes:   captureBytes,
+		captureTimeout: time.Duration(captureTimeoutSeconds) * time.Second,
+		description:    servConf.Description,
+		tr:             tr,
+		tracker:        &sshStrategy.tracker,
+	}
+}
+
+// directTCPIPHandler replaces ssh.DirectTCPIPHandler, that dials the requested destination.
+func (pf *portForwarding) directTCPIPHandler(_ *ssh.Server, _ *gossh.ServerConn, newChannel gossh.NewChannel, ctx ssh.Context) {
+	var data directTCPIPData
+	if err := gossh.Unmarshal(newChannel.ExtraData(), &data); err != nil {
+		newChannel.Reject(gossh.ConnectionFailed, "error parsing forward data: "+err.Error())
+		return
+	}
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	if !pf.tracker.Track(channel) {
+		channel.Close()
+		return
+	}
+	defer pf.tracker.Untrack(channel)
+
+	payload := pf.capture(channel)
+	channel.Close()
+
+	pf.trace(ctx, "SSH Direct TCP/IP Channel", data.DestAddr, data.DestPort, payload)
+}
+
+// capture reads up to captureBytes from the channel, until the client closes it or the timeout expires.
+func (pf *portForwarding) capture(channel gossh.Channel) []byte {
+	timer := time.AfterFunc(pf.captureTimeout, func() { channel.Close() })
+	defer timer.Stop()
+
+	// A short read is expected, the client closes the channel or the timeout expires.
+	buffer := make([]byte, pf.captureBytes)
+	n, _ := io.ReadFull(channel, buffer)
+	return buffer[:n]
+}
+
+// tcpipForwardHandler acknowledges the remote port forward without listening.
+func (pf *portForwarding) tcpipForwardHandler(ctx ssh.Context, _ *ssh.Server, request *gossh.Request) (bool, []byte) {
+	var data tcpipForwardRequest
+	if err := gossh.Unmarshal(request.Payload, &data); err != nil {
+		return false, nil
+	}
+
+	pf.trace(ctx, "SSH Remote Port Forward Request", data.BindAddr, data.BindPort, nil)
+
+	if data.BindPort != 0 {
+		return true, nil
+	}
+	// The port is only returned when the client asks for any port, it is picked from the Linux ephemeral range.
+	return true, gossh.Marshal(tcpipForwardResponse{BindPort: uint32(32768 + rand.IntN(28232))})
+}
+
+func (pf *portForwarding) cancelTCPIPForwardHandler(_ ssh.Context, _ *ssh.Server, _ *gossh.Request) (bool, []byte) {
+	return true, nil
+}
+
+func (pf *portForwarding) trace(ctx ssh.Context, msg, host string, port uint32, payload []byte) {
+	remoteHost, remotePort, _ := net.SplitHostPort(ctx.RemoteAddr().String())
+
+	pf.tr.TraceEvent(tracer.Event{
+		Msg:             msg,
+		Protocol:        tracer.SSH.String(),
+		Status:          tracer.PortForward.String(),
+		RemoteAddr:      ctx.RemoteAddr().String(),
+		SourceIp:        remoteHost,
+		SourcePort:      remotePort,
+		ID:              connectionID(ctx),
+		User:            ctx.User(),
+		Client:          ctx.ClientVersion(),
+		Description:     pf.description,
+		DestinationHost: host,
+		DestinationPort: strconv.FormatUint(uint64(port), 10),
+		Payload:         payload,
+	})
+}
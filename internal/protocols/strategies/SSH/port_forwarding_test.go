@@ -0,0 +1,109 @@
+package SSH
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func dialPortForwarding(t *testing.T, portForwarding parser.SSHPortForwarding, mt *mockTracer) *gossh.Client {
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 5,
+		PasswordRegex:          "^root$",
+		PortForwarding:         portForwarding,
+	}, mt)
+
+	client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+		User:            "root",
+		Auth:            []gossh.AuthMethod{gossh.Password("root")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func waitForEvent(t *testing.T, mt *mockTracer, msg string) tracer.Event {
+	var event tracer.Event
+	require.Eventually(t, func() bool {
+		events := eventsByMsg(mt.Events(), msg)
+		if len(events) == 0 {
+			return false
+		}
+		event = events[0]
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+	return event
+}
+
+func TestSSHStrategy_DirectTCPIP(t *testing.T) {
+	mt := &mockTracer{}
+	client := dialPortForwarding(t, parser.SSHPortForwarding{Enabled: true, CaptureBytes: 18}, mt)
+
+	conn, err := client.Dial("tcp", "10.0.0.1:3306")
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: internal\r\n\r\n"))
+	require.NoError(t, err)
+
+	event := waitForEvent(t, mt, "SSH Direct TCP/IP Channel")
+	conn.Close()
+
+	assert.Equal(t, "10.0.0.1", event.DestinationHost)
+	assert.Equal(t, "3306", event.DestinationPort)
+	// The capture stops after CaptureBytes.
+	assert.Equal(t, "GET / HTTP/1.1\r\nHo", string(event.Payload))
+	assert.Equal(t, tracer.PortForward.String(), event.Status)
+	assert.Equal(t, "root", event.User)
+}
+
+func TestSSHStrategy_DirectTCPIP_CaptureTimeout(t *testing.T) {
+	mt := &mockTracer{}
+	client := dialPortForwarding(t, parser.SSHPortForwarding{Enabled: true, CaptureTimeoutSeconds: 1}, mt)
+
+	conn, err := client.Dial("tcp", "db.internal:5432")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("\x00\x00\x00\x08"))
+	require.NoError(t, err)
+
+	event := waitForEvent(t, mt, "SSH Direct TCP/IP Channel")
+	assert.Equal(t, "db.internal", event.DestinationHost)
+	assert.Equal(t, []byte("\x00\x00\x00\x08"), event.Payload)
+}
+
+func TestSSHStrategy_TCPIPForward(t *testing.T) {
+	mt := &mockTracer{}
+	client := dialPortForwarding(t, parser.SSHPortForwarding{Enabled: true}, mt)
+
+	listener, err := client.Listen("tcp", "0.0.0.0:8080")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	event := waitForEvent(t, mt, "SSH Remote Port Forward Request")
+	assert.Equal(t, "0.0.0.0", event.DestinationHost)
+	assert.Equal(t, "8080", event.DestinationPort)
+
+	// When any port is requested, the server picks one.
+	listener, err = client.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	assert.NotZero(t, listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestSSHStrategy_PortForwarding_Disabled(t *testing.T) {
+	mt := &mockTracer{}
+	client := dialPortForwarding(t, parser.SSHPortForwarding{}, mt)
+
+	_, err := client.Dial("tcp", "10.0.0.1:3306")
+	assert.Error(t, err)
+	_, err = client.Listen("tcp", "0.0.0.0:8080")
+	assert.Error(t, err)
+	assert.Empty(t, eventsByMsg(mt.Events(), "SSH Direct TCP/IP Channel"))
+}
@@ -0,0 +1,75 @@
+package SSH
+
+import (
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+type offeredPublicKeysContextKey struct{}
+
+// recordOfferedPublicKey records the fingerprint among the distinct keys offered on the connection,
+// it returns whether the key is new together with the number of distinct keys offered so far.
+// The callback is invoked twice for the same key, first to query it and then to verify its signature,
+// so that the keys are deduplicated to trace and count every key once.
+func recordOfferedPublicKey(ctx ssh.Context, fingerprint string) (bool, int) {
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	offered, ok := ctx.Value(offeredPublicKeysContextKey{}).(map[string]struct{})
+	if !ok {
+		offered = make(map[string]struct{})
+		ctx.SetValue(offeredPublicKeysContextKey{}, offered)
+	}
+	if _, exists := offered[fingerprint]; exists {
+		return false, len(offered)
+	}
+	offered[fingerprint] = struct{}{}
+	return true, len(offered)
+}
+
+// buildPublicKeyHandler traces every key offered by the client and accepts it according to the configured policy.
+func buildPublicKeyHandler(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) ssh.PublicKeyHandler {
+	policy := servConf.PublicKeyAuth
+	serviceMetrics := protocols.ServiceMetrics(tr)
+
+	return func(ctx ssh.Context, key ssh.PublicKey) bool {
+		fingerprint := gossh.FingerprintSHA256(key)
+		isNew, attempts := recordOfferedPublicKey(ctx, fingerprint)
+
+		if isNew {
+			host, port, _ := net.SplitHostPort(ctx.RemoteAddr().String())
+
+			tr.TraceEvent(tracer.Event{
+				Msg:                  "New SSH Public Key Login Attempt",
+				Protocol:             tracer.SSH.String(),
+				Status:               tracer.Stateless.String(),
+				User:                 ctx.User(),
+				Client:               ctx.ClientVersion(),
+				RemoteAddr:           ctx.RemoteAddr().String(),
+				SourceIp:             host,
+				SourcePort:           port,
+				ID:                   connectionID(ctx),
+				Description:          servConf.Description,
+				PublicKey:            strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))),
+				PublicKeyType:        key.Type(),
+				PublicKeyFingerprint: fingerprint,
+			})
+		}
+
+		accepted := slices.Contains(policy.AcceptedFingerprints, fingerprint) ||
+			(policy.AcceptAfterAttempts > 0 && attempts >= policy.AcceptAfterAttempts)
+		if isNew {
+			serviceMetrics.AuthAttempt(metrics.PublicKeyAuth, accepted)
+		}
+		return accepted
+	}
+}
@@ -0,0 +1,103 @@
+package SSH
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) gossh.Signer {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := gossh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	return signer
+}
+
+func dialWithPublicKeys(address string, signers ...gossh.Signer) error {
+	client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+		User:            "root",
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signers...)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func publicKeyEvents(events []tracer.Event) []tracer.Event {
+	var filtered []tracer.Event
+	for _, event := range events {
+		if event.PublicKeyFingerprint != "" {
+			filtered = append(filtered, event)
+		}
+	}
+	return filtered
+}
+
+func TestSSHStrategy_PublicKeyAuth_AcceptedFingerprint(t *testing.T) {
+	unknown := newTestSigner(t)
+	accepted := newTestSigner(t)
+	mt := &mockTracer{}
+
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PublicKeyAuth: parser.SSHPublicKeyAuth{
+			Enabled:              true,
+			AcceptedFingerprints: []string{gossh.FingerprintSHA256(accepted.PublicKey())},
+		},
+	}, mt)
+
+	require.Error(t, dialWithPublicKeys(address, unknown))
+	require.NoError(t, dialWithPublicKeys(address, unknown, accepted))
+
+	events := publicKeyEvents(mt.Events())
+	// The accepted key is queried and then verified, but it must be traced once.
+	require.Len(t, events, 3)
+
+	event := events[2]
+	assert.Equal(t, "New SSH Public Key Login Attempt", event.Msg)
+	assert.Equal(t, tracer.Stateless.String(), event.Status)
+	assert.Equal(t, "root", event.User)
+	assert.Equal(t, gossh.KeyAlgoED25519, event.PublicKeyType)
+	assert.Equal(t, gossh.FingerprintSHA256(accepted.PublicKey()), event.PublicKeyFingerprint)
+	assert.Equal(t, strings.TrimSpace(string(gossh.MarshalAuthorizedKey(accepted.PublicKey()))), event.PublicKey)
+}
+
+func TestSSHStrategy_PublicKeyAuth_AcceptAfterAttempts(t *testing.T) {
+	mt := &mockTracer{}
+
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PublicKeyAuth: parser.SSHPublicKeyAuth{
+			Enabled:             true,
+			AcceptAfterAttempts: 2,
+		},
+	}, mt)
+
+	require.Error(t, dialWithPublicKeys(address, newTestSigner(t)))
+	require.NoError(t, dialWithPublicKeys(address, newTestSigner(t), newTestSigner(t)))
+
+	assert.Len(t, publicKeyEvents(mt.Events()), 3)
+}
+
+func TestSSHStrategy_PublicKeyAuth_Disabled(t *testing.T) {
+	mt := &mockTracer{}
+
+	address := startSSHStrategy(t, parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PasswordRegex:          "^root$",
+	}, mt)
+
+	require.Error(t, dialWithPublicKeys(address, newTestSigner(t)))
+	assert.Empty(t, publicKeyEvents(mt.Events()))
+}
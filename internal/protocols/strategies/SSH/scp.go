@@ -0,0 +1,276 @@
+package SSH
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/vfs"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const scpTransfer = "SCP"
+
+// scpMaxLineLength bounds the control lines of the protocol, e.g. "C0644 12 payload.sh", which are buffered whole.
+const scpMaxLineLength = 4096
+
+// scpCommand is the remote end of the legacy scp protocol, as run by the scp client over an exec request.
+type scpCommand struct {
+	// sink is set by -t, the client uploads to target; otherwise -f is set and the client downloads target.
+	sink   bool
+	target string
+}
+
+// parseSCPCommand parses the scp command run by the client, e.g. "scp -r -d -t -- /tmp".
+func parseSCPCommand(rawCommand string) (scpCommand, bool) {
+	fields := strings.Fields(rawCommand)
+	if len(fields) < 2 || fields[0] != "scp" {
+		return scpCommand{}, false
+	}
+
+	var command scpCommand
+	var sink, source, endOfFlags bool
+	for _, field := range fields[1:] {
+		if !endOfFlags && field == "--" {
+			endOfFlags = true
+			continue
+		}
+		if !endOfFlags && strings.HasPrefix(field, "-") {
+			sink = sink || strings.ContainsRune(field, 't')
+			source = source || strings.ContainsRune(field, 'f')
+			continue
+		}
+		command.target = field
+	}
+	if sink == source || command.target == "" {
+		return scpCommand{}, false
+	}
+	command.sink = sink
+	return command, true
+}
+
+// serveSCP runs the scp command from the in-memory filesystem of the connection, and returns the exit status.
+func (ft *fileTransfer) serveSCP(sess ssh.Session, command scpCommand) int {
+	sessionID := uuid.New().String()
+	ft.traceSession(sess, sessionID, "New SSH SCP Session", tracer.Start)
+	defer ft.traceSession(sess, sessionID, "End SSH SCP Session", tracer.End)
+
+	fsys, quota := ft.filesystem(sess.Context())
+	target := resolvePath(sess.User(), command.target)
+
+	var err error
+	if command.sink {
+		err = ft.receiveSCP(sess, fsys, quota, sessionID, target)
+	} else {
+		err = ft.sendSCP(sess, fsys, sessionID, target)
+	}
+	if err != nil {
+		log.Debugf("error during serve SCP: %s", err.Error())
+		return 1
+	}
+	return 0
+}
+
+// receiveSCP implements the sink side of the protocol, the client sends "C<mode> <size> <name>" for a file,
+// "D<mode> 0 <name>" and "E" to enter and leave a directory, and "T<mtime> 0 <atime> 0" before either when -p is set.
+func (ft *fileTransfer) receiveSCP(sess ssh.Session, fsys *vfs.FS, quota *uploadQuota, sessionID, target string) error {
+	reader := bufio.NewReaderSize(sess, scpMaxLineLength)
+	ack := func() error {
+		_, err := sess.Write([]byte{0})
+		return err
+	}
+	fail := func(err error) error {
+		fmt.Fprintf(sess, "\x01scp: %s\n", scpErrorMessage(err))
+		return err
+	}
+
+	targetInfo, err := fsys.Stat(target)
+	targetIsDir := err == nil && targetInfo.IsDir()
+	directories := []string{target}
+	destination := func(name string) string {
+		if targetIsDir || len(directories) > 1 {
+			return path.Join(directories[len(directories)-1], path.Base(name))
+		}
+		return target
+	}
+
+	if err := ack(); err != nil {
+		return err
+	}
+	for {
+		slice, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fail(errors.New("protocol error: line too long"))
+		}
+		if errors.Is(err, io.EOF) && len(slice) == 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line := strings.TrimSuffix(string(slice), "\n")
+		if line == "" {
+			return fail(errors.New("protocol error: unexpected empty line"))
+		}
+
+		switch line[0] {
+		case 'T':
+			if err := ack(); err != nil {
+				return err
+			}
+		case 'C':
+			mode, size, name, err := parseSCPHeader(line)
+			if err != nil {
+				return fail(err)
+			}
+			if size > ft.maxUploadSize {
+				return fail(fmt.Errorf("%s: %w", name, errUploadTooLarge))
+			}
+			if err := quota.reserve(size); err != nil {
+				return fail(fmt.Errorf("%s: %w", name, err))
+			}
+			if err := ack(); err != nil {
+				return err
+			}
+			// The content is buffered as it arrives, the size announced by the header is not allocated upfront.
+			var data bytes.Buffer
+			if _, err := io.CopyN(&data, reader, size); err != nil {
+				quota.release(size)
+				return err
+			}
+			// The content is followed by a zero byte.
+			if _, err := reader.ReadByte(); err != nil {
+				quota.release(size)
+				return err
+			}
+
+			filePath := destination(name)
+			ft.upload(sess, sessionID, scpTransfer, filePath, data.Bytes())
+			if err := fsys.WriteFile(filePath, data.Bytes(), mode); err != nil {
+				quota.release(size)
+				return fail(err)
+			}
+			if err := ack(); err != nil {
+				return err
+			}
+		case 'D':
+			mode, _, name, err := parseSCPHeader(line)
+			if err != nil {
+				return fail(err)
+			}
+			directory := destination(name)
+			if err := fsys.Mkdir(directory, mode); err != nil && !errors.Is(err, fs.ErrExist) {
+				return fail(err)
+			}
+			directories = append(directories, directory)
+			if err := ack(); err != nil {
+				return err
+			}
+		case 'E':
+			if len(directories) > 1 {
+				directories = directories[:len(directories)-1]
+			}
+			if err := ack(); err != nil {
+				return err
+			}
+		case '\x01', '\x02':
+			return fmt.Errorf("scp client error: %s", line[1:])
+		default:
+			return fail(fmt.Errorf("protocol error: unexpected %q", line))
+		}
+	}
+}
+
+// sendSCP implements the source side of the protocol for a single file.
+func (ft *fileTransfer) sendSCP(sess ssh.Session, fsys *vfs.FS, sessionID, source string) error {
+	reader := bufio.NewReader(sess)
+	readAck := func() error {
+		status, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if status != 0 {
+			message, _ := reader.ReadString('\n')
+			return fmt.Errorf("scp client error: %s", strings.TrimSpace(message))
+		}
+		return nil
+	}
+
+	if err := readAck(); err != nil {
+		return err
+	}
+	info, err := fsys.Stat(source)
+	if err == nil && info.IsDir() {
+		err = &fs.PathError{Op: "open", Path: source, Err: vfs.ErrIsDir}
+	}
+	var data []byte
+	if err == nil {
+		data, err = fsys.ReadFile(source)
+	}
+	if err != nil {
+		fmt.Fprintf(sess, "\x01scp: %s\n", scpErrorMessage(err))
+		return err
+	}
+
+	fmt.Fprintf(sess, "C%04o %d %s\n", info.Mode().Perm(), len(data), path.Base(source))
+	if err := readAck(); err != nil {
+		return err
+	}
+	if _, err := sess.Write(append(data, 0)); err != nil {
+		return err
+	}
+	if err := readAck(); err != nil {
+		return err
+	}
+	ft.download(sess, sessionID, scpTransfer, source, int64(len(data)))
+	return nil
+}
+
+// parseSCPHeader parses the "C" and "D" lines, e.g. "C0644 12 payload.sh".
+func parseSCPHeader(line string) (fs.FileMode, int64, string, error) {
+	fields := strings.SplitN(line[1:], " ", 3)
+	if len(fields) != 3 {
+		return 0, 0, "", fmt.Errorf("protocol error: malformed header %q", line)
+	}
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("protocol error: bad mode %q", fields[0])
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, 0, "", fmt.Errorf("protocol error: bad size %q", fields[1])
+	}
+	name := fields[2]
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return 0, 0, "", fmt.Errorf("protocol error: bad filename %q", name)
+	}
+	return fs.FileMode(mode).Perm(), size, name, nil
+}
+
+// scpErrorMessage formats err the way OpenSSH does, e.g. "/tmp/x: No such file or directory".
+func scpErrorMessage(err error) string {
+	var pathError *fs.PathError
+	if !errors.As(err, &pathError) {
+		return err.Error()
+	}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return pathError.Path + ": No such file or directory"
+	case errors.Is(err, vfs.ErrIsDir):
+		return pathError.Path + ": not a regular file"
+	case errors.Is(err, vfs.ErrNotDir):
+		return pathError.Path + ": Not a directory"
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,137 @@
+package SSH
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/vfs"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+	log "github.com/sirupsen/logrus"
+)
+
+const sftpTransfer = "SFTP"
+
+// serveSFTP serves the SFTP subsystem from the in-memory filesystem of the connection.
+func (ft *fileTransfer) serveSFTP(sess ssh.Session) {
+	sessionID := uuid.New().String()
+	ft.traceSession(sess, sessionID, "New SSH SFTP Session", tracer.Start)
+
+	fsys, quota := ft.filesystem(sess.Context())
+	handlers := &sftpHandlers{
+		fileTransfer: ft,
+		fsys:         fsys,
+		quota:        quota,
+		sess:         sess,
+		sessionID:    sessionID,
+	}
+	server := sftp.NewRequestServer(sess, sftp.Handlers{
+		FileGet:  handlers,
+		FilePut:  handlers,
+		FileCmd:  handlers,
+		FileList: handlers,
+	}, sftp.WithStartDirectory(homeDirectory(sess.User())))
+
+	if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+		log.Debugf("error during serve SFTP: %s", err.Error())
+	}
+	server.Close()
+
+	ft.traceSession(sess, sessionID, "End SSH SFTP Session", tracer.End)
+}
+
+// sftpHandlers implements the request handlers of sftp.RequestServer on top of vfs.FS.
+type sftpHandlers struct {
+	fileTransfer *fileTransfer
+	fsys         *vfs.FS
+	quota        *uploadQuota
+	sess         ssh.Session
+	sessionID    string
+}
+
+func (handlers *sftpHandlers) Fileread(request *sftp.Request) (io.ReaderAt, error) {
+	data, err := handlers.fsys.ReadFile(request.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	handlers.fileTransfer.download(handlers.sess, handlers.sessionID, sftpTransfer, request.Filepath, int64(len(data)))
+	return bytes.NewReader(data), nil
+}
+
+func (handlers *sftpHandlers) Filewrite(request *sftp.Request) (io.WriterAt, error) {
+	// The parent directory must exist, as on a real filesystem.
+	if info, err := handlers.fsys.Stat(path.Dir(request.Filepath)); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, vfs.ErrNotDir
+	}
+	if info, err := handlers.fsys.Stat(request.Filepath); err == nil && info.IsDir() {
+		return nil, vfs.ErrIsDir
+	}
+
+	name := request.Filepath
+	return &uploadBuffer{
+		limit: handlers.fileTransfer.maxUploadSize,
+		quota: handlers.quota,
+		onClose: func(data []byte) error {
+			handlers.fileTransfer.upload(handlers.sess, handlers.sessionID, sftpTransfer, name, data)
+			return handlers.fsys.WriteFile(name, data, 0644)
+		},
+	}, nil
+}
+
+func (handlers *sftpHandlers) Filecmd(request *sftp.Request) error {
+	switch request.Method {
+	case "Setstat":
+		if request.AttrFlags().Permissions {
+			return handlers.fsys.Chmod(request.Filepath, request.Attributes().FileMode())
+		}
+		return nil
+	case "Rename", "PosixRename":
+		return handlers.fsys.Rename(request.Filepath, request.Target)
+	case "Rmdir", "Remove":
+		return handlers.fsys.Remove(request.Filepath)
+	case "Mkdir":
+		return handlers.fsys.Mkdir(request.Filepath, 0755)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (handlers *sftpHandlers) Filelist(request *sftp.Request) (sftp.ListerAt, error) {
+	switch request.Method {
+	case "List":
+		entries, err := handlers.fsys.ReadDir(request.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt(entries), nil
+	case "Stat":
+		info, err := handlers.fsys.Stat(request.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+type listerAt []os.FileInfo
+
+func (entries listerAt) ListAt(list []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(entries)) {
+		return 0, io.EOF
+	}
+	n := copy(list, entries[offset:])
+	if n < len(list) {
+		return n, io.EOF
+	}
+	return n, nil
+}
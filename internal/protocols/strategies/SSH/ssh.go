@@ -2,26 +2,38 @@ package SSH
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/historystore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/recording"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/session"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
-	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
 
 	"github.com/gliderlabs/ssh"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/term"
 )
 
 type SSHStrategy struct {
 	Sessions *historystore.HistoryStore
+
+	mu        sync.Mutex
+	stopped   bool
+	servers   []*ssh.Server
+	listeners []net.Listener
+	tracker   protocols.SessionTracker
 }
 
 func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
@@ -29,154 +41,114 @@ func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 		sshStrategy.Sessions = historystore.NewHistoryStore()
 	}
 	go sshStrategy.Sessions.HistoryCleaner()
+	serviceMetrics := protocols.ServiceMetrics(tr)
+
+	// Without configured host keys, a new key is generated on every start.
+	hostSigners, err := loadHostSigners(servConf.HostKeys)
+	if err != nil {
+		return err
+	}
+	algorithmsConfig, err := buildAlgorithmsConfig(servConf.Algorithms)
+	if err != nil {
+		return err
+	}
+	var transfer *fileTransfer
+	if servConf.FileTransfer.Enabled {
+		if transfer, err = newFileTransfer(servConf, tr); err != nil {
+			return err
+		}
+	}
+	var keyboardInteractiveHandler ssh.KeyboardInteractiveHandler
+	if servConf.KeyboardInteractive.Enabled {
+		if keyboardInteractiveHandler, err = buildKeyboardInteractiveHandler(servConf, tr); err != nil {
+			return err
+		}
+	}
+
 	go func() {
 		server := &ssh.Server{
 			Addr:        servConf.Address,
 			MaxTimeout:  time.Duration(servConf.DeadlineTimeoutSeconds) * time.Second,
 			IdleTimeout: time.Duration(servConf.DeadlineTimeoutSeconds) * time.Second,
 			Version:     servConf.ServerVersion,
+			HostSigners: hostSigners,
+			ServerConfigCallback: func(ctx ssh.Context) *gossh.ServerConfig {
+				return &gossh.ServerConfig{Config: algorithmsConfig}
+			},
 			Handler: func(sess ssh.Session) {
-				uuidSession := uuid.New()
+				if !sshStrategy.tracker.Track(sess) {
+					sess.Exit(1)
+					return
+				}
+				defer sshStrategy.tracker.Untrack(sess)
 
 				host, port, _ := net.SplitHostPort(sess.RemoteAddr().String())
-				sessionKey := "SSH" + host + sess.User()
 
-				// Inline SSH command
-				if sess.RawCommand() != "" {
-					var histories []plugins.Message
-					if sshStrategy.Sessions.HasKey(sessionKey) {
-						histories = sshStrategy.Sessions.Query(sessionKey)
-					}
-					for _, command := range servConf.Commands {
-						if command.Regex.MatchString(sess.RawCommand()) {
-							commandOutput := command.Handler
-							if command.Plugin != "" {
-								if cp, ok := plugin.GetCommand(command.Plugin); ok {
-									output, err := cp.Execute(context.Background(), plugin.CommandRequest{
-										Command:  sess.RawCommand(),
-										ClientIP: host,
-										Protocol: "ssh",
-										History:  plugins.MessagesToPlugin(histories),
-										Config:   plugins.ConfigFromServiceConf(servConf),
-									})
-									if err != nil {
-										log.Errorf("plugin %q execute error: %s", command.Plugin, err.Error())
-										commandOutput = "command not found"
-									} else {
-										commandOutput = output
-									}
-								} else {
-									log.Warnf("unknown plugin %q, skipping", command.Plugin)
-								}
-							}
-							var newEntries []plugins.Message
-							newEntries = append(newEntries, plugins.Message{Role: plugins.USER.String(), Content: sess.RawCommand()})
-							newEntries = append(newEntries, plugins.Message{Role: plugins.ASSISTANT.String(), Content: commandOutput})
-							// Append the new entries to the store.
-							sshStrategy.Sessions.Append(sessionKey, newEntries...)
-
-							sess.Write(append([]byte(commandOutput), '\n'))
-
-							tr.TraceEvent(tracer.Event{
-								Msg:           "SSH Raw Command",
-								Protocol:      tracer.SSH.String(),
-								RemoteAddr:    sess.RemoteAddr().String(),
-								SourceIp:      host,
-								SourcePort:    port,
-								Status:        tracer.Start.String(),
-								ID:            uuidSession.String(),
-								Environ:       strings.Join(sess.Environ(), ","),
-								User:          sess.User(),
-								Description:   servConf.Description,
-								Command:       sess.RawCommand(),
-								CommandOutput: commandOutput,
-								Handler:       command.Name,
-							})
-							return
-						}
+				if transfer != nil {
+					if command, ok := parseSCPCommand(sess.RawCommand()); ok {
+						sess.Exit(transfer.serveSCP(sess, command))
+						return
 					}
 				}
 
-				tr.TraceEvent(tracer.Event{
-					Msg:         "New SSH Terminal Session",
-					Protocol:    tracer.SSH.String(),
-					RemoteAddr:  sess.RemoteAddr().String(),
-					SourceIp:    host,
-					SourcePort:  port,
-					Status:      tracer.Start.String(),
-					ID:          uuidSession.String(),
-					Environ:     strings.Join(sess.Environ(), ","),
-					User:        sess.User(),
-					Description: servConf.Description,
-				})
+				terminalSession := &session.Session{
+					ID:            uuid.New().String(),
+					Protocol:      tracer.SSH,
+					Name:          "SSH Terminal Session",
+					ServConf:      servConf,
+					Tracer:        tr,
+					Histories:     sshStrategy.Sessions,
+					HistoryKey:    "SSH" + host + sess.User(),
+					RemoteAddr:    sess.RemoteAddr().String(),
+					SourceIp:      host,
+					SourcePort:    port,
+					User:          sess.User(),
+					Environ:       strings.Join(sess.Environ(), ","),
+					Description:   servConf.Description,
+					SkipUnmatched: true,
+					ExitCommand:   "exit",
+					Metrics:       serviceMetrics,
+				}
 
-				terminal := term.NewTerminal(sess, buildPrompt(sess.User(), servConf.ServerName))
-				var histories []plugins.Message
-				if sshStrategy.Sessions.HasKey(sessionKey) {
-					histories = sshStrategy.Sessions.Query(sessionKey)
+				// Inline SSH command
+				if sess.RawCommand() != "" {
+					if commandLine := terminalSession.Execute(sess.RawCommand()); commandLine.Matched {
+						sess.Write(append([]byte(commandLine.Output), '\n'))
+
+						terminalSession.TraceEvent(tracer.Event{
+							Msg:           "SSH Raw Command",
+							Status:        tracer.Start.String(),
+							Environ:       terminalSession.Environ,
+							User:          sess.User(),
+							Description:   servConf.Description,
+							Command:       sess.RawCommand(),
+							CommandOutput: commandLine.Output,
+							Handler:       commandLine.Handler,
+							SubCommands:   commandLine.SubCommands,
+						})
+						terminalSession.End()
+						return
+					}
 				}
 
-				for {
-					commandInput, err := terminal.ReadLine()
+				var rw io.ReadWriter = sess
+				if servConf.Recording.Enabled {
+					pty, _, _ := sess.Pty()
+					recorder, err := recording.Create(recording.Directory(servConf.Recording.Path), terminalSession.ID, recording.Header{
+						Width:  pty.Window.Width,
+						Height: pty.Window.Height,
+						Env:    map[string]string{"TERM": pty.Term, "SHELL": "/bin/bash"},
+					}, recording.NewOptions(servConf.Recording))
 					if err != nil {
-						break
-					}
-					if commandInput == "exit" {
-						break
-					}
-					for _, command := range servConf.Commands {
-						if command.Regex.MatchString(commandInput) {
-							commandOutput := command.Handler
-							if command.Plugin != "" {
-								if cp, ok := plugin.GetCommand(command.Plugin); ok {
-									output, err := cp.Execute(context.Background(), plugin.CommandRequest{
-										Command:  commandInput,
-										ClientIP: host,
-										Protocol: "ssh",
-										History:  plugins.MessagesToPlugin(histories),
-										Config:   plugins.ConfigFromServiceConf(servConf),
-									})
-									if err != nil {
-										log.Errorf("plugin %q execute error: %s", command.Plugin, err.Error())
-										commandOutput = "command not found"
-									} else {
-										commandOutput = output
-									}
-								} else {
-									log.Warnf("unknown plugin %q, skipping", command.Plugin)
-								}
-							}
-							var newEntries []plugins.Message
-							newEntries = append(newEntries, plugins.Message{Role: plugins.USER.String(), Content: commandInput})
-							newEntries = append(newEntries, plugins.Message{Role: plugins.ASSISTANT.String(), Content: commandOutput})
-							sshStrategy.Sessions.Append(sessionKey, newEntries...)
-							histories = append(histories, newEntries...)
-
-							terminal.Write(append([]byte(commandOutput), '\n'))
-
-							tr.TraceEvent(tracer.Event{
-								Msg:           "SSH Terminal Session Interaction",
-								RemoteAddr:    sess.RemoteAddr().String(),
-								SourceIp:      host,
-								SourcePort:    port,
-								Status:        tracer.Interaction.String(),
-								Command:       commandInput,
-								CommandOutput: commandOutput,
-								ID:            uuidSession.String(),
-								Protocol:      tracer.SSH.String(),
-								Description:   servConf.Description,
-								Handler:       command.Name,
-							})
-							break // Inner range over commands.
-						}
+						log.Errorf("error during recording SSH session: %s", err.Error())
+					} else {
+						defer recorder.Close()
+						rw = recorder.ReadWriter(sess)
 					}
 				}
 
-				tr.TraceEvent(tracer.Event{
-					Msg:      "End SSH Session",
-					Status:   tracer.End.String(),
-					ID:       uuidSession.String(),
-					Protocol: tracer.SSH.String(),
+				terminalSession.Run(&terminalTransport{
+					terminal: term.NewTerminal(rw, buildPrompt(sess.User(), servConf.ServerName)),
 				})
 			},
 			PasswordHandler: func(ctx ssh.Context, password string) bool {
@@ -192,20 +164,64 @@ func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 					RemoteAddr:  ctx.RemoteAddr().String(),
 					SourceIp:    host,
 					SourcePort:  port,
-					ID:          uuid.New().String(),
+					ID:          connectionID(ctx),
 					Description: servConf.Description,
 				})
 				matched, err := regexp.MatchString(servConf.PasswordRegex, password)
 				if err != nil {
 					log.Errorf("error regex: %s, %s", servConf.PasswordRegex, err.Error())
-					return false
 				}
+				serviceMetrics.AuthAttempt(metrics.PasswordAuth, matched)
 				return matched
 			},
+			KeyboardInteractiveHandler: keyboardInteractiveHandler,
 		}
-		err := server.ListenAndServe()
+		if transfer != nil {
+			server.SubsystemHandlers = map[string]ssh.SubsystemHandler{
+				"sftp": func(sess ssh.Session) {
+					if !sshStrategy.tracker.Track(sess) {
+						sess.Exit(1)
+						return
+					}
+					defer sshStrategy.tracker.Untrack(sess)
+
+					transfer.serveSFTP(sess)
+					sess.Exit(0)
+				},
+			}
+		}
+		if servConf.PortForwarding.Enabled {
+			forwarding := newPortForwarding(servConf, tr, sshStrategy)
+			server.ChannelHandlers = map[string]ssh.ChannelHandler{
+				"session":      ssh.DefaultSessionHandler,
+				"direct-tcpip": forwarding.directTCPIPHandler,
+			}
+			server.RequestHandlers = map[string]ssh.RequestHandler{
+				"tcpip-forward":        forwarding.tcpipForwardHandler,
+				"cancel-tcpip-forward": forwarding.cancelTCPIPForwardHandler,
+			}
+		}
+		if servConf.PublicKeyAuth.Enabled {
+			server.PublicKeyHandler = buildPublicKeyHandler(servConf, tr)
+		}
+		listener, err := net.Listen("tcp", servConf.Address)
 		if err != nil {
 			log.Errorf("error during init SSH Protocol: %s", err.Error())
+			return
+		}
+
+		sshStrategy.mu.Lock()
+		if sshStrategy.stopped {
+			sshStrategy.mu.Unlock()
+			listener.Close()
+			return
+		}
+		sshStrategy.servers = append(sshStrategy.servers, server)
+		sshStrategy.listeners = append(sshStrategy.listeners, listener)
+		sshStrategy.mu.Unlock()
+
+		if err := server.Serve(listener); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+			log.Errorf("error during init SSH Protocol: %s", err.Error())
 		}
 	}()
 
@@ -216,6 +232,58 @@ func (sshStrategy *SSHStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 	return nil
 }
 
+// Shutdown stops the SSH servers and drains the active sessions, see protocols.SessionTracker.
+func (sshStrategy *SSHStrategy) Shutdown(ctx context.Context) error {
+	sshStrategy.mu.Lock()
+	sshStrategy.stopped = true
+	servers := sshStrategy.servers
+	for _, listener := range sshStrategy.listeners {
+		listener.Close()
+	}
+	sshStrategy.servers = nil
+	sshStrategy.listeners = nil
+	sshStrategy.mu.Unlock()
+
+	// Sessions are drained first, so that they are able to trace their End event before the connections are closed.
+	err := sshStrategy.tracker.Drain(ctx)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			server.Close()
+		}
+	}
+	return err
+}
+
+type connectionIDContextKey struct{}
+
+// connectionID returns the ID shared by the authentication attempts of the connection,
+// so that the steps of a multi-step login can be correlated.
+func connectionID(ctx ssh.Context) string {
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	if id, ok := ctx.Value(connectionIDContextKey{}).(string); ok {
+		return id
+	}
+	id := uuid.New().String()
+	ctx.SetValue(connectionIDContextKey{}, id)
+	return id
+}
+
+// terminalTransport reads the command lines from the terminal, which echoes the input and displays the prompt.
+type terminalTransport struct {
+	terminal *term.Terminal
+}
+
+func (t *terminalTransport) ReadLine() (string, error) {
+	return t.terminal.ReadLine()
+}
+
+func (t *terminalTransport) WriteOutput(output string) error {
+	_, err := t.terminal.Write(append([]byte(output), '\n'))
+	return err
+}
+
 func buildPrompt(user string, serverName string) string {
 	return fmt.Sprintf("%s@%s:~$ ", user, serverName)
 }
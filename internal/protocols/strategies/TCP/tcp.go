@@ -2,16 +2,18 @@ package TCP
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/historystore"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/session"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
-	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
@@ -19,6 +21,10 @@ import (
 
 type TCPStrategy struct {
 	Sessions *historystore.HistoryStore
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	tracker   protocols.SessionTracker
 }
 
 func (tcpStrategy *TCPStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
@@ -33,18 +39,32 @@ func (tcpStrategy *TCPStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 		return err
 	}
 
+	tcpStrategy.mu.Lock()
+	tcpStrategy.listeners = append(tcpStrategy.listeners, listen)
+	tcpStrategy.mu.Unlock()
+
 	go func() {
 		for {
-			if conn, err := listen.Accept(); err == nil {
-				go func(c net.Conn) {
-					defer func() {
-						if r := recover(); r != nil {
-							log.Errorf("panic in TCP handler: %v", r)
-						}
-					}()
-					handleTCPConnection(c, servConf, tr, tcpStrategy)
-				}(conn)
+			conn, err := listen.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+			if !tcpStrategy.tracker.Track(conn) {
+				conn.Close()
+				continue
 			}
+			go func(c net.Conn) {
+				defer tcpStrategy.tracker.Untrack(c)
+				defer func() {
+					if r := recover(); r != nil {
+						log.Errorf("panic in TCP handler: %v", r)
+					}
+				}()
+				handleTCPConnection(c, servConf, tr, tcpStrategy)
+			}(conn)
 		}
 	}()
 
@@ -56,6 +76,18 @@ func (tcpStrategy *TCPStrategy) Init(servConf parser.BeelzebubServiceConfigurati
 	return nil
 }
 
+// Shutdown closes the listeners and drains the active sessions, see protocols.SessionTracker.
+func (tcpStrategy *TCPStrategy) Shutdown(ctx context.Context) error {
+	tcpStrategy.mu.Lock()
+	for _, listener := range tcpStrategy.listeners {
+		listener.Close()
+	}
+	tcpStrategy.listeners = nil
+	tcpStrategy.mu.Unlock()
+
+	return tcpStrategy.tracker.Drain(ctx)
+}
+
 func handleTCPConnection(conn net.Conn, servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer, tcpStrategy *TCPStrategy) {
 	defer conn.Close()
 
@@ -69,7 +101,7 @@ func handleTCPConnection(conn net.Conn, servConf parser.BeelzebubServiceConfigur
 	}
 
 	// Backward compatibility: if no commands configured, use legacy behavior
-	if len(servConf.Commands) == 0 {
+	if len(servConf.Commands) == 0 && servConf.FallbackCommand.Handler == "" && servConf.FallbackCommand.Plugin == "" {
 		buffer := make([]byte, 1024)
 		command := ""
 
@@ -92,123 +124,41 @@ func handleTCPConnection(conn net.Conn, servConf parser.BeelzebubServiceConfigur
 	}
 
 	// Interactive session mode
-	sessionID := uuid.New()
-	sessionKey := "TCP" + host
-
-	tr.TraceEvent(tracer.Event{
-		Msg:         "New TCP Session",
-		Protocol:    tracer.TCP.String(),
+	sess := &session.Session{
+		ID:          uuid.New().String(),
+		Protocol:    tracer.TCP,
+		Name:        "TCP Session",
+		ServConf:    servConf,
+		Tracer:      tr,
+		Histories:   tcpStrategy.Sessions,
+		HistoryKey:  "TCP" + host,
 		RemoteAddr:  conn.RemoteAddr().String(),
 		SourceIp:    host,
 		SourcePort:  port,
-		Status:      tracer.Start.String(),
-		ID:          sessionID.String(),
 		Description: servConf.Description,
-	})
-
-	// Load history for LLM context
-	var histories []plugins.Message
-	if tcpStrategy.Sessions.HasKey(sessionKey) {
-		histories = tcpStrategy.Sessions.Query(sessionKey)
+		Metrics:     protocols.ServiceMetrics(tr),
 	}
+	sess.Run(&tcpTransport{conn: conn})
+}
 
-	// Interactive command loop
-	for {
-		buffer := make([]byte, 4096)
-		n, err := conn.Read(buffer)
-		if err != nil {
-			break
-		}
-
-		commandInput := strings.TrimRight(string(buffer[:n]), "\r\n")
-
-		// Match command against regexes
-		matched := false
-		for _, command := range servConf.Commands {
-			if command.Regex.MatchString(commandInput) {
-				matched = true
-				commandOutput := command.Handler
-				handlerName := command.Name
-				if handlerName == "" {
-					handlerName = "configured_regex"
-				}
-
-				// Plugin dispatch via registry
-				if command.Plugin != "" {
-					if cp, ok := plugin.GetCommand(command.Plugin); ok {
-						output, err := cp.Execute(context.Background(), plugin.CommandRequest{
-							Command:  commandInput,
-							ClientIP: host,
-							Protocol: "tcp",
-							History:  plugins.MessagesToPlugin(histories),
-							Config:   plugins.ConfigFromServiceConf(servConf),
-						})
-						if err != nil {
-							log.Errorf("plugin %q execute error: %s", command.Plugin, err.Error())
-						} else {
-							commandOutput = output
-						}
-					} else {
-						log.Warnf("unknown plugin %q, skipping", command.Plugin)
-					}
-				}
-
-				// Store command and response in history
-				var newEntries []plugins.Message
-				newEntries = append(newEntries, plugins.Message{Role: plugins.USER.String(), Content: commandInput})
-				newEntries = append(newEntries, plugins.Message{Role: plugins.ASSISTANT.String(), Content: commandOutput})
-				tcpStrategy.Sessions.Append(sessionKey, newEntries...)
-				histories = append(histories, newEntries...)
-
-				// Send response to client
-				if commandOutput != "" {
-					_, err := conn.Write([]byte(commandOutput))
-					if err != nil {
-						break
-					}
-				}
-
-				// Trace interaction event
-				tr.TraceEvent(tracer.Event{
-					Msg:           "TCP Session Interaction",
-					RemoteAddr:    conn.RemoteAddr().String(),
-					SourceIp:      host,
-					SourcePort:    port,
-					Status:        tracer.Interaction.String(),
-					Command:       commandInput,
-					CommandOutput: commandOutput,
-					ID:            sessionID.String(),
-					Protocol:      tracer.TCP.String(),
-					Description:   servConf.Description,
-					Handler:       handlerName,
-				})
-
-				break
-			}
-		}
+// tcpTransport reads a command line per packet, and writes the outputs as they are.
+type tcpTransport struct {
+	conn net.Conn
+}
 
-		// If no command matched
-		if !matched {
-			tr.TraceEvent(tracer.Event{
-				Msg:         "TCP Session Interaction",
-				RemoteAddr:  conn.RemoteAddr().String(),
-				SourceIp:    host,
-				SourcePort:  port,
-				Status:      tracer.Interaction.String(),
-				Command:     commandInput,
-				ID:          sessionID.String(),
-				Protocol:    tracer.TCP.String(),
-				Description: servConf.Description,
-				Handler:     "not_found",
-			})
-		}
+func (t *tcpTransport) ReadLine() (string, error) {
+	buffer := make([]byte, 4096)
+	n, err := t.conn.Read(buffer)
+	if err != nil {
+		return "", err
 	}
+	return strings.TrimRight(string(buffer[:n]), "\r\n"), nil
+}
 
-	// Trace session end
-	tr.TraceEvent(tracer.Event{
-		Msg:      "End TCP Session",
-		Status:   tracer.End.String(),
-		ID:       sessionID.String(),
-		Protocol: tracer.TCP.String(),
-	})
+func (t *tcpTransport) WriteOutput(output string) error {
+	if output == "" {
+		return nil
+	}
+	_, err := t.conn.Write([]byte(output))
+	return err
 }
@@ -1,6 +1,7 @@
 package TCP
 
 import (
+	"context"
 	"net"
 	"regexp"
 	"strings"
@@ -181,6 +182,42 @@ func TestTCPStrategy_Init_InvalidAddress(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestTCPStrategy_Shutdown_ClosesActiveSessions(t *testing.T) {
+	strategy := &TCPStrategy{}
+	mt := &mockTracer{}
+
+	servConf := parser.BeelzebubServiceConfiguration{
+		Address:                "127.0.0.1:0",
+		Description:            "test",
+		DeadlineTimeoutSeconds: 60,
+		Commands: []parser.Command{
+			{Regex: regexp.MustCompile(`.*`), Handler: "ok"},
+		},
+	}
+	assert.NoError(t, strategy.Init(servConf, mt))
+
+	address := strategy.listeners[0].Addr().String()
+	conn, err := net.Dial("tcp", address)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Wait until the session is started.
+	conn.Write([]byte("ping\n"))
+	buf := make([]byte, 16)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Read(buf)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, strategy.Shutdown(ctx), context.DeadlineExceeded)
+
+	assert.Equal(t, tracer.End.String(), mt.events[len(mt.events)-1].Status)
+
+	_, err = net.Dial("tcp", address)
+	assert.Error(t, err, "the listener is closed")
+}
+
 func TestHandleTCPConnection_CommandWithEmptyHandler(t *testing.T) {
 	client, server := net.Pipe()
 	defer client.Close()
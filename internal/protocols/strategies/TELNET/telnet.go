@@ -2,20 +2,24 @@ package TELNET
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/beelzebub-labs/beelzebub/v3/internal/historystore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
-	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/protocols"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/recording"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/session"
 	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
-	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
 )
 
 // Telnet IAC (Interpret As Command) constants
@@ -31,8 +35,18 @@ const (
 	SUPPRESS_GO_AHEAD = 3   // Suppress Go Ahead option
 )
 
+// maxLineLength is the length of the longest line read, a longer line ends the session.
+const maxLineLength = 64 * 1024
+
+var errLineTooLong = errors.New("line too long")
+
 type TelnetStrategy struct {
 	Sessions *historystore.HistoryStore
+
+	mu        sync.Mutex
+	stopped   bool
+	listeners []net.Listener
+	tracker   protocols.SessionTracker
 }
 
 func (telnetStrategy *TelnetStrategy) Init(servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer) error {
@@ -49,17 +63,33 @@ func (telnetStrategy *TelnetStrategy) Init(servConf parser.BeelzebubServiceConfi
 		}
 		defer listener.Close()
 
+		telnetStrategy.mu.Lock()
+		if telnetStrategy.stopped {
+			telnetStrategy.mu.Unlock()
+			return
+		}
+		telnetStrategy.listeners = append(telnetStrategy.listeners, listener)
+		telnetStrategy.mu.Unlock()
+
 		for {
 			conn, err := listener.Accept()
 			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				log.Errorf("error accepting TELNET connection: %s", err.Error())
 				continue
 			}
+			if !telnetStrategy.tracker.Track(conn) {
+				conn.Close()
+				continue
+			}
 
 			// Set deadline timeout
 			conn.SetDeadline(time.Now().Add(time.Duration(servConf.DeadlineTimeoutSeconds) * time.Second))
 
 			go func(c net.Conn) {
+				defer telnetStrategy.tracker.Untrack(c)
 				defer func() {
 					if r := recover(); r != nil {
 						log.Errorf("panic in TELNET handler: %v", r)
@@ -77,6 +107,19 @@ func (telnetStrategy *TelnetStrategy) Init(servConf parser.BeelzebubServiceConfi
 	return nil
 }
 
+// Shutdown closes the listeners and drains the active sessions, see protocols.SessionTracker.
+func (telnetStrategy *TelnetStrategy) Shutdown(ctx context.Context) error {
+	telnetStrategy.mu.Lock()
+	telnetStrategy.stopped = true
+	for _, listener := range telnetStrategy.listeners {
+		listener.Close()
+	}
+	telnetStrategy.listeners = nil
+	telnetStrategy.mu.Unlock()
+
+	return telnetStrategy.tracker.Drain(ctx)
+}
+
 func handleTelnetConnection(conn net.Conn, servConf parser.BeelzebubServiceConfiguration, tr tracer.Tracer, telnetStrategy *TelnetStrategy) {
 	defer conn.Close()
 
@@ -135,6 +178,8 @@ func handleTelnetConnection(conn net.Conn, servConf parser.BeelzebubServiceConfi
 
 	// Validate password
 	matched, err := regexp.MatchString(servConf.PasswordRegex, password)
+	serviceMetrics := protocols.ServiceMetrics(tr)
+	serviceMetrics.AuthAttempt(metrics.PasswordAuth, matched)
 	if err != nil {
 		log.Errorf("error regex: %s, %s", servConf.PasswordRegex, err.Error())
 		conn.Write([]byte("Login incorrect\r\n"))
@@ -147,146 +192,75 @@ func handleTelnetConnection(conn net.Conn, servConf parser.BeelzebubServiceConfi
 	}
 
 	// Session phase - authenticated
-	uuidSession := uuid.New()
-	sessionKey := "TELNET" + host + username
-
-	tr.TraceEvent(tracer.Event{
-		Msg:         "New TELNET Terminal Session",
-		Protocol:    tracer.TELNET.String(),
-		RemoteAddr:  conn.RemoteAddr().String(),
-		SourceIp:    host,
-		SourcePort:  port,
-		Status:      tracer.Start.String(),
-		ID:          uuidSession.String(),
-		User:        username,
-		Description: servConf.Description,
-	})
-
-	// Load history for LLM context
-	var histories []plugins.Message
-	if telnetStrategy.Sessions.HasKey(sessionKey) {
-		histories = telnetStrategy.Sessions.Query(sessionKey)
+	sess := &session.Session{
+		ID:              uuid.New().String(),
+		Protocol:        tracer.TELNET,
+		Name:            "TELNET Terminal Session",
+		ServConf:        servConf,
+		Tracer:          tr,
+		Histories:       telnetStrategy.Sessions,
+		HistoryKey:      "TELNET" + host + username,
+		RemoteAddr:      conn.RemoteAddr().String(),
+		SourceIp:        host,
+		SourcePort:      port,
+		User:            username,
+		Description:     servConf.Description,
+		UnmatchedOutput: "command not found",
+		ExitCommand:     "exit",
+		Metrics:         serviceMetrics,
 	}
+	transport := &telnetTransport{conn: conn, prompt: buildPrompt(username, servConf.ServerName)}
 
-	// Interactive command loop
-	for {
-		// Display prompt (no newline - user types on same line)
-		prompt := buildPrompt(username, servConf.ServerName)
-		_, err := conn.Write([]byte(prompt))
+	// Record the session; the client echoes the typed line locally, so the line is recorded as output too
+	if servConf.Recording.Enabled {
+		recorder, err := recording.Create(recording.Directory(servConf.Recording.Path), sess.ID, recording.Header{}, recording.NewOptions(servConf.Recording))
 		if err != nil {
-			break
-		}
-
-		// Read command from user
-		commandInput, err := readLine(conn)
-		if err != nil {
-			break
-		}
-		commandInput = strings.TrimSpace(commandInput)
-
-		// Handle exit command
-		if commandInput == "exit" {
-			break
+			log.Errorf("error during recording TELNET session: %s", err.Error())
+		} else {
+			defer recorder.Close()
+			transport.recorder = recorder
 		}
+	}
 
-		// Match command against regexes
-		matched := false
-		for _, command := range servConf.Commands {
-			if command.Regex.MatchString(commandInput) {
-				matched = true
-				commandOutput := command.Handler
-				handlerName := command.Name
-				if handlerName == "" {
-					handlerName = "configured_regex"
-				}
+	sess.Run(transport)
+}
 
-				// Plugin dispatch via registry
-				if command.Plugin != "" {
-					if cp, ok := plugin.GetCommand(command.Plugin); ok {
-						output, err := cp.Execute(context.Background(), plugin.CommandRequest{
-							Command:  commandInput,
-							ClientIP: host,
-							Protocol: "telnet",
-							History:  plugins.MessagesToPlugin(histories),
-							Config:   plugins.ConfigFromServiceConf(servConf),
-						})
-						if err != nil {
-							log.Errorf("plugin %q execute error: %s", command.Plugin, err.Error())
-							commandOutput = "command not found"
-						} else {
-							commandOutput = output
-						}
-					} else {
-						log.Warnf("unknown plugin %q, skipping", command.Plugin)
-					}
-				}
+// telnetTransport displays the prompt before reading a command line, and writes the outputs followed by CRLF.
+type telnetTransport struct {
+	conn     net.Conn
+	prompt   string
+	recorder *recording.Recorder
+}
 
-				// Store command and response in history
-				var newEntries []plugins.Message
-				newEntries = append(newEntries, plugins.Message{Role: plugins.USER.String(), Content: commandInput})
-				newEntries = append(newEntries, plugins.Message{Role: plugins.ASSISTANT.String(), Content: commandOutput})
-				telnetStrategy.Sessions.Append(sessionKey, newEntries...)
-				histories = append(histories, newEntries...)
-
-				// Send response to client
-				_, err := conn.Write([]byte(commandOutput + "\r\n"))
-				if err != nil {
-					break
-				}
+func (t *telnetTransport) ReadLine() (string, error) {
+	// Display prompt (no newline - user types on same line)
+	if err := t.write(t.prompt); err != nil {
+		return "", err
+	}
 
-				// Trace interaction event
-				tr.TraceEvent(tracer.Event{
-					Msg:           "TELNET Terminal Session Interaction",
-					RemoteAddr:    conn.RemoteAddr().String(),
-					SourceIp:      host,
-					SourcePort:    port,
-					Status:        tracer.Interaction.String(),
-					Command:       commandInput,
-					CommandOutput: commandOutput,
-					ID:            uuidSession.String(),
-					Protocol:      tracer.TELNET.String(),
-					User:          username,
-					Description:   servConf.Description,
-					Handler:       handlerName,
-				})
-
-				break // Found match, exit command loop
-			}
-		}
+	commandInput, err := readLine(t.conn)
+	if err != nil {
+		return "", err
+	}
+	if t.recorder != nil {
+		t.recorder.Input([]byte(commandInput + "\r\n"))
+		t.recorder.Output([]byte(commandInput + "\r\n"))
+	}
+	return strings.TrimSpace(commandInput), nil
+}
 
-		// If no command matched, send "command not found"
-		if !matched {
-			commandOutput := "command not found"
-			_, err := conn.Write([]byte(commandOutput + "\r\n"))
-			if err != nil {
-				break
-			}
+func (t *telnetTransport) WriteOutput(output string) error {
+	return t.write(output + "\r\n")
+}
 
-			// Still trace the interaction even for unmatched commands
-			tr.TraceEvent(tracer.Event{
-				Msg:           "TELNET Terminal Session Interaction",
-				RemoteAddr:    conn.RemoteAddr().String(),
-				SourceIp:      host,
-				SourcePort:    port,
-				Status:        tracer.Interaction.String(),
-				Command:       commandInput,
-				CommandOutput: commandOutput,
-				ID:            uuidSession.String(),
-				Protocol:      tracer.TELNET.String(),
-				User:          username,
-				Description:   servConf.Description,
-				Handler:       "not_found",
-			})
-		}
+func (t *telnetTransport) write(data string) error {
+	if _, err := t.conn.Write([]byte(data)); err != nil {
+		return err
 	}
-
-	// Trace session end
-	tr.TraceEvent(tracer.Event{
-		Msg:      "End TELNET Session",
-		Status:   tracer.End.String(),
-		ID:       uuidSession.String(),
-		Protocol: tracer.TELNET.String(),
-	})
+	if t.recorder != nil {
+		t.recorder.Output([]byte(data))
+	}
+	return nil
 }
 
 func negotiateTelnet(conn net.Conn) {
@@ -346,6 +320,9 @@ func readLine(conn net.Conn) (string, error) {
 
 		// Only keep printable ASCII and tab, skip control bytes
 		if b >= 32 && b <= 126 || b == '\t' {
+			if len(line) >= maxLineLength {
+				return "", errLineTooLong
+			}
 			line = append(line, b)
 		}
 	}
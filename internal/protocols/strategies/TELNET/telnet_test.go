@@ -3,7 +3,10 @@ package TELNET
 import (
 	"io"
 	"net"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
@@ -195,6 +198,17 @@ func TestReadLine_ConnectionClose(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestReadLine_TooLong(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go client.Write([]byte(strings.Repeat("a", maxLineLength+1) + "\n"))
+
+	_, err := readLine(server)
+	assert.ErrorIs(t, err, errLineTooLong)
+}
+
 func TestTelnetStrategy_Init(t *testing.T) {
 	strategy := &TelnetStrategy{}
 	mt := &mockTracer{}
@@ -437,3 +451,50 @@ func TestHandleTelnetConnection_UnmatchedCommand(t *testing.T) {
 	}
 	assert.True(t, found, "expected not_found handler event")
 }
+
+func TestHandleTelnetConnection_Recording(t *testing.T) {
+	client, server := net.Pipe()
+
+	mt := &mockTracer{}
+	servConf := parser.BeelzebubServiceConfiguration{
+		DeadlineTimeoutSeconds: 10,
+		PasswordRegex:          ".*",
+		ServerName:             "testserver",
+		Commands: []parser.Command{
+			{Regex: regexp.MustCompile(`^ls$`), Handler: "file.txt"},
+		},
+		Recording: parser.SessionRecording{Enabled: true, Path: t.TempDir()},
+	}
+	strategy := newTelnetStrategy()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleTelnetConnection(server, servConf, mt, strategy)
+	}()
+
+	doTelnetAuth(client, "user", "pass")
+	drain(client, 300*time.Millisecond)
+	client.Write([]byte("ls\n"))
+	drain(client, 300*time.Millisecond)
+	client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	var sessionID string
+	for _, e := range mt.events {
+		if e.Status == tracer.Start.String() {
+			sessionID = e.ID
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(servConf.Recording.Path, sessionID+".cast"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"version":2`)
+	assert.Contains(t, string(data), `"o","user@testserver:~$ "]`)
+	assert.Contains(t, string(data), `"i","ls\r\n"]`)
+	assert.Contains(t, string(data), `"o","file.txt\r\n"]`)
+}
@@ -0,0 +1,79 @@
+// Package quarantine stores the files dropped by the attackers, content-addressed by their SHA256 hash
+package quarantine
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Sample describes a quarantined file.
+type Sample struct {
+	SHA256 string
+	SHA1   string
+	MD5    string
+	Size   int64
+	// Path is the location of the sample in the quarantine directory, empty when the sample is not stored.
+	Path string
+}
+
+// NewSample computes the hashes of data.
+func NewSample(data []byte) Sample {
+	sha256Sum := sha256.Sum256(data)
+	sha1Sum := sha1.Sum(data)
+	md5Sum := md5.Sum(data)
+
+	return Sample{
+		SHA256: hex.EncodeToString(sha256Sum[:]),
+		SHA1:   hex.EncodeToString(sha1Sum[:]),
+		MD5:    hex.EncodeToString(md5Sum[:]),
+		Size:   int64(len(data)),
+	}
+}
+
+// Store writes data into dir, named by its SHA256 hash; when dir is empty the sample is only hashed.
+// Samples already quarantined are not rewritten, and the files are never executable.
+func Store(dir string, data []byte) (Sample, error) {
+	sample := NewSample(data)
+	if dir == "" {
+		return sample, nil
+	}
+
+	samplePath := filepath.Join(dir, sample.SHA256)
+	if _, err := os.Stat(samplePath); err == nil {
+		sample.Path = samplePath
+		return sample, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return sample, fmt.Errorf("error during stat quarantined sample: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return sample, fmt.Errorf("error during create quarantine directory: %w", err)
+	}
+	// The sample is written to a temporary file first, so that a partial write is never visible under its hash.
+	tempFile, err := os.CreateTemp(dir, ".sample-*")
+	if err != nil {
+		return sample, fmt.Errorf("error during create quarantined sample: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return sample, fmt.Errorf("error during write quarantined sample: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return sample, fmt.Errorf("error during write quarantined sample: %w", err)
+	}
+	if err := os.Rename(tempFile.Name(), samplePath); err != nil {
+		return sample, fmt.Errorf("error during write quarantined sample: %w", err)
+	}
+
+	sample.Path = samplePath
+	return sample, nil
+}
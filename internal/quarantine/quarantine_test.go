@@ -0,0 +1,52 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSample(t *testing.T) {
+	sample := NewSample([]byte("hello"))
+
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", sample.SHA256)
+	assert.Equal(t, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", sample.SHA1)
+	assert.Equal(t, "5d41402abc4b2a76b9719d911017c592", sample.MD5)
+	assert.Equal(t, int64(5), sample.Size)
+	assert.Empty(t, sample.Path)
+}
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "quarantine")
+
+	sample, err := Store(dir, []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, sample.SHA256), sample.Path)
+
+	data, err := os.ReadFile(sample.Path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	info, err := os.Stat(sample.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Storing the same content again is idempotent.
+	again, err := Store(dir, []byte("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, sample, again)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestStore_WithoutDirectory(t *testing.T) {
+	sample, err := Store("", []byte("hello"))
+	require.NoError(t, err)
+	assert.Empty(t, sample.Path)
+	assert.NotEmpty(t, sample.SHA256)
+}
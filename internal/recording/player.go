@@ -0,0 +1,109 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PlayOptions are the playback settings of a recording.
+type PlayOptions struct {
+	// Speed is the playback speed multiplier, zero means real time.
+	Speed float64
+	// IdleLimit caps the pauses between two events, zero keeps the recorded pauses.
+	IdleLimit time.Duration
+}
+
+// Play writes the output events of the recording read from reader into writer, honouring the recorded timing.
+func Play(ctx context.Context, reader io.Reader, writer io.Writer, options PlayOptions) error {
+	speed := options.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	lines := bufio.NewReader(reader)
+	line, err := lines.ReadBytes('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+		return fmt.Errorf("error during read recording header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(line, &header); err != nil {
+		return fmt.Errorf("error during parse recording header: %w", err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+
+	var previous float64
+	for number := 2; ; number++ {
+		line, err := lines.ReadBytes('\n')
+		if len(line) > 0 {
+			elapsed, eventType, data, parseErr := parseEvent(line)
+			if parseErr != nil {
+				return fmt.Errorf("error during parse recording line %d: %w", number, parseErr)
+			}
+			if eventType == Output {
+				delay := time.Duration((elapsed - previous) * float64(time.Second))
+				if options.IdleLimit > 0 && delay > options.IdleLimit {
+					delay = options.IdleLimit
+				}
+				if err := sleep(ctx, time.Duration(float64(delay)/speed)); err != nil {
+					return err
+				}
+				if _, err := io.WriteString(writer, data); err != nil {
+					return err
+				}
+				previous = elapsed
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error during read recording: %w", err)
+		}
+	}
+}
+
+// parseEvent decodes an event line, e.g. [1.25, "o", "ls\r\n"].
+func parseEvent(line []byte) (float64, EventType, string, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return 0, "", "", err
+	}
+	if len(fields) != 3 {
+		return 0, "", "", fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	var elapsed float64
+	var eventType EventType
+	var data string
+	if err := json.Unmarshal(fields[0], &elapsed); err != nil {
+		return 0, "", "", err
+	}
+	if err := json.Unmarshal(fields[1], &eventType); err != nil {
+		return 0, "", "", err
+	}
+	if err := json.Unmarshal(fields[2], &data); err != nil {
+		return 0, "", "", err
+	}
+	return elapsed, eventType, data, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
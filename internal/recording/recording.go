@@ -0,0 +1,322 @@
+// Package recording records the interactive sessions as asciicast v2 files and plays them back
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDirectory is the directory of the recordings when the service does not configure one.
+const DefaultDirectory = "./recordings"
+
+// Extension is the file extension of the recordings, the file name is the session ID.
+const Extension = ".cast"
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// EventType is the type of an asciicast event.
+type EventType string
+
+const (
+	Output EventType = "o"
+	Input  EventType = "i"
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Options are the limits of the recordings of a service, zero values disable the corresponding limit.
+type Options struct {
+	// MaxSize is the size in bytes of a recording, the rest of the session is not recorded.
+	MaxSize int64
+	// MaxRecordings is the number of recordings kept in the directory, the oldest ones are removed.
+	MaxRecordings int
+	// MaxAge is the age after which a recording is removed.
+	MaxAge time.Duration
+}
+
+// NewOptions converts the limits of the recording configuration of a service, zero values disable the corresponding limit.
+func NewOptions(configuration parser.SessionRecording) Options {
+	return Options{
+		MaxSize:       int64(configuration.MaxSizeMegabytes) << 20,
+		MaxRecordings: configuration.MaxRecordings,
+		MaxAge:        time.Duration(configuration.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// errMaxSize stops a recording once it reaches Options.MaxSize, it is not reported by Close.
+var errMaxSize = errors.New("recording size limit reached")
+
+// Directory returns the recordings directory of a service, falling back to DefaultDirectory.
+func Directory(path string) string {
+	if path == "" {
+		return DefaultDirectory
+	}
+	return path
+}
+
+// Path returns the path of the recording of a session, the session ID must be a plain file name.
+func Path(dir, sessionID string) (string, error) {
+	if sessionID == "" || sessionID == "." || sessionID == ".." || filepath.Base(sessionID) != sessionID {
+		return "", fmt.Errorf("invalid session ID %q", sessionID)
+	}
+	return filepath.Join(dir, sessionID+Extension), nil
+}
+
+// Recorder writes the events of a session, timestamped from the moment the recorder is created.
+// Every event is written as soon as it happens, so that an abruptly closed session is still replayable.
+type Recorder struct {
+	mu      sync.Mutex
+	writer  io.Writer
+	closer  io.Closer
+	start   time.Time
+	pending map[EventType][]byte
+	err     error
+	size    int64
+	maxSize int64
+}
+
+// Create creates the recording of a session into dir, the directory is created when missing. The recordings of dir
+// exceeding the retention of options are removed first.
+func Create(dir, sessionID string, header Header, options Options) (*Recorder, error) {
+	recordingPath, err := Path(dir, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error during create recordings directory: %w", err)
+	}
+	if err := removeOldRecordings(dir, options, time.Now()); err != nil {
+		log.Errorf("Error during remove old recordings of %s: %s", dir, err.Error())
+	}
+	file, err := os.OpenFile(recordingPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error during create recording: %w", err)
+	}
+
+	recorder, err := NewRecorder(file, header)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	recorder.closer = file
+	recorder.maxSize = options.MaxSize
+	return recorder, nil
+}
+
+// removeOldRecordings removes the recordings older than MaxAge, then the oldest ones to leave room for a new recording
+// within MaxRecordings.
+func removeOldRecordings(dir string, options Options, now time.Time) error {
+	if options.MaxRecordings <= 0 && options.MaxAge <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type recordingFile struct {
+		path    string
+		modTime time.Time
+	}
+	var recordings []recordingFile
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), Extension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		recordingPath := filepath.Join(dir, entry.Name())
+		if options.MaxAge > 0 && now.Sub(info.ModTime()) > options.MaxAge {
+			if err := os.Remove(recordingPath); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		recordings = append(recordings, recordingFile{path: recordingPath, modTime: info.ModTime()})
+	}
+
+	if options.MaxRecordings > 0 {
+		slices.SortFunc(recordings, func(a, b recordingFile) int {
+			return a.modTime.Compare(b.modTime)
+		})
+		for len(recordings) >= options.MaxRecordings {
+			if err := os.Remove(recordings[0].path); err != nil {
+				errs = append(errs, err)
+			}
+			recordings = recordings[1:]
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewRecorder writes the header into writer and returns a recorder for the session events.
+// The zero fields of the header are filled with the asciicast version, an 80x24 terminal and the current time.
+func NewRecorder(writer io.Writer, header Header) (*Recorder, error) {
+	start := time.Now()
+	header.Version = 2
+	if header.Width <= 0 {
+		header.Width = defaultWidth
+	}
+	if header.Height <= 0 {
+		header.Height = defaultHeight
+	}
+	if header.Timestamp == 0 {
+		header.Timestamp = start.Unix()
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("error during marshal recording header: %w", err)
+	}
+	if _, err := writer.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("error during write recording header: %w", err)
+	}
+
+	return &Recorder{
+		writer:  writer,
+		start:   start,
+		pending: make(map[EventType][]byte),
+		size:    int64(len(data) + 1),
+	}, nil
+}
+
+// Output records the data written to the client.
+func (r *Recorder) Output(data []byte) {
+	r.record(Output, data)
+}
+
+// Input records the data received from the client.
+func (r *Recorder) Input(data []byte) {
+	r.record(Input, data)
+}
+
+func (r *Recorder) record(eventType EventType, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil || len(data) == 0 {
+		return
+	}
+
+	// A multi-byte character split across two writes is kept until it is complete, asciicast events are UTF-8 strings.
+	data = append(r.pending[eventType], data...)
+	cut := incompleteSuffix(data)
+	r.pending[eventType] = append([]byte(nil), data[len(data)-cut:]...)
+	data = data[:len(data)-cut]
+	if len(data) == 0 {
+		return
+	}
+
+	r.write(time.Since(r.start), eventType, data)
+}
+
+func (r *Recorder) write(elapsed time.Duration, eventType EventType, data []byte) {
+	seconds := math.Round(elapsed.Seconds()*1e6) / 1e6
+	line, err := json.Marshal([]any{seconds, eventType, string(data)})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if r.maxSize > 0 && r.size+int64(len(line))+1 > r.maxSize {
+		// The recording stays a valid asciicast file, the rest of the session is not recorded.
+		r.err = errMaxSize
+		return
+	}
+	n, err := r.writer.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		// The session goes on without recording, a full disk must not break the honeypot.
+		r.err = err
+	}
+}
+
+// Close flushes the pending bytes and closes the underlying file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		for _, eventType := range []EventType{Input, Output} {
+			if len(r.pending[eventType]) > 0 {
+				r.write(time.Since(r.start), eventType, r.pending[eventType])
+			}
+		}
+	}
+	r.pending = nil
+
+	var err error
+	if r.err != nil && !errors.Is(r.err, os.ErrClosed) && !errors.Is(r.err, errMaxSize) {
+		err = fmt.Errorf("error during write recording: %w", r.err)
+	}
+	r.err = os.ErrClosed
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("error during close recording: %w", closeErr)
+		}
+		r.closer = nil
+	}
+	return err
+}
+
+// ReadWriter wraps the client connection, the reads are recorded as input and the writes as output.
+func (r *Recorder) ReadWriter(rw io.ReadWriter) io.ReadWriter {
+	return &recordedReadWriter{rw: rw, recorder: r}
+}
+
+type recordedReadWriter struct {
+	rw       io.ReadWriter
+	recorder *Recorder
+}
+
+func (r *recordedReadWriter) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	r.recorder.Input(p[:n])
+	return n, err
+}
+
+func (r *recordedReadWriter) Write(p []byte) (int, error) {
+	n, err := r.rw.Write(p)
+	r.recorder.Output(p[:n])
+	return n, err
+}
+
+// incompleteSuffix returns the length of the truncated UTF-8 sequence at the end of data.
+func incompleteSuffix(data []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if utf8.RuneStart(b) {
+			if b >= utf8.RuneSelf && !utf8.FullRune(data[len(data)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&buf, Header{Env: map[string]string{"TERM": "xterm"}})
+	require.NoError(t, err)
+
+	rw := recorder.ReadWriter(&struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("ls\r"), &bytes.Buffer{}})
+	_, err = rw.Write([]byte("$ "))
+	require.NoError(t, err)
+	_, err = rw.Read(make([]byte, 16))
+	require.NoError(t, err)
+	// "é" is split across two writes.
+	recorder.Output([]byte("caf\xc3"))
+	recorder.Output([]byte("\xa9\r\n"))
+	require.NoError(t, recorder.Close())
+	recorder.Output([]byte("ignored"))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 5)
+
+	var header Header
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, 2, header.Version)
+	assert.Equal(t, 80, header.Width)
+	assert.Equal(t, 24, header.Height)
+	assert.NotZero(t, header.Timestamp)
+	assert.Equal(t, map[string]string{"TERM": "xterm"}, header.Env)
+
+	var events []EventType
+	var data []string
+	for _, line := range lines[1:] {
+		elapsed, eventType, text, err := parseEvent([]byte(line))
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, elapsed, 0.0)
+		events = append(events, eventType)
+		data = append(data, text)
+	}
+	assert.Equal(t, []EventType{Output, Input, Output, Output}, events)
+	assert.Equal(t, []string{"$ ", "ls\r", "caf", "é\r\n"}, data)
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recordings")
+
+	recorder, err := Create(dir, "4a3b", Header{}, Options{})
+	require.NoError(t, err)
+	recorder.Output([]byte("hello"))
+	require.NoError(t, recorder.Close())
+
+	info, err := os.Stat(filepath.Join(dir, "4a3b.cast"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = Create(dir, "4a3b", Header{}, Options{})
+	assert.Error(t, err)
+
+	for _, sessionID := range []string{"", "..", "../4a3b", "a/b"} {
+		_, err := Create(dir, sessionID, Header{}, Options{})
+		assert.Error(t, err, sessionID)
+	}
+}
+
+func TestCreate_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+
+	recorder, err := Create(dir, "4a3b", Header{}, Options{MaxSize: 200})
+	require.NoError(t, err)
+	recorder.Output([]byte("hello"))
+	recorder.Output([]byte(strings.Repeat("x", 200)))
+	recorder.Output([]byte("world"))
+	require.NoError(t, recorder.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "4a3b.cast"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 200)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2, "the recording stops at the first event exceeding the limit")
+	_, _, text, err := parseEvent([]byte(lines[1]))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", text)
+}
+
+func TestRemoveOldRecordings(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"a.cast", "b.cast", "c.cast", "d.cast", "notes.txt"} {
+		recordingPath := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(recordingPath, nil, 0600))
+		modTime := now.Add(-time.Duration(10-i) * time.Hour)
+		require.NoError(t, os.Chtimes(recordingPath, modTime, modTime))
+	}
+
+	// a.cast is too old, then b.cast is removed to leave room for a new recording.
+	require.NoError(t, removeOldRecordings(dir, Options{MaxRecordings: 3, MaxAge: 8 * time.Hour}, now))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"c.cast", "d.cast", "notes.txt"}, names)
+
+	require.NoError(t, removeOldRecordings(dir, Options{}, now))
+	recorder, err := Create(dir, "e", Header{}, Options{MaxRecordings: 1})
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "only the new recording and the other files are left")
+}
+
+func TestPlay(t *testing.T) {
+	recording := `{"version":2,"width":80,"height":24,"timestamp":1760669641}
+[0.1,"o","$ "]
+[0.5,"i","ls\r"]
+[0.6,"o","ls\r\n"]
+[30,"o","bin etc\r\n"]
+[30.1,"r","100x40"]`
+
+	var out bytes.Buffer
+	started := time.Now()
+	err := Play(context.Background(), strings.NewReader(recording), &out, PlayOptions{Speed: 10, IdleLimit: time.Second})
+	require.NoError(t, err)
+
+	assert.Equal(t, "$ ls\r\nbin etc\r\n", out.String())
+	// 0.6s of recorded timing plus the 30s pause capped to 1s, at 10x.
+	assert.GreaterOrEqual(t, time.Since(started), 150*time.Millisecond)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
+
+func TestPlay_Errors(t *testing.T) {
+	tests := []string{
+		"",
+		`{"version":1}`,
+		"{\"version\":2}\n[0.1,\"o\"]\n",
+		"{\"version\":2}\nnot json\n",
+	}
+
+	for _, recording := range tests {
+		err := Play(context.Background(), strings.NewReader(recording), &bytes.Buffer{}, PlayOptions{})
+		assert.Error(t, err, recording)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := Play(ctx, strings.NewReader("{\"version\":2}\n[5,\"o\",\"late\"]\n"), &bytes.Buffer{}, PlayOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,258 @@
+// Package rotation is responsible for writing files rotated by size or time, e.g. the events and the logs
+package rotation
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// backupTimeFormat is the timestamp of the rotated files, e.g. events-20240501T100000.000.jsonl.
+const backupTimeFormat = "20060102T150405.000"
+
+const compressedExtension = ".gz"
+
+// Options are the rotation settings of a file, zero values disable the corresponding rotation.
+type Options struct {
+	// MaxSize is the size in bytes after which the file is rotated.
+	MaxSize int64
+	// Interval rotates the file when a new period starts, the periods being aligned on UTC, e.g. every day at midnight.
+	Interval time.Duration
+	// Compress gzips the rotated files, in the background.
+	Compress bool
+	// MaxBackups is the number of rotated files kept, the oldest ones are removed.
+	MaxBackups int
+}
+
+// Writer appends to a file, rotating it according to its Options: the current file is renamed with the timestamp of
+// the rotation, e.g. events.jsonl becomes events-20240501T100000.000.jsonl, and a new one is created.
+// Writer is safe for concurrent use.
+type Writer struct {
+	path    string
+	options Options
+
+	mu          sync.Mutex
+	file        *os.File
+	size        int64
+	periodStart time.Time
+	closed      bool
+
+	// millMu serializes the compression and the retention of the rotated files, which run in the background.
+	millMu sync.Mutex
+	mill   sync.WaitGroup
+
+	now func() time.Time
+}
+
+// Open opens the file in append mode, creating it if needed.
+func Open(path string, options Options) (*Writer, error) {
+	writer := &Writer{path: path, options: options, now: time.Now}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (writer *Writer) open() error {
+	file, err := os.OpenFile(writer.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error during open %s: %w", writer.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error during open %s: %w", writer.path, err)
+	}
+
+	writer.file = file
+	writer.size = info.Size()
+	// An existing file belongs to the period of its last write, so that a restart does not extend the period.
+	started := writer.now()
+	if info.Size() > 0 {
+		started = info.ModTime()
+	}
+	writer.periodStart = writer.period(started)
+	return nil
+}
+
+func (writer *Writer) period(t time.Time) time.Time {
+	if writer.options.Interval <= 0 {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(writer.options.Interval)
+}
+
+// Write appends p to the file, rotating it first when p would exceed MaxSize or a new period has started.
+func (writer *Writer) Write(p []byte) (int, error) {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if writer.closed {
+		return 0, os.ErrClosed
+	}
+
+	if writer.size > 0 && writer.shouldRotate(int64(len(p))) {
+		if err := writer.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := writer.file.Write(p)
+	writer.size += int64(n)
+	return n, err
+}
+
+func (writer *Writer) WriteString(s string) (int, error) {
+	return writer.Write([]byte(s))
+}
+
+func (writer *Writer) shouldRotate(length int64) bool {
+	if writer.options.MaxSize > 0 && writer.size+length > writer.options.MaxSize {
+		return true
+	}
+	return writer.options.Interval > 0 && !writer.period(writer.now()).Equal(writer.periodStart)
+}
+
+// Rotate rotates the file, even if it does not meet the rotation settings.
+func (writer *Writer) Rotate() error {
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+
+	if writer.closed {
+		return os.ErrClosed
+	}
+	return writer.rotate()
+}
+
+func (writer *Writer) rotate() error {
+	if err := writer.file.Close(); err != nil {
+		return fmt.Errorf("error during close %s: %w", writer.path, err)
+	}
+	backup := writer.backupPath(writer.now())
+	if err := os.Rename(writer.path, backup); err != nil {
+		return fmt.Errorf("error during rotate %s: %w", writer.path, err)
+	}
+	if err := writer.open(); err != nil {
+		return err
+	}
+
+	writer.mill.Add(1)
+	go func() {
+		defer writer.mill.Done()
+		writer.millMu.Lock()
+		defer writer.millMu.Unlock()
+
+		if writer.options.Compress {
+			if err := compress(backup); err != nil {
+				log.Errorf("Error during compress %s: %s", backup, err.Error())
+			}
+		}
+		if err := writer.removeOldBackups(); err != nil {
+			log.Errorf("Error during remove old backups of %s: %s", writer.path, err.Error())
+		}
+	}()
+	return nil
+}
+
+func (writer *Writer) backupPath(t time.Time) string {
+	dir, base := filepath.Split(writer.path)
+	extension := filepath.Ext(base)
+	name := strings.TrimSuffix(base, extension)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", name, t.UTC().Format(backupTimeFormat), extension))
+}
+
+// Backups returns the rotated files, from the oldest to the newest.
+func (writer *Writer) Backups() ([]string, error) {
+	dir, base := filepath.Split(writer.path)
+	extension := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, extension) + "-"
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		timestamp, found := strings.CutPrefix(name, prefix)
+		if !found || entry.IsDir() {
+			continue
+		}
+		timestamp = strings.TrimSuffix(strings.TrimSuffix(timestamp, compressedExtension), extension)
+		if _, err := time.Parse(backupTimeFormat, timestamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// The timestamps sort lexically.
+	slices.Sort(backups)
+	return backups, nil
+}
+
+func (writer *Writer) removeOldBackups() error {
+	if writer.options.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := writer.Backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for len(backups) > writer.options.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			errs = append(errs, err)
+		}
+		backups = backups[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the file, waiting for the compression of the rotated files.
+func (writer *Writer) Close() error {
+	writer.mu.Lock()
+	if writer.closed {
+		writer.mu.Unlock()
+		return nil
+	}
+	writer.closed = true
+	err := writer.file.Close()
+	writer.mu.Unlock()
+
+	writer.mill.Wait()
+	return err
+}
+
+// compress gzips the file, then removes it.
+func compress(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.OpenFile(path+compressedExtension, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gzipWriter := gzip.NewWriter(destination)
+	if _, err := io.Copy(gzipWriter, source); err != nil {
+		destination.Close()
+		os.Remove(path + compressedExtension)
+		return err
+	}
+	if err := errors.Join(gzipWriter.Close(), destination.Close()); err != nil {
+		os.Remove(path + compressedExtension)
+		return err
+	}
+	source.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,122 @@
+package rotation
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock returns a clock advancing by a millisecond on every call, so that the backups have distinct names.
+func fakeClock(start time.Time) func() time.Time {
+	now := start
+	return func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	writer, err := Open(path, Options{MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+	writer.now = fakeClock(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := writer.WriteString(line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	backups, err := writer.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2, "the oldest backup is removed")
+	assert.Regexp(t, `/events-20240501T100000\.\d{3}\.jsonl$`, backups[0])
+	assert.Equal(t, "second\n", readFile(t, backups[0]))
+	assert.Equal(t, "third\n", readFile(t, backups[1]))
+	assert.Equal(t, "fourth\n", readFile(t, path))
+
+	_, err = writer.Write([]byte("closed"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestWriter_RotatesByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beelzebub.log")
+	writer, err := Open(path, Options{Interval: 24 * time.Hour})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	writer.now = func() time.Time { return now }
+	writer.periodStart = writer.period(now)
+
+	_, err = writer.WriteString("day 1\n")
+	require.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	_, err = writer.WriteString("day 1 again\n")
+	require.NoError(t, err)
+	now = now.Add(time.Minute)
+	_, err = writer.WriteString("day 2\n")
+	require.NoError(t, err)
+
+	backups, err := writer.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "day 1\nday 1 again\n", readFile(t, backups[0]))
+	assert.Equal(t, "day 2\n", readFile(t, path))
+}
+
+func TestWriter_ResumesPeriodOfExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("yesterday\n"), 0644))
+	yesterday := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(path, yesterday, yesterday))
+
+	writer, err := Open(path, Options{Interval: 24 * time.Hour})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	_, err = writer.WriteString("today\n")
+	require.NoError(t, err)
+
+	backups, err := writer.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "yesterday\n", readFile(t, backups[0]))
+}
+
+func TestWriter_Compress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	writer, err := Open(path, Options{Compress: true})
+	require.NoError(t, err)
+
+	_, err = writer.WriteString("compressed\n")
+	require.NoError(t, err)
+	require.NoError(t, writer.Rotate())
+	require.NoError(t, writer.Close())
+
+	backups, err := writer.Backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, compressedExtension, filepath.Ext(backups[0]))
+
+	file, err := os.Open(backups[0])
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, "compressed\n", string(data))
+}
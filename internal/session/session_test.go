@@ -0,0 +1,207 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/historystore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/parser"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+	"github.com/beelzebub-labs/beelzebub/v3/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTracer struct {
+	events []tracer.Event
+}
+
+func (m *mockTracer) TraceEvent(event tracer.Event) {
+	m.events = append(m.events, event)
+}
+
+// mockTransport reads the lines in order, then io.EOF.
+type mockTransport struct {
+	lines   []string
+	outputs []string
+}
+
+func (m *mockTransport) ReadLine() (string, error) {
+	if len(m.lines) == 0 {
+		return "", io.EOF
+	}
+	line := m.lines[0]
+	m.lines = m.lines[1:]
+	return line, nil
+}
+
+func (m *mockTransport) WriteOutput(output string) error {
+	m.outputs = append(m.outputs, output)
+	return nil
+}
+
+type failingPlugin struct{}
+
+func (failingPlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{Name: "SessionTestFailing"}
+}
+
+func (failingPlugin) Execute(context.Context, plugin.CommandRequest) (string, error) {
+	return "", errors.New("boom")
+}
+
+// statefulPlugin records the sessions it is asked to end.
+type statefulPlugin struct {
+	ended []string
+}
+
+func (*statefulPlugin) Metadata() plugin.Metadata {
+	return plugin.Metadata{Name: "SessionTestStateful"}
+}
+
+func (*statefulPlugin) Execute(context.Context, plugin.CommandRequest) (string, error) {
+	return "ok", nil
+}
+
+func (p *statefulPlugin) EndSession(sessionID string) {
+	p.ended = append(p.ended, sessionID)
+}
+
+var stateful = &statefulPlugin{}
+
+func init() {
+	plugin.Register(failingPlugin{})
+	plugin.Register(stateful)
+}
+
+func newSession(tr tracer.Tracer, servConf parser.BeelzebubServiceConfiguration) *Session {
+	return &Session{
+		ID:         "session",
+		Protocol:   tracer.TELNET,
+		Name:       "TELNET Terminal Session",
+		ServConf:   servConf,
+		Tracer:     tr,
+		Histories:  historystore.NewHistoryStore(),
+		HistoryKey: "TELNET127.0.0.1root",
+		SourceIp:   "127.0.0.1",
+		User:       "root",
+	}
+}
+
+func TestSession_Run(t *testing.T) {
+	mt := &mockTracer{}
+	sess := newSession(mt, parser.BeelzebubServiceConfiguration{
+		Commands: []parser.Command{
+			{Name: "ls", Regex: regexp.MustCompile(`^ls$`), Handler: "file.txt"},
+			{Regex: regexp.MustCompile(`^pwd$`), Handler: "/root"},
+			{Regex: regexp.MustCompile(`^crash$`), Handler: "unused", Plugin: "SessionTestFailing"},
+		},
+	})
+	sess.UnmatchedOutput = "command not found"
+	sess.ExitCommand = "exit"
+
+	transport := &mockTransport{lines: []string{"ls", "pwd; id", "crash", "exit", "ls"}}
+	sess.Run(transport)
+
+	assert.Equal(t, []string{"file.txt", "/root\ncommand not found", "command not found"}, transport.outputs)
+	assert.Equal(t, []string{"ls"}, transport.lines)
+
+	require.Len(t, mt.events, 5)
+	assert.Equal(t, "New TELNET Terminal Session", mt.events[0].Msg)
+	assert.Equal(t, tracer.Start.String(), mt.events[0].Status)
+	assert.Equal(t, tracer.Event{
+		Msg:           "TELNET Terminal Session Interaction",
+		Protocol:      tracer.TELNET.String(),
+		Status:        tracer.Interaction.String(),
+		ID:            "session",
+		SourceIp:      "127.0.0.1",
+		User:          "root",
+		Command:       "ls",
+		CommandOutput: "file.txt",
+		Handler:       "ls",
+		SubCommands:   []tracer.SubCommand{{Command: "ls", CommandOutput: "file.txt", Handler: "ls"}},
+	}, mt.events[1])
+	assert.Equal(t, ConfiguredRegexHandler, mt.events[2].Handler)
+	assert.Equal(t, ConfiguredRegexHandler, mt.events[3].Handler)
+	assert.Equal(t, "End TELNET Session", mt.events[4].Msg)
+	assert.Equal(t, tracer.End.String(), mt.events[4].Status)
+
+	// Only the matched sub-commands are stored in the history.
+	assert.Len(t, sess.Histories.Query(sess.HistoryKey), 6)
+}
+
+func TestSession_EndReleasesPlugins(t *testing.T) {
+	stateful.ended = nil
+	servConf := parser.BeelzebubServiceConfiguration{
+		Commands: []parser.Command{
+			{Regex: regexp.MustCompile(`^state$`), Plugin: "SessionTestStateful"},
+			{Regex: regexp.MustCompile(`^crash$`), Plugin: "SessionTestFailing"},
+		},
+	}
+
+	sess := newSession(&mockTracer{}, servConf)
+	sess.Run(&mockTransport{lines: []string{"state", "state", "crash"}})
+	assert.Equal(t, []string{"session"}, stateful.ended)
+
+	// A session which runs no stateful plugin has nothing to release.
+	sess = newSession(&mockTracer{}, servConf)
+	sess.ID = "other"
+	sess.Run(&mockTransport{lines: []string{"crash"}})
+	assert.Equal(t, []string{"session"}, stateful.ended)
+
+	sess.Execute("state")
+	sess.End()
+	sess.End()
+	assert.Equal(t, []string{"session", "other"}, stateful.ended)
+}
+
+func TestSession_SkipUnmatched(t *testing.T) {
+	mt := &mockTracer{}
+	sess := newSession(mt, parser.BeelzebubServiceConfiguration{
+		Commands: []parser.Command{{Regex: regexp.MustCompile(`^ls$`), Handler: "file.txt"}},
+	})
+	sess.SkipUnmatched = true
+
+	transport := &mockTransport{lines: []string{"id", "ls"}}
+	sess.Run(transport)
+
+	assert.Equal(t, []string{"file.txt"}, transport.outputs)
+	require.Len(t, mt.events, 3)
+	assert.Equal(t, "ls", mt.events[1].Command)
+}
+
+func TestSession_FallbackCommand(t *testing.T) {
+	mt := &mockTracer{}
+	sess := newSession(mt, parser.BeelzebubServiceConfiguration{
+		Commands:        []parser.Command{{Regex: regexp.MustCompile(`^ls$`), Handler: "file.txt"}},
+		FallbackCommand: parser.Command{Handler: "Segmentation fault"},
+	})
+	sess.SkipUnmatched = true
+
+	commandLine := sess.Execute("id && ls")
+
+	assert.True(t, commandLine.Matched)
+	assert.Equal(t, "Segmentation fault\nfile.txt", commandLine.Output)
+	assert.Equal(t, FallbackHandler, commandLine.SubCommands[0].Handler)
+	assert.Empty(t, mt.events)
+}
+
+func TestSession_LoadsHistory(t *testing.T) {
+	histories := historystore.NewHistoryStore()
+	sess := newSession(&mockTracer{}, parser.BeelzebubServiceConfiguration{
+		Commands: []parser.Command{{Regex: regexp.MustCompile(`^ls$`), Handler: "file.txt"}},
+	})
+	sess.Histories = histories
+
+	sess.Execute("ls")
+	assert.Len(t, sess.histories, 2)
+
+	// A new session of the same attacker resumes the history.
+	next := newSession(&mockTracer{}, sess.ServConf)
+	next.Histories = histories
+	next.Execute("ls")
+	assert.Len(t, next.histories, 4)
+}
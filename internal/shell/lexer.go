@@ -0,0 +1,299 @@
+package shell
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	wordToken tokenKind = iota
+	redirectToken
+	operatorToken
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	word  word
+}
+
+// word is a word of the command line before the expansion, a sequence of literal text and command substitutions.
+type word []wordPart
+
+type wordPart struct {
+	text string
+	// substitution is set for a $(...) or `...` substitution, whose command line is parsed into pipelines once.
+	substitution bool
+	pipelines    []pipeline
+	// quoted is set for a substitution within double quotes, whose output is not split into fields.
+	quoted bool
+}
+
+// lexer splits a command line into words and operators, removing the quoting the way a POSIX shell does.
+type lexer struct {
+	input  []rune
+	pos    int
+	tokens []token
+	// depth is the number of command substitutions the input is nested in.
+	depth int
+
+	word        word
+	literal     strings.Builder
+	wordStarted bool
+	// wordQuoted is set when any part of the current word was quoted or escaped.
+	wordQuoted bool
+}
+
+func tokenize(line string, depth int) ([]token, error) {
+	l := &lexer{input: []rune(line), depth: depth}
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case r == ' ' || r == '\t':
+			l.flush()
+			l.pos++
+		case r == '\'':
+			if err := l.singleQuoted(); err != nil {
+				return nil, err
+			}
+		case r == '"':
+			if err := l.doubleQuoted(); err != nil {
+				return nil, err
+			}
+		case r == '\\':
+			l.escaped()
+		case r == '$' && l.peek(1) == '(':
+			if err := l.dollarSubstitution(false); err != nil {
+				return nil, err
+			}
+		case r == '`':
+			if err := l.backtickSubstitution(false); err != nil {
+				return nil, err
+			}
+		case r == '&' && l.peek(1) == '>':
+			l.redirect()
+		case r == '>' || r == '<':
+			l.redirect()
+		case r == ';' || r == '&' || r == '|' || r == '\n':
+			l.operator()
+		default:
+			l.literal.WriteRune(r)
+			l.wordStarted = true
+			l.pos++
+		}
+	}
+	l.flush()
+	return l.tokens, nil
+}
+
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset < len(l.input) {
+		return l.input[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) flushLiteral() {
+	if l.literal.Len() > 0 {
+		l.word = append(l.word, wordPart{text: l.literal.String()})
+		l.literal.Reset()
+	}
+}
+
+func (l *lexer) flush() {
+	l.flushLiteral()
+	if l.wordStarted && len(l.word) == 0 {
+		// An empty quoted word, e.g. "", is still a word.
+		l.word = word{{}}
+	}
+	if l.wordStarted {
+		l.tokens = append(l.tokens, token{kind: wordToken, word: l.word})
+	}
+	l.word = nil
+	l.wordStarted = false
+	l.wordQuoted = false
+}
+
+func (l *lexer) singleQuoted() error {
+	end := l.indexFrom(l.pos+1, '\'')
+	if end < 0 {
+		return &SyntaxError{Msg: "unexpected EOF while looking for matching `''"}
+	}
+	l.literal.WriteString(string(l.input[l.pos+1 : end]))
+	l.wordStarted = true
+	l.wordQuoted = true
+	l.pos = end + 1
+	return nil
+}
+
+func (l *lexer) doubleQuoted() error {
+	l.pos++
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch {
+		case r == '"':
+			l.wordStarted = true
+			l.wordQuoted = true
+			l.pos++
+			return nil
+		case r == '\\' && strings.ContainsRune("\\\"$`\n", l.peek(1)):
+			l.literal.WriteRune(l.input[l.pos+1])
+			l.pos += 2
+		case r == '$' && l.peek(1) == '(':
+			if err := l.dollarSubstitution(true); err != nil {
+				return err
+			}
+		case r == '`':
+			if err := l.backtickSubstitution(true); err != nil {
+				return err
+			}
+		default:
+			l.literal.WriteRune(r)
+			l.pos++
+		}
+	}
+	return &SyntaxError{Msg: "unexpected EOF while looking for matching `\"'"}
+}
+
+func (l *lexer) escaped() {
+	if l.pos+1 < len(l.input) {
+		l.literal.WriteRune(l.input[l.pos+1])
+		l.pos += 2
+	} else {
+		l.literal.WriteRune('\\')
+		l.pos++
+	}
+	l.wordStarted = true
+	l.wordQuoted = true
+}
+
+// dollarSubstitution reads a $(...) substitution up to the matching parenthesis, skipping the quoted text.
+func (l *lexer) dollarSubstitution(quoted bool) error {
+	start := l.pos + 2
+	depth := 1
+	for i := start; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '\\':
+			i++
+		case '\'':
+			if i = l.indexFrom(i+1, '\''); i < 0 {
+				return &SyntaxError{Msg: "unexpected EOF while looking for matching `''"}
+			}
+		case '"':
+			if i = l.closingDoubleQuote(i + 1); i < 0 {
+				return &SyntaxError{Msg: "unexpected EOF while looking for matching `\"'"}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				l.pos = i + 1
+				return l.substitution(string(l.input[start:i]), quoted)
+			}
+		}
+	}
+	return &SyntaxError{Msg: "unexpected EOF while looking for matching `)'"}
+}
+
+// backtickSubstitution reads a `...` substitution, where a backslash escapes a backquote or another backslash.
+func (l *lexer) backtickSubstitution(quoted bool) error {
+	var commandLine strings.Builder
+	for i := l.pos + 1; i < len(l.input); i++ {
+		switch r := l.input[i]; {
+		case r == '\\' && i+1 < len(l.input) && strings.ContainsRune("\\`$", l.input[i+1]):
+			commandLine.WriteRune(l.input[i+1])
+			i++
+		case r == '`':
+			l.pos = i + 1
+			return l.substitution(commandLine.String(), quoted)
+		default:
+			commandLine.WriteRune(r)
+		}
+	}
+	return &SyntaxError{Msg: "unexpected EOF while looking for matching ``'"}
+}
+
+// substitution adds a command substitution to the word, its command line is parsed upfront so that a syntax
+// error is reported before running any command, and so that it is parsed only once however deep it is nested.
+func (l *lexer) substitution(commandLine string, quoted bool) error {
+	if l.depth >= MaxNestingDepth {
+		return &SyntaxError{Msg: "maximum nesting depth of command substitutions exceeded"}
+	}
+	pipelines, err := parse(commandLine, l.depth+1)
+	if err != nil {
+		return err
+	}
+	l.flushLiteral()
+	l.word = append(l.word, wordPart{substitution: true, pipelines: pipelines, quoted: quoted})
+	l.wordStarted = true
+	l.wordQuoted = true
+	return nil
+}
+
+// redirect emits one of ">", ">>", "<", the stderr forms "2>", "2>>" when the word before is an unquoted 2,
+// the duplications ">&", "2>&" and the redirections of both outputs "&>", "&>>".
+func (l *lexer) redirect() {
+	var operator string
+	if !l.wordQuoted && len(l.word) == 0 && (l.literal.String() == "1" || l.literal.String() == "2") {
+		operator = l.literal.String()
+		l.literal.Reset()
+		l.wordStarted = false
+	}
+	l.flush()
+
+	if l.input[l.pos] == '&' {
+		operator += "&"
+		l.pos++
+	}
+	r := l.input[l.pos]
+	operator += string(r)
+	l.pos++
+	if r == '>' && l.peek(0) == '>' {
+		operator += ">"
+		l.pos++
+	} else if r == '>' && l.peek(0) == '&' && !strings.HasPrefix(operator, "&") {
+		operator += "&"
+		l.pos++
+	}
+	operator = strings.TrimPrefix(operator, "1")
+	l.tokens = append(l.tokens, token{kind: redirectToken, value: operator})
+}
+
+// operator emits one of ";", "&", "&&", "|" and "||", a newline separates the commands like ";".
+func (l *lexer) operator() {
+	l.flush()
+	r := l.input[l.pos]
+	l.pos++
+	operator := string(r)
+	switch {
+	case r == '\n':
+		operator = ";"
+	case (r == '&' || r == '|') && l.peek(0) == r:
+		operator += string(r)
+		l.pos++
+	}
+	l.tokens = append(l.tokens, token{kind: operatorToken, value: operator})
+}
+
+func (l *lexer) closingDoubleQuote(start int) int {
+	for i := start; i < len(l.input); i++ {
+		switch l.input[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *lexer) indexFrom(start int, target rune) int {
+	for i := start; i < len(l.input); i++ {
+		if l.input[i] == target {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,268 @@
+// Package shell parses the command lines typed by the attackers, following the POSIX shell grammar closely enough
+// to emulate a shell: the lists and pipelines are split into simple commands, the command substitutions are
+// expanded, quoting is removed and the redirections are separated from the arguments.
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxLineLength is the length in bytes of the longest command line parsed, a longer one is a syntax error.
+	MaxLineLength = 64 * 1024
+	// MaxNestingDepth is the maximum number of nested command substitutions, e.g. 2 for "echo $(echo $(id))".
+	MaxNestingDepth = 32
+	// MaxSubCommands is the maximum number of simple commands of a line, the substituted ones included; a line with
+	// more commands is a syntax error.
+	MaxSubCommands = 64
+)
+
+// SyntaxError is returned for a command line a shell would refuse, Msg mimics the bash message.
+type SyntaxError struct {
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Msg
+}
+
+// Command is a simple command, expanded and with the quoting removed.
+type Command struct {
+	Args      []string
+	Redirects []Redirect
+}
+
+// Redirect is a redirection of a simple command, e.g. "> /tmp/x".
+type Redirect struct {
+	// Op is one of ">", ">>", "<", "2>", "2>>", the duplications ">&" and "2>&", and "&>", "&>>" for both outputs.
+	Op     string
+	Target string
+}
+
+// String returns the command as it could be typed, quoting the arguments that would not be a single word otherwise.
+func (command Command) String() string {
+	words := make([]string, 0, len(command.Args)+len(command.Redirects))
+	for _, arg := range command.Args {
+		words = append(words, Quote(arg))
+	}
+	for _, redirect := range command.Redirects {
+		if strings.HasSuffix(redirect.Op, "&") {
+			words = append(words, redirect.Op+Quote(redirect.Target))
+		} else {
+			words = append(words, redirect.Op, Quote(redirect.Target))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Quote returns s as a single shell word, single quoted when it holds blanks or characters special to the shell.
+func Quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`|&;<>()*?[]#~{}!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Executor runs a simple command and returns its output, and whether it succeeded for the && and || operators.
+type Executor func(command Command) (output string, ok bool)
+
+type simpleCommand struct {
+	words     []word
+	redirects []redirectWord
+}
+
+type redirectWord struct {
+	op     string
+	target word
+}
+
+type pipeline struct {
+	// op is the operator between the previous pipeline and this one: "" for the first, ";", "&", "&&" or "||".
+	op       string
+	commands []simpleCommand
+}
+
+// Run runs a command line the way a shell would: the pipelines are run in order, skipping them according to the
+// && and || operators, and the command substitutions are replaced by the output of their command line.
+// Every simple command is run by execute; the output of a pipeline is the output of its last command, and the
+// output of the line joins the outputs of its pipelines with newlines.
+func Run(line string, execute Executor) (string, error) {
+	if len(line) > MaxLineLength {
+		return "", &SyntaxError{Msg: "command line too long"}
+	}
+	pipelines, err := parse(line, 0)
+	if err != nil {
+		return "", err
+	}
+	if countCommands(pipelines) > MaxSubCommands {
+		return "", &SyntaxError{Msg: "too many commands"}
+	}
+	return run(pipelines, execute)
+}
+
+// countCommands returns the number of simple commands of the pipelines, together with the ones of their command
+// substitutions.
+func countCommands(pipelines []pipeline) int {
+	count := 0
+	for _, pipeline := range pipelines {
+		for _, simple := range pipeline.commands {
+			count++
+			words := simple.words
+			for _, redirect := range simple.redirects {
+				words = append(words[:len(words):len(words)], redirect.target)
+			}
+			for _, w := range words {
+				for _, part := range w {
+					count += countCommands(part.pipelines)
+				}
+			}
+		}
+	}
+	return count
+}
+
+func run(pipelines []pipeline, execute Executor) (string, error) {
+	var outputs []string
+	ok := true
+	for _, pipeline := range pipelines {
+		if (pipeline.op == "&&" && !ok) || (pipeline.op == "||" && ok) {
+			continue
+		}
+		var output string
+		for _, simple := range pipeline.commands {
+			command, err := simple.expand(execute)
+			if err != nil {
+				return "", err
+			}
+			output, ok = execute(command)
+		}
+		if output != "" {
+			outputs = append(outputs, output)
+		}
+	}
+	return strings.Join(outputs, "\n"), nil
+}
+
+// parse parses a command line nested in depth command substitutions.
+func parse(line string, depth int) ([]pipeline, error) {
+	tokens, err := tokenize(line, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelines []pipeline
+	var current pipeline
+	var command simpleCommand
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case wordToken:
+			command.words = append(command.words, tokens[i].word)
+		case redirectToken:
+			if i+1 >= len(tokens) || tokens[i+1].kind != wordToken {
+				return nil, unexpectedToken(tokens, i+1)
+			}
+			command.redirects = append(command.redirects, redirectWord{op: tokens[i].value, target: tokens[i+1].word})
+			i++
+		case operatorToken:
+			if len(command.words) == 0 && len(command.redirects) == 0 {
+				return nil, unexpectedToken(tokens, i)
+			}
+			current.commands = append(current.commands, command)
+			command = simpleCommand{}
+			if tokens[i].value == "|" {
+				continue
+			}
+			pipelines = append(pipelines, current)
+			current = pipeline{op: tokens[i].value}
+		}
+	}
+
+	if len(command.words) > 0 || len(command.redirects) > 0 {
+		current.commands = append(current.commands, command)
+		return append(pipelines, current), nil
+	}
+	if len(current.commands) > 0 || current.op == "&&" || current.op == "||" {
+		return nil, &SyntaxError{Msg: "syntax error: unexpected end of file"}
+	}
+	return pipelines, nil
+}
+
+func unexpectedToken(tokens []token, i int) error {
+	next := "newline"
+	if i < len(tokens) {
+		next = tokens[i].value
+	}
+	return &SyntaxError{Msg: fmt.Sprintf("syntax error near unexpected token `%s'", next)}
+}
+
+func (simple simpleCommand) expand(execute Executor) (Command, error) {
+	var command Command
+	for _, w := range simple.words {
+		fields, err := w.expand(execute)
+		if err != nil {
+			return Command{}, err
+		}
+		command.Args = append(command.Args, fields...)
+	}
+	for _, redirect := range simple.redirects {
+		fields, err := redirect.target.expand(execute)
+		if err != nil {
+			return Command{}, err
+		}
+		command.Redirects = append(command.Redirects, Redirect{Op: redirect.op, Target: strings.Join(fields, " ")})
+	}
+	return command, nil
+}
+
+// expand returns the fields of the word: the output of an unquoted substitution is split on blanks, so the word
+// may expand to several fields, or to none.
+func (w word) expand(execute Executor) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	started := false
+	for _, part := range w {
+		if !part.substitution {
+			field.WriteString(part.text)
+			started = true
+			continue
+		}
+
+		output, err := run(part.pipelines, execute)
+		if err != nil {
+			return nil, err
+		}
+		output = strings.TrimRight(output, "\n")
+		if part.quoted {
+			field.WriteString(output)
+			started = true
+			continue
+		}
+
+		words := strings.Fields(output)
+		if len(words) == 0 {
+			continue
+		}
+		if strings.TrimLeft(output, " \t\n") != output && started {
+			fields = append(fields, field.String())
+			field.Reset()
+		}
+		for i, expanded := range words {
+			if i > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			field.WriteString(expanded)
+		}
+		started = true
+		if strings.TrimRight(output, " \t") != output {
+			fields = append(fields, field.String())
+			field.Reset()
+			started = false
+		}
+	}
+	if started {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
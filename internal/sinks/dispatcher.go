@@ -0,0 +1,340 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Config is a sink with its delivery settings.
+type Config struct {
+	// Name identifies the sink in the logs and in the metrics.
+	Name   string
+	Sink   Sink
+	Filter Filter
+	Retry  Retry
+	Batch  Batch
+	// RateLimit applies to the retries too.
+	RateLimit RateLimit
+	// QueueSize is the number of events waiting for the sink, zero means DefaultQueueSize; when the queue is full,
+	// the events are dropped for this sink only.
+	QueueSize int
+	// Spool, when set, replaces the queue: the events are written to disk and removed once delivered, they are retried
+	// until delivered unless Retry.MaxAttempts is set, and survive a restart. The dispatcher closes the spool.
+	Spool *Spool
+}
+
+// Dispatcher fans the events out to the sinks; every sink has its own queue and goroutine, so that a slow or failing
+// sink does not delay the others.
+type Dispatcher struct {
+	sinks []*dispatchedSink
+	// eventsTotal counts the events by sink and result, it is exposed once registered by Register.
+	eventsTotal *prometheus.CounterVec
+	// retryCtx is cancelled by Close when its deadline expires, to stop the pending retries.
+	retryCtx    context.Context
+	cancelRetry context.CancelFunc
+	closeOnce   sync.Once
+	// closedMu guards closed against Dispatch, which drops the events once the queues are closed.
+	closedMu sync.RWMutex
+	closed   bool
+}
+
+type dispatchedSink struct {
+	Config
+	// batchSize is 1 when the sink does not deliver batches.
+	batchSize int
+	// limiter is nil without rate limit.
+	limiter     *rate.Limiter
+	events      chan tracer.Event
+	done        chan struct{}
+	eventsTotal *prometheus.CounterVec
+}
+
+// NewDispatcher starts the delivery goroutines of the sinks.
+func NewDispatcher(configs []Config) *Dispatcher {
+	dispatcher := &Dispatcher{
+		eventsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "beelzebub",
+			Name:      "sink_events_total",
+			Help:      "The total number of events handled by the sinks, by sink and result: delivered, retried, failed, dropped or evicted",
+		}, []string{"sink", "result"}),
+	}
+	dispatcher.retryCtx, dispatcher.cancelRetry = context.WithCancel(context.Background())
+
+	for _, config := range configs {
+		queueSize := config.QueueSize
+		if queueSize <= 0 {
+			queueSize = DefaultQueueSize
+		}
+		sink := &dispatchedSink{
+			Config:      config,
+			batchSize:   1,
+			events:      make(chan tracer.Event, queueSize),
+			done:        make(chan struct{}),
+			eventsTotal: dispatcher.eventsTotal,
+		}
+		if _, ok := config.Sink.(BatchSink); ok {
+			sink.batchSize = config.Batch.size()
+		}
+		if config.RateLimit.PerSecond > 0 {
+			sink.limiter = rate.NewLimiter(rate.Limit(config.RateLimit.PerSecond), max(config.RateLimit.Burst, 1))
+		}
+		dispatcher.sinks = append(dispatcher.sinks, sink)
+		go sink.run(dispatcher.retryCtx)
+	}
+	return dispatcher
+}
+
+// Register registers the metrics of the sinks on registerer.
+func (dispatcher *Dispatcher) Register(registerer prometheus.Registerer) error {
+	if err := registerer.Register(dispatcher.eventsTotal); err != nil {
+		return fmt.Errorf("error during register sinks metrics: %w", err)
+	}
+	return nil
+}
+
+// Dispatch queues the event for every sink whose filter matches it, it never blocks. After Close, the event is dropped.
+// Dispatch is a tracer.Strategy.
+func (dispatcher *Dispatcher) Dispatch(event tracer.Event) {
+	dispatcher.closedMu.RLock()
+	defer dispatcher.closedMu.RUnlock()
+
+	for _, sink := range dispatcher.sinks {
+		if !sink.Filter.Match(event) {
+			continue
+		}
+		if dispatcher.closed {
+			sink.eventsTotal.WithLabelValues(sink.Name, "dropped").Inc()
+			log.Warnf("Sink %s is closed, event dropped", sink.Name)
+			continue
+		}
+		if sink.Spool != nil {
+			sink.spool(event)
+			continue
+		}
+		select {
+		case sink.events <- event:
+		default:
+			sink.eventsTotal.WithLabelValues(sink.Name, "dropped").Inc()
+			log.Warnf("Sink %s queue is full, event dropped", sink.Name)
+		}
+	}
+}
+
+func (sink *dispatchedSink) spool(event tracer.Event) {
+	evicted, err := sink.Spool.Append(event)
+	if evicted > 0 {
+		sink.eventsTotal.WithLabelValues(sink.Name, "evicted").Add(float64(evicted))
+		log.Warnf("Sink %s spool is full, %d events evicted", sink.Name, evicted)
+	}
+	if err != nil {
+		sink.eventsTotal.WithLabelValues(sink.Name, "failed").Inc()
+		log.Errorf("Error during spool event of sink %s: %s", sink.Name, err.Error())
+	}
+}
+
+// Close waits for the sinks to deliver the queued events, then closes them. When ctx expires, the pending retries are
+// abandoned and the events still queued are lost, except the spooled ones which are delivered on the next run.
+func (dispatcher *Dispatcher) Close(ctx context.Context) error {
+	var err error
+	dispatcher.closeOnce.Do(func() {
+		dispatcher.closedMu.Lock()
+		dispatcher.closed = true
+		for _, sink := range dispatcher.sinks {
+			if sink.Spool != nil {
+				sink.Spool.stop()
+			} else {
+				close(sink.events)
+			}
+		}
+		dispatcher.closedMu.Unlock()
+
+		for _, sink := range dispatcher.sinks {
+			select {
+			case <-sink.done:
+			case <-ctx.Done():
+				dispatcher.cancelRetry()
+				<-sink.done
+			}
+		}
+		dispatcher.cancelRetry()
+
+		for _, sink := range dispatcher.sinks {
+			if closeErr := sink.Sink.Close(); closeErr != nil {
+				err = errors.Join(err, fmt.Errorf("error during close sink %s: %w", sink.Name, closeErr))
+			}
+			if sink.Spool != nil {
+				if closeErr := sink.Spool.Close(); closeErr != nil {
+					err = errors.Join(err, fmt.Errorf("error during close spool of sink %s: %w", sink.Name, closeErr))
+				}
+			}
+		}
+	})
+	return err
+}
+
+func (sink *dispatchedSink) run(ctx context.Context) {
+	defer close(sink.done)
+	if sink.Spool != nil {
+		sink.runSpool(ctx)
+		return
+	}
+	if sink.batchSize > 1 {
+		sink.runBatches(ctx)
+		return
+	}
+	for event := range sink.events {
+		sink.deliverQueued(ctx, []tracer.Event{event})
+	}
+}
+
+// runBatches sends the queued events in batches, a batch is sent once full or once its first event has waited
+// Batch.Interval.
+func (sink *dispatchedSink) runBatches(ctx context.Context) {
+	batch := make([]tracer.Event, 0, sink.batchSize)
+	timer := time.NewTimer(sink.Batch.interval())
+	timer.Stop()
+	defer timer.Stop()
+	var timeout <-chan time.Time
+
+	flush := func() {
+		timer.Stop()
+		timeout = nil
+		if len(batch) > 0 {
+			sink.deliverQueued(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case event, ok := <-sink.events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) == 1 {
+				timer.Reset(sink.Batch.interval())
+				timeout = timer.C
+			}
+			if len(batch) >= sink.batchSize {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		}
+	}
+}
+
+func (sink *dispatchedSink) deliverQueued(ctx context.Context, events []tracer.Event) {
+	if ctx.Err() != nil {
+		sink.eventsTotal.WithLabelValues(sink.Name, "dropped").Add(float64(len(events)))
+		return
+	}
+	if undelivered := sink.deliver(ctx, events, sink.Retry.maxAttempts()); undelivered > 0 && ctx.Err() != nil {
+		sink.eventsTotal.WithLabelValues(sink.Name, "failed").Add(float64(undelivered))
+	}
+}
+
+// runSpool delivers the spooled events in order, an event leaves the spool once delivered or given up. A batch holds
+// the events spooled while the previous one was delivered, up to the batch size.
+func (sink *dispatchedSink) runSpool(ctx context.Context) {
+	for readErrors := 0; ; {
+		events, err := sink.Spool.NextBatch(ctx, sink.batchSize)
+		if errors.Is(err, errSpoolClosed) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			sink.eventsTotal.WithLabelValues(sink.Name, "failed").Inc()
+			log.Errorf("Error during read spool of sink %s: %s", sink.Name, err.Error())
+			if err := sink.Spool.Ack(); err != nil {
+				log.Errorf("Error during acknowledge event of sink %s: %s", sink.Name, err.Error())
+			}
+			readErrors++
+			sink.wait(ctx, sink.Retry.Backoff(readErrors))
+			continue
+		}
+		readErrors = 0
+
+		// Without MaxAttempts the event is retried until delivered, when ctx expires it stays in the spool.
+		if sink.deliver(ctx, events, sink.Retry.MaxAttempts) == 0 || ctx.Err() == nil {
+			if err := sink.Spool.Ack(); err != nil {
+				log.Errorf("Error during acknowledge event of sink %s: %s", sink.Name, err.Error())
+			}
+		}
+	}
+}
+
+// deliver sends the events, retrying with an exponential backoff until the sink accepts them, the attempts run out or
+// ctx expires; zero maxAttempts retries forever. Only the events of a BatchError to retry are sent again. It returns the
+// number of events neither delivered nor given up, when ctx expired.
+func (sink *dispatchedSink) deliver(ctx context.Context, events []tracer.Event, maxAttempts int) int {
+	for attempt := 1; ; attempt++ {
+		err := sink.send(ctx, events)
+		if err == nil {
+			sink.eventsTotal.WithLabelValues(sink.Name, "delivered").Add(float64(len(events)))
+			return 0
+		}
+
+		var batchError *BatchError
+		if errors.As(err, &batchError) {
+			sink.eventsTotal.WithLabelValues(sink.Name, "delivered").Add(float64(len(events) - len(batchError.Retry) - len(batchError.Rejected)))
+			if len(batchError.Rejected) > 0 {
+				sink.eventsTotal.WithLabelValues(sink.Name, "failed").Add(float64(len(batchError.Rejected)))
+				log.Errorf("Error during send event to sink %s: %s", sink.Name, err.Error())
+			}
+			if len(batchError.Retry) == 0 {
+				return 0
+			}
+			retry := make([]tracer.Event, 0, len(batchError.Retry))
+			for _, index := range batchError.Retry {
+				retry = append(retry, events[index])
+			}
+			events = retry
+		}
+
+		if ctx.Err() != nil {
+			log.Errorf("Error during send event to sink %s: %s", sink.Name, err.Error())
+			return len(events)
+		}
+		if maxAttempts > 0 && attempt >= maxAttempts {
+			sink.eventsTotal.WithLabelValues(sink.Name, "failed").Add(float64(len(events)))
+			log.Errorf("Error during send event to sink %s: %s", sink.Name, err.Error())
+			return 0
+		}
+
+		sink.eventsTotal.WithLabelValues(sink.Name, "retried").Add(float64(len(events)))
+		log.Debugf("Error during send event to sink %s, attempt %d: %s", sink.Name, attempt, err.Error())
+		sink.wait(ctx, sink.Retry.Backoff(attempt))
+	}
+}
+
+// send waits for the rate limit, then sends the events as a batch when the sink supports it.
+func (sink *dispatchedSink) send(ctx context.Context, events []tracer.Event) error {
+	if sink.limiter != nil {
+		if err := sink.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if batchSink, ok := sink.Sink.(BatchSink); ok && sink.batchSize > 1 {
+		return batchSink.SendBatch(ctx, events)
+	}
+	return sink.Sink.Send(ctx, events[0])
+}
+
+func (sink *dispatchedSink) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,252 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// DefaultElasticsearchIndex names the indices after the day of the events, e.g. beelzebub-2024.05.01.
+	DefaultElasticsearchIndex = `beelzebub-{{date "2006.01.02" .DateTime}}`
+	// DefaultElasticsearchBatchSize is the number of events of a bulk request when the configuration does not set one.
+	DefaultElasticsearchBatchSize = 500
+	// DefaultElasticsearchTimeout bounds a request when the configuration does not set one.
+	DefaultElasticsearchTimeout = 10 * time.Second
+	defaultIndexTemplateName    = "beelzebub"
+	defaultIndexPattern         = "beelzebub-*"
+	// maxBulkItemErrors bounds the item errors reported by a bulk request.
+	maxBulkItemErrors = 3
+)
+
+// ElasticsearchOptions are the settings of the elasticsearch sink.
+type ElasticsearchOptions struct {
+	// URL is the base URL of the cluster, e.g. https://localhost:9200.
+	URL string
+	// Index is a Go template rendered with the event, DefaultElasticsearchIndex when empty.
+	Index string
+	// Username and Password authenticate with HTTP basic authentication, APIKey with an API key.
+	Username string
+	Password string
+	APIKey   string
+	// OpenSearch maps HeadersMap as flat_object, the OpenSearch equivalent of flattened.
+	OpenSearch bool
+	// TemplateName is the name of the installed index template, beelzebub when empty.
+	TemplateName string
+	// IndexPattern selects the indices of the index template, beelzebub-* when empty.
+	IndexPattern string
+	// DisableTemplate leaves the index template to the administrators of the cluster.
+	DisableTemplate bool
+	Timeout         time.Duration
+	TLS             *tls.Config
+}
+
+// Elasticsearch indexes the events in Elasticsearch or OpenSearch with the _bulk API. Before the first events, it
+// installs an index template mapping SourceIp as ip, DateTime as date and HeadersMap as flattened.
+type Elasticsearch struct {
+	options ElasticsearchOptions
+	client  *resty.Client
+	index   *eventTemplate
+
+	mu                sync.Mutex
+	templateInstalled bool
+}
+
+type bulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]bulkItem `json:"items"`
+}
+
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// NewElasticsearch validates the options, the cluster is contacted by the first events.
+func NewElasticsearch(options ElasticsearchOptions) (*Elasticsearch, error) {
+	parsedURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid elasticsearch url: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid elasticsearch url %q, expected an http or https url", options.URL)
+	}
+	options.URL = strings.TrimSuffix(options.URL, "/")
+	if options.Index == "" {
+		options.Index = DefaultElasticsearchIndex
+	}
+	if options.TemplateName == "" {
+		options.TemplateName = defaultIndexTemplateName
+	}
+	if options.IndexPattern == "" {
+		options.IndexPattern = defaultIndexPattern
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultElasticsearchTimeout
+	}
+
+	index, err := newEventTemplate("index", options.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	client := resty.New().SetBaseURL(options.URL).SetTimeout(options.Timeout)
+	if options.APIKey != "" {
+		client.SetHeader("Authorization", "ApiKey "+options.APIKey)
+	} else if options.Username != "" {
+		client.SetBasicAuth(options.Username, options.Password)
+	}
+	if options.TLS != nil {
+		client.SetTLSClientConfig(options.TLS)
+	}
+	return &Elasticsearch{options: options, client: client, index: index, templateInstalled: options.DisableTemplate}, nil
+}
+
+func (elasticsearch *Elasticsearch) Send(ctx context.Context, event tracer.Event) error {
+	return elasticsearch.SendBatch(ctx, []tracer.Event{event})
+}
+
+// SendBatch indexes the events in a single bulk request; the items failed with 429 or a 5xx status are retried, the
+// others are rejected, e.g. a mapping error.
+func (elasticsearch *Elasticsearch) SendBatch(ctx context.Context, events []tracer.Event) error {
+	if err := elasticsearch.installTemplate(ctx); err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	for _, event := range events {
+		index, err := elasticsearch.index.render(event)
+		if err != nil {
+			return err
+		}
+		action, err := json.Marshal(map[string]map[string]string{"index": {"_index": index}})
+		if err != nil {
+			return fmt.Errorf("error during marshal bulk action: %w", err)
+		}
+		document, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error during marshal event: %w", err)
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(document)
+		body.WriteByte('\n')
+	}
+
+	response, err := elasticsearch.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/x-ndjson").
+		SetBody(body.Bytes()).
+		Post("/_bulk")
+	if err != nil {
+		return fmt.Errorf("error during send bulk request: %w", err)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("bulk request responded with status %d: %s", response.StatusCode(), response.String())
+	}
+	var bulk bulkResponse
+	if err := json.Unmarshal(response.Body(), &bulk); err != nil {
+		return fmt.Errorf("error during unmarshal bulk response: %w", err)
+	}
+	if !bulk.Errors {
+		return nil
+	}
+	if len(bulk.Items) != len(events) {
+		return fmt.Errorf("bulk request returned %d items for %d events", len(bulk.Items), len(events))
+	}
+
+	batchError := &BatchError{}
+	var itemErrors []error
+	for i, item := range bulk.Items {
+		result := item["index"]
+		if result.Error == nil {
+			continue
+		}
+		if result.Status == http.StatusTooManyRequests || result.Status >= http.StatusInternalServerError {
+			batchError.Retry = append(batchError.Retry, i)
+		} else {
+			batchError.Rejected = append(batchError.Rejected, i)
+		}
+		if len(itemErrors) < maxBulkItemErrors {
+			itemErrors = append(itemErrors, fmt.Errorf("status %d, %s: %s", result.Status, result.Error.Type, result.Error.Reason))
+		}
+	}
+	batchError.Err = errors.Join(itemErrors...)
+	return batchError
+}
+
+// installTemplate puts the index template once, it is attempted again by the next events after an error.
+func (elasticsearch *Elasticsearch) installTemplate(ctx context.Context) error {
+	elasticsearch.mu.Lock()
+	defer elasticsearch.mu.Unlock()
+
+	if elasticsearch.templateInstalled {
+		return nil
+	}
+	response, err := elasticsearch.client.R().
+		SetContext(ctx).
+		SetBody(elasticsearch.indexTemplate()).
+		Put("/_index_template/" + url.PathEscape(elasticsearch.options.TemplateName))
+	if err != nil {
+		return fmt.Errorf("error during install index template: %w", err)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("index template installation responded with status %d: %s", response.StatusCode(), response.String())
+	}
+	elasticsearch.templateInstalled = true
+	return nil
+}
+
+// indexTemplate maps the strings as keywords, except the free text fields, so that the events can be aggregated.
+// The malformed dates and addresses, e.g. empty, are ignored rather than rejecting the event.
+func (elasticsearch *Elasticsearch) indexTemplate() map[string]any {
+	flattened := "flattened"
+	if elasticsearch.options.OpenSearch {
+		flattened = "flat_object"
+	}
+	text := map[string]any{
+		"type":   "text",
+		"fields": map[string]any{"keyword": map[string]any{"type": "keyword", "ignore_above": 1024}},
+	}
+	return map[string]any{
+		"index_patterns": []string{elasticsearch.options.IndexPattern},
+		"template": map[string]any{
+			"mappings": map[string]any{
+				"dynamic_templates": []any{
+					map[string]any{"strings": map[string]any{
+						"match_mapping_type": "string",
+						"mapping":            map[string]any{"type": "keyword", "ignore_above": 1024},
+					}},
+				},
+				"properties": map[string]any{
+					"DateTime":      map[string]any{"type": "date", "ignore_malformed": true},
+					"SourceIp":      map[string]any{"type": "ip", "ignore_malformed": true},
+					"HeadersMap":    map[string]any{"type": flattened},
+					"Command":       text,
+					"CommandOutput": text,
+					"Body":          text,
+					"Msg":           text,
+				},
+			},
+		},
+	}
+}
+
+func (elasticsearch *Elasticsearch) Close() error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeElasticsearch rejects the events with ID "invalid", and the events with ID "busy" on their first attempt.
+type fakeElasticsearch struct {
+	mu        sync.Mutex
+	templates []map[string]any
+	indexed   map[string]string
+	attempts  map[string]int
+}
+
+func (fake *fakeElasticsearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/_index_template/beelzebub":
+		var template map[string]any
+		json.NewDecoder(r.Body).Decode(&template)
+		fake.templates = append(fake.templates, template)
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		var items []string
+		hasErrors := false
+		lines := bufio.NewScanner(r.Body)
+		for lines.Scan() {
+			var action map[string]map[string]string
+			json.Unmarshal(lines.Bytes(), &action)
+			lines.Scan()
+			var event tracer.Event
+			json.Unmarshal(lines.Bytes(), &event)
+
+			fake.attempts[event.ID]++
+			switch {
+			case event.ID == "invalid":
+				hasErrors = true
+				items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+			case event.ID == "busy" && fake.attempts[event.ID] == 1:
+				hasErrors = true
+				items = append(items, `{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+			default:
+				fake.indexed[event.ID] = action["index"]["_index"]
+				items = append(items, `{"index":{"status":201}}`)
+			}
+		}
+		fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNewElasticsearch_InvalidOptions(t *testing.T) {
+	_, err := NewElasticsearch(ElasticsearchOptions{URL: "localhost:9200"})
+	assert.Error(t, err)
+	_, err = NewElasticsearch(ElasticsearchOptions{URL: "http://localhost:9200", Index: "{{.Protocol"})
+	assert.Error(t, err)
+}
+
+func TestElasticsearch_Bulk(t *testing.T) {
+	fake := &fakeElasticsearch{indexed: map[string]string{}, attempts: map[string]int{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	elasticsearch, err := NewElasticsearch(ElasticsearchOptions{URL: server.URL + "/"})
+	require.NoError(t, err)
+	dispatcher := NewDispatcher([]Config{{
+		Name:  "elasticsearch",
+		Sink:  elasticsearch,
+		Batch: Batch{Size: 3},
+		Retry: Retry{InitialBackoff: 1},
+	}})
+
+	for _, id := range []string{"delivered", "invalid", "busy"} {
+		dispatcher.Dispatch(tracer.Event{ID: id, DateTime: "2024-05-01T10:00:00Z", SourceIp: "10.0.0.1"})
+	}
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, map[string]string{"delivered": "beelzebub-2024.05.01", "busy": "beelzebub-2024.05.01"}, fake.indexed)
+	assert.Equal(t, map[string]int{"delivered": 1, "invalid": 1, "busy": 2}, fake.attempts, "only the retryable item is sent again")
+
+	require.Len(t, fake.templates, 1, "the index template is installed once")
+	properties := fake.templates[0]["template"].(map[string]any)["mappings"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, "ip", properties["SourceIp"].(map[string]any)["type"])
+	assert.Equal(t, "date", properties["DateTime"].(map[string]any)["type"])
+	assert.Equal(t, "flattened", properties["HeadersMap"].(map[string]any)["type"])
+}
+
+func TestElasticsearch_BulkRequestFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	elasticsearch, err := NewElasticsearch(ElasticsearchOptions{URL: server.URL, DisableTemplate: true})
+	require.NoError(t, err)
+
+	err = elasticsearch.Send(context.Background(), tracer.Event{ID: "1"})
+	assert.ErrorContains(t, err, "503")
+	var batchError *BatchError
+	assert.NotErrorAs(t, err, &batchError, "the whole request is retried")
+}
@@ -0,0 +1,39 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/rotation"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+// File writes the events as JSON lines, one event per line, separately from the logs.
+type File struct {
+	writer *rotation.Writer
+}
+
+// NewFile opens the events file, rotated according to options.
+func NewFile(path string, options rotation.Options) (*File, error) {
+	writer, err := rotation.Open(path, options)
+	if err != nil {
+		return nil, err
+	}
+	return &File{writer: writer}, nil
+}
+
+func (file *File) Send(ctx context.Context, event tracer.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error during marshal event: %w", err)
+	}
+	if _, err := file.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error during write event: %w", err)
+	}
+	return nil
+}
+
+func (file *File) Close() error {
+	return file.writer.Close()
+}
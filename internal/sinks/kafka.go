@@ -0,0 +1,185 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const (
+	// DefaultKafkaTopic is the topic of the events when the configuration does not set one.
+	DefaultKafkaTopic = "beelzebub-events"
+	// DefaultKafkaBatchSize is the number of events produced at once when the configuration does not set one.
+	DefaultKafkaBatchSize = 100
+	// DefaultKafkaDeliveryTimeout bounds the retries of the client when the configuration does not set one.
+	DefaultKafkaDeliveryTimeout = 30 * time.Second
+)
+
+// kafkaCompressions are the compression codecs of the record batches, by name.
+var kafkaCompressions = map[string]kgo.CompressionCodec{
+	"":       kgo.NoCompression(),
+	"none":   kgo.NoCompression(),
+	"gzip":   kgo.GzipCompression(),
+	"snappy": kgo.SnappyCompression(),
+	"lz4":    kgo.Lz4Compression(),
+	"zstd":   kgo.ZstdCompression(),
+}
+
+// KafkaSASL are the SASL credentials of the kafka sink.
+type KafkaSASL struct {
+	// Mechanism is PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// KafkaOptions are the settings of the kafka sink.
+type KafkaOptions struct {
+	// Brokers are the host:port of the seed brokers.
+	Brokers []string
+	// Topic is a Go template rendered with the event, e.g. "beelzebub-{{lower .Protocol}}", DefaultKafkaTopic when empty.
+	Topic    string
+	ClientID string
+	// Compression is the codec of the record batches: none, gzip, snappy, lz4 or zstd, none when empty.
+	Compression string
+	// Idempotent enables the idempotent producer, which writes every record once and in order despite the retries of
+	// the client, acknowledged by all the in-sync replicas; otherwise the records are acknowledged by the leader.
+	Idempotent bool
+	// SASL authenticates the client, nil disables the authentication.
+	SASL *KafkaSASL
+	// DeliveryTimeout bounds the retries of a record by the client, then the record is retried by the dispatcher,
+	// DefaultKafkaDeliveryTimeout when zero.
+	DeliveryTimeout time.Duration
+	// TLS enables TLS, nil means plaintext.
+	TLS *tls.Config
+}
+
+// Kafka produces the events to a topic as JSON records keyed by SourceIp, so that the events of an attacker are
+// ordered within a partition.
+type Kafka struct {
+	client *kgo.Client
+	topic  *eventTemplate
+}
+
+// NewKafka validates the options and creates the client, the brokers are contacted by the first events.
+func NewKafka(options KafkaOptions) (*Kafka, error) {
+	if len(options.Brokers) == 0 {
+		return nil, errors.New("the kafka sink requires at least a broker")
+	}
+	if options.Topic == "" {
+		options.Topic = DefaultKafkaTopic
+	}
+	topic, err := newEventTemplate("topic", options.Topic)
+	if err != nil {
+		return nil, err
+	}
+	compression, found := kafkaCompressions[options.Compression]
+	if !found {
+		return nil, fmt.Errorf("unknown kafka compression %q, expected one of none, gzip, snappy, lz4, zstd", options.Compression)
+	}
+	if options.DeliveryTimeout <= 0 {
+		options.DeliveryTimeout = DefaultKafkaDeliveryTimeout
+	}
+
+	kafkaOptions := []kgo.Opt{
+		kgo.SeedBrokers(options.Brokers...),
+		kgo.ProducerBatchCompression(compression),
+		kgo.RecordDeliveryTimeout(options.DeliveryTimeout),
+	}
+	if options.ClientID != "" {
+		kafkaOptions = append(kafkaOptions, kgo.ClientID(options.ClientID))
+	}
+	if options.Idempotent {
+		kafkaOptions = append(kafkaOptions, kgo.RequiredAcks(kgo.AllISRAcks()))
+	} else {
+		kafkaOptions = append(kafkaOptions, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	}
+	if options.SASL != nil {
+		mechanism, err := kafkaSASLMechanism(*options.SASL)
+		if err != nil {
+			return nil, err
+		}
+		kafkaOptions = append(kafkaOptions, kgo.SASL(mechanism))
+	}
+	if options.TLS != nil {
+		kafkaOptions = append(kafkaOptions, kgo.DialTLSConfig(options.TLS))
+	}
+
+	client, err := kgo.NewClient(kafkaOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("error during init kafka client: %w", err)
+	}
+	return &Kafka{client: client, topic: topic}, nil
+}
+
+func kafkaSASLMechanism(credentials KafkaSASL) (sasl.Mechanism, error) {
+	switch credentials.Mechanism {
+	case "PLAIN":
+		return plain.Auth{User: credentials.Username, Pass: credentials.Password}.AsMechanism(), nil
+	case "SCRAM-SHA-256":
+		return scram.Auth{User: credentials.Username, Pass: credentials.Password}.AsSha256Mechanism(), nil
+	case "SCRAM-SHA-512":
+		return scram.Auth{User: credentials.Username, Pass: credentials.Password}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("unknown kafka sasl mechanism %q, expected one of PLAIN, SCRAM-SHA-256, SCRAM-SHA-512", credentials.Mechanism)
+	}
+}
+
+func (kafka *Kafka) Send(ctx context.Context, event tracer.Event) error {
+	return kafka.SendBatch(ctx, []tracer.Event{event})
+}
+
+// SendBatch produces the events and waits for their acknowledgement; the records failed with an error that Kafka
+// does not consider retriable, e.g. a record too large, are rejected.
+func (kafka *Kafka) SendBatch(ctx context.Context, events []tracer.Event) error {
+	records := make([]*kgo.Record, 0, len(events))
+	for _, event := range events {
+		topic, err := kafka.topic.render(event)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error during marshal event: %w", err)
+		}
+		records = append(records, &kgo.Record{Topic: topic, Key: []byte(event.SourceIp), Value: value})
+	}
+
+	results := kafka.client.ProduceSync(ctx, records...)
+	if results.FirstErr() == nil {
+		return nil
+	}
+
+	batchError := &BatchError{}
+	for i, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if batchError.Err == nil {
+			batchError.Err = fmt.Errorf("error during produce kafka record: %w", result.Err)
+		}
+		var kafkaError *kerr.Error
+		if errors.As(result.Err, &kafkaError) && !kafkaError.Retriable {
+			batchError.Rejected = append(batchError.Rejected, i)
+		} else {
+			batchError.Retry = append(batchError.Retry, i)
+		}
+	}
+	return batchError
+}
+
+func (kafka *Kafka) Close() error {
+	kafka.client.Close()
+	return nil
+}
@@ -0,0 +1,72 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestNewKafka_InvalidOptions(t *testing.T) {
+	_, err := NewKafka(KafkaOptions{})
+	assert.Error(t, err)
+	_, err = NewKafka(KafkaOptions{Brokers: []string{"localhost:9092"}, Compression: "brotli"})
+	assert.Error(t, err)
+	_, err = NewKafka(KafkaOptions{Brokers: []string{"localhost:9092"}, SASL: &KafkaSASL{Mechanism: "GSSAPI"}})
+	assert.Error(t, err)
+	_, err = NewKafka(KafkaOptions{Brokers: []string{"localhost:9092"}, Topic: "{{.Protocol"})
+	assert.Error(t, err)
+}
+
+func TestKafka_SendBatch(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, "beelzebub-ssh"))
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	kafka, err := NewKafka(KafkaOptions{
+		Brokers:     cluster.ListenAddrs(),
+		Topic:       "beelzebub-{{lower .Protocol}}",
+		Compression: "zstd",
+		Idempotent:  true,
+	})
+	require.NoError(t, err)
+	defer kafka.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, kafka.SendBatch(ctx, []tracer.Event{
+		{ID: "1", Protocol: "SSH", SourceIp: "10.0.0.1"},
+		{ID: "2", Protocol: "SSH", SourceIp: "10.0.0.2"},
+		{ID: "3", Protocol: "SSH", SourceIp: "10.0.0.1"},
+	}))
+
+	consumer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics("beelzebub-ssh"))
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	partitions := make(map[string]int32)
+	ids := make(map[string][]string)
+	for records := 0; records < 3; {
+		fetches := consumer.PollFetches(ctx)
+		require.NoError(t, fetches.Err())
+		fetches.EachRecord(func(record *kgo.Record) {
+			records++
+			var event tracer.Event
+			require.NoError(t, json.Unmarshal(record.Value, &event))
+			assert.Equal(t, event.SourceIp, string(record.Key))
+			if partition, found := partitions[event.SourceIp]; found {
+				assert.Equal(t, partition, record.Partition, "the events of a source are in the same partition")
+			}
+			partitions[event.SourceIp] = record.Partition
+			ids[event.SourceIp] = append(ids[event.SourceIp], event.ID)
+		})
+	}
+	assert.Equal(t, map[string][]string{"10.0.0.1": {"1", "3"}, "10.0.0.2": {"2"}}, ids, "the events of a source are ordered")
+}
@@ -0,0 +1,250 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultOpenTelemetrySessionTimeout ends the span of a session without End event when the configuration does not
+	// set one, e.g. a connection of a honeypot which crashed.
+	DefaultOpenTelemetrySessionTimeout = 30 * time.Minute
+	// openTelemetryEventName is the event name of the log records.
+	openTelemetryEventName = "beelzebub.event"
+	// sessionSweepInterval is the interval between two checks of the expired sessions.
+	sessionSweepInterval = time.Minute
+)
+
+// OpenTelemetryOptions are the settings of the opentelemetry sink.
+type OpenTelemetryOptions struct {
+	Logger otellog.Logger
+	Tracer trace.Tracer
+	// InteractionSpans records the Interaction and Download events as child spans of the session, otherwise as span
+	// events.
+	InteractionSpans bool
+	// SessionTimeout ends the span of a session without events for this duration,
+	// DefaultOpenTelemetrySessionTimeout when zero.
+	SessionTimeout time.Duration
+}
+
+// OpenTelemetry exports every event as an OTLP log record, and the sessions as traces: the span of a session lasts
+// from its Start event to its End event, the Interaction and Download events being child spans or span events. The log
+// records of a session carry its trace context, so that the backends correlate them.
+type OpenTelemetry struct {
+	options OpenTelemetryOptions
+
+	mu        sync.Mutex
+	sessions  map[string]*session
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+// session is the span of an attacker session, by event ID.
+type session struct {
+	ctx      context.Context
+	span     trace.Span
+	lastSeen time.Time
+}
+
+func NewOpenTelemetry(options OpenTelemetryOptions) (*OpenTelemetry, error) {
+	if options.Logger == nil || options.Tracer == nil {
+		return nil, errors.New("the opentelemetry sink requires a logger and a tracer")
+	}
+	if options.SessionTimeout <= 0 {
+		options.SessionTimeout = DefaultOpenTelemetrySessionTimeout
+	}
+	return &OpenTelemetry{
+		options:  options,
+		sessions: make(map[string]*session),
+		now:      time.Now,
+	}, nil
+}
+
+func (openTelemetry *OpenTelemetry) Send(ctx context.Context, event tracer.Event) error {
+	timestamp, err := time.Parse(time.RFC3339, event.DateTime)
+	if err != nil {
+		timestamp = openTelemetry.now()
+	}
+	attributes := eventAttributes(event)
+
+	openTelemetry.mu.Lock()
+	sessionCtx := openTelemetry.trace(ctx, event, timestamp, attributes)
+	openTelemetry.sweep()
+	openTelemetry.mu.Unlock()
+
+	var record otellog.Record
+	record.SetEventName(openTelemetryEventName)
+	record.SetTimestamp(timestamp)
+	record.SetObservedTimestamp(openTelemetry.now())
+	record.SetSeverity(otellog.SeverityInfo)
+	record.SetSeverityText("INFO")
+	record.SetBody(otellog.StringValue(event.Msg))
+	record.AddAttributes(logAttributes(attributes)...)
+	openTelemetry.options.Logger.Emit(sessionCtx, record)
+	return nil
+}
+
+// trace records the event in the span of its session, it returns the context of the session, or ctx when the event
+// does not belong to an open session.
+func (openTelemetry *OpenTelemetry) trace(ctx context.Context, event tracer.Event, timestamp time.Time, attributes []attribute.KeyValue) context.Context {
+	current, found := openTelemetry.sessions[event.ID]
+	switch event.Status {
+	case tracer.Start.String():
+		if found {
+			// The ID has been reused, the previous session is over.
+			current.span.End()
+		}
+		sessionCtx, span := openTelemetry.options.Tracer.Start(context.WithoutCancel(ctx), event.Protocol+" session",
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithTimestamp(timestamp),
+			trace.WithAttributes(attributes...),
+		)
+		openTelemetry.sessions[event.ID] = &session{ctx: sessionCtx, span: span, lastSeen: openTelemetry.now()}
+		return sessionCtx
+	case tracer.Interaction.String(), tracer.Download.String():
+		if !found {
+			return ctx
+		}
+		current.lastSeen = openTelemetry.now()
+		if !openTelemetry.options.InteractionSpans {
+			current.span.AddEvent("interaction", trace.WithTimestamp(timestamp), trace.WithAttributes(attributes...))
+			return current.ctx
+		}
+		interactionCtx, span := openTelemetry.options.Tracer.Start(current.ctx, event.Protocol+" interaction",
+			trace.WithTimestamp(timestamp),
+			trace.WithAttributes(attributes...),
+		)
+		span.End(trace.WithTimestamp(timestamp))
+		return interactionCtx
+	case tracer.End.String():
+		if !found {
+			return ctx
+		}
+		current.span.End(trace.WithTimestamp(timestamp))
+		delete(openTelemetry.sessions, event.ID)
+		return current.ctx
+	default:
+		return ctx
+	}
+}
+
+// sweep ends the spans of the sessions without events for SessionTimeout.
+func (openTelemetry *OpenTelemetry) sweep() {
+	now := openTelemetry.now()
+	if now.Sub(openTelemetry.lastSweep) < sessionSweepInterval {
+		return
+	}
+	openTelemetry.lastSweep = now
+	for id, current := range openTelemetry.sessions {
+		if now.Sub(current.lastSeen) >= openTelemetry.options.SessionTimeout {
+			current.span.SetAttributes(attribute.Bool("beelzebub.session.timeout", true))
+			current.span.End(trace.WithTimestamp(current.lastSeen))
+			delete(openTelemetry.sessions, id)
+		}
+	}
+}
+
+// Close ends the spans of the open sessions, the providers are shut down by their owner.
+func (openTelemetry *OpenTelemetry) Close() error {
+	openTelemetry.mu.Lock()
+	defer openTelemetry.mu.Unlock()
+	for id, current := range openTelemetry.sessions {
+		current.span.End()
+		delete(openTelemetry.sessions, id)
+	}
+	return nil
+}
+
+// eventAttributes maps the fields of the event to attributes, following the semantic conventions when they define
+// the field, e.g. SourceIp→client.address, beelzebub.<field> otherwise; the empty values are omitted.
+func eventAttributes(event tracer.Event) []attribute.KeyValue {
+	fields := []eventField{
+		{"session.id", event.ID},
+		{"client.address", event.SourceIp},
+		{"user_agent.original", event.UserAgent},
+		{"http.request.method", event.HTTPMethod},
+		{"url.path", event.RequestURI},
+		{"server.address", event.HostHTTPRequest},
+		{"user.name", event.User},
+		{"file.name", event.FileName},
+		{"beelzebub.protocol", event.Protocol},
+		{"beelzebub.status", event.Status},
+		{"beelzebub.description", event.Description},
+		{"beelzebub.service_address", event.ServiceAddress},
+		{"beelzebub.remote_addr", event.RemoteAddr},
+		{"beelzebub.command", event.Command},
+		{"beelzebub.command_output", event.CommandOutput},
+		{"beelzebub.handler", event.Handler},
+		{"beelzebub.password", event.Password},
+		{"beelzebub.environ", event.Environ},
+		{"beelzebub.client", event.Client},
+		{"beelzebub.headers", event.Headers},
+		{"beelzebub.cookies", event.Cookies},
+		{"beelzebub.body", event.Body},
+		{"beelzebub.tls_server_name", event.TLSServerName},
+		{"beelzebub.public_key", event.PublicKey},
+		{"beelzebub.public_key_type", event.PublicKeyType},
+		{"beelzebub.public_key_fingerprint", event.PublicKeyFingerprint},
+		{"beelzebub.prompt", event.Prompt},
+		{"beelzebub.file_sha256", event.FileSHA256},
+		{"beelzebub.file_sha1", event.FileSHA1},
+		{"beelzebub.file_md5", event.FileMD5},
+		{"beelzebub.destination_host", event.DestinationHost},
+		{"beelzebub.url", event.URL},
+	}
+	attributes := make([]attribute.KeyValue, 0, len(fields)+4)
+	for _, field := range fields {
+		if field.value != "" {
+			attributes = append(attributes, attribute.String(field.key, field.value))
+		}
+	}
+	if port, err := strconv.Atoi(event.SourcePort); err == nil {
+		attributes = append(attributes, attribute.Int("client.port", port))
+	}
+	if port, err := strconv.Atoi(event.DestinationPort); err == nil {
+		attributes = append(attributes, attribute.Int("beelzebub.destination_port", port))
+	}
+	if event.FileSize > 0 {
+		attributes = append(attributes, attribute.Int64("file.size", event.FileSize))
+	}
+	if len(event.SubCommands) > 0 {
+		commands := make([]string, 0, len(event.SubCommands))
+		for _, subCommand := range event.SubCommands {
+			commands = append(commands, subCommand.Command)
+		}
+		attributes = append(attributes, attribute.StringSlice("beelzebub.sub_commands", commands))
+	}
+	return attributes
+}
+
+// logAttributes converts the attributes of eventAttributes to log attributes.
+func logAttributes(attributes []attribute.KeyValue) []otellog.KeyValue {
+	values := make([]otellog.KeyValue, 0, len(attributes))
+	for _, kv := range attributes {
+		key := string(kv.Key)
+		switch kv.Value.Type() {
+		case attribute.INT64:
+			values = append(values, otellog.Int64(key, kv.Value.AsInt64()))
+		case attribute.STRINGSLICE:
+			slice := kv.Value.AsStringSlice()
+			elements := make([]otellog.Value, 0, len(slice))
+			for _, element := range slice {
+				elements = append(elements, otellog.StringValue(element))
+			}
+			values = append(values, otellog.Slice(key, elements...))
+		default:
+			values = append(values, otellog.String(key, kv.Value.Emit()))
+		}
+	}
+	return values
+}
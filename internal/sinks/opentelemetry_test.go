@@ -0,0 +1,147 @@
+package sinks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// logRecorder is a log exporter keeping the records in memory.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (recorder *logRecorder) Export(ctx context.Context, records []sdklog.Record) error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	for _, record := range records {
+		recorder.records = append(recorder.records, record.Clone())
+	}
+	return nil
+}
+
+func (recorder *logRecorder) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (recorder *logRecorder) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+func newTestOpenTelemetry(t *testing.T, interactionSpans bool) (*OpenTelemetry, *logRecorder, *tracetest.SpanRecorder) {
+	logs := &logRecorder{}
+	spans := tracetest.NewSpanRecorder()
+	loggerProvider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logs)))
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+
+	sink, err := NewOpenTelemetry(OpenTelemetryOptions{
+		Logger:           loggerProvider.Logger("test"),
+		Tracer:           tracerProvider.Tracer("test"),
+		InteractionSpans: interactionSpans,
+	})
+	require.NoError(t, err)
+	return sink, logs, spans
+}
+
+func sessionEvents() []tracer.Event {
+	return []tracer.Event{
+		{ID: "session", Protocol: "SSH", Status: tracer.Start.String(), DateTime: "2024-05-01T10:00:00Z", Msg: "New SSH Session", SourceIp: "192.0.2.1", SourcePort: "4242"},
+		{ID: "session", Protocol: "SSH", Status: tracer.Interaction.String(), DateTime: "2024-05-01T10:00:05Z", Msg: "New SSH Terminal Session", Command: "uname -a"},
+		{ID: "session", Protocol: "SSH", Status: tracer.End.String(), DateTime: "2024-05-01T10:00:10Z", Msg: "End SSH Session"},
+		{ID: "stateless", Protocol: "HTTP", Status: tracer.Stateless.String(), DateTime: "2024-05-01T10:00:20Z", Msg: "HTTP New request"},
+	}
+}
+
+func TestNewOpenTelemetry_InvalidOptions(t *testing.T) {
+	_, err := NewOpenTelemetry(OpenTelemetryOptions{})
+	assert.ErrorContains(t, err, "requires a logger and a tracer")
+}
+
+func TestOpenTelemetry_SessionSpanEvents(t *testing.T) {
+	sink, logs, spans := newTestOpenTelemetry(t, false)
+
+	for _, event := range sessionEvents() {
+		require.NoError(t, sink.Send(context.Background(), event))
+	}
+	require.NoError(t, sink.Close())
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	span := ended[0]
+	assert.Equal(t, "SSH session", span.Name())
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), span.StartTime().UTC())
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 10, 0, time.UTC), span.EndTime().UTC())
+	assert.Contains(t, span.Attributes(), attribute.String("client.address", "192.0.2.1"))
+	assert.Contains(t, span.Attributes(), attribute.Int("client.port", 4242))
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, "interaction", span.Events()[0].Name)
+	assert.Contains(t, span.Events()[0].Attributes, attribute.String("beelzebub.command", "uname -a"))
+
+	require.Len(t, logs.records, 4)
+	for _, record := range logs.records[:3] {
+		assert.Equal(t, span.SpanContext().TraceID(), record.TraceID(), "the log records of the session are correlated")
+	}
+	assert.False(t, logs.records[3].TraceID().IsValid(), "a stateless event is not part of a trace")
+	assert.Equal(t, otellog.StringValue("HTTP New request"), logs.records[3].Body())
+	assert.Equal(t, openTelemetryEventName, logs.records[3].EventName())
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 20, 0, time.UTC), logs.records[3].Timestamp().UTC())
+}
+
+func TestOpenTelemetry_InteractionSpans(t *testing.T) {
+	sink, logs, spans := newTestOpenTelemetry(t, true)
+
+	for _, event := range sessionEvents()[:3] {
+		require.NoError(t, sink.Send(context.Background(), event))
+	}
+
+	ended := spans.Ended()
+	require.Len(t, ended, 2)
+	interaction, session := ended[0], ended[1]
+	assert.Equal(t, "SSH interaction", interaction.Name())
+	assert.Equal(t, session.SpanContext().SpanID(), interaction.Parent().SpanID())
+	assert.Equal(t, session.SpanContext().TraceID(), interaction.SpanContext().TraceID())
+	assert.Equal(t, interaction.SpanContext().SpanID(), logs.records[1].SpanID(), "the log record of the interaction belongs to its span")
+}
+
+func TestOpenTelemetry_DownloadEvents(t *testing.T) {
+	sink, _, spans := newTestOpenTelemetry(t, false)
+
+	events := sessionEvents()[:3]
+	events[1].Status = tracer.Download.String()
+	for _, event := range events {
+		require.NoError(t, sink.Send(context.Background(), event))
+	}
+
+	// A download belongs to the session span, as the other interactions.
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	require.Len(t, ended[0].Events(), 1)
+	assert.Equal(t, "interaction", ended[0].Events()[0].Name)
+}
+
+func TestOpenTelemetry_SessionTimeout(t *testing.T) {
+	sink, _, spans := newTestOpenTelemetry(t, false)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	sink.now = func() time.Time { return now }
+
+	require.NoError(t, sink.Send(context.Background(), sessionEvents()[0]))
+	now = now.Add(DefaultOpenTelemetrySessionTimeout)
+	require.NoError(t, sink.Send(context.Background(), sessionEvents()[3]))
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	assert.Contains(t, ended[0].Attributes(), attribute.Bool("beelzebub.session.timeout", true))
+	assert.Empty(t, sink.sessions)
+}
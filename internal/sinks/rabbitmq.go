@@ -0,0 +1,177 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// RabbitMQQueueName is the queue receiving the events published on RabbitMQ when the configuration does not set one.
+const RabbitMQQueueName = "event"
+
+// RabbitMQOptions are the settings of the RabbitMQ sink.
+type RabbitMQOptions struct {
+	URI string
+	// Exchange is declared and receives the events, when empty they are published on Queue through the default exchange.
+	Exchange string
+	// ExchangeType is the type of the exchange: direct, fanout, topic or headers, topic when empty.
+	ExchangeType string
+	// RoutingKey is a template rendered with the event, e.g. "beelzebub.{{lower .Protocol}}.{{lower .Status}}", the
+	// name of the queue when empty.
+	RoutingKey string
+	// Queue is declared when no exchange is set, RabbitMQQueueName when empty. With an exchange, the consumers bind
+	// their own queues.
+	Queue string
+	// Durable declares a durable exchange or queue and publishes persistent messages, which survive a broker restart.
+	Durable bool
+	// PublisherConfirms waits for the broker to acknowledge every event, a rejected event is retried.
+	PublisherConfirms bool
+	// TLS is used by the amqps:// URIs, e.g. to authenticate with a client certificate.
+	TLS *tls.Config
+}
+
+// RabbitMQ publishes the events as JSON, reconnecting to the broker when the connection is lost.
+type RabbitMQ struct {
+	options    RabbitMQOptions
+	routingKey *eventTemplate
+
+	mu         sync.Mutex
+	connection *amqp.Connection
+	channel    *amqp.Channel
+}
+
+// NewRabbitMQ connects to the broker and declares the exchange or the queue of the events.
+func NewRabbitMQ(options RabbitMQOptions) (*RabbitMQ, error) {
+	if options.Queue == "" {
+		options.Queue = RabbitMQQueueName
+	}
+	if options.ExchangeType == "" {
+		options.ExchangeType = amqp.ExchangeTopic
+	}
+	if options.RoutingKey == "" {
+		options.RoutingKey = options.Queue
+	}
+	routingKey, err := newEventTemplate("routing key", options.RoutingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rabbitMQ := &RabbitMQ{options: options, routingKey: routingKey}
+	if err := rabbitMQ.connect(); err != nil {
+		return nil, err
+	}
+	return rabbitMQ, nil
+}
+
+func (rabbitMQ *RabbitMQ) connect() error {
+	connection, err := amqp.DialTLS(rabbitMQ.options.URI, rabbitMQ.options.TLS)
+	if err != nil {
+		return fmt.Errorf("error during connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := connection.Channel()
+	if err != nil {
+		connection.Close()
+		return fmt.Errorf("error during open RabbitMQ channel: %w", err)
+	}
+
+	if rabbitMQ.options.PublisherConfirms {
+		if err := channel.Confirm(false); err != nil {
+			connection.Close()
+			return fmt.Errorf("error during enable RabbitMQ publisher confirms: %w", err)
+		}
+	}
+
+	if rabbitMQ.options.Exchange != "" {
+		if err := channel.ExchangeDeclare(rabbitMQ.options.Exchange, rabbitMQ.options.ExchangeType, rabbitMQ.options.Durable, false, false, false, nil); err != nil {
+			connection.Close()
+			return fmt.Errorf("error during declare RabbitMQ exchange: %w", err)
+		}
+	} else {
+		//creates a queue if it doesn't already exist, or ensures that an existing queue matches the same parameters.
+		if _, err = channel.QueueDeclare(rabbitMQ.options.Queue, rabbitMQ.options.Durable, false, false, false, nil); err != nil {
+			connection.Close()
+			return fmt.Errorf("error during declare RabbitMQ queue: %w", err)
+		}
+	}
+
+	rabbitMQ.connection = connection
+	rabbitMQ.channel = channel
+	return nil
+}
+
+func (rabbitMQ *RabbitMQ) disconnect() error {
+	if rabbitMQ.connection == nil {
+		return nil
+	}
+	err := rabbitMQ.connection.Close()
+	rabbitMQ.connection = nil
+	rabbitMQ.channel = nil
+	if errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (rabbitMQ *RabbitMQ) Send(ctx context.Context, event tracer.Event) error {
+	routingKey, err := rabbitMQ.routingKey.render(event)
+	if err != nil {
+		return err
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error during marshal event: %w", err)
+	}
+
+	publishing := amqp.Publishing{ContentType: "application/json", Body: eventJSON}
+	if rabbitMQ.options.Durable {
+		publishing.DeliveryMode = amqp.Persistent
+	}
+
+	rabbitMQ.mu.Lock()
+	defer rabbitMQ.mu.Unlock()
+
+	// The channel is closed when the broker restarts or the network fails, it is re-created on the next event.
+	if rabbitMQ.channel == nil || rabbitMQ.channel.IsClosed() {
+		rabbitMQ.disconnect()
+		if err := rabbitMQ.connect(); err != nil {
+			return err
+		}
+		log.Info("Reconnected to RabbitMQ")
+	}
+
+	confirmation, err := rabbitMQ.channel.PublishWithDeferredConfirmWithContext(ctx, rabbitMQ.options.Exchange, routingKey, false, false, publishing)
+	if err != nil {
+		return fmt.Errorf("error during publish event on RabbitMQ: %w", err)
+	}
+	if confirmation != nil {
+		acked, err := confirmation.WaitContext(ctx)
+		if err != nil {
+			return fmt.Errorf("error during wait RabbitMQ publisher confirm: %w", err)
+		}
+		if !acked {
+			return errors.New("event rejected by RabbitMQ")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"status":     event.Status,
+		"routingKey": routingKey,
+		"event":      event,
+	}).Debug("Event published")
+	return nil
+}
+
+func (rabbitMQ *RabbitMQ) Close() error {
+	rabbitMQ.mu.Lock()
+	defer rabbitMQ.mu.Unlock()
+	return rabbitMQ.disconnect()
+}
@@ -0,0 +1,146 @@
+// Package sinks is responsible for delivering the traced events to the tracing backends, e.g. RabbitMQ
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+const (
+	// DefaultQueueSize is the number of events waiting for a sink when the configuration does not set one.
+	DefaultQueueSize = 1000
+	// DefaultMaxAttempts is the number of delivery attempts of an event when the configuration does not set one.
+	DefaultMaxAttempts = 3
+	// DefaultInitialBackoff is the pause before the first retry when the configuration does not set one.
+	DefaultInitialBackoff = time.Second
+	// DefaultMaxBackoff caps the pause between two retries when the configuration does not set one.
+	DefaultMaxBackoff = 30 * time.Second
+	// DefaultBatchInterval is the longest wait of an event for its batch to fill when the configuration does not set one.
+	DefaultBatchInterval = 5 * time.Second
+)
+
+// Sink is a tracing backend.
+type Sink interface {
+	// Send delivers the event; an error means that the event has not been delivered, and can be retried.
+	Send(ctx context.Context, event tracer.Event) error
+	// Close releases the resources of the sink, once no more events are sent.
+	Close() error
+}
+
+// BatchSink is a sink able to deliver several events at once, e.g. in a single HTTP request.
+type BatchSink interface {
+	Sink
+	// SendBatch delivers the events; an error means that the batch has not been delivered, and can be retried.
+	SendBatch(ctx context.Context, events []tracer.Event) error
+}
+
+// BatchError is returned by a sink which delivered only some of the events, e.g. a bulk request with failed items:
+// the events at the Retry indexes are retried, the ones at the Rejected indexes are given up, the others are delivered.
+type BatchError struct {
+	Retry    []int
+	Rejected []int
+	Err      error
+}
+
+func (batchError *BatchError) Error() string {
+	return fmt.Sprintf("%d events to retry, %d events rejected: %s", len(batchError.Retry), len(batchError.Rejected), batchError.Err.Error())
+}
+
+func (batchError *BatchError) Unwrap() error {
+	return batchError.Err
+}
+
+// Filter selects the events delivered to a sink, an empty list matches any value.
+type Filter struct {
+	// Protocols are matched case-insensitively against the event protocol, e.g. "ssh".
+	Protocols []string
+	// Statuses are matched case-insensitively against the event status, e.g. "Interaction".
+	Statuses []string
+	// Services are matched against the address and the description of the service which traced the event.
+	Services []string
+}
+
+// Match reports whether the event passes the filter.
+func (filter Filter) Match(event tracer.Event) bool {
+	if len(filter.Protocols) > 0 && !slices.ContainsFunc(filter.Protocols, func(protocol string) bool {
+		return strings.EqualFold(protocol, event.Protocol)
+	}) {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !slices.ContainsFunc(filter.Statuses, func(status string) bool {
+		return strings.EqualFold(status, event.Status)
+	}) {
+		return false
+	}
+	if len(filter.Services) > 0 && !slices.ContainsFunc(filter.Services, func(service string) bool {
+		return service == event.ServiceAddress || service == event.Description
+	}) {
+		return false
+	}
+	return true
+}
+
+// Retry is the delivery policy of a sink: a failed event is retried with an exponential backoff.
+type Retry struct {
+	// MaxAttempts is the number of delivery attempts of an event, zero means DefaultMaxAttempts.
+	MaxAttempts int
+	// InitialBackoff is the pause before the first retry, doubled on every retry, zero means DefaultInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the pause between two retries, zero means DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+func (retry Retry) maxAttempts() int {
+	if retry.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return retry.MaxAttempts
+}
+
+// Backoff returns the pause before the given retry, the first retry being 1.
+func (retry Retry) Backoff(retryNumber int) time.Duration {
+	backoff := retry.InitialBackoff
+	if backoff <= 0 {
+		backoff = DefaultInitialBackoff
+	}
+	maxBackoff := retry.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	for i := 1; i < retryNumber && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// Batch groups the events of a BatchSink, the other sinks ignore it.
+type Batch struct {
+	// Size is the maximum number of events of a batch, zero or one disables the batching.
+	Size int
+	// Interval is the longest wait of an event for its batch to fill, zero means DefaultBatchInterval.
+	Interval time.Duration
+}
+
+func (batch Batch) size() int {
+	return max(batch.Size, 1)
+}
+
+func (batch Batch) interval() time.Duration {
+	if batch.Interval <= 0 {
+		return DefaultBatchInterval
+	}
+	return batch.Interval
+}
+
+// RateLimit caps the requests to a sink, a batch being a single request.
+type RateLimit struct {
+	// PerSecond is the sustained rate of requests, zero disables the limit.
+	PerSecond float64
+	// Burst is the number of requests sent at once above the rate, zero means 1.
+	Burst int
+}
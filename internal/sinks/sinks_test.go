@@ -0,0 +1,303 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/rotation"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSink struct {
+	mu sync.Mutex
+	// failures is the number of Send calls failing before the first success, -1 fails forever.
+	failures int
+	attempts int
+	events   []tracer.Event
+	block    chan struct{}
+	closed   bool
+}
+
+func (m *mockSink) Send(ctx context.Context, event tracer.Event) error {
+	if m.block != nil {
+		select {
+		case <-m.block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts++
+	if m.failures != 0 {
+		if m.failures > 0 {
+			m.failures--
+		}
+		return errors.New("mockError")
+	}
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockSink) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockSink) ids() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, event := range m.events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+type mockBatchSink struct {
+	mockSink
+	batches [][]string
+}
+
+func (m *mockBatchSink) SendBatch(ctx context.Context, events []tracer.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	m.batches = append(m.batches, ids)
+	return nil
+}
+
+func TestFilterMatch(t *testing.T) {
+	event := tracer.Event{Protocol: "SSH", Status: "Interaction", ServiceAddress: ":22", Description: "SSH interactive"}
+
+	assert.True(t, Filter{}.Match(event))
+	assert.True(t, Filter{Protocols: []string{"http", "ssh"}}.Match(event))
+	assert.False(t, Filter{Protocols: []string{"http"}}.Match(event))
+	assert.True(t, Filter{Statuses: []string{"interaction"}}.Match(event))
+	assert.False(t, Filter{Statuses: []string{"Start", "End"}}.Match(event))
+	assert.True(t, Filter{Services: []string{":22"}}.Match(event))
+	assert.True(t, Filter{Services: []string{"SSH interactive"}}.Match(event))
+	assert.False(t, Filter{Services: []string{":2222"}}.Match(event))
+	assert.False(t, Filter{Protocols: []string{"ssh"}, Statuses: []string{"Start"}}.Match(event))
+
+	// The port forwarding requests are selected by their own status, not by Stateless.
+	forward := tracer.Event{Protocol: "SSH", Status: tracer.PortForward.String(), Msg: "SSH Remote Port Forward Request"}
+	assert.True(t, Filter{Statuses: []string{"portforward"}}.Match(forward))
+	assert.False(t, Filter{Statuses: []string{"Stateless"}}.Match(forward))
+	assert.False(t, Filter{Statuses: []string{"PortForward"}}.Match(event))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, DefaultInitialBackoff, Retry{}.Backoff(1))
+	assert.Equal(t, 2*DefaultInitialBackoff, Retry{}.Backoff(2))
+	assert.Equal(t, DefaultMaxBackoff, Retry{}.Backoff(100))
+
+	retry := Retry{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	assert.Equal(t, 100*time.Millisecond, retry.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, retry.Backoff(2))
+	assert.Equal(t, 300*time.Millisecond, retry.Backoff(3))
+}
+
+func TestDispatcher_FanOutWithFilters(t *testing.T) {
+	all := &mockSink{}
+	ssh := &mockSink{}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "all", Sink: all},
+		{Name: "ssh", Sink: ssh, Filter: Filter{Protocols: []string{"ssh"}}},
+	})
+
+	dispatcher.Dispatch(tracer.Event{ID: "1", Protocol: "SSH"})
+	dispatcher.Dispatch(tracer.Event{ID: "2", Protocol: "HTTP"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, []string{"1", "2"}, all.ids())
+	assert.Equal(t, []string{"1"}, ssh.ids())
+	assert.True(t, all.closed)
+	assert.True(t, ssh.closed)
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	flaky := &mockSink{failures: 2}
+	broken := &mockSink{failures: -1}
+	retry := Retry{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "flaky", Sink: flaky, Retry: retry},
+		{Name: "broken", Sink: broken, Retry: retry},
+	})
+
+	dispatcher.Dispatch(tracer.Event{ID: "1"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, []string{"1"}, flaky.ids())
+	assert.Equal(t, 3, flaky.attempts)
+	assert.Empty(t, broken.ids())
+	assert.Equal(t, 3, broken.attempts)
+}
+
+func TestDispatcher_FailingSinkDoesNotBlockOthers(t *testing.T) {
+	stuck := &mockSink{block: make(chan struct{})}
+	healthy := &mockSink{}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "stuck", Sink: stuck, QueueSize: 1},
+		{Name: "healthy", Sink: healthy},
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		dispatcher.Dispatch(tracer.Event{ID: id})
+	}
+	assert.Eventually(t, func() bool {
+		return len(healthy.ids()) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	// The stuck sink is abandoned once the deadline of Close expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, dispatcher.Close(ctx))
+	assert.Empty(t, stuck.ids())
+	assert.True(t, stuck.closed)
+}
+
+func TestDispatcher_DispatchAfterClose(t *testing.T) {
+	sink := &mockSink{}
+	dispatcher := NewDispatcher([]Config{{Name: "all", Sink: sink}})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	// A tracer worker still running after the deadline of the shutdown must not panic.
+	assert.NotPanics(t, func() { dispatcher.Dispatch(tracer.Event{ID: "1"}) })
+	assert.Empty(t, sink.ids())
+	assert.Equal(t, 1.0, testutil.ToFloat64(dispatcher.eventsTotal.WithLabelValues("all", "dropped")))
+}
+
+func TestDispatcher_Batch(t *testing.T) {
+	batched := &mockBatchSink{}
+	single := &mockSink{}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "batched", Sink: batched, Batch: Batch{Size: 2, Interval: 20 * time.Millisecond}},
+		// A sink unable to deliver batches ignores the batch settings.
+		{Name: "single", Sink: single, Batch: Batch{Size: 2}},
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		dispatcher.Dispatch(tracer.Event{ID: id})
+	}
+	// The incomplete batch is sent once its interval expires.
+	assert.Eventually(t, func() bool {
+		batched.mu.Lock()
+		defer batched.mu.Unlock()
+		return len(batched.batches) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	dispatcher.Dispatch(tracer.Event{ID: "4"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, [][]string{{"1", "2"}, {"3"}, {"4"}}, batched.batches, "the pending batch is sent on close")
+	assert.Equal(t, []string{"1", "2", "3", "4"}, single.ids())
+}
+
+func TestDispatcher_RateLimit(t *testing.T) {
+	limited := &mockSink{}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "limited", Sink: limited, RateLimit: RateLimit{PerSecond: 20}},
+	})
+
+	start := time.Now()
+	for _, id := range []string{"1", "2", "3"} {
+		dispatcher.Dispatch(tracer.Event{ID: id})
+	}
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, []string{"1", "2", "3"}, limited.ids())
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "two requests wait for the limit")
+}
+
+func TestNewRabbitMQ_InvalidURI(t *testing.T) {
+	_, err := NewRabbitMQ(RabbitMQOptions{URI: "invalid-uri"})
+	assert.Error(t, err)
+}
+
+func TestNewRabbitMQ_InvalidRoutingKey(t *testing.T) {
+	_, err := NewRabbitMQ(RabbitMQOptions{URI: "amqp://localhost/", RoutingKey: "beelzebub.{{.Protocol"})
+	assert.Error(t, err)
+}
+
+func TestEventTemplate(t *testing.T) {
+	routingKey, err := newEventTemplate("routing key", "beelzebub.{{lower .Protocol}}.{{lower .Status}}")
+	require.NoError(t, err)
+
+	rendered, err := routingKey.render(tracer.Event{Protocol: "SSH", Status: "Interaction"})
+	require.NoError(t, err)
+	assert.Equal(t, "beelzebub.ssh.interaction", rendered)
+
+	unknownField, err := newEventTemplate("routing key", "{{.Unknown}}")
+	require.NoError(t, err)
+	_, err = unknownField.render(tracer.Event{})
+	assert.Error(t, err)
+}
+
+func TestTLSConfig(t *testing.T) {
+	config, err := TLSConfig(TLSOptions{ServerName: "rabbit", InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.Equal(t, "rabbit", config.ServerName)
+	assert.True(t, config.InsecureSkipVerify)
+
+	_, err = TLSConfig(TLSOptions{CACertPath: "/not/found.pem"})
+	assert.Error(t, err)
+
+	caCertPath := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCertPath, []byte("not a certificate"), 0600))
+	_, err = TLSConfig(TLSOptions{CACertPath: caCertPath})
+	assert.Error(t, err)
+
+	_, err = TLSConfig(TLSOptions{CertPath: "/not/found.pem", KeyPath: "/not/found.key"})
+	assert.Error(t, err)
+}
+
+type mockBeelzebubCloudClient struct {
+	result bool
+	err    error
+}
+
+func (m mockBeelzebubCloudClient) SendEvent(event tracer.Event) (bool, error) {
+	return m.result, m.err
+}
+
+func TestBeelzebubCloud_Send(t *testing.T) {
+	assert.NoError(t, (&BeelzebubCloud{client: mockBeelzebubCloudClient{result: true}}).Send(context.Background(), tracer.Event{}))
+	assert.Error(t, (&BeelzebubCloud{client: mockBeelzebubCloudClient{result: false}}).Send(context.Background(), tracer.Event{}))
+	assert.Error(t, (&BeelzebubCloud{client: mockBeelzebubCloudClient{err: errors.New("mockError")}}).Send(context.Background(), tracer.Event{}))
+}
+
+func TestFile_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	file, err := NewFile(path, rotation.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, file.Send(context.Background(), tracer.Event{ID: "1", CommandOutput: "a\nb"}))
+	require.NoError(t, file.Send(context.Background(), tracer.Event{ID: "2"}))
+	require.NoError(t, file.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var event tracer.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal(t, tracer.Event{ID: "1", CommandOutput: "a\nb"}, event)
+}
@@ -0,0 +1,238 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultSplunkBatchSize is the number of events of a request when the configuration does not set one.
+	DefaultSplunkBatchSize = 100
+	// DefaultSplunkTimeout bounds a request when the configuration does not set one.
+	DefaultSplunkTimeout = 10 * time.Second
+	// DefaultSplunkAckTimeout bounds the wait for the indexer acknowledgement when the configuration does not set one.
+	DefaultSplunkAckTimeout = time.Minute
+	splunkAckPollInterval   = time.Second
+	// splunkInvalidDataFormat is the HEC code of a request with an invalid event, the events before it are indexed.
+	splunkInvalidDataFormat = 6
+)
+
+// SplunkMetadata are the metadata of the events, empty values mean the defaults of the HEC token.
+type SplunkMetadata struct {
+	Host       string
+	Source     string
+	Sourcetype string
+	Index      string
+}
+
+// SplunkOptions are the settings of the splunk sink.
+type SplunkOptions struct {
+	// URL is the base URL of the HTTP Event Collector, e.g. https://splunk.example.com:8088.
+	URL   string
+	Token string
+	SplunkMetadata
+	// Services override the metadata of the events of a service, by address or description.
+	Services map[string]SplunkMetadata
+	// IndexerAck counts an event as delivered only once indexed, it requires the indexer acknowledgement on the token.
+	IndexerAck bool
+	// AckTimeout is the longest wait for the indexer acknowledgement, DefaultSplunkAckTimeout when zero.
+	AckTimeout time.Duration
+	Timeout    time.Duration
+	TLS        *tls.Config
+}
+
+// Splunk sends the events to a Splunk HTTP Event Collector, the DateTime of an event being its time.
+type Splunk struct {
+	options SplunkOptions
+	client  *resty.Client
+}
+
+type splunkEvent struct {
+	Time       *float64     `json:"time,omitempty"`
+	Host       string       `json:"host,omitempty"`
+	Source     string       `json:"source,omitempty"`
+	Sourcetype string       `json:"sourcetype,omitempty"`
+	Index      string       `json:"index,omitempty"`
+	Event      tracer.Event `json:"event"`
+}
+
+type splunkResponse struct {
+	Text               string `json:"text"`
+	Code               int    `json:"code"`
+	InvalidEventNumber *int   `json:"invalid-event-number"`
+	AckID              *int64 `json:"ackId"`
+}
+
+// NewSplunk validates the options, the collector is contacted by the first events.
+func NewSplunk(options SplunkOptions) (*Splunk, error) {
+	parsedURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid splunk url: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid splunk url %q, expected an http or https url", options.URL)
+	}
+	if options.Token == "" {
+		return nil, errors.New("the splunk sink requires a token")
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultSplunkTimeout
+	}
+	if options.AckTimeout <= 0 {
+		options.AckTimeout = DefaultSplunkAckTimeout
+	}
+
+	client := resty.New().
+		SetBaseURL(strings.TrimSuffix(options.URL, "/")).
+		SetTimeout(options.Timeout).
+		SetHeader("Authorization", "Splunk "+options.Token)
+	if options.IndexerAck {
+		// The acknowledgements are scoped to a channel.
+		client.SetHeader("X-Splunk-Request-Channel", uuid.New().String())
+	}
+	if options.TLS != nil {
+		client.SetTLSClientConfig(options.TLS)
+	}
+	return &Splunk{options: options, client: client}, nil
+}
+
+func (splunk *Splunk) Send(ctx context.Context, event tracer.Event) error {
+	return splunk.SendBatch(ctx, []tracer.Event{event})
+}
+
+// SendBatch sends the events in a single request; with the indexer acknowledgement, it waits until they are indexed.
+func (splunk *Splunk) SendBatch(ctx context.Context, events []tracer.Event) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		data, err := json.Marshal(splunk.hecEvent(event))
+		if err != nil {
+			return fmt.Errorf("error during marshal event: %w", err)
+		}
+		body.Write(data)
+	}
+
+	response, err := splunk.client.R().
+		SetContext(ctx).
+		SetBody(body.Bytes()).
+		Post("/services/collector/event")
+	if err != nil {
+		return fmt.Errorf("error during send splunk request: %w", err)
+	}
+	var result splunkResponse
+	json.Unmarshal(response.Body(), &result)
+
+	if response.StatusCode() == http.StatusBadRequest && result.Code == splunkInvalidDataFormat && result.InvalidEventNumber != nil {
+		return invalidEventError(len(events), *result.InvalidEventNumber, result.Text)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("splunk responded with status %d: %s", response.StatusCode(), response.String())
+	}
+	if !splunk.options.IndexerAck {
+		return nil
+	}
+	if result.AckID == nil {
+		return errors.New("splunk responded without ackId, enable the indexer acknowledgement on the token")
+	}
+	return splunk.waitAck(ctx, *result.AckID)
+}
+
+// invalidEventError rejects the invalid event, the events after it are not indexed and are retried.
+func invalidEventError(events, invalidEvent int, text string) error {
+	if invalidEvent < 0 || invalidEvent >= events {
+		return fmt.Errorf("splunk rejected the event %d: %s", invalidEvent, text)
+	}
+	batchError := &BatchError{Rejected: []int{invalidEvent}, Err: fmt.Errorf("splunk rejected the event %d: %s", invalidEvent, text)}
+	for i := invalidEvent + 1; i < events; i++ {
+		batchError.Retry = append(batchError.Retry, i)
+	}
+	return batchError
+}
+
+// waitAck polls the acknowledgement of the request until the events are indexed; after AckTimeout the events are
+// considered lost, and retried.
+func (splunk *Splunk) waitAck(ctx context.Context, ackID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, splunk.options.AckTimeout)
+	defer cancel()
+	ticker := time.NewTicker(splunkAckPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var acks struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		response, err := splunk.client.R().
+			SetContext(ctx).
+			SetBody(map[string][]int64{"acks": {ackID}}).
+			Post("/services/collector/ack")
+		if err == nil && response.IsSuccess() {
+			if err := json.Unmarshal(response.Body(), &acks); err == nil && acks.Acks[strconv.FormatInt(ackID, 10)] {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("error during wait splunk acknowledgement %d: %w", ackID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (splunk *Splunk) hecEvent(event tracer.Event) splunkEvent {
+	metadata := splunk.options.SplunkMetadata
+	service, found := splunk.options.Services[event.ServiceAddress]
+	if !found {
+		service, found = splunk.options.Services[event.Description]
+	}
+	if found {
+		metadata = metadata.override(service)
+	}
+
+	hecEvent := splunkEvent{
+		Host:       metadata.Host,
+		Source:     metadata.Source,
+		Sourcetype: metadata.Sourcetype,
+		Index:      metadata.Index,
+		Event:      event,
+	}
+	if date, err := time.Parse(time.RFC3339Nano, event.DateTime); err == nil {
+		// Epoch seconds, with milliseconds.
+		seconds := float64(date.UnixMilli()) / 1000
+		hecEvent.Time = &seconds
+	}
+	return hecEvent
+}
+
+func (metadata SplunkMetadata) override(service SplunkMetadata) SplunkMetadata {
+	if service.Host != "" {
+		metadata.Host = service.Host
+	}
+	if service.Source != "" {
+		metadata.Source = service.Source
+	}
+	if service.Sourcetype != "" {
+		metadata.Sourcetype = service.Sourcetype
+	}
+	if service.Index != "" {
+		metadata.Index = service.Index
+	}
+	return metadata
+}
+
+func (splunk *Splunk) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHEC is an HTTP Event Collector acknowledging the requests once acknowledged is set.
+type fakeHEC struct {
+	mu           sync.Mutex
+	events       []map[string]any
+	channels     []string
+	acknowledged bool
+	// invalidEvent is the index of the event rejected in the next request, -1 for none.
+	invalidEvent int
+}
+
+func (fake *fakeHEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Splunk token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fake.channels = append(fake.channels, r.Header.Get("X-Splunk-Request-Channel"))
+
+	switch r.URL.Path {
+	case "/services/collector/event":
+		decoder := json.NewDecoder(r.Body)
+		for i := 0; decoder.More(); i++ {
+			var event map[string]any
+			if err := decoder.Decode(&event); err != nil || i == fake.invalidEvent {
+				fake.invalidEvent = -1
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"text":"Invalid data format","code":6,"invalid-event-number":%d}`, i)
+				return
+			}
+			fake.events = append(fake.events, event)
+		}
+		fmt.Fprint(w, `{"text":"Success","code":0,"ackId":7}`)
+	case "/services/collector/ack":
+		fmt.Fprintf(w, `{"acks":{"7":%t}}`, fake.acknowledged)
+	}
+}
+
+func TestNewSplunk_InvalidOptions(t *testing.T) {
+	_, err := NewSplunk(SplunkOptions{URL: "splunk:8088", Token: "token"})
+	assert.Error(t, err)
+	_, err = NewSplunk(SplunkOptions{URL: "https://splunk:8088"})
+	assert.Error(t, err)
+}
+
+func TestSplunk_SendBatch(t *testing.T) {
+	fake := &fakeHEC{acknowledged: true, invalidEvent: -1}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	splunk, err := NewSplunk(SplunkOptions{
+		URL:            server.URL,
+		Token:          "token",
+		SplunkMetadata: SplunkMetadata{Sourcetype: "beelzebub", Index: "honeypot"},
+		Services:       map[string]SplunkMetadata{":22": {Index: "ssh", Host: "sensor-1"}},
+		IndexerAck:     true,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, splunk.SendBatch(context.Background(), []tracer.Event{
+		{ID: "1", DateTime: "2024-05-01T10:00:00Z", ServiceAddress: ":22"},
+		{ID: "2", ServiceAddress: ":80"},
+	}))
+
+	require.Len(t, fake.events, 2)
+	assert.Equal(t, 1714557600.0, fake.events[0]["time"])
+	assert.Equal(t, "ssh", fake.events[0]["index"])
+	assert.Equal(t, "sensor-1", fake.events[0]["host"])
+	assert.Equal(t, "beelzebub", fake.events[0]["sourcetype"], "the service inherits the default metadata")
+	assert.Equal(t, "1", fake.events[0]["event"].(map[string]any)["ID"])
+	assert.NotContains(t, fake.events[1], "time")
+	assert.Equal(t, "honeypot", fake.events[1]["index"])
+
+	require.Len(t, fake.channels, 2, "the event request, then the acknowledgement poll")
+	assert.NotEmpty(t, fake.channels[0])
+	assert.Equal(t, fake.channels[0], fake.channels[1])
+}
+
+func TestSplunk_AckTimeout(t *testing.T) {
+	server := httptest.NewServer(&fakeHEC{invalidEvent: -1})
+	defer server.Close()
+
+	splunk, err := NewSplunk(SplunkOptions{URL: server.URL, Token: "token", IndexerAck: true, AckTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	err = splunk.Send(context.Background(), tracer.Event{ID: "1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSplunk_InvalidEvent(t *testing.T) {
+	fake := &fakeHEC{invalidEvent: 1}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	splunk, err := NewSplunk(SplunkOptions{URL: server.URL, Token: "token"})
+	require.NoError(t, err)
+
+	err = splunk.SendBatch(context.Background(), []tracer.Event{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+	var batchError *BatchError
+	require.ErrorAs(t, err, &batchError)
+	assert.Equal(t, []int{1}, batchError.Rejected)
+	assert.Equal(t, []int{2}, batchError.Retry, "the events after the invalid one are not indexed")
+}
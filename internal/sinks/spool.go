@@ -0,0 +1,426 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+const (
+	// DefaultSpoolSegmentSize is the size of a spool segment when the configuration does not set one.
+	DefaultSpoolSegmentSize int64 = 16 << 20
+	// DefaultSpoolMaxSize caps the size of a spool when the configuration does not set one.
+	DefaultSpoolMaxSize int64 = 1 << 30
+
+	spoolSegmentExtension = ".jsonl"
+	spoolCursorFileName   = "cursor"
+)
+
+var errSpoolClosed = errors.New("spool closed")
+
+// SpoolOptions are the settings of a spool, zero values mean the defaults.
+type SpoolOptions struct {
+	// SegmentSize is the size of a segment file, a new segment is started once it is exceeded.
+	SegmentSize int64
+	// MaxSize caps the size of the spool, the oldest segments are evicted to make room.
+	MaxSize int64
+}
+
+type spoolSegment struct {
+	sequence uint64
+	size     int64
+	// events is the number of events of the segment not acknowledged yet.
+	events int
+}
+
+// Spool is a write-ahead log of events, made of segmented JSONL files: the events are appended before the delivery,
+// and removed once acknowledged, so that they survive a restart while the sink is unreachable.
+// The events are written to the operating system without fsync, they survive a crash of beelzebub but not of the host.
+type Spool struct {
+	dir     string
+	options SpoolOptions
+
+	mu       sync.Mutex
+	notify   chan struct{}
+	segments []*spoolSegment
+	writer   *os.File
+	reader   *os.File
+	lines    *bufio.Reader
+	// readOffset is the offset of the first event not acknowledged, in the first segment.
+	readOffset int64
+	// peeked is the length of the lines returned by Next or NextBatch, and peekedEvents their number, waiting for Ack.
+	peeked       int64
+	peekedEvents int
+	closed       bool
+}
+
+// OpenSpool opens the spool in dir, the events left by a previous run are delivered first.
+func OpenSpool(dir string, options SpoolOptions) (*Spool, error) {
+	if options.SegmentSize <= 0 {
+		options.SegmentSize = DefaultSpoolSegmentSize
+	}
+	if options.MaxSize <= 0 {
+		options.MaxSize = DefaultSpoolMaxSize
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error during create spool directory: %w", err)
+	}
+
+	spool := &Spool{dir: dir, options: options, notify: make(chan struct{}, 1)}
+	if err := spool.load(); err != nil {
+		spool.Close()
+		return nil, err
+	}
+	return spool, nil
+}
+
+// load reads the segments and the cursor left on disk.
+func (spool *Spool) load() error {
+	entries, err := os.ReadDir(spool.dir)
+	if err != nil {
+		return fmt.Errorf("error during read spool directory: %w", err)
+	}
+	for _, entry := range entries {
+		name, found := strings.CutSuffix(entry.Name(), spoolSegmentExtension)
+		if !found || entry.IsDir() {
+			continue
+		}
+		sequence, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		spool.segments = append(spool.segments, &spoolSegment{sequence: sequence})
+	}
+	slices.SortFunc(spool.segments, func(a, b *spoolSegment) int {
+		return cmp.Compare(a.sequence, b.sequence)
+	})
+
+	cursorSequence, cursorOffset := spool.readCursor()
+	// The segments before the cursor are acknowledged, their removal was interrupted.
+	for len(spool.segments) > 0 && spool.segments[0].sequence < cursorSequence {
+		os.Remove(spool.segmentPath(spool.segments[0].sequence))
+		spool.segments = spool.segments[1:]
+	}
+	if len(spool.segments) > 0 && spool.segments[0].sequence == cursorSequence {
+		spool.readOffset = cursorOffset
+	}
+
+	for i, segment := range spool.segments {
+		offset := int64(0)
+		if i == 0 {
+			offset = spool.readOffset
+		}
+		if err := spool.scanSegment(segment, offset, i == len(spool.segments)-1); err != nil {
+			return err
+		}
+	}
+
+	if len(spool.segments) == 0 {
+		spool.segments = append(spool.segments, &spoolSegment{sequence: 1})
+	}
+	if spool.writer, err = os.OpenFile(spool.segmentPath(spool.lastSegment().sequence), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
+		return fmt.Errorf("error during open spool segment: %w", err)
+	}
+	return spool.openReader()
+}
+
+// scanSegment counts the events of the segment after offset; the last segment is truncated after its last complete
+// line, which may have been cut by a crash.
+func (spool *Spool) scanSegment(segment *spoolSegment, offset int64, last bool) error {
+	data, err := os.ReadFile(spool.segmentPath(segment.sequence))
+	if err != nil {
+		return fmt.Errorf("error during read spool segment: %w", err)
+	}
+	if last {
+		if complete := int64(bytes.LastIndexByte(data, '\n') + 1); complete < int64(len(data)) {
+			if err := os.Truncate(spool.segmentPath(segment.sequence), complete); err != nil {
+				return fmt.Errorf("error during repair spool segment: %w", err)
+			}
+			data = data[:complete]
+		}
+	}
+	segment.size = int64(len(data))
+	if offset > segment.size {
+		offset = segment.size
+		spool.readOffset = offset
+	}
+	segment.events = bytes.Count(data[offset:], []byte{'\n'})
+	return nil
+}
+
+func (spool *Spool) openReader() error {
+	if spool.reader != nil {
+		spool.reader.Close()
+	}
+	reader, err := os.Open(spool.segmentPath(spool.segments[0].sequence))
+	if err != nil {
+		return fmt.Errorf("error during open spool segment: %w", err)
+	}
+	if _, err := reader.Seek(spool.readOffset, io.SeekStart); err != nil {
+		reader.Close()
+		return fmt.Errorf("error during open spool segment: %w", err)
+	}
+	spool.reader = reader
+	spool.lines = bufio.NewReader(reader)
+	return nil
+}
+
+// Append writes the event to the spool, it returns the number of events evicted to respect the maximum size.
+func (spool *Spool) Append(event tracer.Event) (int, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("error during marshal spooled event: %w", err)
+	}
+	data = append(data, '\n')
+
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	if spool.closed {
+		return 0, errSpoolClosed
+	}
+
+	last := spool.lastSegment()
+	if last.size > 0 && last.size+int64(len(data)) > spool.options.SegmentSize {
+		if err := spool.rotate(); err != nil {
+			return 0, err
+		}
+		last = spool.lastSegment()
+	}
+	if _, err := spool.writer.Write(data); err != nil {
+		return 0, fmt.Errorf("error during write spooled event: %w", err)
+	}
+	last.size += int64(len(data))
+	last.events++
+
+	evicted, err := spool.evict()
+
+	select {
+	case spool.notify <- struct{}{}:
+	default:
+	}
+	return evicted, err
+}
+
+func (spool *Spool) rotate() error {
+	sequence := spool.lastSegment().sequence + 1
+	writer, err := os.OpenFile(spool.segmentPath(sequence), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("error during create spool segment: %w", err)
+	}
+	spool.writer.Close()
+	spool.writer = writer
+	spool.segments = append(spool.segments, &spoolSegment{sequence: sequence})
+	return nil
+}
+
+// evict removes the oldest segments while the spool exceeds its maximum size, the segment being written is kept.
+func (spool *Spool) evict() (int, error) {
+	evicted := 0
+	for len(spool.segments) > 1 && spool.size() > spool.options.MaxSize {
+		evicted += spool.segments[0].events
+		if err := spool.removeFirstSegment(); err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, nil
+}
+
+func (spool *Spool) removeFirstSegment() error {
+	if err := os.Remove(spool.segmentPath(spool.segments[0].sequence)); err != nil {
+		return fmt.Errorf("error during remove spool segment: %w", err)
+	}
+	spool.segments = spool.segments[1:]
+	spool.readOffset = 0
+	spool.peeked = 0
+	spool.peekedEvents = 0
+	if err := spool.openReader(); err != nil {
+		return err
+	}
+	return spool.writeCursor()
+}
+
+// Next returns the oldest event not acknowledged, waiting for one until ctx expires. The same event is returned until
+// Ack is called, which also skips an event that cannot be unmarshalled. It returns errSpoolClosed once the spool is
+// closed and drained.
+func (spool *Spool) Next(ctx context.Context) (tracer.Event, error) {
+	events, err := spool.NextBatch(ctx, 1)
+	if err != nil {
+		return tracer.Event{}, err
+	}
+	return events[0], nil
+}
+
+// NextBatch is Next for up to size events, it does not wait for more events than the first one. The events stored
+// after an event that cannot be unmarshalled are returned by the next call, once Ack has skipped it.
+func (spool *Spool) NextBatch(ctx context.Context, size int) ([]tracer.Event, error) {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	for {
+		for spool.segments[0].events == 0 && len(spool.segments) > 1 {
+			if err := spool.removeFirstSegment(); err != nil {
+				return nil, err
+			}
+		}
+		if spool.segments[0].events > 0 {
+			break
+		}
+		if spool.closed {
+			return nil, errSpoolClosed
+		}
+
+		spool.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			spool.mu.Lock()
+			return nil, ctx.Err()
+		case <-spool.notify:
+		}
+		spool.mu.Lock()
+	}
+
+	spool.peeked = 0
+	spool.peekedEvents = 0
+	if _, err := spool.reader.Seek(spool.readOffset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error during read spooled event: %w", err)
+	}
+	spool.lines.Reset(spool.reader)
+
+	var events []tracer.Event
+	// The batch stops at the end of the first segment.
+	for len(events) < size && spool.peekedEvents < spool.segments[0].events {
+		line, err := spool.lines.ReadBytes('\n')
+		if err != nil {
+			if len(events) > 0 {
+				break
+			}
+			return nil, fmt.Errorf("error during read spooled event: %w", err)
+		}
+		var event tracer.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			if len(events) > 0 {
+				break
+			}
+			spool.peeked = int64(len(line))
+			spool.peekedEvents = 1
+			return nil, fmt.Errorf("error during unmarshal spooled event: %w", err)
+		}
+		spool.peeked += int64(len(line))
+		spool.peekedEvents++
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Ack removes the events returned by Next or NextBatch from the spool, it is called once the sink has delivered them,
+// or when they are given up.
+func (spool *Spool) Ack() error {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	if spool.peekedEvents == 0 {
+		return nil
+	}
+	spool.readOffset += spool.peeked
+	spool.segments[0].events -= spool.peekedEvents
+	spool.peeked = 0
+	spool.peekedEvents = 0
+
+	if spool.segments[0].events == 0 && len(spool.segments) > 1 {
+		return spool.removeFirstSegment()
+	}
+	return spool.writeCursor()
+}
+
+// Len returns the number of events not acknowledged.
+func (spool *Spool) Len() int {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	events := 0
+	for _, segment := range spool.segments {
+		events += segment.events
+	}
+	return events
+}
+
+// stop stops accepting events, Next keeps returning the spooled ones until drained.
+func (spool *Spool) stop() {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	spool.closed = true
+	select {
+	case spool.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Close releases the files of the spool, the events not acknowledged stay on disk for the next run.
+func (spool *Spool) Close() error {
+	spool.mu.Lock()
+	defer spool.mu.Unlock()
+
+	var err error
+	if spool.writer != nil {
+		err = spool.writer.Close()
+	}
+	if spool.reader != nil {
+		err = errors.Join(err, spool.reader.Close())
+	}
+	return err
+}
+
+func (spool *Spool) size() int64 {
+	var size int64
+	for _, segment := range spool.segments {
+		size += segment.size
+	}
+	return size
+}
+
+func (spool *Spool) lastSegment() *spoolSegment {
+	return spool.segments[len(spool.segments)-1]
+}
+
+func (spool *Spool) segmentPath(sequence uint64) string {
+	return filepath.Join(spool.dir, fmt.Sprintf("%020d%s", sequence, spoolSegmentExtension))
+}
+
+// writeCursor persists the position of the first event not acknowledged, atomically.
+func (spool *Spool) writeCursor() error {
+	cursorPath := filepath.Join(spool.dir, spoolCursorFileName)
+	data := fmt.Sprintf("%d %d\n", spool.segments[0].sequence, spool.readOffset)
+	if err := os.WriteFile(cursorPath+".tmp", []byte(data), 0600); err != nil {
+		return fmt.Errorf("error during write spool cursor: %w", err)
+	}
+	if err := os.Rename(cursorPath+".tmp", cursorPath); err != nil {
+		return fmt.Errorf("error during write spool cursor: %w", err)
+	}
+	return nil
+}
+
+func (spool *Spool) readCursor() (sequence uint64, offset int64) {
+	data, err := os.ReadFile(filepath.Join(spool.dir, spoolCursorFileName))
+	if err != nil {
+		return 0, 0
+	}
+	if _, err := fmt.Sscanf(string(data), "%d %d", &sequence, &offset); err != nil {
+		return 0, 0
+	}
+	return sequence, offset
+}
@@ -0,0 +1,193 @@
+package sinks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nextID(t *testing.T, spool *Spool) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	event, err := spool.Next(ctx)
+	require.NoError(t, err)
+	return event.ID
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExtension))
+	require.NoError(t, err)
+	return files
+}
+
+func TestSpool_AppendNextAck(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, SpoolOptions{SegmentSize: 64})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		evicted, err := spool.Append(tracer.Event{ID: id})
+		require.NoError(t, err)
+		assert.Zero(t, evicted)
+	}
+	assert.Equal(t, 3, spool.Len())
+	assert.Len(t, segmentFiles(t, dir), 3, "a segment holds one event of this size")
+
+	// The event is returned again until acknowledged.
+	assert.Equal(t, "1", nextID(t, spool))
+	assert.Equal(t, "1", nextID(t, spool))
+	require.NoError(t, spool.Ack())
+	assert.Equal(t, "2", nextID(t, spool))
+	require.NoError(t, spool.Ack())
+
+	assert.Equal(t, 1, spool.Len())
+	assert.Len(t, segmentFiles(t, dir), 1, "the acknowledged segments are removed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, "3", nextID(t, spool))
+	require.NoError(t, spool.Ack())
+	_, err = spool.Next(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	spool.stop()
+	_, err = spool.Next(context.Background())
+	assert.ErrorIs(t, err, errSpoolClosed)
+	_, err = spool.Append(tracer.Event{ID: "4"})
+	assert.ErrorIs(t, err, errSpoolClosed)
+	require.NoError(t, spool.Close())
+}
+
+func TestSpool_Resume(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, SpoolOptions{})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := spool.Append(tracer.Event{ID: id})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "1", nextID(t, spool))
+	require.NoError(t, spool.Ack())
+	require.NoError(t, spool.Close())
+
+	// A crash while writing leaves a partial line, which is discarded.
+	segments := segmentFiles(t, dir)
+	require.Len(t, segments, 1)
+	file, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"ID":"4"`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	resumed, err := OpenSpool(dir, SpoolOptions{})
+	require.NoError(t, err)
+	defer resumed.Close()
+	assert.Equal(t, 2, resumed.Len())
+
+	_, err = resumed.Append(tracer.Event{ID: "5"})
+	require.NoError(t, err)
+	for _, id := range []string{"2", "3", "5"} {
+		assert.Equal(t, id, nextID(t, resumed))
+		require.NoError(t, resumed.Ack())
+	}
+	assert.Zero(t, resumed.Len())
+}
+
+func TestSpool_NextBatch(t *testing.T) {
+	spool, err := OpenSpool(t.TempDir(), SpoolOptions{})
+	require.NoError(t, err)
+	defer spool.Close()
+
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := spool.Append(tracer.Event{ID: id})
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	events, err := spool.NextBatch(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "2", events[1].ID)
+	require.NoError(t, spool.Ack())
+	assert.Equal(t, 1, spool.Len())
+
+	events, err = spool.NextBatch(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "the batch holds the spooled events only")
+	assert.Equal(t, "3", events[0].ID)
+	require.NoError(t, spool.Ack())
+	assert.Zero(t, spool.Len())
+}
+
+func TestSpool_EvictsOldestSegments(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, SpoolOptions{SegmentSize: 64, MaxSize: 100})
+	require.NoError(t, err)
+	defer spool.Close()
+
+	evictedTotal := 0
+	for _, id := range []string{"1", "2", "3", "4"} {
+		evicted, err := spool.Append(tracer.Event{ID: id})
+		require.NoError(t, err)
+		evictedTotal += evicted
+	}
+
+	assert.Equal(t, 3, evictedTotal)
+	assert.Equal(t, 1, spool.Len())
+	assert.Equal(t, "4", nextID(t, spool))
+}
+
+func TestDispatcher_SpoolRetriesUntilDelivered(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, SpoolOptions{})
+	require.NoError(t, err)
+
+	// More failures than the default attempts of a sink without spool.
+	flaky := &mockSink{failures: 5}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "spooled", Sink: flaky, Retry: Retry{InitialBackoff: time.Millisecond}, Spool: spool},
+	})
+
+	dispatcher.Dispatch(tracer.Event{ID: "1"})
+	dispatcher.Dispatch(tracer.Event{ID: "2"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, []string{"1", "2"}, flaky.ids())
+	assert.Equal(t, 7, flaky.attempts)
+	assert.True(t, flaky.closed)
+}
+
+func TestDispatcher_SpoolKeepsUndeliveredEvents(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := OpenSpool(dir, SpoolOptions{})
+	require.NoError(t, err)
+
+	broken := &mockSink{failures: -1}
+	dispatcher := NewDispatcher([]Config{
+		{Name: "spooled", Sink: broken, Retry: Retry{InitialBackoff: time.Millisecond}, Spool: spool},
+	})
+	dispatcher.Dispatch(tracer.Event{ID: "1"})
+	dispatcher.Dispatch(tracer.Event{ID: "2"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, dispatcher.Close(ctx))
+
+	// The events are delivered on the next run.
+	resumed, err := OpenSpool(dir, SpoolOptions{})
+	require.NoError(t, err)
+	healthy := &mockSink{}
+	dispatcher = NewDispatcher([]Config{{Name: "spooled", Sink: healthy, Spool: resumed}})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, []string{"1", "2"}, healthy.ids())
+}
@@ -0,0 +1,35 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/eventstore"
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+// SQLite stores the events in a local SQLite database, queried by the events commands of the CLI.
+type SQLite struct {
+	store *eventstore.Store
+}
+
+// NewSQLite opens the database, creating it if needed, and starts its retention policy.
+func NewSQLite(path string, options eventstore.Options) (*SQLite, error) {
+	store, err := eventstore.Open(path, options)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{store: store}, nil
+}
+
+func (sqlite *SQLite) Send(ctx context.Context, event tracer.Event) error {
+	return sqlite.store.Insert(ctx, event)
+}
+
+// SendBatch stores the events in a single transaction.
+func (sqlite *SQLite) SendBatch(ctx context.Context, events []tracer.Event) error {
+	return sqlite.store.Insert(ctx, events...)
+}
+
+func (sqlite *SQLite) Close() error {
+	return sqlite.store.Close()
+}
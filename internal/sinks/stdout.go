@@ -0,0 +1,24 @@
+package sinks
+
+import (
+	"context"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stdout logs the events, through the configured logger.
+type Stdout struct{}
+
+func (Stdout) Send(ctx context.Context, event tracer.Event) error {
+	log.WithFields(log.Fields{
+		"status": event.Status,
+		"event":  event,
+	}).Info("New Event")
+	return nil
+}
+
+func (Stdout) Close() error {
+	return nil
+}
@@ -0,0 +1,174 @@
+package sinks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+const (
+	// DefaultSyslogFacility is local0, the facility of the messages when the configuration does not set one.
+	DefaultSyslogFacility = 16
+	// syslogSeverity is notice, a normal but significant condition.
+	syslogSeverity = 5
+	// syslogTimeout bounds the connection and the write of a message.
+	syslogTimeout = 10 * time.Second
+	// maxUDPMessageSize is the largest payload of a UDP datagram, the longer messages are truncated.
+	maxUDPMessageSize = 65507
+	defaultAppName    = "beelzebub"
+)
+
+// SyslogOptions are the settings of the syslog sink.
+type SyslogOptions struct {
+	// Network is udp, tcp or tls.
+	Network string
+	// Address is the host:port of the collector.
+	Address string
+	// Format is the body of the messages: json, cef or leef, json when empty.
+	Format string
+	// Facility is the syslog facility code, DefaultSyslogFacility when zero.
+	Facility int
+	// Hostname identifies the sensor, the hostname of the machine when empty.
+	Hostname string
+	// AppName is the APP-NAME of the messages, beelzebub when empty.
+	AppName string
+	// TLS is used by the tls network.
+	TLS *tls.Config
+}
+
+// Syslog sends the events as RFC 5424 messages; over TCP and TLS the messages are framed by octet counting, as
+// required by RFC 5425, and the connection is re-established after an error.
+type Syslog struct {
+	options SyslogOptions
+	procID  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslog validates the options, the connection to the collector is established by the first event.
+func NewSyslog(options SyslogOptions) (*Syslog, error) {
+	switch options.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q, expected one of udp, tcp, tls", options.Network)
+	}
+	if _, _, err := net.SplitHostPort(options.Address); err != nil {
+		return nil, fmt.Errorf("invalid syslog address: %w", err)
+	}
+	if _, err := formatMessage(options.Format, tracer.Event{}); err != nil {
+		return nil, err
+	}
+	if options.Facility < 0 || options.Facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d, expected a value between 0 and 23", options.Facility)
+	}
+	if options.Facility == 0 {
+		options.Facility = DefaultSyslogFacility
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+	if options.AppName == "" {
+		options.AppName = defaultAppName
+	}
+	return &Syslog{options: options, procID: strconv.Itoa(os.Getpid())}, nil
+}
+
+func (syslog *Syslog) Send(ctx context.Context, event tracer.Event) error {
+	body, err := formatMessage(syslog.options.Format, event)
+	if err != nil {
+		return err
+	}
+	message := syslog.message(event, body)
+
+	if syslog.options.Network == "udp" {
+		if len(message) > maxUDPMessageSize {
+			message = message[:maxUDPMessageSize]
+		}
+	} else {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+
+	syslog.mu.Lock()
+	defer syslog.mu.Unlock()
+
+	if syslog.conn == nil {
+		if err := syslog.dial(ctx); err != nil {
+			return err
+		}
+	}
+	syslog.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if _, err := syslog.conn.Write([]byte(message)); err != nil {
+		syslog.conn.Close()
+		syslog.conn = nil
+		return fmt.Errorf("error during send syslog message: %w", err)
+	}
+	return nil
+}
+
+func (syslog *Syslog) dial(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	var err error
+	if syslog.options.Network == "tls" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: syslog.options.TLS}
+		syslog.conn, err = tlsDialer.DialContext(ctx, "tcp", syslog.options.Address)
+	} else {
+		syslog.conn, err = dialer.DialContext(ctx, syslog.options.Network, syslog.options.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("error during connect to syslog collector: %w", err)
+	}
+	return nil
+}
+
+// message renders the RFC 5424 message: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG.
+// The MSGID is the protocol of the event.
+func (syslog *Syslog) message(event tracer.Event, body string) string {
+	timestamp := event.DateTime
+	if timestamp == "" {
+		timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		syslog.options.Facility*8+syslogSeverity,
+		timestamp,
+		headerField(syslog.options.Hostname, 255),
+		headerField(syslog.options.AppName, 48),
+		headerField(syslog.procID, 128),
+		headerField(event.Protocol, 32),
+		body,
+	)
+}
+
+func (syslog *Syslog) Close() error {
+	syslog.mu.Lock()
+	defer syslog.mu.Unlock()
+
+	if syslog.conn == nil {
+		return nil
+	}
+	err := syslog.conn.Close()
+	syslog.conn = nil
+	return err
+}
+
+// headerField returns the value as a header field of RFC 5424: printable ASCII without spaces, at most maxLength
+// characters, "-" when empty.
+func headerField(value string, maxLength int) string {
+	field := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(field) < maxLength; i++ {
+		if value[i] > ' ' && value[i] < 127 {
+			field = append(field, value[i])
+		}
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
@@ -0,0 +1,216 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+)
+
+// The formats of the syslog message body.
+const (
+	JSONFormat = "json"
+	CEFFormat  = "cef"
+	LEEFFormat = "leef"
+)
+
+const (
+	vendor  = "Beelzebub"
+	product = "Beelzebub"
+	// productVersion is the major version of beelzebub, reported by the CEF and LEEF headers.
+	productVersion = "3"
+	// cefSeverity is the severity of every event, an interaction with a honeypot being suspicious by definition.
+	cefSeverity = "5"
+)
+
+// eventField is a field of the event in a CEF or LEEF extension, the empty values are omitted.
+type eventField struct {
+	key   string
+	value string
+}
+
+// formatMessage renders the body of the syslog message in the given format.
+func formatMessage(format string, event tracer.Event) (string, error) {
+	switch format {
+	case "", JSONFormat:
+		data, err := json.Marshal(event)
+		if err != nil {
+			return "", fmt.Errorf("error during marshal event: %w", err)
+		}
+		return string(data), nil
+	case CEFFormat:
+		return formatCEF(event), nil
+	case LEEFFormat:
+		return formatLEEF(event), nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected one of %s, %s, %s", format, JSONFormat, CEFFormat, LEEFFormat)
+	}
+}
+
+// formatCEF renders the event as ArcSight CEF:
+//
+//	CEF:0|Beelzebub|Beelzebub|3|<Protocol>:<Status>|<Msg>|5|<extension>
+//
+// The extension maps DateTime→rt, SourceIp→src, SourcePort→spt, User→suser, DestinationHost→dhost,
+// DestinationPort→dpt, Protocol→app, ID→externalId, HTTPMethod→requestMethod, RequestURI→request,
+// UserAgent→requestClientApplication, FileName→fname, FileSize→fsize, FileSHA256→fileHash, Msg→msg,
+// Command→cs1, CommandOutput→cs2, Password→cs3, Description→cs4, HostHTTPRequest→cs5 and URL→cs6.
+func formatCEF(event tracer.Event) string {
+	var rt string
+	if dateTime, err := time.Parse(time.RFC3339, event.DateTime); err == nil {
+		rt = strconv.FormatInt(dateTime.UnixMilli(), 10)
+	}
+	fields := []eventField{
+		{"rt", rt},
+		{"src", event.SourceIp},
+		{"spt", event.SourcePort},
+		{"suser", event.User},
+		{"dhost", event.DestinationHost},
+		{"dpt", event.DestinationPort},
+		{"app", event.Protocol},
+		{"externalId", event.ID},
+		{"requestMethod", event.HTTPMethod},
+		{"request", event.RequestURI},
+		{"requestClientApplication", event.UserAgent},
+		{"fname", event.FileName},
+		{"fsize", fileSize(event)},
+		{"fileHash", event.FileSHA256},
+		{"msg", event.Msg},
+	}
+	fields = appendCustomString(fields, 1, "Command", event.Command)
+	fields = appendCustomString(fields, 2, "CommandOutput", event.CommandOutput)
+	fields = appendCustomString(fields, 3, "Password", event.Password)
+	fields = appendCustomString(fields, 4, "Service", event.Description)
+	fields = appendCustomString(fields, 5, "Host", event.HostHTTPRequest)
+	fields = appendCustomString(fields, 6, "URL", event.URL)
+
+	var extension []string
+	for _, field := range fields {
+		if field.value != "" {
+			extension = append(extension, field.key+"="+escapeCEFExtension(field.value))
+		}
+	}
+
+	return strings.Join([]string{
+		"CEF:0",
+		escapeCEFHeader(vendor),
+		escapeCEFHeader(product),
+		productVersion,
+		escapeCEFHeader(eventSignature(event)),
+		escapeCEFHeader(eventName(event)),
+		cefSeverity,
+		strings.Join(extension, " "),
+	}, "|")
+}
+
+// formatLEEF renders the event as QRadar LEEF 1.0, with tab separated attributes:
+//
+//	LEEF:1.0|Beelzebub|Beelzebub|3|<Protocol>:<Status>|<attributes>
+//
+// The attributes map DateTime→devTime, Status→cat, SourceIp→src, SourcePort→srcPort, User→usrName,
+// DestinationHost→dst, DestinationPort→dstPort, URL→url; the other fields keep their name in lower camel case,
+// e.g. Command→command, CommandOutput→commandOutput, Password→password, ID→sessionId, Description→service.
+func formatLEEF(event tracer.Event) string {
+	fields := []eventField{
+		{"devTime", event.DateTime},
+		{"devTimeFormat", leefTimeFormat(event)},
+		{"cat", event.Status},
+		{"src", event.SourceIp},
+		{"srcPort", event.SourcePort},
+		{"usrName", event.User},
+		{"dst", event.DestinationHost},
+		{"dstPort", event.DestinationPort},
+		{"url", event.URL},
+		{"protocol", event.Protocol},
+		{"sessionId", event.ID},
+		{"service", event.Description},
+		{"command", event.Command},
+		{"commandOutput", event.CommandOutput},
+		{"password", event.Password},
+		{"httpMethod", event.HTTPMethod},
+		{"requestUri", event.RequestURI},
+		{"userAgent", event.UserAgent},
+		{"host", event.HostHTTPRequest},
+		{"fileName", event.FileName},
+		{"fileSize", fileSize(event)},
+		{"fileSha256", event.FileSHA256},
+		{"msg", event.Msg},
+	}
+
+	var attributes []string
+	for _, field := range fields {
+		if field.value != "" {
+			attributes = append(attributes, field.key+"="+escapeLEEFAttribute(field.value))
+		}
+	}
+
+	return strings.Join([]string{
+		"LEEF:1.0",
+		escapeLEEFHeader(vendor),
+		escapeLEEFHeader(product),
+		productVersion,
+		escapeLEEFHeader(eventSignature(event)),
+		// The attributes are tab separated, as required by LEEF 1.0.
+		strings.Join(attributes, "\t"),
+	}, "|")
+}
+
+func appendCustomString(fields []eventField, index int, label, value string) []eventField {
+	if value == "" {
+		return fields
+	}
+	key := "cs" + strconv.Itoa(index)
+	return append(fields, eventField{key, value}, eventField{key + "Label", label})
+}
+
+func eventSignature(event tracer.Event) string {
+	return event.Protocol + ":" + event.Status
+}
+
+func eventName(event tracer.Event) string {
+	if event.Msg != "" {
+		return event.Msg
+	}
+	return event.Protocol + " " + event.Status
+}
+
+func fileSize(event tracer.Event) string {
+	if event.FileSize == 0 {
+		return ""
+	}
+	return strconv.FormatInt(event.FileSize, 10)
+}
+
+// leefTimeFormat is the Java date format of devTime, the RFC 3339 DateTime of the event.
+func leefTimeFormat(event tracer.Event) string {
+	if event.DateTime == "" {
+		return ""
+	}
+	return "yyyy-MM-dd'T'HH:mm:ssX"
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper   = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	leefValueEscaper    = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+func escapeCEFHeader(s string) string {
+	return cefHeaderEscaper.Replace(s)
+}
+
+func escapeCEFExtension(s string) string {
+	return cefExtensionEscaper.Replace(s)
+}
+
+func escapeLEEFHeader(s string) string {
+	return leefHeaderEscaper.Replace(s)
+}
+
+func escapeLEEFAttribute(s string) string {
+	return leefValueEscaper.Replace(s)
+}
@@ -0,0 +1,141 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogEvent = tracer.Event{
+	DateTime:      "2024-05-01T10:00:00Z",
+	Protocol:      "SSH",
+	Status:        "Interaction",
+	ID:            "session-1",
+	SourceIp:      "10.0.0.1",
+	SourcePort:    "4242",
+	User:          "root",
+	Command:       "echo a=b | cat",
+	CommandOutput: "a=b\nline\t2",
+	Description:   "SSH interactive",
+}
+
+func TestFormatCEF(t *testing.T) {
+	assert.Equal(t,
+		`CEF:0|Beelzebub|Beelzebub|3|SSH:Interaction|SSH Interaction|5|rt=1714557600000 src=10.0.0.1 spt=4242 suser=root app=SSH externalId=session-1 `+
+			`cs1=echo a\=b | cat cs1Label=Command cs2=a\=b\nline	2 cs2Label=CommandOutput cs4=SSH interactive cs4Label=Service`,
+		formatCEF(syslogEvent))
+
+	assert.Equal(t, `CEF:0|Beelzebub|Beelzebub|3|HTTP:Stateless|a\|b|5|app=HTTP msg=a|b`, formatCEF(tracer.Event{Protocol: "HTTP", Status: "Stateless", Msg: "a|b"}))
+}
+
+func TestFormatLEEF(t *testing.T) {
+	assert.Equal(t,
+		"LEEF:1.0|Beelzebub|Beelzebub|3|SSH:Interaction|devTime=2024-05-01T10:00:00Z\tdevTimeFormat=yyyy-MM-dd'T'HH:mm:ssX\tcat=Interaction\t"+
+			"src=10.0.0.1\tsrcPort=4242\tusrName=root\tprotocol=SSH\tsessionId=session-1\tservice=SSH interactive\t"+
+			`command=echo a=b | cat`+"\t"+`commandOutput=a=b\nline\t2`,
+		formatLEEF(syslogEvent))
+}
+
+func TestNewSyslog_InvalidOptions(t *testing.T) {
+	_, err := NewSyslog(SyslogOptions{Network: "sctp", Address: "localhost:514"})
+	assert.Error(t, err)
+	_, err = NewSyslog(SyslogOptions{Network: "udp", Address: "localhost"})
+	assert.Error(t, err)
+	_, err = NewSyslog(SyslogOptions{Network: "udp", Address: "localhost:514", Format: "xml"})
+	assert.Error(t, err)
+	_, err = NewSyslog(SyslogOptions{Network: "udp", Address: "localhost:514", Facility: 24})
+	assert.Error(t, err)
+}
+
+func TestSyslog_UDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	syslog, err := NewSyslog(SyslogOptions{Network: "udp", Address: listener.LocalAddr().String(), Format: CEFFormat, Hostname: "sensor 1"})
+	require.NoError(t, err)
+	defer syslog.Close()
+
+	require.NoError(t, syslog.Send(context.Background(), syslogEvent))
+
+	buffer := make([]byte, maxUDPMessageSize)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	require.NoError(t, err)
+
+	message := string(buffer[:n])
+	prefix := "<133>1 2024-05-01T10:00:00Z sensor1 beelzebub " + syslog.procID + " SSH - "
+	assert.True(t, strings.HasPrefix(message, prefix), message)
+	assert.Equal(t, formatCEF(syslogEvent), strings.TrimPrefix(message, prefix))
+}
+
+func TestSyslog_TCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	messages := make(chan string, 3)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			reader := bufio.NewReader(conn)
+			// Octet counting framing: MSG-LEN SP SYSLOG-MSG.
+			for {
+				length, err := reader.ReadString(' ')
+				if err != nil {
+					break
+				}
+				n, _ := strconv.Atoi(strings.TrimSpace(length))
+				message := make([]byte, n)
+				if _, err := io.ReadFull(reader, message); err != nil {
+					break
+				}
+				messages <- string(message)
+				// The collector drops the connection after every message.
+				conn.Close()
+			}
+		}
+	}()
+
+	syslog, err := NewSyslog(SyslogOptions{Network: "tcp", Address: listener.Addr().String(), Facility: 1})
+	require.NoError(t, err)
+	defer syslog.Close()
+
+	require.NoError(t, syslog.Send(context.Background(), tracer.Event{ID: "1", Protocol: "TCP"}))
+	message := <-messages
+	assert.True(t, strings.HasPrefix(message, "<13>1 "), message)
+	assert.Contains(t, message, `"ID":"1"`)
+
+	// The write on the connection closed by the collector eventually fails, then the sink reconnects.
+	assert.Eventually(t, func() bool {
+		err := syslog.Send(context.Background(), tracer.Event{ID: "2", Protocol: "TCP"})
+		if err != nil {
+			return false
+		}
+		select {
+		case message := <-messages:
+			return strings.Contains(message, `"ID":"2"`)
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestHeaderField(t *testing.T) {
+	assert.Equal(t, "-", headerField("", 10))
+	assert.Equal(t, "sensor1", headerField("sensor 1", 10))
+	assert.Equal(t, "abc", headerField("abcdef", 3))
+}
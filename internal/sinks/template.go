@@ -0,0 +1,54 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are available to the templates of the sinks, e.g. "beelzebub.{{lower .Protocol}}.{{lower .Status}}";
+// json encodes a value, e.g. {"text": {{json .Command}}}, and date formats the DateTime of an event with a Go layout,
+// e.g. "beelzebub-{{date "2006.01.02" .DateTime}}".
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"json":  toJSON,
+	"date":  formatDate,
+}
+
+func toJSON(value any) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// formatDate formats an RFC 3339 date in UTC, the current time when the date is not valid.
+func formatDate(layout, value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		date = time.Now()
+	}
+	return date.UTC().Format(layout)
+}
+
+// eventTemplate renders a setting of a sink from the fields of the event, e.g. a routing key, or from a batch of events.
+type eventTemplate struct {
+	template *template.Template
+}
+
+func newEventTemplate(name, text string) (*eventTemplate, error) {
+	parsed, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error during parse %s template: %w", name, err)
+	}
+	return &eventTemplate{template: parsed}, nil
+}
+
+func (eventTemplate *eventTemplate) render(data any) (string, error) {
+	var builder strings.Builder
+	if err := eventTemplate.template.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("error during render %s template: %w", eventTemplate.template.Name(), err)
+	}
+	return builder.String(), nil
+}
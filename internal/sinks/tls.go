@@ -0,0 +1,50 @@
+package sinks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSOptions are the TLS settings of a connection to a sink, zero values mean the system defaults.
+type TLSOptions struct {
+	// CACertPath is a PEM bundle of the authorities trusted to sign the certificate of the server.
+	CACertPath string
+	// CertPath and KeyPath are the PEM client certificate and key, for the mutual authentication.
+	CertPath string
+	KeyPath  string
+	// ServerName overrides the name verified against the certificate of the server.
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// TLSConfig loads the certificates of the options.
+func TLSConfig(options TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         options.ServerName,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if options.CACertPath != "" {
+		caCert, err := os.ReadFile(options.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("error during read CA certificate: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no certificate found in the CA certificate file")
+		}
+	}
+
+	if options.CertPath != "" || options.KeyPath != "" {
+		certificate, err := tls.LoadX509KeyPair(options.CertPath, options.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("error during load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
@@ -0,0 +1,156 @@
+package sinks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// WebhookSignatureHeader carries the HMAC-SHA256 of the body, as sha256=<hex>, when a secret is configured.
+	WebhookSignatureHeader = "X-Beelzebub-Signature-256"
+	// DefaultWebhookTimeout bounds a request when the configuration does not set one.
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookOptions are the settings of the webhook sink.
+type WebhookOptions struct {
+	URL string
+	// Method is the HTTP method of the requests, POST when empty.
+	Method  string
+	Headers map[string]string
+	// Body is a Go template rendered with the event, e.g. {"text": {{json .Command}}}, the JSON event when empty.
+	Body string
+	// BatchBody is a Go template rendered with the events of a batch, the bodies of the events one per line when empty.
+	BatchBody string
+	// Secret signs the body with HMAC-SHA256 in the WebhookSignatureHeader header, no signature when empty.
+	Secret  string
+	Timeout time.Duration
+	TLS     *tls.Config
+}
+
+// Webhook sends the events to an HTTP endpoint, e.g. Slack, Teams or Mattermost incoming webhooks; any status other
+// than 2xx is an error.
+type Webhook struct {
+	options   WebhookOptions
+	client    *resty.Client
+	body      *eventTemplate
+	batchBody *eventTemplate
+}
+
+// NewWebhook validates the options and parses the templates.
+func NewWebhook(options WebhookOptions) (*Webhook, error) {
+	parsedURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook url %q, expected an http or https url", options.URL)
+	}
+	if options.Method == "" {
+		options.Method = http.MethodPost
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultWebhookTimeout
+	}
+
+	webhook := &Webhook{options: options}
+	if options.Body != "" {
+		if webhook.body, err = newEventTemplate("body", options.Body); err != nil {
+			return nil, err
+		}
+	}
+	if options.BatchBody != "" {
+		if webhook.batchBody, err = newEventTemplate("batchBody", options.BatchBody); err != nil {
+			return nil, err
+		}
+	}
+
+	webhook.client = resty.New().
+		SetTimeout(options.Timeout).
+		SetHeader("Content-Type", "application/json").
+		SetHeaders(options.Headers)
+	if options.TLS != nil {
+		webhook.client.SetTLSClientConfig(options.TLS)
+	}
+	return webhook, nil
+}
+
+func (webhook *Webhook) Send(ctx context.Context, event tracer.Event) error {
+	body, err := webhook.render(event)
+	if err != nil {
+		return err
+	}
+	return webhook.post(ctx, body)
+}
+
+// SendBatch sends the events in a single request.
+func (webhook *Webhook) SendBatch(ctx context.Context, events []tracer.Event) error {
+	if webhook.batchBody != nil {
+		body, err := webhook.batchBody.render(events)
+		if err != nil {
+			return err
+		}
+		return webhook.post(ctx, body)
+	}
+
+	bodies := make([]string, 0, len(events))
+	for _, event := range events {
+		body, err := webhook.render(event)
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, body)
+	}
+	return webhook.post(ctx, strings.Join(bodies, "\n"))
+}
+
+func (webhook *Webhook) render(event tracer.Event) (string, error) {
+	if webhook.body != nil {
+		return webhook.body.render(event)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("error during marshal event: %w", err)
+	}
+	return string(data), nil
+}
+
+func (webhook *Webhook) post(ctx context.Context, body string) error {
+	request := webhook.client.R().SetContext(ctx).SetBody(body)
+	if webhook.options.Secret != "" {
+		request.SetHeader(WebhookSignatureHeader, "sha256="+sign(webhook.options.Secret, body))
+	}
+
+	response, err := request.Execute(webhook.options.Method, webhook.options.URL)
+	if err != nil {
+		return fmt.Errorf("error during send webhook request: %w", err)
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode())
+	}
+	return nil
+}
+
+func (webhook *Webhook) Close() error {
+	return nil
+}
+
+// sign returns the hex HMAC-SHA256 of the body.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beelzebub-labs/beelzebub/v3/internal/tracer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   string
+}
+
+func webhookServer(t *testing.T, status int) (*httptest.Server, chan webhookRequest) {
+	requests := make(chan webhookRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests <- webhookRequest{header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestNewWebhook_InvalidOptions(t *testing.T) {
+	_, err := NewWebhook(WebhookOptions{URL: "ftp://localhost/"})
+	assert.Error(t, err)
+	_, err = NewWebhook(WebhookOptions{URL: "http://localhost/", Body: "{{.Protocol"})
+	assert.Error(t, err)
+}
+
+func TestWebhook_Send(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusOK)
+	webhook, err := NewWebhook(WebhookOptions{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Body:    `{"text": {{json (printf "%s from %s: %s" .Protocol .SourceIp .Command)}}}`,
+		Secret:  "secret",
+	})
+	require.NoError(t, err)
+	defer webhook.Close()
+
+	require.NoError(t, webhook.Send(context.Background(), tracer.Event{Protocol: "SSH", SourceIp: "10.0.0.1", Command: `echo "hi"`}))
+
+	request := <-requests
+	assert.Equal(t, `{"text": "SSH from 10.0.0.1: echo \"hi\""}`, request.body)
+	assert.Equal(t, "Bearer token", request.header.Get("Authorization"))
+	assert.Equal(t, "application/json", request.header.Get("Content-Type"))
+	assert.Equal(t, "sha256=72c06a8fbdd41564df075fd91c93c785598457286f8cacf000ab55bc45c00ea6", request.header.Get(WebhookSignatureHeader))
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	server, _ := webhookServer(t, http.StatusTooManyRequests)
+	webhook, err := NewWebhook(WebhookOptions{URL: server.URL})
+	require.NoError(t, err)
+
+	err = webhook.Send(context.Background(), tracer.Event{ID: "1"})
+	assert.ErrorContains(t, err, "429")
+}
+
+func TestWebhook_Batch(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusNoContent)
+	webhook, err := NewWebhook(WebhookOptions{URL: server.URL})
+	require.NoError(t, err)
+
+	dispatcher := NewDispatcher([]Config{{Name: "webhook", Sink: webhook, Batch: Batch{Size: 2, Interval: time.Hour}}})
+	dispatcher.Dispatch(tracer.Event{ID: "1"})
+	dispatcher.Dispatch(tracer.Event{ID: "2"})
+
+	request := <-requests
+	lines := strings.Split(request.body, "\n")
+	require.Len(t, lines, 2, "the events of a batch are sent one per line")
+	assert.Contains(t, lines[0], `"ID":"1"`)
+	assert.Contains(t, lines[1], `"ID":"2"`)
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	batchWebhook, err := NewWebhook(WebhookOptions{URL: server.URL, BatchBody: `{{len .}} events, last {{(index . 1).ID}}`})
+	require.NoError(t, err)
+	require.NoError(t, batchWebhook.SendBatch(context.Background(), []tracer.Event{{ID: "1"}, {ID: "2"}}))
+	assert.Equal(t, "2 events, last 2", (<-requests).body)
+}
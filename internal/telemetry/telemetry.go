@@ -0,0 +1,181 @@
+// Package telemetry is responsible for exporting the events, the sessions and the metrics with OpenTelemetry
+package telemetry
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	GRPC = "grpc"
+	HTTP = "http"
+	// DefaultServiceName is the service.name of the resource when the configuration does not set one.
+	DefaultServiceName = "beelzebub"
+	// DefaultMetricsInterval is the export interval of the metrics when the configuration does not set one.
+	DefaultMetricsInterval = time.Minute
+)
+
+// Options are the settings of the OTLP exporters.
+type Options struct {
+	// Protocol is grpc or http, grpc when empty.
+	Protocol string
+	// Endpoint is the host:port of the collector, the default of the protocol when empty: localhost:4317 for grpc,
+	// localhost:4318 for http.
+	Endpoint string
+	// Insecure disables TLS.
+	Insecure    bool
+	Headers     map[string]string
+	TLS         *tls.Config
+	ServiceName string
+	// MetricsInterval is the export interval of the metrics, DefaultMetricsInterval when zero.
+	MetricsInterval time.Duration
+}
+
+// Providers export the log records, the spans and the metrics to an OTLP collector.
+type Providers struct {
+	LoggerProvider *sdklog.LoggerProvider
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+}
+
+// Setup creates the providers, the MeterProvider becomes the global one so that the instruments of the packages, e.g.
+// the events counters of the tracer, are exported.
+func Setup(ctx context.Context, options Options) (*Providers, error) {
+	if options.ServiceName == "" {
+		options.ServiceName = DefaultServiceName
+	}
+	if options.MetricsInterval <= 0 {
+		options.MetricsInterval = DefaultMetricsInterval
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(options.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error during init opentelemetry resource: %w", err)
+	}
+
+	var logExporter sdklog.Exporter
+	var traceExporter sdktrace.SpanExporter
+	var metricExporter sdkmetric.Exporter
+	switch options.Protocol {
+	case GRPC, "":
+		logExporter, traceExporter, metricExporter, err = grpcExporters(ctx, options)
+	case HTTP:
+		logExporter, traceExporter, metricExporter, err = httpExporters(ctx, options)
+	default:
+		return nil, fmt.Errorf("unknown opentelemetry protocol %q, expected one of grpc, http", options.Protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error during init opentelemetry exporters: %w", err)
+	}
+
+	providers := &Providers{
+		LoggerProvider: sdklog.NewLoggerProvider(
+			sdklog.WithResource(res),
+			sdklog.WithProcessor(sdklog.NewBatchProcessor(logExporter)),
+		),
+		TracerProvider: sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithBatcher(traceExporter),
+		),
+		MeterProvider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(options.MetricsInterval))),
+		),
+	}
+	otel.SetMeterProvider(providers.MeterProvider)
+	return providers, nil
+}
+
+func grpcExporters(ctx context.Context, options Options) (sdklog.Exporter, sdktrace.SpanExporter, sdkmetric.Exporter, error) {
+	logOptions := []otlploggrpc.Option{otlploggrpc.WithHeaders(options.Headers)}
+	traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithHeaders(options.Headers)}
+	metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithHeaders(options.Headers)}
+	if options.Endpoint != "" {
+		logOptions = append(logOptions, otlploggrpc.WithEndpoint(options.Endpoint))
+		traceOptions = append(traceOptions, otlptracegrpc.WithEndpoint(options.Endpoint))
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithEndpoint(options.Endpoint))
+	}
+	if options.Insecure {
+		logOptions = append(logOptions, otlploggrpc.WithInsecure())
+		traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+	} else if options.TLS != nil {
+		logOptions = append(logOptions, otlploggrpc.WithTLSCredentials(credentials.NewTLS(options.TLS)))
+		traceOptions = append(traceOptions, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(options.TLS)))
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(options.TLS)))
+	}
+
+	logExporter, err := otlploggrpc.New(ctx, logOptions...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	traceExporter, err := otlptracegrpc.New(ctx, traceOptions...)
+	if err != nil {
+		return nil, nil, nil, errors.Join(err, logExporter.Shutdown(ctx))
+	}
+	metricExporter, err := otlpmetricgrpc.New(ctx, metricOptions...)
+	if err != nil {
+		return nil, nil, nil, errors.Join(err, logExporter.Shutdown(ctx), traceExporter.Shutdown(ctx))
+	}
+	return logExporter, traceExporter, metricExporter, nil
+}
+
+func httpExporters(ctx context.Context, options Options) (sdklog.Exporter, sdktrace.SpanExporter, sdkmetric.Exporter, error) {
+	logOptions := []otlploghttp.Option{otlploghttp.WithHeaders(options.Headers)}
+	traceOptions := []otlptracehttp.Option{otlptracehttp.WithHeaders(options.Headers)}
+	metricOptions := []otlpmetrichttp.Option{otlpmetrichttp.WithHeaders(options.Headers)}
+	if options.Endpoint != "" {
+		logOptions = append(logOptions, otlploghttp.WithEndpoint(options.Endpoint))
+		traceOptions = append(traceOptions, otlptracehttp.WithEndpoint(options.Endpoint))
+		metricOptions = append(metricOptions, otlpmetrichttp.WithEndpoint(options.Endpoint))
+	}
+	if options.Insecure {
+		logOptions = append(logOptions, otlploghttp.WithInsecure())
+		traceOptions = append(traceOptions, otlptracehttp.WithInsecure())
+		metricOptions = append(metricOptions, otlpmetrichttp.WithInsecure())
+	} else if options.TLS != nil {
+		logOptions = append(logOptions, otlploghttp.WithTLSClientConfig(options.TLS))
+		traceOptions = append(traceOptions, otlptracehttp.WithTLSClientConfig(options.TLS))
+		metricOptions = append(metricOptions, otlpmetrichttp.WithTLSClientConfig(options.TLS))
+	}
+
+	logExporter, err := otlploghttp.New(ctx, logOptions...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	traceExporter, err := otlptracehttp.New(ctx, traceOptions...)
+	if err != nil {
+		return nil, nil, nil, errors.Join(err, logExporter.Shutdown(ctx))
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx, metricOptions...)
+	if err != nil {
+		return nil, nil, nil, errors.Join(err, logExporter.Shutdown(ctx), traceExporter.Shutdown(ctx))
+	}
+	return logExporter, traceExporter, metricExporter, nil
+}
+
+// Shutdown exports the pending log records, spans and metrics, then stops the exporters.
+func (providers *Providers) Shutdown(ctx context.Context) error {
+	return errors.Join(
+		providers.LoggerProvider.Shutdown(ctx),
+		providers.TracerProvider.Shutdown(ctx),
+		providers.MeterProvider.Shutdown(ctx),
+	)
+}
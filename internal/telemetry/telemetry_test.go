@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+func TestSetup_UnknownProtocol(t *testing.T) {
+	_, err := Setup(context.Background(), Options{Protocol: "udp"})
+	assert.ErrorContains(t, err, `unknown opentelemetry protocol "udp"`)
+}
+
+func TestSetup_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	providers, err := Setup(context.Background(), Options{
+		Protocol: HTTP,
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Insecure: true,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+
+	var record otellog.Record
+	record.SetBody(otellog.StringValue("event"))
+	providers.LoggerProvider.Logger("test").Emit(context.Background(), record)
+	_, span := providers.TracerProvider.Tracer("test").Start(context.Background(), "session")
+	span.End()
+	counter, err := providers.MeterProvider.Meter("test").Int64Counter("events")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 1)
+
+	require.NoError(t, providers.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]string{
+		"/v1/logs":    "Bearer token",
+		"/v1/traces":  "Bearer token",
+		"/v1/metrics": "Bearer token",
+	}, paths)
+}
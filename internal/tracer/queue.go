@@ -0,0 +1,322 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultQueueSize is the capacity of the events queue when the configuration does not set one.
+const DefaultQueueSize = 10000
+
+// DefaultMaxSpillBytes is the maximum size of the spill file when the configuration does not set one.
+const DefaultMaxSpillBytes = 1 << 30
+
+// spillFileName is the name of the file holding the events spilled to disk, inside the spill directory.
+const spillFileName = "events.spill.jsonl"
+
+var errQueueClosed = errors.New("events queue closed")
+
+var errSpillFull = errors.New("spill file full")
+
+// OverflowPolicy is the behaviour of the tracer when the events queue is full, DropNewest by default: tracing never
+// blocks the honeypots unless Block is configured.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the event being traced.
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest queued event, to make room for the event being traced.
+	DropOldest
+	// Block waits for room in the queue, slowing down the honeypot to the pace of the strategy.
+	Block
+	// SpillToDisk appends the events to a file until the strategy catches up, they are delivered in order.
+	SpillToDisk
+)
+
+func (policy OverflowPolicy) String() string {
+	return [...]string{"drop-newest", "drop-oldest", "block", "spill-to-disk"}[policy]
+}
+
+// ParseOverflowPolicy converts the name of an overflow policy to its OverflowPolicy constant, empty means DropNewest.
+func ParseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "", "drop-newest":
+		return DropNewest, nil
+	case "drop-oldest":
+		return DropOldest, nil
+	case "block":
+		return Block, nil
+	case "spill-to-disk":
+		return SpillToDisk, nil
+	default:
+		return 0, fmt.Errorf("unknown overflow policy %q, expected one of block, drop-newest, drop-oldest, spill-to-disk", s)
+	}
+}
+
+// QueueOptions are the settings of the events queue between the honeypots and the strategy.
+type QueueOptions struct {
+	// Size is the capacity of the queue, zero means DefaultQueueSize.
+	Size int
+	// Workers is the number of goroutines running the strategy, zero means Workers.
+	Workers        int
+	OverflowPolicy OverflowPolicy
+	// SpillDir is the directory of the spilled events, required by SpillToDisk.
+	SpillDir string
+	// MaxSpillBytes is the maximum size of the spill file, zero means DefaultMaxSpillBytes. Once reached, the events
+	// are dropped until the file is drained.
+	MaxSpillBytes int64
+}
+
+// queue is a bounded FIFO of events, applying the overflow policy when full.
+type queue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	buffer   []Event
+	head     int
+	count    int
+	policy   OverflowPolicy
+	spill    *spillFile
+	closed   bool
+}
+
+func newQueue(options QueueOptions) (*queue, error) {
+	size := options.Size
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+
+	q := &queue{
+		buffer: make([]Event, size),
+		policy: options.OverflowPolicy,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+
+	if options.OverflowPolicy == SpillToDisk {
+		if options.SpillDir == "" {
+			return nil, errors.New("the spill-to-disk overflow policy requires a spill directory")
+		}
+		maxSpillBytes := options.MaxSpillBytes
+		if maxSpillBytes <= 0 {
+			maxSpillBytes = DefaultMaxSpillBytes
+		}
+		spill, err := openSpillFile(options.SpillDir, maxSpillBytes)
+		if err != nil {
+			return nil, err
+		}
+		q.spill = spill
+	}
+	return q, nil
+}
+
+// pushResult is the outcome of a push: the event is queued, possibly on disk, or dropped by the overflow policy.
+type pushResult struct {
+	queued  bool
+	spilled bool
+	// dropped is the number of events lost to make room, or the event being traced when it is not queued.
+	dropped int
+}
+
+// push enqueues the event, applying the overflow policy when the queue is full.
+func (q *queue) push(event Event) (pushResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.policy == Block {
+		for q.count == len(q.buffer) && !q.closed {
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return pushResult{}, errQueueClosed
+	}
+
+	// Once an event is spilled, the following ones are spilled too until the file is drained, to keep the order.
+	if q.spill != nil && (q.count == len(q.buffer) || q.spill.pending > 0) {
+		err := q.spill.write(event)
+		if errors.Is(err, errSpillFull) {
+			return pushResult{dropped: 1}, nil
+		}
+		if err != nil {
+			// The event is lost, it is counted as failed rather than dropped.
+			return pushResult{}, err
+		}
+		q.notEmpty.Signal()
+		return pushResult{queued: true, spilled: true}, nil
+	}
+
+	var result pushResult
+	if q.count == len(q.buffer) {
+		if q.policy != DropOldest {
+			return pushResult{dropped: 1}, nil
+		}
+		q.buffer[q.head] = Event{}
+		q.head = (q.head + 1) % len(q.buffer)
+		q.count--
+		result.dropped = 1
+	}
+
+	q.buffer[(q.head+q.count)%len(q.buffer)] = event
+	q.count++
+	q.notEmpty.Signal()
+	result.queued = true
+	return result, nil
+}
+
+// pop dequeues the oldest event, waiting for one; ok is false once the queue is closed and drained. An error means
+// that a spilled event could not be read back, and is lost.
+func (q *queue) pop() (event Event, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.count == 0 && (q.spill == nil || q.spill.pending == 0) {
+		if q.closed {
+			if q.spill != nil {
+				if err := q.spill.close(); err != nil {
+					return Event{}, false, err
+				}
+			}
+			return Event{}, false, nil
+		}
+		q.notEmpty.Wait()
+	}
+
+	if q.count == 0 {
+		event, err = q.spill.read()
+		return event, true, err
+	}
+
+	event = q.buffer[q.head]
+	q.buffer[q.head] = Event{}
+	q.head = (q.head + 1) % len(q.buffer)
+	q.count--
+	q.notFull.Signal()
+	return event, true, nil
+}
+
+// len returns the number of events waiting, spilled events included.
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.spill != nil {
+		return q.count + q.spill.pending
+	}
+	return q.count
+}
+
+// close stops accepting events, the queued ones are still returned by pop.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// spillFile is an append-only JSONL file of events, read back in order and truncated once drained.
+// The events left in the file by a previous run are delivered first.
+type spillFile struct {
+	path    string
+	writer  *os.File
+	reader  *os.File
+	lines   *bufio.Reader
+	pending int
+	// size is the size of the file, which is truncated only once drained, and maxSize its limit.
+	size    int64
+	maxSize int64
+}
+
+func openSpillFile(dir string, maxSize int64) (*spillFile, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("error during create spill directory: %w", err)
+	}
+	spillPath := filepath.Join(dir, spillFileName)
+
+	writer, err := os.OpenFile(spillPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error during open spill file: %w", err)
+	}
+	reader, err := os.Open(spillPath)
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("error during open spill file: %w", err)
+	}
+
+	spill := &spillFile{path: spillPath, writer: writer, reader: reader, maxSize: maxSize}
+	if info, err := writer.Stat(); err == nil {
+		spill.size = info.Size()
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		spill.pending++
+	}
+	if err := scanner.Err(); err != nil {
+		spill.close()
+		return nil, fmt.Errorf("error during read spill file: %w", err)
+	}
+	if _, err := reader.Seek(0, 0); err != nil {
+		spill.close()
+		return nil, fmt.Errorf("error during read spill file: %w", err)
+	}
+	spill.lines = bufio.NewReader(reader)
+	return spill, nil
+}
+
+func (s *spillFile) write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error during marshal spilled event: %w", err)
+	}
+	if s.size+int64(len(data))+1 > s.maxSize {
+		return errSpillFull
+	}
+	written, err := s.writer.Write(append(data, '\n'))
+	s.size += int64(written)
+	if err != nil {
+		return fmt.Errorf("error during write spilled event: %w", err)
+	}
+	s.pending++
+	return nil
+}
+
+func (s *spillFile) read() (Event, error) {
+	line, err := s.lines.ReadBytes('\n')
+	s.pending--
+	if s.pending == 0 {
+		// The file is drained, it is truncated so that it does not grow forever.
+		if truncateErr := s.writer.Truncate(0); truncateErr == nil {
+			s.size = 0
+			s.reader.Seek(0, 0)
+			s.lines.Reset(s.reader)
+		}
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("error during read spilled event: %w", err)
+	}
+
+	var event Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return Event{}, fmt.Errorf("error during unmarshal spilled event: %w", err)
+	}
+	return event, nil
+}
+
+func (s *spillFile) close() error {
+	err := s.writer.Close()
+	if readerErr := s.reader.Close(); err == nil {
+		err = readerErr
+	}
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,236 @@
+package tracer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func popIDs(t *testing.T, q *queue, n int) []string {
+	var ids []string
+	for i := 0; i < n; i++ {
+		event, ok, err := q.pop()
+		require.NoError(t, err)
+		require.True(t, ok)
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropNewest, DropOldest, Block, SpillToDisk} {
+		parsed, err := ParseOverflowPolicy(policy.String())
+		assert.NoError(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+
+	parsed, err := ParseOverflowPolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, DropNewest, parsed)
+
+	_, err = ParseOverflowPolicy("drop-all")
+	assert.Error(t, err)
+}
+
+func TestQueue_DropNewest(t *testing.T) {
+	q, err := newQueue(QueueOptions{Size: 2})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2"} {
+		result, err := q.push(Event{ID: id})
+		require.NoError(t, err)
+		assert.Equal(t, pushResult{queued: true}, result)
+	}
+	result, err := q.push(Event{ID: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{dropped: 1}, result)
+
+	assert.Equal(t, []string{"1", "2"}, popIDs(t, q, 2))
+}
+
+func TestQueue_DropOldest(t *testing.T) {
+	q, err := newQueue(QueueOptions{Size: 2, OverflowPolicy: DropOldest})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := q.push(Event{ID: id})
+		require.NoError(t, err)
+	}
+	result, err := q.push(Event{ID: "4"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{queued: true, dropped: 1}, result)
+
+	assert.Equal(t, []string{"3", "4"}, popIDs(t, q, 2))
+	assert.Equal(t, 0, q.len())
+}
+
+func TestQueue_Block(t *testing.T) {
+	q, err := newQueue(QueueOptions{Size: 1, OverflowPolicy: Block})
+	require.NoError(t, err)
+
+	_, err = q.push(Event{ID: "1"})
+	require.NoError(t, err)
+
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		q.push(Event{ID: "2"})
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("push should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, []string{"1"}, popIDs(t, q, 1))
+	<-pushed
+	assert.Equal(t, []string{"2"}, popIDs(t, q, 1))
+
+	q.close()
+	_, err = q.push(Event{ID: "3"})
+	assert.ErrorIs(t, err, errQueueClosed)
+	_, ok, _ := q.pop()
+	assert.False(t, ok)
+}
+
+func TestQueue_SpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := newQueue(QueueOptions{OverflowPolicy: SpillToDisk})
+	assert.Error(t, err)
+
+	q, err := newQueue(QueueOptions{Size: 2, OverflowPolicy: SpillToDisk, SpillDir: dir})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		_, err := q.push(Event{ID: id, Payload: []byte{0, 1}})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 4, q.len())
+
+	// Once spilling, the events go to disk even when there is room in memory, to keep the order.
+	assert.Equal(t, []string{"1"}, popIDs(t, q, 1))
+	result, err := q.push(Event{ID: "5"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{queued: true, spilled: true}, result)
+
+	event, ok, err := q.pop()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "2", event.ID)
+	assert.Equal(t, []byte{0, 1}, event.Payload)
+	assert.Equal(t, []string{"3", "4", "5"}, popIDs(t, q, 3))
+
+	info, err := os.Stat(filepath.Join(dir, spillFileName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "the drained spill file is truncated")
+
+	// The events left on disk are delivered by the next queue.
+	_, err = q.push(Event{ID: "6"})
+	require.NoError(t, err)
+	_, err = q.push(Event{ID: "7"})
+	require.NoError(t, err)
+	_, err = q.push(Event{ID: "8"})
+	require.NoError(t, err)
+	q.close()
+	q.spill.close()
+
+	resumed, err := newQueue(QueueOptions{Size: 2, OverflowPolicy: SpillToDisk, SpillDir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resumed.len())
+	assert.Equal(t, []string{"8"}, popIDs(t, resumed, 1))
+}
+
+func TestQueue_SpillToDisk_MaxSpillBytes(t *testing.T) {
+	q, err := newQueue(QueueOptions{Size: 1, OverflowPolicy: SpillToDisk, SpillDir: t.TempDir(), MaxSpillBytes: 1000})
+	require.NoError(t, err)
+
+	_, err = q.push(Event{ID: "1"})
+	require.NoError(t, err)
+	result, err := q.push(Event{ID: "2"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{queued: true, spilled: true}, result)
+
+	// The spill file is full, the event is dropped.
+	result, err = q.push(Event{ID: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{dropped: 1}, result)
+	assert.Equal(t, 2, q.len())
+
+	// Once drained, the file accepts events again.
+	assert.Equal(t, []string{"1", "2"}, popIDs(t, q, 2))
+	_, err = q.push(Event{ID: "4"})
+	require.NoError(t, err)
+	result, err = q.push(Event{ID: "5"})
+	require.NoError(t, err)
+	assert.Equal(t, pushResult{queued: true, spilled: true}, result)
+}
+
+func TestQueue_SpillToDisk_WriteError(t *testing.T) {
+	q, err := newQueue(QueueOptions{Size: 1, OverflowPolicy: SpillToDisk, SpillDir: t.TempDir()})
+	require.NoError(t, err)
+
+	_, err = q.push(Event{ID: "1"})
+	require.NoError(t, err)
+	q.spill.writer.Close()
+
+	// A failed write is reported as an error only, it is not counted as a drop as well.
+	result, err := q.push(Event{ID: "2"})
+	assert.Error(t, err)
+	assert.Equal(t, pushResult{}, result)
+	q.spill.close()
+}
+
+func TestConfigure_DoesNotBlock(t *testing.T) {
+	tracer := GetInstance(func(event Event) {})
+	defer func() {
+		require.NoError(t, tracer.Configure(QueueOptions{}))
+		tracer.SetStrategy(func(event Event) {})
+	}()
+
+	require.Error(t, tracer.Configure(QueueOptions{OverflowPolicy: SpillToDisk}))
+	require.NoError(t, tracer.Configure(QueueOptions{Size: 1, Workers: 1}))
+
+	started := make(chan struct{}, 4)
+	released := make(chan struct{})
+	var mu sync.Mutex
+	var handled []string
+	tracer.SetStrategy(func(event Event) {
+		started <- struct{}{}
+		<-released
+		mu.Lock()
+		handled = append(handled, event.ID)
+		mu.Unlock()
+	})
+
+	tracer.TraceEvent(Event{ID: "1", Protocol: SSH.String()})
+	<-started
+
+	traced := make(chan struct{})
+	go func() {
+		defer close(traced)
+		for _, id := range []string{"2", "3", "4"} {
+			tracer.TraceEvent(Event{ID: id, Protocol: SSH.String()})
+		}
+	}()
+	select {
+	case <-traced:
+	case <-time.After(time.Second):
+		t.Fatal("TraceEvent should not block while the strategy is stuck")
+	}
+
+	close(released)
+	require.NoError(t, tracer.Flush(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	// The worker holds the first event, the queue the second one, the others are dropped.
+	assert.Equal(t, []string{"1", "2"}, handled)
+}
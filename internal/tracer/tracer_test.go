@@ -1,11 +1,17 @@
 package tracer
 
 import (
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestInit(t *testing.T) {
@@ -77,6 +83,8 @@ func TestStringStatus(t *testing.T) {
 	assert.Equal(t, End.String(), "End")
 	assert.Equal(t, Stateless.String(), "Stateless")
 	assert.Equal(t, Interaction.String(), "Interaction")
+	assert.Equal(t, PortForward.String(), "PortForward")
+	assert.Equal(t, Download.String(), "Download")
 }
 
 type mockCounter struct {
@@ -104,7 +112,6 @@ func newTracerWithMockCounters(strategy Strategy) (*tracer, map[string]*mockCoun
 	}
 	tr := &tracer{
 		strategy:          strategy,
-		eventsChan:        make(chan Event, Workers),
 		eventsTotal:       counters["total"],
 		eventsSSHTotal:    counters["ssh"],
 		eventsTCPTotal:    counters["tcp"],
@@ -158,6 +165,29 @@ func TestUpdatePrometheusCounters_UnknownProtocol(t *testing.T) {
 	assert.Equal(t, 0, counters["telnet"].count)
 }
 
+func TestUpdateOpenTelemetryCounters(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	otelEvents, err := provider.Meter("test").Int64Counter("beelzebub.events")
+	require.NoError(t, err)
+	tr := &tracer{otelEvents: otelEvents}
+
+	tr.updateOpenTelemetryCounters(SSH.String())
+	tr.updateOpenTelemetryCounters(SSH.String())
+	tr.updateOpenTelemetryCounters(HTTP.String())
+
+	var metrics metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &metrics))
+	require.Len(t, metrics.ScopeMetrics, 1)
+	sum := metrics.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	counts := map[string]int64{}
+	for _, point := range sum.DataPoints {
+		protocol, _ := point.Attributes.Value(attribute.Key("protocol"))
+		counts[protocol.AsString()] = point.Value
+	}
+	assert.Equal(t, map[string]int64{"SSH": 2, "HTTP": 1}, counts)
+}
+
 func TestGetStrategy(t *testing.T) {
 	mockStrategy := func(event Event) {}
 
@@ -225,3 +255,56 @@ func TestSetGetStrategyConcurrency(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestFlush(t *testing.T) {
+	tracer := GetInstance(func(event Event) {})
+
+	released := make(chan struct{})
+	tracer.SetStrategy(func(event Event) {
+		<-released
+	})
+	defer tracer.SetStrategy(func(event Event) {})
+
+	tracer.TraceEvent(Event{ID: "mockID", Protocol: HTTP.String()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tracer.Flush(ctx), context.DeadlineExceeded)
+
+	close(released)
+	assert.NoError(t, tracer.Flush(context.Background()))
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	tracer := GetInstance(func(event Event) {})
+	tr, ok := FromContext(NewContext(context.Background(), tracer))
+	assert.True(t, ok)
+	assert.Equal(t, tracer, tr)
+}
+
+func TestRegister(t *testing.T) {
+	tr := GetInstance(func(event Event) {})
+	registry := prometheus.NewRegistry()
+
+	require.NoError(t, tr.Register(registry))
+	assert.NoError(t, tr.Register(registry), "registering twice on the same registry is not an error")
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "beelzebub_events_total")
+	assert.Contains(t, names, "beelzebub_events_queue_length")
+
+	// Every registry is independent, e.g. one per test.
+	assert.NoError(t, tr.Register(prometheus.NewRegistry()))
+
+	conflicting := prometheus.NewRegistry()
+	conflicting.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "beelzebub", Name: "events_total"}))
+	assert.Error(t, tr.Register(conflicting))
+}
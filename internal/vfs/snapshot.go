@@ -0,0 +1,122 @@
+package vfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSnapshot returns a filesystem seeded from a directory or a tarball, optionally gzip compressed (.tar.gz, .tgz).
+// Only directories and regular files are loaded, together with their permission bits and modification times.
+func LoadSnapshot(snapshotPath string) (*FS, error) {
+	info, err := os.Stat(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+	}
+	if info.IsDir() {
+		return loadDir(snapshotPath)
+	}
+
+	file, err := os.Open(snapshotPath)
+	if err != nil {
+		return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(snapshotPath, ".gz") || strings.HasSuffix(snapshotPath, ".tgz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+	return LoadTar(reader)
+}
+
+// LoadTar returns a filesystem seeded from a tar stream.
+func LoadTar(reader io.Reader) (*FS, error) {
+	fsys := New()
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+		}
+
+		name := Clean(header.Name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.MkdirAll(name, header.FileInfo().Mode()); err != nil {
+				return nil, err
+			}
+		case tar.TypeReg:
+			data, err := io.ReadAll(tarReader)
+			if err != nil {
+				return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+			}
+			if err := fsys.MkdirAll(path.Dir(name), 0755); err != nil {
+				return nil, err
+			}
+			if err := fsys.WriteFile(name, data, header.FileInfo().Mode()); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		if err := fsys.Chtimes(name, header.ModTime); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func loadDir(root string) (*FS, error) {
+	fsys := New()
+	err := filepath.WalkDir(root, func(hostPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(root, hostPath)
+		if err != nil {
+			return err
+		}
+		name := Clean(filepath.ToSlash(relativePath))
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			if err := fsys.MkdirAll(name, info.Mode()); err != nil {
+				return err
+			}
+		case entry.Type().IsRegular():
+			data, err := os.ReadFile(hostPath)
+			if err != nil {
+				return err
+			}
+			if err := fsys.WriteFile(name, data, info.Mode()); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		return fsys.Chtimes(name, info.ModTime())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error during load filesystem snapshot: %w", err)
+	}
+	return fsys, nil
+}
@@ -0,0 +1,71 @@
+package vfs
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSnapshot_Tarball(t *testing.T) {
+	modTime := time.Date(2023, 11, 14, 13, 30, 0, 0, time.UTC)
+	snapshotPath := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+
+	file, err := os.Create(snapshotPath)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "./etc/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime}))
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "./etc/hostname", Typeflag: tar.TypeReg, Mode: 0644, Size: 7, ModTime: modTime}))
+	_, err = tarWriter.Write([]byte("ubuntu\n"))
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "./bin/sh", Typeflag: tar.TypeSymlink, Linkname: "dash"}))
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, file.Close())
+
+	fsys, err := LoadSnapshot(snapshotPath)
+	require.NoError(t, err)
+
+	data, err := fsys.ReadFile("/etc/hostname")
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu\n", string(data))
+
+	info, err := fsys.Stat("/etc/hostname")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0644), info.Mode())
+	assert.True(t, modTime.Equal(info.ModTime()))
+
+	// Symbolic links are skipped.
+	_, err = fsys.Stat("/bin/sh")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLoadSnapshot_Directory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "root", ".ssh"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "root", ".ssh", "id_rsa"), []byte("key"), 0600))
+
+	fsys, err := LoadSnapshot(root)
+	require.NoError(t, err)
+
+	data, err := fsys.ReadFile("/root/.ssh/id_rsa")
+	require.NoError(t, err)
+	assert.Equal(t, "key", string(data))
+
+	info, err := fsys.Stat("/root/.ssh")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, fs.FileMode(0700), info.Mode().Perm())
+}
+
+func TestLoadSnapshot_Missing(t *testing.T) {
+	_, err := LoadSnapshot(filepath.Join(t.TempDir(), "missing.tar"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,456 @@
+// Package vfs is an in-memory virtual filesystem, used by the deception services to emulate the filesystem of a host
+// without ever touching the real one.
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotDir   = errors.New("not a directory")
+	ErrIsDir    = errors.New("is a directory")
+	ErrNotEmpty = errors.New("directory not empty")
+	ErrNoSpace  = errors.New("no space left on device")
+)
+
+// modeMask are the bits of a mode kept by the filesystem, the permissions together with setuid, setgid and sticky.
+const modeMask = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// FS is a tree of files and directories held in memory, safe for concurrent use.
+// Paths are slash-separated and resolved from the root, e.g. "tmp/x" is "/tmp/x".
+type FS struct {
+	mu   sync.RWMutex
+	root *node
+	// size is the bytes of the file contents, the ones beyond baseline are accounted by quota.
+	size     int64
+	baseline int64
+	quota    *Quota
+}
+
+type node struct {
+	name     string
+	mode     fs.FileMode
+	modTime  time.Time
+	data     []byte
+	children map[string]*node
+}
+
+// New returns an empty filesystem, with the root directory only.
+func New() *FS {
+	return &FS{root: newDirNode("/", 0755)}
+}
+
+func newDirNode(name string, perm fs.FileMode) *node {
+	return &node{name: name, mode: fs.ModeDir | perm&modeMask, modTime: time.Now(), children: make(map[string]*node)}
+}
+
+func (n *node) isDir() bool {
+	return n.mode.IsDir()
+}
+
+func (n *node) clone() *node {
+	cloned := &node{name: n.name, mode: n.mode, modTime: n.modTime, data: n.data}
+	if n.children != nil {
+		cloned.children = make(map[string]*node, len(n.children))
+		for name, child := range n.children {
+			cloned.children[name] = child.clone()
+		}
+	}
+	return cloned
+}
+
+// size returns the bytes of the file contents of the node, together with its children.
+func (n *node) size() int64 {
+	size := int64(len(n.data))
+	for _, child := range n.children {
+		size += child.size()
+	}
+	return size
+}
+
+// Quota bounds the bytes of the file contents written to the filesystems sharing it, a reservation counts against
+// the parent quota too. Quota is safe for concurrent use.
+type Quota struct {
+	mu     sync.Mutex
+	used   int64
+	limit  int64
+	parent *Quota
+}
+
+// NewQuota returns a quota of limit bytes, nested in parent when it is not nil.
+func NewQuota(limit int64, parent *Quota) *Quota {
+	return &Quota{limit: limit, parent: parent}
+}
+
+// reserve accounts n more bytes, or returns ErrNoSpace when they would exceed a limit.
+func (q *Quota) reserve(n int64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.used+n > q.limit {
+		return ErrNoSpace
+	}
+	if err := q.parent.reserve(n); err != nil {
+		return err
+	}
+	q.used += n
+	return nil
+}
+
+// release gives back n bytes.
+func (q *Quota) release(n int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.used -= n
+	q.parent.release(n)
+}
+
+// Used returns the bytes accounted by the quota.
+func (q *Quota) Used() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.used
+}
+
+// ReleaseAll gives back every byte accounted by the quota, e.g. once the filesystem using it is dropped.
+func (q *Quota) ReleaseAll() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.parent.release(q.used)
+	q.used = 0
+}
+
+// Clean returns the absolute, cleaned form of name.
+func Clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+// Clone returns a deep copy of the filesystem, e.g. to give every session its own copy of a seeded filesystem.
+// File contents are shared, since they are never modified in place.
+func (f *FS) Clone() *FS {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return &FS{root: f.root.clone(), size: f.size}
+}
+
+// SetQuota bounds the growth of the file contents beyond their current size, a write exceeding the quota fails with
+// ErrNoSpace.
+func (f *FS) SetQuota(quota *Quota) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.quota = quota
+	f.baseline = f.size
+}
+
+// resize accounts the growth of the file contents, the caller must hold the lock.
+func (f *FS) resize(growth int64) error {
+	accounted := func(size int64) int64 { return max(0, size-f.baseline) }
+	delta := accounted(f.size+growth) - accounted(f.size)
+	if delta > 0 {
+		if err := f.quota.reserve(delta); err != nil {
+			return err
+		}
+	} else {
+		f.quota.release(-delta)
+	}
+	f.size += growth
+	return nil
+}
+
+// lookup returns the node of the cleaned path, the caller must hold the lock.
+func (f *FS) lookup(op, name string) (*node, error) {
+	current := f.root
+	for _, element := range strings.Split(strings.TrimPrefix(name, "/"), "/") {
+		if element == "" {
+			continue
+		}
+		if !current.isDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: ErrNotDir}
+		}
+		child, ok := current.children[element]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		current = child
+	}
+	return current, nil
+}
+
+// lookupParent returns the directory containing the cleaned path, the caller must hold the lock.
+func (f *FS) lookupParent(op, name string) (*node, error) {
+	if name == "/" {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	parent, err := f.lookup(op, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	if !parent.isDir() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: ErrNotDir}
+	}
+	return parent, nil
+}
+
+// Stat returns the FileInfo of the named file.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	name = Clean(name)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	n, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(n), nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.FileInfo, error) {
+	name = Clean(name)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	n, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDir}
+	}
+	entries := make([]fs.FileInfo, 0, len(n.children))
+	for _, child := range n.children {
+		entries = append(entries, newFileInfo(child))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// ReadFile returns the content of the named file.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	name = Clean(name)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	n, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.isDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: ErrIsDir}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// WriteFile writes data to the named file, creating it with perm if needed; the parent directory must exist.
+func (f *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, err := f.lookupParent("open", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	existing, ok := parent.children[base]
+	if ok && existing.isDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: ErrIsDir}
+	}
+	growth := int64(len(data))
+	if ok {
+		growth -= int64(len(existing.data))
+	}
+	if err := f.resize(growth); err != nil {
+		return &fs.PathError{Op: "write", Path: name, Err: err}
+	}
+	if ok {
+		existing.data = append([]byte(nil), data...)
+		existing.modTime = time.Now()
+		return nil
+	}
+	parent.children[base] = &node{name: base, mode: perm & modeMask, modTime: time.Now(), data: append([]byte(nil), data...)}
+	return nil
+}
+
+// Mkdir creates the named directory, the parent directory must exist.
+func (f *FS) Mkdir(name string, perm fs.FileMode) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, err := f.lookupParent("mkdir", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	if _, ok := parent.children[base]; ok {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parent.children[base] = newDirNode(base, perm)
+	return nil
+}
+
+// MkdirAll creates the named directory together with any missing parent.
+func (f *FS) MkdirAll(name string, perm fs.FileMode) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current := f.root
+	for _, element := range strings.Split(strings.TrimPrefix(name, "/"), "/") {
+		if element == "" {
+			continue
+		}
+		child, ok := current.children[element]
+		if !ok {
+			child = newDirNode(element, perm)
+			current.children[element] = child
+		}
+		if !child.isDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: ErrNotDir}
+		}
+		current = child
+	}
+	return nil
+}
+
+// Remove removes the named file or empty directory.
+func (f *FS) Remove(name string) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, err := f.lookupParent("remove", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	n, ok := parent.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if n.isDir() && len(n.children) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: ErrNotEmpty}
+	}
+	delete(parent.children, base)
+	f.resize(-n.size())
+	return nil
+}
+
+// RemoveAll removes the named file or directory together with its content.
+func (f *FS) RemoveAll(name string) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parent, err := f.lookupParent("remove", name)
+	if err != nil {
+		return err
+	}
+	base := path.Base(name)
+	n, ok := parent.children[base]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(parent.children, base)
+	f.resize(-n.size())
+	return nil
+}
+
+// Rename moves oldname to newname, replacing newname when it is an existing file.
+func (f *FS) Rename(oldname, newname string) error {
+	oldname, newname = Clean(oldname), Clean(newname)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	oldParent, err := f.lookupParent("rename", oldname)
+	if err != nil {
+		return err
+	}
+	n, ok := oldParent.children[path.Base(oldname)]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if newname == oldname {
+		return nil
+	}
+	if n.isDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	newParent, err := f.lookupParent("rename", newname)
+	if err != nil {
+		return err
+	}
+	base := path.Base(newname)
+	existing, replaced := newParent.children[base]
+	if replaced && existing.isDir() {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	if replaced {
+		f.resize(-existing.size())
+	}
+	delete(oldParent.children, path.Base(oldname))
+	n.name = base
+	newParent.children[base] = n
+	return nil
+}
+
+// Chmod changes the permission bits of the named file, together with setuid, setgid and sticky.
+func (f *FS) Chmod(name string, perm fs.FileMode) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode.Type() | perm&modeMask
+	return nil
+}
+
+// Chtimes changes the modification time of the named file.
+func (f *FS) Chtimes(name string, modTime time.Time) error {
+	name = Clean(name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, err := f.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	n.modTime = modTime
+	return nil
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(n *node) fileInfo {
+	return fileInfo{name: n.name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() any           { return nil }
@@ -0,0 +1,139 @@
+package vfs
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndReadFile(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.MkdirAll("/root/.ssh", 0700))
+	require.NoError(t, fsys.WriteFile("root/.ssh/authorized_keys", []byte("ssh-ed25519 AAAA"), 0600))
+
+	data, err := fsys.ReadFile("/root/../root/.ssh/authorized_keys")
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519 AAAA", string(data))
+
+	info, err := fsys.Stat("/root/.ssh/authorized_keys")
+	require.NoError(t, err)
+	assert.Equal(t, "authorized_keys", info.Name())
+	assert.Equal(t, int64(16), info.Size())
+	assert.Equal(t, fs.FileMode(0600), info.Mode())
+	assert.False(t, info.IsDir())
+
+	info, err = fsys.Stat("/root/.ssh")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestErrors(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.WriteFile("/file", []byte("x"), 0644))
+	require.NoError(t, fsys.MkdirAll("/dir/sub", 0755))
+
+	_, err := fsys.ReadFile("/missing")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fsys.ReadFile("/dir")
+	assert.ErrorIs(t, err, ErrIsDir)
+
+	_, err = fsys.ReadDir("/file")
+	assert.ErrorIs(t, err, ErrNotDir)
+
+	assert.ErrorIs(t, fsys.WriteFile("/missing/file", nil, 0644), fs.ErrNotExist)
+	assert.ErrorIs(t, fsys.WriteFile("/file/child", nil, 0644), ErrNotDir)
+	assert.ErrorIs(t, fsys.Mkdir("/dir", 0755), fs.ErrExist)
+	assert.ErrorIs(t, fsys.Remove("/dir"), ErrNotEmpty)
+	assert.ErrorIs(t, fsys.Rename("/dir", "/dir/sub/dir"), fs.ErrInvalid)
+}
+
+func TestReadDir_Sorted(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.WriteFile("/b", nil, 0644))
+	require.NoError(t, fsys.Mkdir("/a", 0755))
+	require.NoError(t, fsys.WriteFile("/c", nil, 0644))
+
+	entries, err := fsys.ReadDir("/")
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func TestRemoveAndRename(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.MkdirAll("/tmp/dir", 0755))
+	require.NoError(t, fsys.WriteFile("/tmp/dir/payload", []byte("x"), 0755))
+
+	require.NoError(t, fsys.Rename("/tmp/dir", "/tmp/moved"))
+	_, err := fsys.Stat("/tmp/dir")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	data, err := fsys.ReadFile("/tmp/moved/payload")
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(data))
+
+	require.NoError(t, fsys.RemoveAll("/tmp/moved"))
+	_, err = fsys.Stat("/tmp/moved/payload")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestClone_IsIndependent(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.WriteFile("/etc", []byte("original"), 0644))
+
+	cloned := fsys.Clone()
+	require.NoError(t, cloned.WriteFile("/etc", []byte("modified"), 0644))
+	require.NoError(t, cloned.Chmod("/etc", 0600))
+
+	data, err := fsys.ReadFile("/etc")
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+	info, err := fsys.Stat("/etc")
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0644), info.Mode())
+}
+
+func TestQuota(t *testing.T) {
+	fsys := New()
+	require.NoError(t, fsys.WriteFile("/seeded", []byte("seeded"), 0644))
+
+	global := NewQuota(12, nil)
+	quota := NewQuota(8, global)
+	fsys.SetQuota(quota)
+
+	require.NoError(t, fsys.WriteFile("/a", []byte("12345"), 0644))
+	err := fsys.WriteFile("/b", []byte("6789"), 0644)
+	assert.ErrorIs(t, err, ErrNoSpace)
+	_, err = fsys.Stat("/b")
+	assert.ErrorIs(t, err, fs.ErrNotExist, "a write over quota leaves the filesystem unchanged")
+
+	// Overwriting and removing give the bytes back.
+	require.NoError(t, fsys.WriteFile("/a", []byte("1"), 0644))
+	require.NoError(t, fsys.WriteFile("/b", []byte("6789"), 0644))
+	assert.Equal(t, int64(5), quota.Used())
+	require.NoError(t, fsys.Remove("/b"))
+	assert.Equal(t, int64(1), quota.Used())
+
+	// The seeded files are not accounted, removing them makes room too.
+	require.NoError(t, fsys.Remove("/seeded"))
+	assert.Zero(t, quota.Used())
+	require.NoError(t, fsys.WriteFile("/b", []byte("6789"), 0644))
+	assert.Zero(t, quota.Used())
+	require.NoError(t, fsys.WriteFile("/c", []byte("abcd"), 0644))
+	assert.Equal(t, int64(3), quota.Used())
+	assert.Equal(t, int64(3), global.Used())
+
+	// The parent quota is shared with the other filesystems.
+	other := NewQuota(12, global)
+	otherFS := New()
+	otherFS.SetQuota(other)
+	assert.ErrorIs(t, otherFS.WriteFile("/d", make([]byte, 10), 0644), ErrNoSpace)
+	quota.ReleaseAll()
+	assert.Zero(t, global.Used())
+	assert.NoError(t, otherFS.WriteFile("/d", make([]byte, 10), 0644))
+}
@@ -33,6 +33,14 @@ type CommandPlugin interface {
 	Execute(ctx context.Context, req CommandRequest) (string, error)
 }
 
+// SessionEndPlugin is a CommandPlugin keeping state per session, e.g. a
+// virtual filesystem. EndSession is called once the session identified by
+// CommandRequest.SessionID is over, so that its state can be released.
+type SessionEndPlugin interface {
+	CommandPlugin
+	EndSession(sessionID string)
+}
+
 // HTTPPlugin generates full HTTP responses (status code, headers, body).
 // Use this for plugins that need fine-grained control over the HTTP layer,
 // such as directory-listing generators or custom web honeypots.
@@ -47,8 +55,17 @@ type CommandRequest struct {
 	Command string
 	// ClientIP is the remote IP address of the attacker.
 	ClientIP string
+	// RemoteAddr is the remote address of the attacker, e.g. "198.51.100.1:53124", and SourcePort its port.
+	RemoteAddr string
+	SourcePort string
+	// Description is the description of the service which received the command.
+	Description string
 	// Protocol is the honeypot protocol ("http", "ssh", "tcp", "telnet").
 	Protocol string
+	// SessionID identifies the attacker session, stateful plugins use it to keep their state across commands.
+	SessionID string
+	// User is the username the attacker logged in with, empty when the protocol has none.
+	User string
 	// History is the conversation so far (for stateful/LLM plugins).
 	History []Message
 	// Config holds plugin-specific settings from the service YAML.
@@ -86,4 +103,6 @@ type Config struct {
 	RateLimitWindowSeconds  int
 	ServerVersion           string
 	ServerName              string
+	// FilesystemSnapshot is the directory or tarball seeding the virtual filesystem of the shell plugin.
+	FilesystemSnapshot string
 }